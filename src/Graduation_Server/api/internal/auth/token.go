@@ -0,0 +1,159 @@
+// Package auth はJWTの発行・検証をまとめたトークンサービス。
+// ログイン・アカウント作成・メインデータ取得・JWTミドルウェアはすべてここを通す。
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// JWTに含めるクレーム（発行するトークンはすべてこの形）
+// user_id: users.id
+// name:    発行時点の表示名
+// ver:     users.token_version（「全端末からログアウト」で上がると古いトークンは無効）
+// role:    users.role（"admin" なら運営APIを呼べる。変更時は token_version も上げる）
+// jti:     RegisteredClaims.ID（ログアウト時にこのトークン単体を失効させるのに使う）
+type Claims struct {
+	UserID       int64  `json:"user_id"`
+	Username     string `json:"name"`
+	TokenVersion int    `json:"ver"`
+	Role         string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+var (
+	ErrNoKeys        = errors.New("auth: no signing keys configured")
+	ErrUnknownKeyID  = errors.New("auth: unknown key id")
+	ErrInvalidToken  = errors.New("auth: invalid token")
+	ErrMissingUserID = errors.New("auth: missing user_id in token")
+)
+
+// Issuer はトークンの発行と検証を行う。
+// keys に入っている鍵はすべて検証に使い、発行には activeKID の鍵だけを使う。
+// 鍵をローテーションするときは新しい鍵を追加して activeKID を切り替え、
+// 古い鍵は発行済みトークンが切れるまで keys に残しておく。
+type Issuer struct {
+	keys      map[string][]byte // kid -> HMAC鍵
+	activeKID string
+	ttl       time.Duration
+}
+
+// NewIssuer は鍵一覧・発行用の kid・アクセストークンの有効期間から Issuer を作る。
+func NewIssuer(keys map[string][]byte, activeKID string, ttl time.Duration) (*Issuer, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	if _, ok := keys[activeKID]; !ok {
+		return nil, fmt.Errorf("%w: active kid %q", ErrUnknownKeyID, activeKID)
+	}
+	copied := make(map[string][]byte, len(keys))
+	for kid, key := range keys {
+		if len(key) == 0 {
+			return nil, fmt.Errorf("auth: empty key for kid %q", kid)
+		}
+		copied[kid] = key
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("auth: ttl must be positive")
+	}
+	return &Issuer{keys: copied, activeKID: activeKID, ttl: ttl}, nil
+}
+
+// 発行用の kid
+func (i *Issuer) ActiveKeyID() string {
+	return i.activeKID
+}
+
+// アクセストークンの有効期間（切れたらリフレッシュトークンで取り直す）
+func (i *Issuer) TTL() time.Duration {
+	return i.ttl
+}
+
+// Issue はアクセストークンを発行し、署名済み文字列・jti・有効期限を返す。
+func (i *Issuer) Issue(userID int64, name string, version int, role string) (token, jti string, expiresAt time.Time, err error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", time.Time{}, err
+	}
+	jti = hex.EncodeToString(b)
+
+	now := time.Now()
+	expiresAt = now.Add(i.ttl)
+	claims := &Claims{
+		UserID:       userID,
+		Username:     name,
+		TokenVersion: version,
+		Role:         role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	t.Header["kid"] = i.activeKID
+	token, err = t.SignedString(i.keys[i.activeKID])
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	return token, jti, expiresAt, nil
+}
+
+// Parse は署名と有効期限を検証してクレームを返す。
+// kid ヘッダーがあればその鍵で、無い（kid 導入前の）トークンは登録済みの鍵を順に試す。
+func (i *Issuer) Parse(tokenStr string) (*Claims, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{
+		jwt.SigningMethodHS256.Alg(),
+		jwt.SigningMethodHS384.Alg(),
+		jwt.SigningMethodHS512.Alg(),
+	}))
+
+	var candidates [][]byte
+	unverified, _, err := parser.ParseUnverified(tokenStr, &Claims{})
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if kid, ok := unverified.Header["kid"].(string); ok {
+		key, found := i.keys[kid]
+		if !found {
+			return nil, ErrUnknownKeyID
+		}
+		candidates = [][]byte{key}
+	} else {
+		for _, key := range i.keys {
+			candidates = append(candidates, key)
+		}
+	}
+
+	for _, key := range candidates {
+		claims := &Claims{}
+		token, err := parser.ParseWithClaims(tokenStr, claims, func(*jwt.Token) (interface{}, error) {
+			return key, nil
+		})
+		if err != nil || !token.Valid {
+			continue
+		}
+		if claims.UserID == 0 {
+			return nil, ErrMissingUserID
+		}
+		return claims, nil
+	}
+	return nil, ErrInvalidToken
+}
+
+// アプリ全体で使う Issuer（main で Init する）
+var defaultIssuer *Issuer
+
+func Init(i *Issuer) {
+	defaultIssuer = i
+}
+
+// Init 済みの Issuer を返す
+func Default() *Issuer {
+	return defaultIssuer
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	oldKey = []byte("old-signing-key")
+	newKey = []byte("new-signing-key")
+
+	errAny = errors.New("any error") // 期待するエラーの種類を問わない
+)
+
+func mustIssuer(t *testing.T, keys map[string][]byte, activeKID string) *Issuer {
+	t.Helper()
+	i, err := NewIssuer(keys, activeKID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return i
+}
+
+// sign は kid を指定して（空なら kid ヘッダー無しで）トークンを作る
+func sign(t *testing.T, key []byte, kid string, claims *Claims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	s, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func claimsFor(userID int64, expiresAt time.Time) *Claims {
+	return &Claims{UserID: userID, Username: "taro", RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(expiresAt)}}
+}
+
+func kidOf(t *testing.T, token string) any {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Header["kid"]
+}
+
+func TestNewIssuer(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		keys      map[string][]byte
+		activeKID string
+		ttl       time.Duration
+		wantErr   error // nil なら成功、errAny なら種類を問わない失敗
+	}{
+		{"ok", map[string][]byte{"k1": oldKey}, "k1", time.Hour, nil},
+		{"no keys", nil, "k1", time.Hour, ErrNoKeys},
+		{"unknown active kid", map[string][]byte{"k1": oldKey}, "k2", time.Hour, ErrUnknownKeyID},
+		{"empty key", map[string][]byte{"k1": oldKey, "k2": {}}, "k1", time.Hour, errAny},
+		{"zero ttl", map[string][]byte{"k1": oldKey}, "k1", 0, errAny},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewIssuer(tt.keys, tt.activeKID, tt.ttl)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("err = %v", err)
+			case tt.wantErr == errAny && err == nil:
+				t.Fatal("err = nil, want an error")
+			case tt.wantErr != nil && tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// 発行は activeKID の鍵で署名し、検証は kid ヘッダーの鍵だけを使う。kid の無いトークンは登録済みの鍵を全部試す
+func TestIssuerParse(t *testing.T) {
+	i := mustIssuer(t, map[string][]byte{"k1": oldKey, "k2": newKey}, "k2")
+	later := time.Now().Add(time.Hour)
+	issued, _, _, err := i.Issue(7, "taro", 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		token   string
+		wantID  int64
+		wantErr error
+	}{
+		{"issued with the active kid", issued, 7, nil},
+		{"kid selects its own key", sign(t, oldKey, "k1", claimsFor(8, later)), 8, nil},
+		{"kid pointing at another key", sign(t, oldKey, "k2", claimsFor(8, later)), 0, ErrInvalidToken},
+		{"unknown kid", sign(t, oldKey, "k9", claimsFor(8, later)), 0, ErrUnknownKeyID},
+		{"no kid, old key", sign(t, oldKey, "", claimsFor(9, later)), 9, nil},
+		{"no kid, new key", sign(t, newKey, "", claimsFor(10, later)), 10, nil},
+		{"no kid, unregistered key", sign(t, []byte("stranger"), "", claimsFor(11, later)), 0, ErrInvalidToken},
+		{"expired", sign(t, newKey, "k2", claimsFor(12, time.Now().Add(-time.Minute))), 0, ErrInvalidToken},
+		{"missing user_id", sign(t, newKey, "k2", claimsFor(0, later)), 0, ErrMissingUserID},
+		{"malformed", "not-a-jwt", 0, ErrInvalidToken},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := i.Parse(tt.token)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.UserID != tt.wantID {
+				t.Fatalf("user_id = %d, want %d", claims.UserID, tt.wantID)
+			}
+		})
+	}
+	if kid := kidOf(t, issued); kid != "k2" {
+		t.Fatalf("issued kid = %v, want k2", kid)
+	}
+}
+
+// ローテーション: 新しい鍵を足して activeKID を切り替えても古いトークンは通り、新しいトークンは新しい kid になる
+func TestIssuerRotation(t *testing.T) {
+	before := mustIssuer(t, map[string][]byte{"k1": oldKey}, "k1")
+	oldToken, _, _, err := before.Issue(1, "taro", 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := mustIssuer(t, map[string][]byte{"k1": oldKey, "k2": newKey}, "k2")
+	if claims, err := after.Parse(oldToken); err != nil || claims.UserID != 1 {
+		t.Fatalf("old token after rotation = %+v, %v", claims, err)
+	}
+	newToken, _, _, err := after.Issue(1, "taro", 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := kidOf(t, newToken); kid != "k2" {
+		t.Fatalf("new token kid = %v, want k2", kid)
+	}
+	// 切り替え前の Issuer は新しい kid を知らない
+	if _, err := before.Parse(newToken); !errors.Is(err, ErrUnknownKeyID) {
+		t.Fatalf("new token on the old issuer: err = %v", err)
+	}
+
+	// 古い鍵を外すと古いトークンは通らない
+	retired := mustIssuer(t, map[string][]byte{"k2": newKey}, "k2")
+	if _, err := retired.Parse(oldToken); !errors.Is(err, ErrUnknownKeyID) {
+		t.Fatalf("old token after retiring k1: err = %v", err)
+	}
+	if _, err := retired.Parse(newToken); err != nil {
+		t.Fatal(err)
+	}
+}
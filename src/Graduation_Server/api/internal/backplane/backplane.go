@@ -0,0 +1,33 @@
+// Package backplane はサーバーを複数台並べたときの、インスタンス間のメッセージ配送と
+// ルームの担当（オーナー）決めを行う。
+//
+// Local は1プロセス内で完結する実装（1台構成・テスト用）、
+// Redis は Redis プロトコル（RESP）で話す実装（複数台構成用）。
+//
+// 配送は Redis の pub/sub と同じく「その時点で購読している相手にだけ届く」。
+// 切断中に送られたメッセージは届かないので、受け取る側は最新状態を読み直せる形にしておく。
+package backplane
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrClosed = errors.New("backplane: closed")
+
+type Backplane interface {
+	// Publish は channel の購読者全員（他インスタンスを含む）へ payload を送る
+	Publish(ctx context.Context, channel string, payload []byte) error
+	// Subscribe は channel を購読する。購読が有効になってから戻る。
+	// handler は1つのゴルーチンから受信順に呼ばれる（中で Publish してもよい）。
+	Subscribe(ctx context.Context, channel string, handler func(payload []byte)) error
+	// Acquire は key の担当を owner として ttl の間確保する。
+	// 空いているか、既に owner が持っていれば true（期限を延ばす）。
+	Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	// Owner は key の現在の担当（いなければ ""）
+	Owner(ctx context.Context, key string) (string, error)
+	// Release は owner が持っている場合だけ key の担当を手放す
+	Release(ctx context.Context, key, owner string) error
+	Close() error
+}
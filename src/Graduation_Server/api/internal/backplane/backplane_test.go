@@ -0,0 +1,220 @@
+package backplane
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// 実装ごとに「別インスタンスから見た2つの Backplane」を用意して同じテストを流す
+var implementations = map[string]func(t *testing.T) (a, b Backplane){
+	"local": func(t *testing.T) (Backplane, Backplane) {
+		l := NewLocal()
+		t.Cleanup(func() { l.Close() })
+		return l, l
+	},
+	"redis": func(t *testing.T) (Backplane, Backplane) {
+		srv := startFakeRedis(t, "")
+		a, b := NewRedis(srv.Addr(), ""), NewRedis(srv.Addr(), "")
+		t.Cleanup(func() { a.Close(); b.Close() })
+		return a, b
+	},
+}
+
+func receive(t *testing.T, ch <-chan string) string {
+	t.Helper()
+	select {
+	case s := <-ch:
+		return s
+	case <-time.After(3 * time.Second):
+		t.Fatal("message not delivered")
+		return ""
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	for name, newPair := range implementations {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			a, b := newPair(t)
+
+			got := make(chan string, 200)
+			if err := b.Subscribe(ctx, "events", func(p []byte) { got <- string(p) }); err != nil {
+				t.Fatal(err)
+			}
+			other := make(chan string, 1)
+			if err := b.Subscribe(ctx, "other", func(p []byte) { other <- string(p) }); err != nil {
+				t.Fatal(err)
+			}
+
+			// 送った順に届く
+			for i := 0; i < 100; i++ {
+				if err := a.Publish(ctx, "events", []byte(fmt.Sprint(i))); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i := 0; i < 100; i++ {
+				if s := receive(t, got); s != fmt.Sprint(i) {
+					t.Fatalf("message %d = %q", i, s)
+				}
+			}
+			select {
+			case s := <-other:
+				t.Fatalf("unexpected message on other channel: %q", s)
+			default:
+			}
+
+			// handler の中から Publish しても詰まらない
+			echo := make(chan string, 1)
+			if err := a.Subscribe(ctx, "echo", func(p []byte) { echo <- string(p) }); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.Subscribe(ctx, "ping", func(p []byte) { _ = b.Publish(ctx, "echo", p) }); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.Publish(ctx, "ping", []byte("hello")); err != nil {
+				t.Fatal(err)
+			}
+			if s := receive(t, echo); s != "hello" {
+				t.Fatalf("echo = %q", s)
+			}
+		})
+	}
+}
+
+func TestAcquireOwnership(t *testing.T) {
+	for name, newPair := range implementations {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			a, b := newPair(t)
+
+			must := func(ok bool, err error) bool {
+				t.Helper()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return ok
+			}
+			if !must(a.Acquire(ctx, "room:1", "A", time.Minute)) {
+				t.Fatal("A could not acquire a free key")
+			}
+			if must(b.Acquire(ctx, "room:1", "B", time.Minute)) {
+				t.Fatal("B acquired a key owned by A")
+			}
+			if !must(a.Acquire(ctx, "room:1", "A", time.Minute)) {
+				t.Fatal("A could not renew its own key")
+			}
+			if owner, err := b.Owner(ctx, "room:1"); err != nil || owner != "A" {
+				t.Fatalf("Owner = %q, %v", owner, err)
+			}
+
+			// 持っていない側の Release は無視される
+			if err := b.Release(ctx, "room:1", "B"); err != nil {
+				t.Fatal(err)
+			}
+			if owner, _ := b.Owner(ctx, "room:1"); owner != "A" {
+				t.Fatalf("owner after foreign release = %q", owner)
+			}
+			if err := a.Release(ctx, "room:1", "A"); err != nil {
+				t.Fatal(err)
+			}
+			if !must(b.Acquire(ctx, "room:1", "B", 50*time.Millisecond)) {
+				t.Fatal("B could not acquire a released key")
+			}
+
+			// 期限が切れたら他が取れる
+			time.Sleep(100 * time.Millisecond)
+			if owner, _ := a.Owner(ctx, "room:1"); owner != "" {
+				t.Fatalf("owner after expiry = %q", owner)
+			}
+			if !must(a.Acquire(ctx, "room:1", "A", time.Minute)) {
+				t.Fatal("A could not acquire an expired key")
+			}
+
+			// 期限切れの後に他が取った担当は、前の持ち主の延長・Release で奪えない
+			if !must(b.Acquire(ctx, "room:2", "B", 50*time.Millisecond)) {
+				t.Fatal("B could not acquire room:2")
+			}
+			time.Sleep(100 * time.Millisecond)
+			if !must(a.Acquire(ctx, "room:2", "A", time.Minute)) {
+				t.Fatal("A could not acquire an expired room:2")
+			}
+			if must(b.Acquire(ctx, "room:2", "B", time.Minute)) {
+				t.Fatal("B renewed a key A took over")
+			}
+			if err := b.Release(ctx, "room:2", "B"); err != nil {
+				t.Fatal(err)
+			}
+			if owner, _ := a.Owner(ctx, "room:2"); owner != "A" {
+				t.Fatalf("owner after stale release = %q", owner)
+			}
+		})
+	}
+}
+
+func TestRedisResubscribesAfterDisconnect(t *testing.T) {
+	ctx := context.Background()
+	srv := startFakeRedis(t, "")
+	a, b := NewRedis(srv.Addr(), ""), NewRedis(srv.Addr(), "")
+	t.Cleanup(func() { a.Close(); b.Close() })
+
+	got := make(chan string, 100)
+	if err := b.Subscribe(ctx, "events", func(p []byte) { got <- string(p) }); err != nil {
+		t.Fatal(err)
+	}
+	srv.dropClients()
+
+	// 切断中の分は届かないので、購読し直されるまで送り続ける
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		_ = a.Publish(ctx, "events", []byte("after"))
+		select {
+		case s := <-got:
+			if s != "after" {
+				t.Fatalf("got %q", s)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	t.Fatal("subscription was not restored")
+}
+
+func TestRedisAuth(t *testing.T) {
+	ctx := context.Background()
+	srv := startFakeRedis(t, "s3cret")
+
+	bad := NewRedis(srv.Addr(), "wrong")
+	defer bad.Close()
+	var rerr RedisError
+	if err := bad.Ping(ctx); !errors.As(err, &rerr) {
+		t.Fatalf("Ping with wrong password = %v, want RedisError", err)
+	}
+
+	good := NewRedis(srv.Addr(), "s3cret")
+	defer good.Close()
+	if err := good.Ping(ctx); err != nil {
+		t.Fatalf("Ping = %v", err)
+	}
+}
+
+func TestClosedBackplane(t *testing.T) {
+	ctx := context.Background()
+	l := NewLocal()
+	l.Close()
+	if err := l.Publish(ctx, "x", nil); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Local.Publish after Close = %v", err)
+	}
+
+	srv := startFakeRedis(t, "")
+	r := NewRedis(srv.Addr(), "")
+	r.Close()
+	if err := r.Publish(ctx, "x", nil); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Redis.Publish after Close = %v", err)
+	}
+	if err := r.Subscribe(ctx, "x", func([]byte) {}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Redis.Subscribe after Close = %v", err)
+	}
+}
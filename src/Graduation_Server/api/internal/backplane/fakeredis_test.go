@@ -0,0 +1,234 @@
+package backplane
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis はテスト用の RESP サーバー。
+// このパッケージが使うコマンド（AUTH PING PUBLISH SUBSCRIBE SET GET PEXPIRE DEL）と、
+// EVAL は renewScript / releaseScript だけを実装する。
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu      sync.Mutex
+	values  map[string]fakeValue
+	subs    map[string]map[*fakeClient]bool
+	clients map[*fakeClient]bool
+}
+
+type fakeValue struct {
+	v       string
+	expires time.Time // ゼロなら無期限
+}
+
+type fakeClient struct {
+	conn *respConn
+	wmu  sync.Mutex
+	auth bool
+}
+
+func startFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedis{
+		ln:       ln,
+		password: password,
+		values:   make(map[string]fakeValue),
+		subs:     make(map[string]map[*fakeClient]bool),
+		clients:  make(map[*fakeClient]bool),
+	}
+	go s.serve()
+	t.Cleanup(func() {
+		ln.Close()
+		s.dropClients()
+	})
+	return s
+}
+
+func (s *fakeRedis) Addr() string { return s.ln.Addr().String() }
+
+// dropClients は全接続を切る（再接続の確認用）
+func (s *fakeRedis) dropClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		c.conn.Close()
+	}
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &fakeClient{conn: newRespConn(nc), auth: s.password == ""}
+		s.mu.Lock()
+		s.clients[c] = true
+		s.mu.Unlock()
+		go s.handle(c)
+	}
+}
+
+func (s *fakeRedis) handle(c *fakeClient) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		for _, m := range s.subs {
+			delete(m, c)
+		}
+		s.mu.Unlock()
+		c.conn.Close()
+	}()
+	for {
+		reply, err := c.conn.readReply()
+		if err != nil {
+			return
+		}
+		arr, ok := reply.([]interface{})
+		if !ok || len(arr) == 0 {
+			return
+		}
+		args := make([]string, len(arr))
+		for i, a := range arr {
+			b, _ := a.([]byte)
+			args[i] = string(b)
+		}
+		s.exec(c, strings.ToUpper(args[0]), args[1:])
+	}
+}
+
+func (c *fakeClient) write(s string) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, _ = c.conn.w.WriteString(s)
+	_ = c.conn.w.Flush()
+}
+
+func bulk(s string) string { return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n" }
+
+func (s *fakeRedis) exec(c *fakeClient, cmd string, args []string) {
+	if cmd == "AUTH" {
+		if len(args) == 1 && args[0] == s.password {
+			c.auth = true
+			c.write("+OK\r\n")
+		} else {
+			c.write("-WRONGPASS invalid password\r\n")
+		}
+		return
+	}
+	if !c.auth {
+		c.write("-NOAUTH Authentication required.\r\n")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	get := func(k string) (string, bool) {
+		v, ok := s.values[k]
+		if !ok || (!v.expires.IsZero() && !now.Before(v.expires)) {
+			delete(s.values, k)
+			return "", false
+		}
+		return v.v, true
+	}
+
+	switch cmd {
+	case "PING":
+		c.write("+PONG\r\n")
+	case "PUBLISH":
+		n := 0
+		msg := "*3\r\n" + bulk("message") + bulk(args[0]) + bulk(args[1])
+		for sub := range s.subs[args[0]] {
+			sub.write(msg)
+			n++
+		}
+		c.write(":" + strconv.Itoa(n) + "\r\n")
+	case "SUBSCRIBE":
+		for i, ch := range args {
+			if s.subs[ch] == nil {
+				s.subs[ch] = make(map[*fakeClient]bool)
+			}
+			s.subs[ch][c] = true
+			c.write("*3\r\n" + bulk("subscribe") + bulk(ch) + ":" + strconv.Itoa(i+1) + "\r\n")
+		}
+	case "SET":
+		key, val := args[0], args[1]
+		var nx bool
+		var ttl time.Duration
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "PX":
+				i++
+				ms, _ := strconv.Atoi(args[i])
+				ttl = time.Duration(ms) * time.Millisecond
+			}
+		}
+		if _, exists := get(key); nx && exists {
+			c.write("$-1\r\n")
+			return
+		}
+		v := fakeValue{v: val}
+		if ttl > 0 {
+			v.expires = now.Add(ttl)
+		}
+		s.values[key] = v
+		c.write("+OK\r\n")
+	case "GET":
+		if v, ok := get(args[0]); ok {
+			c.write(bulk(v))
+		} else {
+			c.write("$-1\r\n")
+		}
+	case "PEXPIRE":
+		v, ok := get(args[0])
+		if !ok {
+			c.write(":0\r\n")
+			return
+		}
+		ms, _ := strconv.Atoi(args[1])
+		s.values[args[0]] = fakeValue{v: v, expires: now.Add(time.Duration(ms) * time.Millisecond)}
+		c.write(":1\r\n")
+	case "DEL":
+		n := 0
+		for _, k := range args {
+			if _, ok := get(k); ok {
+				delete(s.values, k)
+				n++
+			}
+		}
+		c.write(":" + strconv.Itoa(n) + "\r\n")
+	case "EVAL":
+		// args: スクリプト, キー数(1), キー, 持ち主, [ミリ秒]
+		key, owner := args[2], args[3]
+		if v, ok := get(key); !ok || v != owner {
+			c.write(":0\r\n")
+			return
+		}
+		switch args[0] {
+		case renewScript:
+			ms, _ := strconv.Atoi(args[4])
+			s.values[key] = fakeValue{v: owner, expires: now.Add(time.Duration(ms) * time.Millisecond)}
+		case releaseScript:
+			delete(s.values, key)
+		default:
+			c.write("-NOSCRIPT unknown script\r\n")
+			return
+		}
+		c.write(":1\r\n")
+	default:
+		c.write("-ERR unknown command '" + cmd + "'\r\n")
+	}
+}
@@ -0,0 +1,150 @@
+package backplane
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Local はプロセス内だけで配送する実装。
+// 同じ Local を複数の利用者で共有すれば、1プロセス内で複数インスタンスを模擬できる。
+type Local struct {
+	mu     sync.Mutex
+	subs   map[string][]*localSub
+	leases map[string]lease
+	closed bool
+}
+
+type lease struct {
+	owner   string
+	expires time.Time
+}
+
+func NewLocal() *Local {
+	return &Local{
+		subs:   make(map[string][]*localSub),
+		leases: make(map[string]lease),
+	}
+}
+
+func (l *Local) Publish(ctx context.Context, channel string, payload []byte) error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return ErrClosed
+	}
+	subs := append([]*localSub(nil), l.subs[channel]...)
+	l.mu.Unlock()
+
+	for _, s := range subs {
+		s.push(append([]byte(nil), payload...))
+	}
+	return nil
+}
+
+func (l *Local) Subscribe(ctx context.Context, channel string, handler func(payload []byte)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrClosed
+	}
+	s := newLocalSub(handler)
+	l.subs[channel] = append(l.subs[channel], s)
+	return nil
+}
+
+func (l *Local) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return false, ErrClosed
+	}
+	now := time.Now()
+	if cur, ok := l.leases[key]; ok && cur.owner != owner && now.Before(cur.expires) {
+		return false, nil
+	}
+	l.leases[key] = lease{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (l *Local) Owner(ctx context.Context, key string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cur, ok := l.leases[key]; ok && time.Now().Before(cur.expires) {
+		return cur.owner, nil
+	}
+	return "", nil
+}
+
+func (l *Local) Release(ctx context.Context, key, owner string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cur, ok := l.leases[key]; ok && cur.owner == owner {
+		delete(l.leases, key)
+	}
+	return nil
+}
+
+func (l *Local) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	for _, subs := range l.subs {
+		for _, s := range subs {
+			s.stop()
+		}
+	}
+	l.subs = nil
+	return nil
+}
+
+// 購読者ごとの受信キュー。Publish 側を待たせないよう上限は設けない。
+type localSub struct {
+	mu      sync.Mutex
+	queue   [][]byte
+	wake    chan struct{}
+	done    chan struct{}
+	handler func([]byte)
+}
+
+func newLocalSub(handler func([]byte)) *localSub {
+	s := &localSub{wake: make(chan struct{}, 1), done: make(chan struct{}), handler: handler}
+	go s.run()
+	return s
+}
+
+func (s *localSub) push(p []byte) {
+	s.mu.Lock()
+	s.queue = append(s.queue, p)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *localSub) stop() { close(s.done) }
+
+func (s *localSub) run() {
+	for {
+		select {
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+		for {
+			s.mu.Lock()
+			if len(s.queue) == 0 {
+				s.mu.Unlock()
+				break
+			}
+			p := s.queue[0]
+			s.queue = s.queue[1:]
+			s.mu.Unlock()
+			s.handler(p)
+		}
+	}
+}
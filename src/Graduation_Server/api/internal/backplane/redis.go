@@ -0,0 +1,314 @@
+package backplane
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Redis は Redis（または RESP を話す互換サーバー）を介して配送する実装。
+//
+// コマンド用と購読用（SUBSCRIBE 中は他のコマンドを送れない）の2本の接続を使う。
+// どちらも切れたら次の利用時（購読は自動で）につなぎ直す。
+type Redis struct {
+	addr     string
+	password string
+
+	// DialTimeout / CommandTimeout は ctx に期限が無いときの上限
+	DialTimeout    time.Duration
+	CommandTimeout time.Duration
+
+	mu   sync.Mutex // コマンド用接続（1コマンドずつ）
+	conn *respConn
+
+	subMu      sync.Mutex
+	subConn    *respConn
+	subRunning bool
+	handlers   map[string][]func([]byte)
+	pending    map[string]chan struct{} // SUBSCRIBE の確認待ち
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewRedis は接続先を覚えるだけで、実際の接続は最初のコマンドで行う（Ping で確認できる）。
+func NewRedis(addr, password string) *Redis {
+	return &Redis{
+		addr:           addr,
+		password:       password,
+		DialTimeout:    5 * time.Second,
+		CommandTimeout: 5 * time.Second,
+		handlers:       make(map[string][]func([]byte)),
+		pending:        make(map[string]chan struct{}),
+		done:           make(chan struct{}),
+	}
+}
+
+func (r *Redis) dial() (*respConn, error) {
+	nc, err := net.DialTimeout("tcp", r.addr, r.DialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := newRespConn(nc)
+	if r.password != "" {
+		_ = nc.SetDeadline(time.Now().Add(r.CommandTimeout))
+		if err := c.writeCommand("AUTH", r.password); err != nil {
+			c.Close()
+			return nil, err
+		}
+		reply, err := c.readReply()
+		if err == nil {
+			if rerr, ok := reply.(RedisError); ok {
+				err = rerr
+			}
+		}
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		_ = nc.SetDeadline(time.Time{})
+	}
+	return c, nil
+}
+
+// do はコマンド用接続で1コマンド送り、応答を返す。
+// 通信エラーなら接続を捨てる（次回つなぎ直す）。Redis のエラー応答は RedisError で返す。
+func (r *Redis) do(ctx context.Context, args ...string) (interface{}, error) {
+	select {
+	case <-r.done:
+		return nil, ErrClosed
+	default:
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		c, err := r.dial()
+		if err != nil {
+			return nil, err
+		}
+		r.conn = c
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(r.CommandTimeout)
+	}
+	_ = r.conn.c.SetDeadline(deadline)
+
+	err := r.conn.writeCommand(args...)
+	var reply interface{}
+	if err == nil {
+		reply, err = r.conn.readReply()
+	}
+	if err != nil {
+		r.conn.Close()
+		r.conn = nil
+		return nil, err
+	}
+	if rerr, ok := reply.(RedisError); ok {
+		return nil, rerr
+	}
+	return reply, nil
+}
+
+// Ping は接続できるか確かめる（起動時の確認用）
+func (r *Redis) Ping(ctx context.Context) error {
+	_, err := r.do(ctx, "PING")
+	return err
+}
+
+func (r *Redis) Publish(ctx context.Context, channel string, payload []byte) error {
+	_, err := r.do(ctx, "PUBLISH", channel, string(payload))
+	return err
+}
+
+func (r *Redis) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	ms := strconv.FormatInt(ttl.Milliseconds(), 10)
+	reply, err := r.do(ctx, "SET", key, owner, "NX", "PX", ms)
+	if err != nil {
+		return false, err
+	}
+	if reply == "OK" {
+		return true, nil
+	}
+	// 既に持っているなら期限を延ばす（持ち主の確認と延長を1回のスクリプトで行い、他の担当は延ばさない）
+	n, err := r.do(ctx, "EVAL", renewScript, "1", key, owner, ms)
+	if err != nil {
+		return false, err
+	}
+	return n == int64(1), nil
+}
+
+func (r *Redis) Owner(ctx context.Context, key string) (string, error) {
+	reply, err := r.do(ctx, "GET", key)
+	if err != nil {
+		return "", err
+	}
+	b, _ := reply.([]byte)
+	return string(b), nil
+}
+
+func (r *Redis) Release(ctx context.Context, key, owner string) error {
+	// 期限切れの後に他が取った担当を消さないよう、持ち主の確認と削除を1回のスクリプトで行う
+	_, err := r.do(ctx, "EVAL", releaseScript, "1", key, owner)
+	return err
+}
+
+// 担当のキーが owner（ARGV[1]）のものであるときだけ延長・削除する（1 = 行った、0 = 持ち主が違う・期限切れ）
+const (
+	renewScript   = `if redis.call('get',KEYS[1])==ARGV[1] then return redis.call('pexpire',KEYS[1],ARGV[2]) end return 0`
+	releaseScript = `if redis.call('get',KEYS[1])==ARGV[1] then return redis.call('del',KEYS[1]) end return 0`
+)
+
+func (r *Redis) Subscribe(ctx context.Context, channel string, handler func(payload []byte)) error {
+	r.subMu.Lock()
+	select {
+	case <-r.done:
+		r.subMu.Unlock()
+		return ErrClosed
+	default:
+	}
+	first := len(r.handlers[channel]) == 0
+	r.handlers[channel] = append(r.handlers[channel], handler)
+	if !first {
+		r.subMu.Unlock()
+		return nil
+	}
+	confirmed := make(chan struct{})
+	r.pending[channel] = confirmed
+	switch {
+	case !r.subRunning:
+		// 接続後に handlers の全チャンネルを SUBSCRIBE する
+		r.subRunning = true
+		go r.subscribeLoop()
+	case r.subConn != nil:
+		// 書けなくても読み込み側が切断を検知してつなぎ直し、購読し直す
+		_ = r.subConn.writeCommand("SUBSCRIBE", channel)
+	}
+	r.subMu.Unlock()
+
+	select {
+	case <-confirmed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.done:
+		return ErrClosed
+	}
+}
+
+// subscribeLoop は購読用接続を保ち、切れたらつなぎ直して全チャンネルを購読し直す
+func (r *Redis) subscribeLoop() {
+	backoff := 100 * time.Millisecond
+	for {
+		c, err := r.dial()
+		if err != nil {
+			slog.Warn("backplane: redis subscribe dial failed", "addr", r.addr, "err", err)
+			select {
+			case <-time.After(backoff):
+			case <-r.done:
+				return
+			}
+			if backoff < 5*time.Second {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = 100 * time.Millisecond
+
+		r.subMu.Lock()
+		select {
+		case <-r.done:
+			r.subMu.Unlock()
+			c.Close()
+			return
+		default:
+		}
+		r.subConn = c
+		channels := make([]string, 0, len(r.handlers))
+		for ch := range r.handlers {
+			channels = append(channels, ch)
+		}
+		if len(channels) > 0 {
+			err = c.writeCommand(append([]string{"SUBSCRIBE"}, channels...)...)
+		}
+		r.subMu.Unlock()
+
+		if err == nil {
+			err = r.readMessages(c)
+		}
+
+		r.subMu.Lock()
+		r.subConn = nil
+		r.subMu.Unlock()
+		c.Close()
+
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+		slog.Warn("backplane: redis subscription lost, reconnecting", "addr", r.addr, "err", err)
+	}
+}
+
+func (r *Redis) readMessages(c *respConn) error {
+	for {
+		reply, err := c.readReply()
+		if err != nil {
+			return err
+		}
+		arr, ok := reply.([]interface{})
+		if !ok || len(arr) < 3 {
+			if rerr, ok := reply.(RedisError); ok {
+				return rerr
+			}
+			continue
+		}
+		kind, _ := arr[0].([]byte)
+		channel, _ := arr[1].([]byte)
+		switch string(kind) {
+		case "subscribe":
+			r.subMu.Lock()
+			if ch, ok := r.pending[string(channel)]; ok {
+				close(ch)
+				delete(r.pending, string(channel))
+			}
+			r.subMu.Unlock()
+		case "message":
+			payload, _ := arr[2].([]byte)
+			r.subMu.Lock()
+			hs := append(([]func([]byte))(nil), r.handlers[string(channel)]...)
+			r.subMu.Unlock()
+			for _, h := range hs {
+				h(payload)
+			}
+		}
+	}
+}
+
+func (r *Redis) Close() error {
+	r.closeOnce.Do(func() {
+		r.subMu.Lock()
+		close(r.done)
+		if r.subConn != nil {
+			r.subConn.Close()
+		}
+		r.subMu.Unlock()
+
+		r.mu.Lock()
+		if r.conn != nil {
+			r.conn.Close()
+			r.conn = nil
+		}
+		r.mu.Unlock()
+	})
+	return nil
+}
+
+// String はログ用の接続先表示
+func (r *Redis) String() string { return fmt.Sprintf("redis://%s", r.addr) }
@@ -0,0 +1,100 @@
+package backplane
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// RESP（Redis のワイヤプロトコル）の最小限の読み書き
+
+// RedisError は Redis が返したエラー応答（-ERR ...）。接続自体はそのまま使える。
+type RedisError string
+
+func (e RedisError) Error() string { return "redis: " + string(e) }
+
+var errProtocol = errors.New("redis: protocol error")
+
+type respConn struct {
+	c net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func newRespConn(c net.Conn) *respConn {
+	return &respConn{c: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+}
+
+// writeCommand はコマンドを bulk string の配列として送る
+func (c *respConn) writeCommand(args ...string) error {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return c.w.Flush()
+}
+
+// readReply は応答を1つ読む。
+// 戻り値は string（+OK など）/ RedisError / int64 / []byte（null は nil）/ []interface{}
+func (c *respConn) readReply() (interface{}, error) {
+	line, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errProtocol
+	}
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return RedisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, errProtocol
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		arr := make([]interface{}, n)
+		for i := range arr {
+			if arr[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	}
+	return nil, errProtocol
+}
+
+func (c *respConn) readLine() ([]byte, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errProtocol
+	}
+	return line[:len(line)-2], nil
+}
+
+func (c *respConn) Close() error { return c.c.Close() }
@@ -0,0 +1,233 @@
+// Package bot は API を外側から叩くスクリプト用のクライアント。
+//
+// 実際のアプリと同じく HTTP でアカウント作成・ログイン・ルーム操作を行い、
+// ルーム / ブラックジャックの WebSocket に接続して届いたメッセージを記録する。
+// e2e テスト（internal/server）と負荷試験から使う。
+// API は現行版（/api/v1）を使い、応答のエンベロープを外して data を読む。
+package bot
+
+import (
+	"api/internal/handlers"
+	"api/internal/response"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client は1人分のユーザー（トークンとユーザーID）を持つ
+type Client struct {
+	BaseURL string // 例: http://127.0.0.1:9090
+	HTTP    *http.Client
+	Name    string
+
+	UserID       int64
+	Token        string
+	RefreshToken string
+}
+
+// New は未ログインのクライアントを作る
+func New(baseURL, name string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		HTTP:    &http.Client{Timeout: 10 * time.Second},
+		Name:    name,
+	}
+}
+
+// StatusError は 2xx 以外の応答（Code はエンベロープの error.code、読めなければ空）
+type StatusError struct {
+	Method string
+	Path   string
+	Status int
+	Code   response.Code
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: status %d: %s", e.Method, e.Path, e.Status, strings.TrimSpace(e.Body))
+}
+
+// ログイン系 API の応答
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ---- アカウント ----
+
+// CreateAccount はアカウントを作ってトークンとユーザーIDを保持する。
+// password が空ならゲスト（auto_flg）として作る。
+func (c *Client) CreateAccount(password string) error {
+	req := handlers.CreateAccountRequest{Name: c.Name, Password: password, AutoFlg: password == ""}
+	var resp tokenResponse
+	if err := c.do(http.MethodPost, "/create_account", req, &resp); err != nil {
+		return err
+	}
+	return c.acceptTokens("/create_account", resp)
+}
+
+// Login は名前とパスワードでログインする
+func (c *Client) Login(password string) error {
+	req := handlers.LoginRequest{Name: c.Name, Password: password}
+	var resp tokenResponse
+	if err := c.do(http.MethodPost, "/login", req, &resp); err != nil {
+		return err
+	}
+	return c.acceptTokens("/login", resp)
+}
+
+func (c *Client) acceptTokens(path string, resp tokenResponse) error {
+	if resp.Token == "" {
+		return fmt.Errorf("%s: no token in response", path)
+	}
+	c.Token, c.RefreshToken = resp.Token, resp.RefreshToken
+	return c.loadUserID()
+}
+
+// Logout はこの端末のトークン（アクセス・リフレッシュ）を失効させる
+func (c *Client) Logout() error {
+	return c.do(http.MethodPost, "/logout", handlers.LogoutRequest{RefreshToken: c.RefreshToken}, nil)
+}
+
+// トークンだけではユーザーIDが分からないので、メインデータから取る
+func (c *Client) loadUserID() error {
+	var resp struct {
+		Settings struct {
+			UserID int64 `json:"user_id"`
+		} `json:"settings"`
+	}
+	if err := c.do(http.MethodGet, "/get_main_data", nil, &resp); err != nil {
+		return err
+	}
+	if resp.Settings.UserID == 0 {
+		return fmt.Errorf("/get_main_data: no user_id in response")
+	}
+	c.UserID = resp.Settings.UserID
+	return nil
+}
+
+// ---- ルーム ----
+
+// CreateRoom はルームを作ってルームコードを返す（作成者がホスト）
+func (c *Client) CreateRoom(gameTypeID, maxPlayers int) (string, error) {
+	var resp handlers.CreateRoomResponse
+	err := c.do(http.MethodPost, "/create_room", handlers.CreateRoomRequest{GameTypeID: gameTypeID, MaxPlayers: maxPlayers}, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.RoomCode, nil
+}
+
+func (c *Client) JoinRoom(roomCode string) error {
+	return c.do(http.MethodPost, "/join_room", handlers.JoinRoomRequest{RoomCode: roomCode}, nil)
+}
+
+func (c *Client) LeaveRoom(roomCode string) (*handlers.LeaveRoomResponse, error) {
+	var resp handlers.LeaveRoomResponse
+	if err := c.do(http.MethodPost, "/rooms/leave", handlers.LeaveRoomRequest{RoomCode: roomCode}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// StartRoom はゲームを開始してゲームIDを返す（ホストのみ）
+func (c *Client) StartRoom(roomCode string) (int64, error) {
+	var resp handlers.StartResponse
+	if err := c.do(http.MethodPost, "/rooms/start", handlers.StartRequest{RoomCode: roomCode}, &resp); err != nil {
+		return 0, err
+	}
+	return resp.GameID, nil
+}
+
+// ---- WebSocket ----
+
+// DialRoom はルーム待機画面の WebSocket に接続する
+func (c *Client) DialRoom(roomCode string) (*Conn, error) {
+	return c.dial("/ws/room/"+url.PathEscape(roomCode), c.Name+"/room")
+}
+
+// DialBlackjack はブラックジャック卓の WebSocket に接続する
+func (c *Client) DialBlackjack(roomCode string) (*Conn, error) {
+	return c.dial("/ws/blackjackwebsocket/"+url.PathEscape(roomCode), c.Name+"/bj")
+}
+
+// DialBlackjackFunc は受信メッセージを記録せず handle に渡すブラックジャック接続（負荷試験用）
+func (c *Client) DialBlackjackFunc(roomCode string, handle func(Message)) (*Conn, error) {
+	return DialFunc(c.wsURL("/ws/blackjackwebsocket/"+url.PathEscape(roomCode)), c.Name+"/bj", handle)
+}
+
+func (c *Client) dial(path, label string) (*Conn, error) {
+	return Dial(c.wsURL(path), label)
+}
+
+// Unity クライアントと同じくクエリでトークンを渡す
+func (c *Client) wsURL(path string) string {
+	return "ws" + strings.TrimPrefix(c.BaseURL, "http") + response.V1Prefix + path + "?token=" + url.QueryEscape(c.Token)
+}
+
+// ---- HTTP ----
+
+// Call は bot に専用メソッドの無い API（運営APIなど）を呼ぶ。
+// path は /api/v1 より後ろ（例: /admin/rooms）。out には data を読む。2xx 以外は *StatusError。
+func (c *Client) Call(method, path string, body, out interface{}) error {
+	return c.do(method, path, body, out)
+}
+
+// do は JSON で送ってエンベロープの data を out に読む。body が nil ならボディ無し。
+func (c *Client) do(method, path string, body, out interface{}) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+response.V1Prefix+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	var env struct {
+		Data  json.RawMessage `json:"data"`
+		Error *response.Error `json:"error"`
+	}
+	decodeErr := json.Unmarshal(raw, &env)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		se := &StatusError{Method: method, Path: path, Status: res.StatusCode, Body: string(raw)}
+		if decodeErr == nil && env.Error != nil {
+			se.Code = env.Error.Code
+		}
+		return se
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("%s %s: decode response: %w (body=%q)", method, path, decodeErr, raw)
+	}
+	if out == nil || len(env.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(env.Data, out); err != nil {
+		return fmt.Errorf("%s %s: decode data: %w (body=%q)", method, path, err, raw)
+	}
+	return nil
+}
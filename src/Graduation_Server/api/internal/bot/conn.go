@@ -0,0 +1,280 @@
+package bot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultTimeout は Expect で timeout に 0 を渡したときの待ち時間
+const DefaultTimeout = 5 * time.Second
+
+// Message は受信したメッセージ1件
+type Message struct {
+	Seq  int       // 接続ごとの受信順（0始まり。DialFunc の接続では常に 0）
+	Type string    // JSON の "type"
+	At   time.Time // 受信時刻
+	Raw  json.RawMessage
+}
+
+// Decode は本文を v に読み込む
+func (m Message) Decode(v interface{}) error {
+	return json.Unmarshal(m.Raw, v)
+}
+
+// Conn は受信したメッセージをすべて記録する WebSocket 接続。
+//
+// Expect はカーソル位置より後ろだけを探し、見つけたらカーソルをその次へ進める。
+// そのため Expect を呼んだ順番が、そのまま「この順で届くはず」という期待になる。
+type Conn struct {
+	Label string // ログ・エラー用（例: "bot1/room"）
+
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+	opened  time.Time
+
+	handle func(Message) // 設定されていれば記録せずにこちらへ渡す（DialFunc）
+
+	mu      sync.Mutex
+	msgs    []Message
+	cursor  int
+	changed chan struct{} // メッセージ追加・切断のたびに close して作り直す
+	readErr error         // 読み込みループの終了理由
+}
+
+// Dial は url に接続して受信ループを始める
+func Dial(url, label string) (*Conn, error) {
+	return DialFunc(url, label, nil)
+}
+
+// DialFunc は受信したメッセージを記録せず handle に渡す接続を作る（長時間の負荷試験用）。
+// handle は受信ゴルーチンから順番に呼ばれる。handle が nil なら Dial と同じ。
+// 記録しないので Expect は使えない（切断の検知には Closed を使う）。
+func DialFunc(url, label string, handle func(Message)) (*Conn, error) {
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: dial: %w", label, err)
+	}
+	c := &Conn{
+		Label:   label,
+		ws:      ws,
+		opened:  time.Now(),
+		handle:  handle,
+		changed: make(chan struct{}),
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *Conn) readLoop() {
+	for {
+		_, raw, err := c.ws.ReadMessage()
+		if err != nil {
+			c.mu.Lock()
+			c.readErr = err
+			c.notifyLocked()
+			c.mu.Unlock()
+			return
+		}
+		var head struct {
+			Type string `json:"type"`
+		}
+		_ = json.Unmarshal(raw, &head)
+
+		if c.handle != nil {
+			c.handle(Message{Type: head.Type, At: time.Now(), Raw: raw})
+			continue
+		}
+		c.mu.Lock()
+		c.msgs = append(c.msgs, Message{Seq: len(c.msgs), Type: head.Type, At: time.Now(), Raw: raw})
+		c.notifyLocked()
+		c.mu.Unlock()
+	}
+}
+
+func (c *Conn) notifyLocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Send は v を JSON で送る
+func (c *Conn) Send(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.ws.WriteJSON(v); err != nil {
+		return fmt.Errorf("%s: send: %w", c.Label, err)
+	}
+	return nil
+}
+
+// Close は接続を閉じる（受信ループも終わる）
+func (c *Conn) Close() error {
+	c.writeMu.Lock()
+	_ = c.ws.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	c.writeMu.Unlock()
+	return c.ws.Close()
+}
+
+// Expect はカーソルより後ろで typ が一致し match が true を返す最初のメッセージを待つ。
+// match が nil なら type だけで判定する。timeout が 0 なら DefaultTimeout。
+func (c *Conn) Expect(typ string, match func(Message) bool, timeout time.Duration) (Message, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	scanned := -1
+	for {
+		c.mu.Lock()
+		if scanned < c.cursor {
+			scanned = c.cursor
+		}
+		for ; scanned < len(c.msgs); scanned++ {
+			m := c.msgs[scanned]
+			if m.Type == typ && (match == nil || match(m)) {
+				c.cursor = scanned + 1
+				c.mu.Unlock()
+				return m, nil
+			}
+		}
+		readErr, changed := c.readErr, c.changed
+		c.mu.Unlock()
+
+		if readErr != nil {
+			return Message{}, c.expectError(typ, "connection closed: "+readErr.Error())
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return Message{}, c.expectError(typ, "timeout after "+timeout.String())
+		}
+	}
+}
+
+// Closed はサーバー側から切断されるのを待つ
+func (c *Conn) Closed(timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		c.mu.Lock()
+		readErr, changed := c.readErr, c.changed
+		c.mu.Unlock()
+		if readErr != nil {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return fmt.Errorf("%s: still open after %s", c.Label, timeout)
+		}
+	}
+}
+
+// どこまで読んで、その後に何が届いていたかをエラーに含める
+func (c *Conn) expectError(typ, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := map[string]int{}
+	for _, m := range c.msgs[c.cursor:] {
+		counts[m.Type]++
+	}
+	var seen []string
+	for t, n := range counts {
+		seen = append(seen, fmt.Sprintf("%s×%d", t, n))
+	}
+	sort.Strings(seen)
+	return &ExpectError{
+		Label:  c.Label,
+		Type:   typ,
+		Reason: reason,
+		Cursor: c.cursor,
+		Seen:   strings.Join(seen, ", "),
+	}
+}
+
+// ExpectError は期待したメッセージが届かなかったことを表す
+type ExpectError struct {
+	Label  string
+	Type   string
+	Reason string
+	Cursor int    // それまでに消化したメッセージ数
+	Seen   string // カーソル以降に届いていたメッセージの種類と件数
+}
+
+func (e *ExpectError) Error() string {
+	seen := e.Seen
+	if seen == "" {
+		seen = "nothing"
+	}
+	return fmt.Sprintf("%s: waiting for %q (after #%d): %s; received since: %s", e.Label, e.Type, e.Cursor, e.Reason, seen)
+}
+
+// Messages は受信済みメッセージのコピーを返す
+func (c *Conn) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Message(nil), c.msgs...)
+}
+
+// Transcript は受信履歴を1行1件で返す（テスト失敗時のログ用）。skip の type は省く。
+func (c *Conn) Transcript(skip ...string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var b strings.Builder
+	fmt.Fprintf(&b, "== %s (%d messages, cursor=%d)\n", c.Label, len(c.msgs), c.cursor)
+	skipped := 0
+	for _, m := range c.msgs {
+		if contains(skip, m.Type) {
+			skipped++
+			continue
+		}
+		raw := string(m.Raw)
+		if len(raw) > 200 {
+			raw = raw[:200] + "..."
+		}
+		fmt.Fprintf(&b, "  #%d +%s %s %s\n", m.Seq, m.At.Sub(c.opened).Round(time.Millisecond), m.Type, raw)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(&b, "  (%d messages of %v omitted)\n", skipped, skip)
+	}
+	if c.readErr != nil {
+		fmt.Fprintf(&b, "  closed: %v\n", c.readErr)
+	}
+	return b.String()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpectDecoded は typ のメッセージを T に読み込み、cond を満たすものが来るまで待つ
+func ExpectDecoded[T any](c *Conn, typ string, cond func(T) bool, timeout time.Duration) (T, error) {
+	var got T
+	_, err := c.Expect(typ, func(m Message) bool {
+		var v T
+		if m.Decode(&v) != nil {
+			return false
+		}
+		if cond != nil && !cond(v) {
+			return false
+		}
+		got = v
+		return true
+	}, timeout)
+	return got, err
+}
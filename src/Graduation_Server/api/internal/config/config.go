@@ -0,0 +1,230 @@
+// Package config はサーバー設定の読み込み・検証を行う。
+//
+// 優先順位（後ろほど強い）:
+//
+//	デフォルト値 < 設定ファイル(TOML) < 環境変数 < コマンドラインフラグ
+//
+// 設定ファイルは -config フラグか APP_CONFIG 環境変数で指定する。
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 実行環境
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+type Config struct {
+	// "development" / "production"（production では開発用の鍵やパスワード無しを許さない）
+	Env string `toml:"env"`
+
+	Server ServerConfig `toml:"server"`
+	DB     DBConfig     `toml:"db"`
+	JWT    JWTConfig    `toml:"jwt"`
+	Timers TimerConfig  `toml:"timers"`
+	Chips  ChipConfig   `toml:"chips"`
+
+	Profile ProfileConfig `toml:"profile"`
+
+	RateLimit RateLimitConfig `toml:"rate_limit"`
+
+	Backplane BackplaneConfig `toml:"backplane"`
+	Log       LogConfig       `toml:"log"`
+	Tracing   TracingConfig   `toml:"tracing"`
+}
+
+type ServerConfig struct {
+	ListenAddr  string   `toml:"listen_addr"`
+	TLSCertFile string   `toml:"tls_cert_file"` // 証明書と鍵の両方を指定したときだけ HTTPS で待ち受ける
+	TLSKeyFile  string   `toml:"tls_key_file"`
+	CORSOrigins []string `toml:"cors_origins"` // "*" で全許可
+	// 停止（デプロイ）時に進行中の卓を待つ上限。過ぎたら残りのベットを返して切断する
+	ShutdownTimeout time.Duration `toml:"shutdown_timeout"`
+}
+
+type DBConfig struct {
+	Host     string `toml:"host"`
+	HostFile string `toml:"host_file"` // 指定時はこのファイルの中身をホストとして使う（旧 ip.txt 運用向け）
+	Port     int    `toml:"port"`
+	User     string `toml:"user"`
+	Password string `toml:"password"`
+	Name     string `toml:"name"`
+}
+
+type JWTConfig struct {
+	Keys            map[string]string `toml:"keys"`       // kid -> 鍵
+	ActiveKID       string            `toml:"active_kid"` // 発行に使う kid（鍵が1つなら省略可）
+	AccessTokenTTL  time.Duration     `toml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration     `toml:"refresh_token_ttl"`
+}
+
+type TimerConfig struct {
+	Action         time.Duration `toml:"action"`           // ブラックジャックの行動制限時間
+	WSPingInterval time.Duration `toml:"ws_ping_interval"` // ルームWSの ping 間隔
+	WSReadTimeout  time.Duration `toml:"ws_read_timeout"`  // ルームWSの無通信タイムアウト
+}
+
+type ChipConfig struct {
+	SoloStart  int `toml:"solo_start"`  // アカウント作成時のソロ用チップ
+	MultiStart int `toml:"multi_start"` // アカウント作成時のマルチ用チップ
+	TableStart int `toml:"table_start"` // ブラックジャック卓に着いたときの手持ちチップ
+}
+
+type ProfileConfig struct {
+	// 名前を変えてから次に変えられるまでの間隔（0 で制限なし）
+	NameChangeCooldown time.Duration `toml:"name_change_cooldown"`
+}
+
+// 呼び出し回数の制限（internal/ratelimit のトークンバケット）。
+// *_rate は1秒あたりに回復する回数、*_burst は続けて呼べる上限。rate が 0 ならその制限は無効。
+type RateLimitConfig struct {
+	APIRate            float64 `toml:"api_rate"` // /api/ 全体（ログイン中はユーザーID、それ以外はIPごと）
+	APIBurst           int     `toml:"api_burst"`
+	CreateRoomRate     float64 `toml:"create_room_rate"` // ルーム作成（ユーザーごと）
+	CreateRoomBurst    int     `toml:"create_room_burst"`
+	CreateAccountRate  float64 `toml:"create_account_rate"` // アカウント作成（IPごと）
+	CreateAccountBurst int     `toml:"create_account_burst"`
+	WSMessageRate      float64 `toml:"ws_message_rate"` // WebSocket の受信メッセージ（1接続ごと）
+	WSMessageBurst     int     `toml:"ws_message_burst"`
+	WSMaxMessageBytes  int     `toml:"ws_max_message_bytes"` // WebSocket の1メッセージの上限（超えたら切断）
+}
+
+// 複数台構成でのインスタンス間の配送（internal/backplane）
+type BackplaneConfig struct {
+	Driver        string        `toml:"driver"` // "local"（1台構成）/ "redis"
+	RedisAddr     string        `toml:"redis_addr"`
+	RedisPassword string        `toml:"redis_password"`
+	InstanceID    string        `toml:"instance_id"` // 空ならホスト名とPIDから作る
+	OwnerTTL      time.Duration `toml:"owner_ttl"`   // 卓の担当のリース期間（落ちた担当はこの時間で引き継がれる）
+}
+
+// ログ（internal/logging）
+type LogConfig struct {
+	Level  string `toml:"level"`  // "debug" / "info" / "warn" / "error"
+	Format string `toml:"format"` // "text"（人が読む）/ "json"（ログ基盤に流す）
+}
+
+// 分散トレース（internal/tracing）
+type TracingConfig struct {
+	Exporter     string   `toml:"exporter"`      // "none" / "stdout"（ローカル確認）/ "otlp"（コレクターへ）
+	OTLPEndpoint string   `toml:"otlp_endpoint"` // OTLP/HTTP の送り先（/v1/traces まで）
+	OTLPHeaders  []string `toml:"otlp_headers"`  // "Key=Value"（コレクターの認証など）
+	SampleRatio  float64  `toml:"sample_ratio"`  // 新しいトレースを記録する割合（0〜1）
+	ServiceName  string   `toml:"service_name"`
+}
+
+// トレースの送り先
+const (
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	TracingOTLP   = "otlp"
+)
+
+// OTLPHeaderMap は otlp_headers を map にする（形式は validate で確認済み）
+func (c *TracingConfig) OTLPHeaderMap() map[string]string {
+	m := make(map[string]string, len(c.OTLPHeaders))
+	for _, h := range c.OTLPHeaders {
+		k, v, _ := strings.Cut(h, "=")
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return m
+}
+
+// ログの形式
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// 配送の種類
+const (
+	BackplaneLocal = "local"
+	BackplaneRedis = "redis"
+)
+
+// Default は従来ハードコードされていた値をデフォルトとして返す。
+// DB のパスワードは含まないので db.password（DB_PASSWORD）で渡す（production では未設定だと起動しない）。
+func Default() *Config {
+	return &Config{
+		Env: EnvDevelopment,
+		Server: ServerConfig{
+			ListenAddr:      "0.0.0.0:9090",
+			CORSOrigins:     []string{"*"},
+			ShutdownTimeout: 60 * time.Second,
+		},
+		DB: DBConfig{
+			Host: "127.0.0.1",
+			Port: 3306,
+			User: "db_user1",
+			Name: "graduationdb",
+		},
+		JWT: JWTConfig{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+		},
+		Timers: TimerConfig{
+			Action:         15 * time.Second,
+			WSPingInterval: 30 * time.Second,
+			WSReadTimeout:  180 * time.Second,
+		},
+		Chips: ChipConfig{
+			SoloStart:  10000,
+			MultiStart: 10000,
+			TableStart: 1000,
+		},
+		Profile: ProfileConfig{
+			NameChangeCooldown: 7 * 24 * time.Hour,
+		},
+		RateLimit: RateLimitConfig{
+			APIRate:            20,
+			APIBurst:           40,
+			CreateRoomRate:     0.2,
+			CreateRoomBurst:    5,
+			CreateAccountRate:  0.05,
+			CreateAccountBurst: 10,
+			WSMessageRate:      20,
+			WSMessageBurst:     40,
+			WSMaxMessageBytes:  64 << 10,
+		},
+		Backplane: BackplaneConfig{
+			Driver:    BackplaneLocal,
+			RedisAddr: "127.0.0.1:6379",
+			OwnerTTL:  15 * time.Second,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: LogFormatText,
+		},
+		Tracing: TracingConfig{
+			Exporter:     TracingNone,
+			OTLPEndpoint: "http://127.0.0.1:4318/v1/traces",
+			SampleRatio:  1,
+			ServiceName:  "graduation-api",
+		},
+	}
+}
+
+// DSN は go-sql-driver/mysql 用の接続文字列を組み立てる。
+// parseTime=true: DATETIME を time.Time として受け取る（トークンの有効期限など）
+func (c *DBConfig) DSN() string {
+	mc := mysql.NewConfig()
+	mc.User = c.User
+	mc.Passwd = c.Password
+	mc.Net = "tcp"
+	mc.Addr = fmt.Sprintf("%s:%d", c.Host, c.Port)
+	mc.DBName = c.Name
+	mc.ParseTime = true
+	return mc.FormatDSN()
+}
+
+// TLS を使うかどうか
+func (c *ServerConfig) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
@@ -0,0 +1,497 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// 開発用のデフォルト鍵（development で JWT 鍵が未設定のときだけ使う）
+const (
+	DevKeyID  = "dev"
+	devSecret = "your_secret_key"
+)
+
+// setting は1つの設定項目を「設定ファイルのキー / 環境変数 / フラグ」に結びつける。
+// flag が空の項目（パスワード・鍵）はコマンドラインから渡せない（ps で見えてしまうため）。
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	get    func() string
+	set    func(string) error
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		strSetting("env", "APP_ENV", "env", "実行環境 (development/production)", &c.Env),
+		strSetting("server.listen_addr", "LISTEN_ADDR", "listen", "待ち受けアドレス", &c.Server.ListenAddr),
+		strSetting("server.tls_cert_file", "TLS_CERT_FILE", "tls-cert", "TLS証明書ファイル", &c.Server.TLSCertFile),
+		strSetting("server.tls_key_file", "TLS_KEY_FILE", "tls-key", "TLS秘密鍵ファイル", &c.Server.TLSKeyFile),
+		listSetting("server.cors_origins", "CORS_ORIGINS", "cors-origins", "許可するOrigin（カンマ区切り、* で全許可）", &c.Server.CORSOrigins),
+		durSetting("server.shutdown_timeout", "SHUTDOWN_TIMEOUT", "shutdown-timeout", "停止時に進行中の卓を待つ上限", &c.Server.ShutdownTimeout),
+		strSetting("db.host", "DB_HOST", "db-host", "DBホスト", &c.DB.Host),
+		strSetting("db.host_file", "DB_HOST_FILE", "db-host-file", "DBホストを書いたファイル（旧 ip.txt）", &c.DB.HostFile),
+		intSetting("db.port", "DB_PORT", "db-port", "DBポート", &c.DB.Port),
+		strSetting("db.user", "DB_USER", "db-user", "DBユーザー", &c.DB.User),
+		secret(strSetting("db.password", "DB_PASSWORD", "", "", &c.DB.Password)),
+		strSetting("db.name", "DB_NAME", "db-name", "DB名", &c.DB.Name),
+		keysSetting("jwt.keys", "JWT_KEYS", &c.JWT.Keys),
+		strSetting("jwt.active_kid", "JWT_ACTIVE_KID", "jwt-active-kid", "JWT発行に使う kid", &c.JWT.ActiveKID),
+		durSetting("jwt.access_token_ttl", "ACCESS_TOKEN_TTL", "access-token-ttl", "アクセストークンの有効期間", &c.JWT.AccessTokenTTL),
+		durSetting("jwt.refresh_token_ttl", "REFRESH_TOKEN_TTL", "refresh-token-ttl", "リフレッシュトークンの有効期間", &c.JWT.RefreshTokenTTL),
+		durSetting("timers.action", "ACTION_TIMER", "action-timer", "行動制限時間", &c.Timers.Action),
+		durSetting("timers.ws_ping_interval", "WS_PING_INTERVAL", "ws-ping-interval", "WebSocket ping 間隔", &c.Timers.WSPingInterval),
+		durSetting("timers.ws_read_timeout", "WS_READ_TIMEOUT", "ws-read-timeout", "WebSocket 無通信タイムアウト", &c.Timers.WSReadTimeout),
+		intSetting("chips.solo_start", "SOLO_START_CHIPS", "solo-start-chips", "初期ソロチップ", &c.Chips.SoloStart),
+		intSetting("chips.multi_start", "MULTI_START_CHIPS", "multi-start-chips", "初期マルチチップ", &c.Chips.MultiStart),
+		intSetting("chips.table_start", "TABLE_START_CHIPS", "table-start-chips", "卓の初期手持ちチップ", &c.Chips.TableStart),
+		durSetting("profile.name_change_cooldown", "NAME_CHANGE_COOLDOWN", "name-change-cooldown", "名前を変えてから次に変えられるまでの間隔（0で制限なし）", &c.Profile.NameChangeCooldown),
+		floatSetting("rate_limit.api_rate", "RATE_LIMIT_API_RATE", "rate-limit-api-rate", "API呼び出しの回復数/秒（0で無効）", &c.RateLimit.APIRate),
+		intSetting("rate_limit.api_burst", "RATE_LIMIT_API_BURST", "rate-limit-api-burst", "API呼び出しの連続上限", &c.RateLimit.APIBurst),
+		floatSetting("rate_limit.create_room_rate", "RATE_LIMIT_CREATE_ROOM_RATE", "rate-limit-create-room-rate", "ルーム作成の回復数/秒（0で無効）", &c.RateLimit.CreateRoomRate),
+		intSetting("rate_limit.create_room_burst", "RATE_LIMIT_CREATE_ROOM_BURST", "rate-limit-create-room-burst", "ルーム作成の連続上限", &c.RateLimit.CreateRoomBurst),
+		floatSetting("rate_limit.create_account_rate", "RATE_LIMIT_CREATE_ACCOUNT_RATE", "rate-limit-create-account-rate", "アカウント作成の回復数/秒（0で無効）", &c.RateLimit.CreateAccountRate),
+		intSetting("rate_limit.create_account_burst", "RATE_LIMIT_CREATE_ACCOUNT_BURST", "rate-limit-create-account-burst", "アカウント作成の連続上限", &c.RateLimit.CreateAccountBurst),
+		floatSetting("rate_limit.ws_message_rate", "RATE_LIMIT_WS_MESSAGE_RATE", "rate-limit-ws-message-rate", "WebSocket受信の回復数/秒（0で無効）", &c.RateLimit.WSMessageRate),
+		intSetting("rate_limit.ws_message_burst", "RATE_LIMIT_WS_MESSAGE_BURST", "rate-limit-ws-message-burst", "WebSocket受信の連続上限", &c.RateLimit.WSMessageBurst),
+		intSetting("rate_limit.ws_max_message_bytes", "WS_MAX_MESSAGE_BYTES", "ws-max-message-bytes", "WebSocketの1メッセージの上限バイト数", &c.RateLimit.WSMaxMessageBytes),
+		strSetting("backplane.driver", "BACKPLANE_DRIVER", "backplane", "インスタンス間の配送 (local/redis)", &c.Backplane.Driver),
+		strSetting("backplane.redis_addr", "REDIS_ADDR", "redis-addr", "Redis のアドレス", &c.Backplane.RedisAddr),
+		secret(strSetting("backplane.redis_password", "REDIS_PASSWORD", "", "", &c.Backplane.RedisPassword)),
+		strSetting("backplane.instance_id", "INSTANCE_ID", "instance-id", "インスタンスID（空ならホスト名-PID）", &c.Backplane.InstanceID),
+		durSetting("backplane.owner_ttl", "OWNER_TTL", "owner-ttl", "卓の担当のリース期間", &c.Backplane.OwnerTTL),
+		strSetting("log.level", "LOG_LEVEL", "log-level", "ログレベル (debug/info/warn/error)", &c.Log.Level),
+		strSetting("log.format", "LOG_FORMAT", "log-format", "ログ形式 (text/json)", &c.Log.Format),
+		strSetting("tracing.exporter", "TRACING_EXPORTER", "tracing", "トレースの送り先 (none/stdout/otlp)", &c.Tracing.Exporter),
+		strSetting("tracing.otlp_endpoint", "OTLP_ENDPOINT", "otlp-endpoint", "OTLP/HTTP の送り先 URL", &c.Tracing.OTLPEndpoint),
+		secret(listSetting("tracing.otlp_headers", "OTLP_HEADERS", "", "", &c.Tracing.OTLPHeaders)),
+		floatSetting("tracing.sample_ratio", "TRACING_SAMPLE_RATIO", "tracing-sample-ratio", "新しいトレースを記録する割合 (0〜1)", &c.Tracing.SampleRatio),
+		strSetting("tracing.service_name", "TRACING_SERVICE_NAME", "tracing-service-name", "トレースのサービス名", &c.Tracing.ServiceName),
+	}
+}
+
+// Load はデフォルト → 設定ファイル → 環境変数 → フラグ の順に重ねて設定を作り、検証する。
+// args はサブコマンド名を除いたコマンドライン引数。フラグ以外の残りの引数も返す。
+// 戻り値の warnings は起動は続けられるが注意が必要な点（開発用鍵の使用など）。
+func Load(name string, args []string) (cfg *Config, rest []string, warnings []string, err error) {
+	cfg = Default()
+	items := cfg.settings()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	configPath := fs.String("config", os.Getenv("APP_CONFIG"), "設定ファイル(TOML)のパス")
+	flagValues := make(map[string]*string)
+	for _, it := range items {
+		if it.flag == "" {
+			continue
+		}
+		flagValues[it.flag] = fs.String(it.flag, "", it.usage+" ("+it.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// ---- 設定ファイル ----
+	if *configPath != "" {
+		md, err := toml.DecodeFile(*configPath, cfg)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("config: %s: %w", *configPath, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, 0, len(undecoded))
+			for _, k := range undecoded {
+				keys = append(keys, k.String())
+			}
+			return nil, nil, nil, fmt.Errorf("config: %s: unknown keys: %s", *configPath, strings.Join(keys, ", "))
+		}
+	}
+
+	// ---- 環境変数 ----
+	for _, it := range items {
+		if v, ok := os.LookupEnv(it.env); ok && v != "" {
+			if err := it.set(v); err != nil {
+				return nil, nil, nil, fmt.Errorf("config: %s: %w", it.env, err)
+			}
+		}
+	}
+
+	// ---- フラグ（明示的に指定されたものだけ）----
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, it := range items {
+			if it.flag == f.Name {
+				if err := it.set(*flagValues[f.Name]); err != nil && flagErr == nil {
+					flagErr = fmt.Errorf("config: -%s: %w", f.Name, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, nil, nil, flagErr
+	}
+
+	// ---- DBホストをファイルから（旧 ip.txt 運用）----
+	if cfg.DB.HostFile != "" {
+		data, err := os.ReadFile(cfg.DB.HostFile)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("config: db.host_file: %w", err)
+		}
+		cfg.DB.Host = strings.TrimSpace(string(data))
+	}
+
+	warnings, err = cfg.validate()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return cfg, fs.Args(), warnings, nil
+}
+
+// validate は設定値をすべて検証し、問題をまとめて返す。
+func (c *Config) validate() (warnings []string, err error) {
+	var errs []error
+	fail := func(format string, a ...any) { errs = append(errs, fmt.Errorf("config: "+format, a...)) }
+
+	if c.Env != EnvDevelopment && c.Env != EnvProduction {
+		fail("env must be %q or %q (got %q)", EnvDevelopment, EnvProduction, c.Env)
+	}
+	prod := c.Env == EnvProduction
+
+	// server
+	if _, port, err := net.SplitHostPort(c.Server.ListenAddr); err != nil || port == "" {
+		fail("server.listen_addr %q must be host:port", c.Server.ListenAddr)
+	}
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		fail("server.tls_cert_file and server.tls_key_file must be set together")
+	}
+	for _, f := range []string{c.Server.TLSCertFile, c.Server.TLSKeyFile} {
+		if f == "" {
+			continue
+		}
+		if _, err := os.Stat(f); err != nil {
+			fail("tls file: %v", err)
+		}
+	}
+	if len(c.Server.CORSOrigins) == 0 {
+		fail("server.cors_origins must not be empty (use \"*\" to allow all)")
+	}
+	for _, o := range c.Server.CORSOrigins {
+		if o == "*" {
+			if prod {
+				warnings = append(warnings, "server.cors_origins allows every origin")
+			}
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			fail("server.cors_origins: %q must look like scheme://host[:port]", o)
+		}
+	}
+
+	// db
+	if c.DB.Host == "" {
+		fail("db.host is required")
+	}
+	if c.DB.Port < 1 || c.DB.Port > 65535 {
+		fail("db.port %d out of range", c.DB.Port)
+	}
+	if c.DB.User == "" {
+		fail("db.user is required")
+	}
+	if c.DB.Name == "" {
+		fail("db.name is required")
+	}
+	if c.DB.Password == "" {
+		if prod {
+			fail("db.password is required in production (DB_PASSWORD)")
+		} else {
+			warnings = append(warnings, "db.password is empty")
+		}
+	}
+
+	// jwt
+	if len(c.JWT.Keys) == 0 {
+		if prod {
+			fail("jwt.keys is required in production (JWT_KEYS)")
+		} else {
+			warnings = append(warnings, "jwt.keys is not set; using the development signing key")
+			c.JWT.Keys = map[string]string{DevKeyID: devSecret}
+		}
+	}
+	for kid, key := range c.JWT.Keys {
+		if kid == "" || key == "" {
+			fail("jwt.keys: empty kid or key")
+		} else if len(key) < 32 && kid != DevKeyID {
+			warnings = append(warnings, fmt.Sprintf("jwt.keys[%s] is shorter than 32 bytes", kid))
+		}
+	}
+	if c.JWT.ActiveKID == "" && len(c.JWT.Keys) == 1 {
+		for kid := range c.JWT.Keys {
+			c.JWT.ActiveKID = kid
+		}
+	}
+	if _, ok := c.JWT.Keys[c.JWT.ActiveKID]; !ok {
+		fail("jwt.active_kid %q is not in jwt.keys", c.JWT.ActiveKID)
+	}
+
+	// durations
+	for _, d := range []struct {
+		key string
+		v   time.Duration
+	}{
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"jwt.access_token_ttl", c.JWT.AccessTokenTTL},
+		{"jwt.refresh_token_ttl", c.JWT.RefreshTokenTTL},
+		{"timers.action", c.Timers.Action},
+		{"timers.ws_ping_interval", c.Timers.WSPingInterval},
+		{"timers.ws_read_timeout", c.Timers.WSReadTimeout},
+		{"backplane.owner_ttl", c.Backplane.OwnerTTL},
+	} {
+		if d.v <= 0 {
+			fail("%s must be positive", d.key)
+		}
+	}
+	if c.JWT.RefreshTokenTTL <= c.JWT.AccessTokenTTL {
+		fail("jwt.refresh_token_ttl must be longer than jwt.access_token_ttl")
+	}
+	if c.Timers.WSReadTimeout <= c.Timers.WSPingInterval {
+		fail("timers.ws_read_timeout must be longer than timers.ws_ping_interval")
+	}
+
+	// chips
+	for _, ch := range []struct {
+		key string
+		v   int
+	}{
+		{"chips.solo_start", c.Chips.SoloStart},
+		{"chips.multi_start", c.Chips.MultiStart},
+		{"chips.table_start", c.Chips.TableStart},
+	} {
+		if ch.v < 0 {
+			fail("%s must not be negative", ch.key)
+		}
+	}
+
+	// profile
+	if c.Profile.NameChangeCooldown < 0 {
+		fail("profile.name_change_cooldown must not be negative")
+	}
+
+	// rate_limit
+	for _, rl := range []struct {
+		key   string
+		rate  float64
+		burst int
+	}{
+		{"rate_limit.api", c.RateLimit.APIRate, c.RateLimit.APIBurst},
+		{"rate_limit.create_room", c.RateLimit.CreateRoomRate, c.RateLimit.CreateRoomBurst},
+		{"rate_limit.create_account", c.RateLimit.CreateAccountRate, c.RateLimit.CreateAccountBurst},
+		{"rate_limit.ws_message", c.RateLimit.WSMessageRate, c.RateLimit.WSMessageBurst},
+	} {
+		if rl.rate < 0 {
+			fail("%s_rate must not be negative", rl.key)
+		}
+		if rl.rate > 0 && rl.burst < 1 {
+			fail("%s_burst must be at least 1", rl.key)
+		}
+	}
+	if c.RateLimit.WSMaxMessageBytes < 1024 {
+		fail("rate_limit.ws_max_message_bytes must be at least 1024")
+	}
+
+	// backplane
+	switch c.Backplane.Driver {
+	case BackplaneLocal:
+	case BackplaneRedis:
+		if _, port, err := net.SplitHostPort(c.Backplane.RedisAddr); err != nil || port == "" {
+			fail("backplane.redis_addr %q must be host:port", c.Backplane.RedisAddr)
+		}
+	default:
+		fail("backplane.driver must be %q or %q (got %q)", BackplaneLocal, BackplaneRedis, c.Backplane.Driver)
+	}
+	if c.Backplane.OwnerTTL > 0 && c.Backplane.OwnerTTL < 3*time.Second {
+		fail("backplane.owner_ttl must be at least 3s")
+	}
+	if c.Backplane.InstanceID == "" {
+		host, _ := os.Hostname()
+		if host == "" {
+			host = "api"
+		}
+		c.Backplane.InstanceID = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	// log
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		fail("log.level must be one of debug, info, warn, error (got %q)", c.Log.Level)
+	}
+	if c.Log.Format != LogFormatText && c.Log.Format != LogFormatJSON {
+		fail("log.format must be %q or %q (got %q)", LogFormatText, LogFormatJSON, c.Log.Format)
+	}
+
+	// tracing
+	switch c.Tracing.Exporter {
+	case TracingNone, TracingStdout:
+	case TracingOTLP:
+		if u, err := url.Parse(c.Tracing.OTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("tracing.otlp_endpoint %q must be an http(s) URL", c.Tracing.OTLPEndpoint)
+		}
+	default:
+		fail("tracing.exporter must be %q, %q or %q (got %q)", TracingNone, TracingStdout, TracingOTLP, c.Tracing.Exporter)
+	}
+	for _, h := range c.Tracing.OTLPHeaders {
+		if k, _, ok := strings.Cut(h, "="); !ok || strings.TrimSpace(k) == "" {
+			fail("tracing.otlp_headers: entries must be Key=Value")
+		}
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		fail("tracing.sample_ratio must be between 0 and 1")
+	}
+	if c.Tracing.Exporter != TracingNone && c.Tracing.ServiceName == "" {
+		fail("tracing.service_name is required")
+	}
+
+	return warnings, errors.Join(errs...)
+}
+
+// Summary は起動ログ用に、秘密情報を伏せた設定一覧を返す。
+func (c *Config) Summary() []string {
+	items := c.settings()
+	lines := make([]string, 0, len(items))
+	for _, it := range items {
+		v := it.get()
+		if it.secret && v != "" {
+			v = "****"
+		}
+		lines = append(lines, it.key+" = "+v)
+	}
+	return lines
+}
+
+// JWT鍵を []byte に変換（auth.NewIssuer 用）
+func (c *JWTConfig) KeyBytes() map[string][]byte {
+	keys := make(map[string][]byte, len(c.Keys))
+	for kid, key := range c.Keys {
+		keys[kid] = []byte(key)
+	}
+	return keys
+}
+
+// ---- setting のコンストラクタ ----
+
+func secret(s setting) setting {
+	s.secret = true
+	return s
+}
+
+func strSetting(key, env, flag, usage string, p *string) setting {
+	return setting{key: key, env: env, flag: flag, usage: usage,
+		get: func() string { return *p },
+		set: func(v string) error { *p = v; return nil },
+	}
+}
+
+func intSetting(key, env, flag, usage string, p *int) setting {
+	return setting{key: key, env: env, flag: flag, usage: usage,
+		get: func() string { return strconv.Itoa(*p) },
+		set: func(v string) error {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				return err
+			}
+			*p = n
+			return nil
+		},
+	}
+}
+
+func floatSetting(key, env, flag, usage string, p *float64) setting {
+	return setting{key: key, env: env, flag: flag, usage: usage,
+		get: func() string { return strconv.FormatFloat(*p, 'g', -1, 64) },
+		set: func(v string) error {
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return err
+			}
+			*p = f
+			return nil
+		},
+	}
+}
+
+func durSetting(key, env, flag, usage string, p *time.Duration) setting {
+	return setting{key: key, env: env, flag: flag, usage: usage,
+		get: func() string { return p.String() },
+		set: func(v string) error {
+			d, err := time.ParseDuration(strings.TrimSpace(v))
+			if err != nil {
+				return err
+			}
+			*p = d
+			return nil
+		},
+	}
+}
+
+func listSetting(key, env, flag, usage string, p *[]string) setting {
+	return setting{key: key, env: env, flag: flag, usage: usage,
+		get: func() string { return strings.Join(*p, ",") },
+		set: func(v string) error {
+			var list []string
+			for _, s := range strings.Split(v, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					list = append(list, s)
+				}
+			}
+			*p = list
+			return nil
+		},
+	}
+}
+
+// JWT鍵は "kid1=secret1,kid2=secret2" 形式（鍵に "=" が含まれてもよいよう最初の "=" で区切る）。
+// 一覧表示では kid だけを出す（get 側で伏せるので secret 指定は不要）。
+func keysSetting(key, env string, p *map[string]string) setting {
+	return setting{key: key, env: env,
+		get: func() string {
+			kids := make([]string, 0, len(*p))
+			for kid := range *p {
+				kids = append(kids, kid+"=****")
+			}
+			sort.Strings(kids)
+			return strings.Join(kids, ",")
+		},
+		set: func(v string) error {
+			keys := make(map[string]string)
+			for _, part := range strings.Split(v, ",") {
+				part = strings.TrimSpace(part)
+				if part == "" {
+					continue
+				}
+				kid, secret, ok := strings.Cut(part, "=")
+				kid = strings.TrimSpace(kid)
+				if !ok || kid == "" || secret == "" {
+					return fmt.Errorf("invalid key entry (want kid=secret)")
+				}
+				if _, dup := keys[kid]; dup {
+					return fmt.Errorf("duplicate kid %q", kid)
+				}
+				keys[kid] = secret
+			}
+			*p = keys
+			return nil
+		},
+	}
+}
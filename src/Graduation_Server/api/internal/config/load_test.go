@@ -0,0 +1,211 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv は設定に使う環境変数をテストの間だけ空にする（空の値は Load が無視する）
+func clearEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("APP_CONFIG", "")
+	for _, it := range Default().settings() {
+		t.Setenv(it.env, "")
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// デフォルト < 設定ファイル < 環境変数 < フラグ の順に上書きされる
+func TestLoadLayering(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "api.toml", `
+[db]
+host = "file-host"
+port = 3307
+user = "file-user"
+password = "file-password"
+
+[timers]
+action = "20s"
+`)
+	t.Setenv("DB_PORT", "3308")
+	t.Setenv("DB_USER", "env-user")
+
+	cfg, rest, _, err := Load("api", []string{"-config", path, "-db-user", "flag-user", "serve", "extra"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		key       string
+		got, want any
+	}{
+		{"db.name (default)", cfg.DB.Name, "graduationdb"},
+		{"db.host (file)", cfg.DB.Host, "file-host"},
+		{"db.password (file)", cfg.DB.Password, "file-password"},
+		{"timers.action (file)", cfg.Timers.Action, 20 * time.Second},
+		{"db.port (env over file)", cfg.DB.Port, 3308},
+		{"db.user (flag over env)", cfg.DB.User, "flag-user"},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.key, c.got, c.want)
+		}
+	}
+	if strings.Join(rest, " ") != "serve extra" {
+		t.Errorf("rest = %v", rest)
+	}
+
+	// APP_CONFIG でもファイルを指定でき、db.host_file は db.host より優先する
+	hostFile := writeFile(t, "ip.txt", " 10.0.0.7\n")
+	t.Setenv("APP_CONFIG", path)
+	t.Setenv("DB_HOST_FILE", hostFile)
+	cfg, _, _, err = Load("api", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DB.Host != "10.0.0.7" || cfg.DB.User != "env-user" {
+		t.Errorf("APP_CONFIG + host file: host = %q, user = %q", cfg.DB.Host, cfg.DB.User)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		file string // 空なら設定ファイル無し
+		env  map[string]string
+		args []string
+		want string // エラーに含まれる文字列
+	}{
+		{"unknown key in file", "[db]\nhots = \"x\"\n", nil, nil, "unknown keys: db.hots"},
+		{"bad env value", "", map[string]string{"DB_PORT": "abc"}, nil, "DB_PORT"},
+		{"bad flag value", "", nil, []string{"-action-timer", "soon"}, "-action-timer"},
+		{"unknown flag", "", nil, []string{"-no-such-flag"}, "no-such-flag"},
+		{"missing host file", "", map[string]string{"DB_HOST_FILE": "/nonexistent/ip.txt"}, nil, "db.host_file"},
+		{"invalid after layering", "", map[string]string{"LOG_FORMAT": "xml"}, nil, "log.format"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, "api.toml", tt.file)}, args...)
+			}
+			_, _, _, err := Load("api", args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		mutate      func(c *Config)
+		wantErrs    []string // エラーに含まれる文字列（空なら成功）
+		wantWarning string   // 警告に含まれる文字列
+	}{
+		{
+			name:        "development defaults fall back to the dev key",
+			mutate:      func(c *Config) {},
+			wantWarning: "development signing key",
+		},
+		{
+			name:        "empty db password is only a warning in development",
+			mutate:      func(c *Config) {},
+			wantWarning: "db.password is empty",
+		},
+		{
+			name: "production requires db password and jwt keys",
+			mutate: func(c *Config) {
+				c.Env = EnvProduction
+			},
+			wantErrs: []string{"db.password is required in production", "jwt.keys is required in production"},
+		},
+		{
+			name: "production with secrets",
+			mutate: func(c *Config) {
+				c.Env = EnvProduction
+				c.DB.Password = "secret"
+				c.JWT.Keys = map[string]string{"k1": strings.Repeat("x", 32)}
+				c.Server.CORSOrigins = []string{"https://example.com"}
+			},
+		},
+		{
+			name: "active kid must name a key",
+			mutate: func(c *Config) {
+				c.JWT.Keys = map[string]string{"k1": strings.Repeat("x", 32), "k2": strings.Repeat("y", 32)}
+			},
+			wantErrs: []string{`jwt.active_kid "" is not in jwt.keys`},
+		},
+		{
+			name:        "short key is a warning",
+			mutate:      func(c *Config) { c.JWT.Keys = map[string]string{"k1": "short"} },
+			wantWarning: "jwt.keys[k1] is shorter than 32 bytes",
+		},
+		{
+			name: "every problem is reported together",
+			mutate: func(c *Config) {
+				c.Env = "staging"
+				c.Server.ListenAddr = "9090"
+				c.JWT.RefreshTokenTTL = c.JWT.AccessTokenTTL
+				c.Backplane.Driver = "kafka"
+				c.Tracing.SampleRatio = 2
+			},
+			wantErrs: []string{
+				"env must be",
+				"server.listen_addr",
+				"jwt.refresh_token_ttl must be longer",
+				"backplane.driver",
+				"tracing.sample_ratio",
+			},
+		},
+		{
+			name: "redis backplane needs host:port",
+			mutate: func(c *Config) {
+				c.Backplane.Driver = BackplaneRedis
+				c.Backplane.RedisAddr = "localhost"
+			},
+			wantErrs: []string{"backplane.redis_addr"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			tt.mutate(c)
+			warnings, err := c.validate()
+			if len(tt.wantErrs) == 0 && err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			for _, want := range tt.wantErrs {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("err = %v, want it to mention %q", err, want)
+				}
+			}
+			if tt.wantWarning != "" && !strings.Contains(strings.Join(warnings, "\n"), tt.wantWarning) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.wantWarning)
+			}
+		})
+	}
+
+	// 鍵が1つなら active_kid はそれになり、instance_id は埋まる
+	c := Default()
+	c.JWT.Keys = map[string]string{"only": strings.Repeat("x", 32)}
+	if _, err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if c.JWT.ActiveKID != "only" || c.Backplane.InstanceID == "" {
+		t.Fatalf("active_kid = %q, instance_id = %q", c.JWT.ActiveKID, c.Backplane.InstanceID)
+	}
+}
@@ -0,0 +1,240 @@
+// パスワード変更・再設定API
+
+package handlers
+
+import (
+	"api/internal/middleware"
+	"api/internal/response"
+	"api/internal/store"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// 一度に発行するリカバリーコードの数
+	recoveryCodeCount = 8
+	// 運営発行の再設定コードの有効期間
+	adminResetCodeTTL = 24 * time.Hour
+)
+
+// current_password: 現在のパスワード
+// new_password:     新しいパスワード
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// name:         アカウント名
+// code:         リカバリーコード または 運営発行の再設定コード
+// new_password: 新しいパスワード
+type ResetPasswordRequest struct {
+	Name        string `json:"name"`
+	Code        string `json:"code"`
+	NewPassword string `json:"new_password"`
+}
+
+// recovery_codes: 発行したコード（平文はこの応答でしか返さない）
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ChangePasswordHandler は現在のパスワードを確認してから新しいパスワードに変更する。
+// 変更後は他の端末のセッションをすべて失効させ、この端末には新しいトークンを返す。
+func ChangePasswordHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		userID := middleware.GetUserID(r)
+		if userID == 0 {
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
+			return
+		}
+
+		var req ChangePasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.BadJSON(w, r)
+			return
+		}
+		if strings.TrimSpace(req.NewPassword) == "" {
+			response.Invalid(w, r, response.Field("new_password", response.FieldRequired))
+			return
+		}
+
+		user, err := repo.Users().GetByID(userID)
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		if user.PasswordHash == "" {
+			// ゲストは /api/account/upgrade で本登録する
+			response.Fail(w, r, http.StatusBadRequest, response.CodeGuestAccount)
+			return
+		}
+
+		// 現在のパスワード確認もログインと同じ制限にかける（トークン盗用時の総当たり対策）
+		ip := clientIP(r)
+		if wait := passwordRetryAfter(userID, ip); wait > 0 {
+			writeTooManyAttempts(w, r, wait)
+			return
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+			recordPasswordFailure(userID, ip)
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeInvalidCredentials)
+			return
+		}
+		recordPasswordSuccess(userID)
+
+		newHash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		if err := repo.Users().SetPassword(userID, string(newHash)); err != nil {
+			slog.ErrorContext(r.Context(), "password update failed", "err", err)
+			response.Internal(w, r)
+			return
+		}
+
+		writeSessionReset(w, r, repo, userID)
+	}
+}
+
+// IssueRecoveryCodesHandler はパスワードを忘れたとき用のリカバリーコードを発行する。
+// 平文は今回のレスポンスでしか返さない。再発行すると未使用の古いコードは無効になる。
+func IssueRecoveryCodesHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		userID := middleware.GetUserID(r)
+		if userID == 0 {
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
+			return
+		}
+
+		codes := make([]string, 0, recoveryCodeCount)
+		hashes := make([]string, 0, recoveryCodeCount)
+		for i := 0; i < recoveryCodeCount; i++ {
+			code, err := newReadableCode()
+			if err != nil {
+				response.Internal(w, r)
+				return
+			}
+			codes = append(codes, code)
+			hashes = append(hashes, hashToken(normalizeReadableCode(code)))
+		}
+		if err := repo.Tokens().ReplaceRecoveryCodes(userID, hashes); err != nil {
+			slog.ErrorContext(r.Context(), "recovery codes insert failed", "err", err)
+			response.Internal(w, r)
+			return
+		}
+
+		response.OK(w, r, RecoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+// ResetPasswordHandler はリカバリーコード（または運営発行の再設定コード）で
+// パスワードを再設定する。成功したら全端末のセッションを失効させ、新しいトークンを返す。
+func ResetPasswordHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		var req ResetPasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.BadJSON(w, r)
+			return
+		}
+		var missing []response.FieldError
+		if req.Name == "" {
+			missing = append(missing, response.Field("name", response.FieldRequired))
+		}
+		if req.Code == "" {
+			missing = append(missing, response.Field("code", response.FieldRequired))
+		}
+		if strings.TrimSpace(req.NewPassword) == "" {
+			missing = append(missing, response.Field("new_password", response.FieldRequired))
+		}
+		if len(missing) > 0 {
+			response.Invalid(w, r, missing...)
+			return
+		}
+
+		// ログインと同じ制限を共有（コードの総当たり対策）
+		ip := clientIP(r)
+		if wait := loginRetryAfter(req.Name, ip); wait > 0 {
+			writeTooManyAttempts(w, r, wait)
+			return
+		}
+
+		newHash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+
+		userID, _, err := repo.Users().Credentials(req.Name)
+		if err != nil && err != store.ErrNotFound {
+			response.Internal(w, r)
+			return
+		}
+		reset := false
+		if err == nil {
+			code := hashToken(normalizeReadableCode(req.Code))
+			reset, err = repo.Tokens().ResetPassword(userID, code, string(newHash))
+			if err != nil {
+				slog.ErrorContext(r.Context(), "password reset failed", "user_id", userID, "err", err)
+				response.Internal(w, r)
+				return
+			}
+		}
+		if !reset {
+			// 名前の有無が分からないよう、失敗理由は1つにまとめる
+			recordLoginFailure(req.Name, ip)
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeInvalidCredentials)
+			return
+		}
+		recordLoginSuccess(req.Name)
+
+		writeSessionReset(w, r, repo, userID)
+	}
+}
+
+// IssueAdminResetCode は運営がサポート対応で使う一回限りの再設定コードを発行する。
+// サーバー上で `api reset-code <name>` として実行し、表示されたコードを本人に伝える。
+func IssueAdminResetCode(repo store.Store, name string) (string, time.Time, error) {
+	userID, _, err := repo.Users().Credentials(name)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	code, err := newReadableCode()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(adminResetCodeTTL)
+	if err := repo.Tokens().CreateAdminResetCode(userID, hashToken(normalizeReadableCode(code)), expiresAt); err != nil {
+		return "", time.Time{}, err
+	}
+	return code, expiresAt, nil
+}
+
+// 全セッションを失効させてから、この端末用のトークンを発行して返す
+func writeSessionReset(w http.ResponseWriter, r *http.Request, repo store.Store, userID int64) {
+	if err := repo.Tokens().RevokeAll(userID); err != nil {
+		slog.ErrorContext(r.Context(), "revoke sessions failed", "user_id", userID, "err", err)
+		response.Internal(w, r)
+		return
+	}
+	pair, err := issueTokenPair(r.Context(), userID)
+	if errors.Is(err, errAccountBanned) {
+		response.Fail(w, r, http.StatusForbidden, response.CodeAccountBanned)
+		return
+	}
+	if err != nil {
+		response.Internal(w, r)
+		return
+	}
+
+	response.OK(w, r, pair.response())
+}
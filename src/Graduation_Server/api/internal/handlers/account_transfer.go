@@ -0,0 +1,149 @@
+// 機種変更用の引き継ぎコードAPI
+
+package handlers
+
+import (
+	"api/internal/middleware"
+	"api/internal/response"
+	"api/internal/store"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// 引き継ぎコードの有効期間
+	transferCodeTTL = 24 * time.Hour
+	// 読み間違えやすい文字（0/O, 1/I/L）を除いた文字セット
+	readableCodeCharset = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	// コード長（表示時は4文字ごとにハイフン区切り）
+	readableCodeLength = 12
+)
+
+// transfer_code: 旧端末で表示されたコード（ハイフン・空白・大小文字は無視）
+type RedeemTransferCodeRequest struct {
+	TransferCode string `json:"transfer_code"`
+}
+
+// transfer_code: 旧端末に表示するコード / expires_at: 有効期限（Unix 秒）
+type TransferCodeResponse struct {
+	TransferCode string `json:"transfer_code"`
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+// 引き継ぎ先の端末で使うトークン（ユーザーIDと名前はそのまま）
+type TransferLoginResponse struct {
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+	TokenResponse
+}
+
+// IssueTransferCodeHandler は旧端末で表示する一回限りの引き継ぎコードを発行する。
+// 新しいコードを発行すると、それまでの未使用コードは無効になる。
+func IssueTransferCodeHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		userID := middleware.GetUserID(r)
+		if userID == 0 {
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
+			return
+		}
+
+		code, err := newReadableCode()
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		expiresAt := time.Now().Add(transferCodeTTL)
+		if err := repo.Tokens().CreateTransferCode(userID, hashToken(normalizeReadableCode(code)), expiresAt); err != nil {
+			slog.ErrorContext(r.Context(), "transfer code insert failed", "err", err)
+			response.Internal(w, r)
+			return
+		}
+
+		response.OK(w, r, TransferCodeResponse{TransferCode: code, ExpiresAt: expiresAt.Unix()})
+	}
+}
+
+// RedeemTransferCodeHandler は新端末で引き継ぎコードを入力してもらい、
+// 同じ users.id のトークンを発行し直す。
+// 旧端末のセッションはすべて失効させる（アカウントが新端末へ移る）。
+func RedeemTransferCodeHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		var req RedeemTransferCodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.BadJSON(w, r)
+			return
+		}
+		code := normalizeReadableCode(req.TransferCode)
+		if len(code) != readableCodeLength {
+			response.Invalid(w, r, response.Field("transfer_code", response.FieldInvalid))
+			return
+		}
+
+		userID, err := repo.Tokens().RedeemTransferCode(hashToken(code))
+		if err == store.ErrNotFound {
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeInvalidCode)
+			return
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "transfer code redeem failed", "err", err)
+			response.Internal(w, r)
+			return
+		}
+
+		if err := repo.Tokens().RevokeAll(userID); err != nil {
+			slog.ErrorContext(r.Context(), "revoke old sessions failed", "user_id", userID, "err", err)
+			response.Internal(w, r)
+			return
+		}
+		pair, err := issueTokenPair(r.Context(), userID)
+		if errors.Is(err, errAccountBanned) {
+			response.Fail(w, r, http.StatusForbidden, response.CodeAccountBanned)
+			return
+		}
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		subject, err := repo.Tokens().UserInfo(userID)
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+
+		response.OK(w, r, TransferLoginResponse{UserID: userID, Name: subject.Name, TokenResponse: pair.response()})
+	}
+}
+
+// "ABCD-EFGH-JKMN" 形式のコードを作る（引き継ぎコード・再設定コード共通）
+func newReadableCode() (string, error) {
+	b := make([]byte, readableCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for i, v := range b {
+		if i > 0 && i%4 == 0 {
+			sb.WriteByte('-')
+		}
+		// 256 は 31 で割り切れないので僅かに偏るが、コードの推測しにくさには影響しない程度
+		sb.WriteByte(readableCodeCharset[int(v)%len(readableCodeCharset)])
+	}
+	return sb.String(), nil
+}
+
+// 入力ゆれ（ハイフン・空白・小文字）を吸収する
+func normalizeReadableCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
@@ -0,0 +1,100 @@
+// ゲストアカウント本登録API
+
+package handlers
+
+import (
+	"api/internal/middleware"
+	"api/internal/models"
+	"api/internal/response"
+	"api/internal/store"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// name:     本登録後の名前（省略時は今の名前のまま）
+// password: 設定するパスワード（必須）
+type UpgradeGuestRequest struct {
+	Name     string `json:"name"`
+	Password string `json:"password"`
+}
+
+// 本登録後の名前と、名前を反映した新しいトークン
+type UpgradeGuestResponse struct {
+	Name string `json:"name"`
+	TokenResponse
+}
+
+// UpgradeGuestHandler は auto_flg で作ったゲストアカウントにパスワードを設定し、
+// 名前＋パスワードでログインできる通常アカウントにする。
+// users.id はそのままなので、チップや設定はすべて引き継がれる。
+func UpgradeGuestHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		userID := middleware.GetUserID(r)
+		if userID == 0 {
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
+			return
+		}
+
+		var req UpgradeGuestRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.BadJSON(w, r)
+			return
+		}
+		if strings.TrimSpace(req.Password) == "" {
+			response.Invalid(w, r, response.Field("password", response.FieldRequired))
+			return
+		}
+
+		user, err := repo.Users().GetByID(userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "guest check failed", "err", err)
+			response.Internal(w, r)
+			return
+		}
+		if user.PasswordHash != "" {
+			response.Fail(w, r, http.StatusConflict, response.CodeAlreadyRegistered)
+			return
+		}
+
+		// 名前の変更（省略時は現在の名前）
+		name := strings.TrimSpace(req.Name)
+		if name == "" {
+			name = user.Name
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		upgraded, err := repo.Users().UpgradeGuest(userID, name, string(hash))
+		if errors.Is(err, models.ErrNameTaken) {
+			response.NG(w, r, http.StatusConflict, response.CodeNameTaken)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "guest upgrade failed", "err", err)
+			response.Internal(w, r)
+			return
+		}
+		if !upgraded {
+			response.Fail(w, r, http.StatusConflict, response.CodeAlreadyRegistered)
+			return
+		}
+
+		// 名前が変わっている可能性があるので、新しいトークンを返す
+		pair, err := issueTokenPair(r.Context(), userID)
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+
+		response.OK(w, r, UpgradeGuestResponse{Name: name, TokenResponse: pair.response()})
+	}
+}
@@ -0,0 +1,597 @@
+// 運営API（/api/admin/...。role=admin のトークンのみ、middleware.AdminMiddleware で保護）
+//
+// MySQL を手で書き換えていた作業（モード・ゲームの公開切り替え、チップの補填、壊れたルームの後始末）を
+// API から行えるようにする。操作はすべて admin_audit_log に記録する。
+
+package handlers
+
+import (
+	"api/internal/middleware"
+	"api/internal/models"
+	"api/internal/response"
+	"api/internal/store"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// 台帳・操作ログの1回の取得件数
+const (
+	adminDefaultLimit = 50
+	adminMaxLimit     = 500
+)
+
+// ---- ルーム ----
+
+// 一覧の1件
+type AdminRoomSummary struct {
+	RoomCode   string `json:"room_code"`
+	Status     string `json:"status"`
+	GameTypeID int    `json:"game_type_id"`
+	MaxPlayers int    `json:"max_players"`
+	Players    int    `json:"players"`
+	OwnerID    int64  `json:"owner_id"`
+	CreatedAt  string `json:"created_at"`
+	TableHere  bool   `json:"table_here"` // ブラックジャック卓の状態をこのインスタンスが持っているか
+}
+
+// 1ルームの詳細。Table は卓の状態（bjRoomStates）で、このインスタンスが持っていなければ null
+type AdminRoomDetail struct {
+	AdminRoomSummary
+	Members   []models.RoomUser `json:"members"`
+	RoomConns int               `json:"room_connections"` // このインスタンスのルームWS接続数
+	TableConn int               `json:"table_connections"`
+	Table     *AdminTableState  `json:"table"`
+}
+
+type AdminTableState struct {
+	DealerID int64              `json:"dealer_id"`
+	Players  []BJBetPlayerState `json:"players"` // 席順
+	Seq      int64              `json:"seq"`
+}
+
+// 運営がルームを閉じたときに参加者へ送る通知
+type RoomClosedNotice struct {
+	Type     string `json:"type"` // "room_closed"
+	RoomCode string `json:"room_code"`
+	Reason   string `json:"reason"`
+}
+
+// ---- 応答 ----
+
+type AdminRoomsResponse struct {
+	Rooms []AdminRoomSummary `json:"rooms"`
+}
+
+type AdminRoomDetailResponse struct {
+	Room AdminRoomDetail `json:"room"`
+}
+
+type AdminCloseRoomResponse struct {
+	RoomCode       string `json:"room_code"`
+	RemovedMembers int    `json:"removed_members"`
+}
+
+type AdminChipLedgerResponse struct {
+	UserID   int64                    `json:"user_id"`
+	SoloTip  int                      `json:"solo_tip"`
+	MultiTip int                      `json:"multi_tip"`
+	Ledger   []models.ChipLedgerEntry `json:"ledger"` // 新しい順
+}
+
+type AdminAdjustChipsResponse struct {
+	Entry *models.ChipLedgerEntry `json:"entry"`
+}
+
+// BAN / BAN 解除した相手
+type AdminUserResponse struct {
+	UserID int64 `json:"user_id"`
+}
+
+type AdminModesResponse struct {
+	Modes []models.Mode         `json:"modes"`
+	Types []models.ModeGameType `json:"types"`
+}
+
+type AdminPlayableResponse struct {
+	ID      int  `json:"id"`
+	CanPlay bool `json:"is_can_play"`
+}
+
+type AdminAuditResponse struct {
+	Entries []models.AuditEntry `json:"entries"` // 新しい順
+}
+
+// GET /api/admin/rooms : 終わっていないルームの一覧
+func AdminListRoomsHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		rooms, err := repo.Rooms().ListOpen()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "admin: list rooms failed", "err", err)
+			response.Internal(w, r)
+			return
+		}
+		list := make([]AdminRoomSummary, 0, len(rooms))
+		for _, room := range rooms {
+			list = append(list, adminRoomSummary(room.Room, room.Players))
+		}
+		response.OK(w, r, AdminRoomsResponse{Rooms: list})
+	}
+}
+
+// GET /api/admin/rooms/{room_code} : 参加者・接続数・卓の状態
+func AdminRoomDetailHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		roomCode := mux.Vars(r)["room_code"]
+		room, err := repo.Rooms().GetByCode(roomCode)
+		if err == store.ErrNotFound {
+			response.Fail(w, r, http.StatusNotFound, response.CodeRoomNotFound)
+			return
+		}
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		members, err := repo.RoomUsers().List(room.ID)
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+
+		detail := AdminRoomDetail{
+			AdminRoomSummary: adminRoomSummary(*room, len(members)),
+			Members:          members,
+		}
+		if detail.Members == nil {
+			detail.Members = []models.RoomUser{}
+		}
+		roomConnMu.Lock()
+		detail.RoomConns = len(roomConnections[roomCode])
+		roomConnMu.Unlock()
+
+		bjMu.Lock()
+		detail.TableConn = len(bjRoomConns[roomCode])
+		if st, ok := bjRoomStates[roomCode]; ok {
+			table := &AdminTableState{DealerID: st.DealerID, Seq: st.seq, Players: []BJBetPlayerState{}}
+			for _, id := range st.Seats {
+				if p, ok := st.Players[id]; ok {
+					table.Players = append(table.Players, *p)
+				}
+			}
+			detail.Table = table
+		}
+		bjMu.Unlock()
+
+		response.OK(w, r, AdminRoomDetailResponse{Room: detail})
+	}
+}
+
+type AdminCloseRoomRequest struct {
+	Reason string `json:"reason"`
+}
+
+// POST /api/admin/rooms/{room_code}/close : 参加者を全員外してルームを閉じる。
+// 卓の状態とスナップショットは捨て、接続中のWSには room_closed を送ってから切断する。
+func AdminCloseRoomHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		roomCode := mux.Vars(r)["room_code"]
+		var req AdminCloseRoomRequest
+		if !decodeAdminRequest(w, r, &req) {
+			return
+		}
+		reason := strings.TrimSpace(req.Reason)
+		if reason == "" {
+			response.Invalid(w, r, response.Field("reason", response.FieldRequired))
+			return
+		}
+
+		room, err := repo.Rooms().GetByCode(roomCode)
+		if err == store.ErrNotFound {
+			response.Fail(w, r, http.StatusNotFound, response.CodeRoomNotFound)
+			return
+		}
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		if room.Status == "closed" {
+			response.Fail(w, r, http.StatusConflict, response.CodeRoomClosed)
+			return
+		}
+		members, err := repo.RoomUsers().List(room.ID)
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		if err := repo.Rooms().Close(room.ID); err != nil {
+			slog.ErrorContext(r.Context(), "admin: close room failed", "room", roomCode, "err", err)
+			response.Internal(w, r)
+			return
+		}
+		forgetBJState(r.Context(), repo, roomCode)
+		closeRoomConnections(r.Context(), roomCode, RoomClosedNotice{Type: "room_closed", RoomCode: roomCode, Reason: reason})
+
+		memberIDs := make([]int64, 0, len(members))
+		for _, m := range members {
+			memberIDs = append(memberIDs, m.UserID)
+		}
+		recordAudit(r, repo, "room.close", roomCode, map[string]interface{}{
+			"reason":     reason,
+			"status":     room.Status,
+			"member_ids": memberIDs,
+		})
+		response.OK(w, r, AdminCloseRoomResponse{RoomCode: roomCode, RemovedMembers: len(members)})
+	}
+}
+
+func adminRoomSummary(room models.Room, players int) AdminRoomSummary {
+	bjMu.Lock()
+	_, here := bjRoomStates[room.RoomCode]
+	bjMu.Unlock()
+	return AdminRoomSummary{
+		RoomCode:   room.RoomCode,
+		Status:     room.Status,
+		GameTypeID: room.GameTypeID,
+		MaxPlayers: room.MaxPlayers,
+		Players:    players,
+		OwnerID:    room.OwnerID,
+		CreatedAt:  room.CreatedAt,
+		TableHere:  here,
+	}
+}
+
+// ---- チップ ----
+
+type AdminAdjustChipsRequest struct {
+	Wallet string `json:"wallet"` // "solo" / "multi"
+	Delta  int    `json:"delta"`  // 増やすなら正、減らすなら負
+	Reason string `json:"reason"`
+}
+
+// GET /api/admin/users/{user_id}/chips : 現在の残高と台帳（?limit=）
+func AdminChipLedgerHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		userID, ok := adminUserID(w, r)
+		if !ok {
+			return
+		}
+		limit, ok := adminLimit(w, r)
+		if !ok {
+			return
+		}
+		tip, err := repo.Tips().Get(userID)
+		if err == store.ErrNotFound {
+			response.Fail(w, r, http.StatusNotFound, response.CodeUserNotFound)
+			return
+		}
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		ledger, err := repo.Tips().Ledger(userID, limit)
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		if ledger == nil {
+			ledger = []models.ChipLedgerEntry{}
+		}
+		response.OK(w, r, AdminChipLedgerResponse{
+			UserID:   userID,
+			SoloTip:  tip.SoloTipCount,
+			MultiTip: tip.MultiTipCount,
+			Ledger:   ledger,
+		})
+	}
+}
+
+// POST /api/admin/users/{user_id}/chips : 残高を増減し、台帳に1行残す
+func AdminAdjustChipsHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		userID, ok := adminUserID(w, r)
+		if !ok {
+			return
+		}
+		var req AdminAdjustChipsRequest
+		if !decodeAdminRequest(w, r, &req) {
+			return
+		}
+		reason := strings.TrimSpace(req.Reason)
+		switch {
+		case req.Wallet != models.WalletSolo && req.Wallet != models.WalletMulti:
+			response.Invalid(w, r, response.Field("wallet", response.FieldInvalid))
+			return
+		case req.Delta == 0:
+			response.Invalid(w, r, response.Field("delta", response.FieldInvalid))
+			return
+		case reason == "":
+			response.Invalid(w, r, response.Field("reason", response.FieldRequired))
+			return
+		}
+
+		entry, err := repo.Tips().Adjust(models.ChipLedgerEntry{
+			UserID:    userID,
+			Wallet:    req.Wallet,
+			Delta:     req.Delta,
+			Reason:    reason,
+			AdminID:   middleware.GetUserID(r),
+			CreatedAt: time.Now(),
+		})
+		switch {
+		case err == store.ErrNotFound:
+			response.Fail(w, r, http.StatusNotFound, response.CodeUserNotFound)
+			return
+		case errors.Is(err, models.ErrInsufficientChips):
+			response.Fail(w, r, http.StatusConflict, response.CodeInsufficientChips)
+			return
+		case err != nil:
+			slog.ErrorContext(r.Context(), "admin: chip adjust failed", "target_user_id", userID, "err", err)
+			response.Internal(w, r)
+			return
+		}
+		if req.Delta > 0 {
+			recordChips("admin_granted", req.Delta)
+		} else {
+			recordChips("admin_removed", -req.Delta)
+		}
+
+		recordAudit(r, repo, "chips.adjust", strconv.FormatInt(userID, 10), map[string]interface{}{
+			"ledger_id":     entry.ID,
+			"wallet":        entry.Wallet,
+			"delta":         entry.Delta,
+			"balance_after": entry.BalanceAfter,
+			"reason":        reason,
+		})
+		response.OK(w, r, AdminAdjustChipsResponse{Entry: entry})
+	}
+}
+
+// ---- BAN ----
+
+type AdminBanRequest struct {
+	Reason string `json:"reason"`
+}
+
+// POST /api/admin/users/{user_id}/ban : ログイン・トークン発行を止め、発行済みトークンとWS接続も切る
+func AdminBanUserHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		userID, ok := adminUserID(w, r)
+		if !ok {
+			return
+		}
+		var req AdminBanRequest
+		if !decodeAdminRequest(w, r, &req) {
+			return
+		}
+		reason := strings.TrimSpace(req.Reason)
+		if reason == "" {
+			response.Invalid(w, r, response.Field("reason", response.FieldRequired))
+			return
+		}
+		if userID == middleware.GetUserID(r) {
+			response.Fail(w, r, http.StatusBadRequest, response.CodeBadRequest)
+			return
+		}
+
+		err := repo.Users().Ban(userID, reason, time.Now())
+		if err == store.ErrNotFound {
+			response.Fail(w, r, http.StatusNotFound, response.CodeUserNotFound)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "admin: ban failed", "target_user_id", userID, "err", err)
+			response.Internal(w, r)
+			return
+		}
+		// token_version を上げて発行済みのアクセストークン・リフレッシュトークンを無効にする
+		if err := repo.Tokens().RevokeAll(userID); err != nil {
+			slog.ErrorContext(r.Context(), "admin: revoke tokens failed", "target_user_id", userID, "err", err)
+			response.Internal(w, r)
+			return
+		}
+		disconnectUser(r.Context(), userID)
+
+		recordAudit(r, repo, "user.ban", strconv.FormatInt(userID, 10), map[string]interface{}{"reason": reason})
+		response.OK(w, r, AdminUserResponse{UserID: userID})
+	}
+}
+
+// POST /api/admin/users/{user_id}/unban
+func AdminUnbanUserHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		userID, ok := adminUserID(w, r)
+		if !ok {
+			return
+		}
+		err := repo.Users().Unban(userID)
+		if err == store.ErrNotFound {
+			response.Fail(w, r, http.StatusNotFound, response.CodeUserNotFound)
+			return
+		}
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		recordAudit(r, repo, "user.unban", strconv.FormatInt(userID, 10), nil)
+		response.OK(w, r, AdminUserResponse{UserID: userID})
+	}
+}
+
+// ---- モード・ゲーム種別 ----
+
+type AdminPlayableRequest struct {
+	CanPlay *bool `json:"is_can_play"`
+}
+
+// GET /api/admin/modes : modes と types の一覧
+func AdminListModesHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		modes, err := repo.Games().Modes()
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		types, err := repo.Games().ModeGameTypes()
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		response.OK(w, r, AdminModesResponse{Modes: modes, Types: types})
+	}
+}
+
+// POST /api/admin/modes/{id} : modes.is_can_play を切り替える
+func AdminSetModeHandler(repo store.Store) http.HandlerFunc {
+	return adminPlayableHandler(repo, "mode.toggle", func(repo store.Store, id int, canPlay bool) error {
+		return repo.Games().SetModePlayable(id, canPlay)
+	})
+}
+
+// POST /api/admin/types/{id} : types.is_can_play を切り替える
+func AdminSetGameTypeHandler(repo store.Store) http.HandlerFunc {
+	return adminPlayableHandler(repo, "type.toggle", func(repo store.Store, id int, canPlay bool) error {
+		return repo.Games().SetModeGameTypePlayable(id, canPlay)
+	})
+}
+
+func adminPlayableHandler(repo store.Store, action string, set func(store.Store, int, bool) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil || id <= 0 {
+			response.Invalid(w, r, response.Field("id", response.FieldInvalid))
+			return
+		}
+		var req AdminPlayableRequest
+		if !decodeAdminRequest(w, r, &req) {
+			return
+		}
+		if req.CanPlay == nil {
+			response.Invalid(w, r, response.Field("is_can_play", response.FieldRequired))
+			return
+		}
+		err = set(repo, id, *req.CanPlay)
+		if err == store.ErrNotFound {
+			response.Fail(w, r, http.StatusNotFound, response.CodeNotFound)
+			return
+		}
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		recordAudit(r, repo, action, strconv.Itoa(id), map[string]interface{}{"is_can_play": *req.CanPlay})
+		response.OK(w, r, AdminPlayableResponse{ID: id, CanPlay: *req.CanPlay})
+	}
+}
+
+// ---- 操作ログ ----
+
+// GET /api/admin/audit : 操作ログを新しい順に（?before=<id>&limit=）
+func AdminAuditLogHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		limit, ok := adminLimit(w, r)
+		if !ok {
+			return
+		}
+		var before int64
+		if s := r.URL.Query().Get("before"); s != "" {
+			v, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || v < 0 {
+				response.Invalid(w, r, response.Field("before", response.FieldInvalid))
+				return
+			}
+			before = v
+		}
+		entries, err := repo.Audit().List(before, limit)
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		if entries == nil {
+			entries = []models.AuditEntry{}
+		}
+		response.OK(w, r, AdminAuditResponse{Entries: entries})
+	}
+}
+
+// recordAudit は運営操作を admin_audit_log とログに残す。
+// 操作自体は済んでいるので、記録に失敗してもエラーログだけ出して応答は成功のまま返す。
+func recordAudit(r *http.Request, repo store.Store, action, target string, detail map[string]interface{}) {
+	adminID := middleware.GetUserID(r)
+	raw, err := json.Marshal(detail)
+	if err != nil || detail == nil {
+		raw = json.RawMessage("{}")
+	}
+	slog.InfoContext(r.Context(), "admin action", "action", action, "target", target, "detail", string(raw))
+	err = repo.Audit().Record(models.AuditEntry{
+		AdminID:   adminID,
+		Action:    action,
+		Target:    target,
+		Detail:    raw,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "admin: audit log write failed", "action", action, "target", target, "err", err)
+	}
+}
+
+// ---- ラウンドの経過（問い合わせ対応）----
+
+// GET /api/admin/rounds/{round_id} : 誰のラウンドでも経過を見られる（/history/rounds/{round_id} と同じ形）
+func AdminRoundReplayHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		id, ok := roundIDParam(w, r)
+		if !ok {
+			return
+		}
+		writeRoundReplay(w, r, repo, id)
+	}
+}
+
+// ---- 共通 ----
+
+func adminUserID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["user_id"], 10, 64)
+	if err != nil || id <= 0 {
+		response.Invalid(w, r, response.Field("user_id", response.FieldInvalid))
+		return 0, false
+	}
+	return id, true
+}
+
+func adminLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return adminDefaultLimit, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 || n > adminMaxLimit {
+		response.Invalid(w, r, response.Field("limit", response.FieldOutOfRange))
+		return 0, false
+	}
+	return n, true
+}
+
+func decodeAdminRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		response.BadJSON(w, r)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,179 @@
+// ブラックジャック卓の状態の永続化（クラッシュ後の復元用）
+//
+// bjRoomStates はメモリにしか無いので、状態が変わるたび（入室・ベット更新・ディーラー交代・返金）に
+// bj_room_snapshots へ保存する。起動時は RecoverBJRooms で
+//   - ルームがまだ使われていれば卓の状態を戻す（再接続すると続きから遊べる）
+//   - ルームが閉じていれば（消えていれば）スナップショットを消す。卓のチップは卓の中だけのものなので、
+//     乗っていたベットは捨てたことをログに残すだけ（アカウントのチップは動かさない）
+//   - ルームを読めなければ（DB の一時的な障害など）スナップショットは残して次の起動・担当の引き受けに任せる
+
+package handlers
+
+import (
+	"api/internal/models"
+	"api/internal/store"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// スナップショットの中身（JSON で保存）
+type bjSnapshot struct {
+	Players      []BJBetPlayerState `json:"players"` // 席順
+	DealerID     int64              `json:"dealer_id"`
+	DealerSeatNo int                `json:"dealer_seat_no"`
+	GameID       int64              `json:"game_id,omitempty"`
+	ModeID       int                `json:"mode_id,omitempty"`
+	Round        int                `json:"round,omitempty"`
+	Log          *models.RoundLog   `json:"log,omitempty"` // 進行中のラウンドの経過
+}
+
+// persistBJState はルームの卓の状態を保存する（失敗してもゲームは止めずにログだけ出す）
+func persistBJState(repo store.Store, roomCode string) {
+	if err := saveBJState(repo, roomCode); err != nil {
+		slog.Error("bj snapshot save failed", "room", roomCode, "err", err)
+	}
+}
+
+// saveBJState は persistBJState の本体（失敗を呼び出し側で扱うとき用）
+func saveBJState(repo store.Store, roomCode string) error {
+	if repo == nil {
+		return nil
+	}
+	bjMu.Lock()
+	st, ok := bjRoomStates[roomCode]
+	if !ok {
+		bjMu.Unlock()
+		return nil
+	}
+	st.seq++
+	snap := bjSnapshot{DealerID: st.DealerID, DealerSeatNo: st.dealerSeatNo, GameID: st.gameID, ModeID: st.modeID, Round: st.round}
+	if st.log != nil {
+		// 経過はロックの外で JSON にするので、イベントの並びを写しておく（各イベントは足した後に変えない）
+		log := *st.log
+		log.Events = append([]models.RoundEvent(nil), st.log.Events...)
+		snap.Log = &log
+	}
+	for _, id := range st.Seats {
+		if p, ok := st.Players[id]; ok {
+			snap.Players = append(snap.Players, *p)
+		}
+	}
+	seq := st.seq
+	bjMu.Unlock()
+
+	raw, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return repo.Snapshots().Save(models.BJSnapshot{RoomCode: roomCode, Seq: seq, State: raw, UpdatedAt: time.Now()})
+}
+
+// forgetBJState はルームが閉じたときに卓の状態とスナップショットを捨てる（担当が別インスタンスでも）
+func forgetBJState(ctx context.Context, repo store.Store, roomCode string) {
+	dropTableState(roomCode)
+	releaseTable(roomCode)
+	sendTableCommand(ctx, tableCommand{Kind: "forget", Room: roomCode})
+	if repo == nil {
+		return
+	}
+	if err := repo.Snapshots().Delete(roomCode); err != nil {
+		slog.Error("bj snapshot delete failed", "room", roomCode, "err", err)
+	}
+}
+
+// RecoverBJRooms は起動時に保存済みの卓の状態を読み戻す。
+// 戻したルーム数と、閉じていたため捨てたルーム数を返す。ルームを読めなかった卓は飛ばし、そのエラーをまとめて返す。
+func RecoverBJRooms(repo store.Store) (restored, discarded int, err error) {
+	snaps, err := repo.Snapshots().List()
+	if err != nil {
+		return 0, 0, err
+	}
+	var errs []error
+	for _, s := range snaps {
+		var snap bjSnapshot
+		if err := json.Unmarshal(s.State, &snap); err != nil {
+			slog.Warn("recover: broken snapshot", "room", s.RoomCode, "err", err)
+			continue
+		}
+
+		room, err := repo.Rooms().GetByCode(s.RoomCode)
+		if err != nil && err != store.ErrNotFound {
+			errs = append(errs, fmt.Errorf("room %s: %w", s.RoomCode, err))
+			continue
+		}
+		if err == store.ErrNotFound || room.Status == "closed" {
+			// 卓はもう無いので、乗っていたベットは記録だけ残して捨てる
+			for _, p := range snap.Players {
+				if p.Bet > 0 {
+					slog.Info("recover: bet discarded", "room", s.RoomCode, "user_id", p.UserID, "bet", p.Bet)
+				}
+			}
+			if err := repo.Snapshots().Delete(s.RoomCode); err != nil {
+				errs = append(errs, fmt.Errorf("room %s: %w", s.RoomCode, err))
+				continue
+			}
+			discarded++
+			continue
+		}
+
+		// 複数台構成では担当を取れた卓だけ戻す（他の卓は担当のインスタンスが持つ）
+		if !ownsTable(repo, s.RoomCode) {
+			continue
+		}
+		installBJState(s.RoomCode, s.Seq, snap)
+		restored++
+	}
+	return restored, discarded, errors.Join(errs...)
+}
+
+// restoreBJRoom は卓の担当を引き受けたときに、保存済みの状態があれば読み戻す
+func restoreBJRoom(repo store.Store, roomCode string) {
+	if repo == nil {
+		return
+	}
+	s, err := repo.Snapshots().Get(roomCode)
+	if err == store.ErrNotFound {
+		return
+	}
+	if err != nil {
+		slog.Error("bj snapshot load failed", "room", roomCode, "err", err)
+		return
+	}
+	var snap bjSnapshot
+	if err := json.Unmarshal(s.State, &snap); err != nil {
+		slog.Warn("recover: broken snapshot", "room", roomCode, "err", err)
+		return
+	}
+	installBJState(roomCode, s.Seq, snap)
+}
+
+// installBJState はスナップショットから卓の状態を作る（既にメモリにあればそちらを優先する）
+func installBJState(roomCode string, seq int64, snap bjSnapshot) {
+	st := &BJRoomState{
+		Players:      make(map[int64]*BJBetPlayerState, len(snap.Players)),
+		DealerID:     snap.DealerID,
+		dealerSeatNo: snap.DealerSeatNo,
+		seq:          seq,
+		gameID:       snap.GameID,
+		modeID:       snap.ModeID,
+		round:        snap.Round,
+		log:          snap.Log,
+	}
+	for _, p := range snap.Players {
+		p := p
+		st.Players[p.UserID] = &p
+	}
+	st.refreshSeatOrder()
+
+	bjMu.Lock()
+	defer bjMu.Unlock()
+	if _, ok := bjRoomStates[roomCode]; ok {
+		return
+	}
+	bjRoomStates[roomCode] = st
+	slog.Info("recover: restored table", "room", roomCode, "players", len(st.Players), "dealer_id", st.DealerID)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"api/internal/models"
+	"api/internal/store"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// 保存済みスナップショットが cond を満たすまで待つ（保存はブロードキャストの後に行われる）
+func waitSnapshot(t *testing.T, repo *store.Memory, roomCode string, cond func(bjSnapshot) bool) bjSnapshot {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		list, _ := repo.Snapshots().List()
+		for _, s := range list {
+			var snap bjSnapshot
+			if s.RoomCode == roomCode && json.Unmarshal(s.State, &snap) == nil && cond(snap) {
+				return snap
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("snapshot for %s never matched", roomCode)
+	return bjSnapshot{}
+}
+
+func TestBJStateSnapshotAndRecovery(t *testing.T) {
+	repo := store.NewMemory()
+
+	host := createTestUser(t, repo, "host")
+	guest := createTestUser(t, repo, "guest")
+	code := createTestRoom(t, repo, host, 4)
+	joinTestRoom(t, repo, guest, code)
+	t.Cleanup(func() {
+		bjMu.Lock()
+		delete(bjRoomStates, code)
+		bjMu.Unlock()
+	})
+
+	r := mux.NewRouter()
+	r.Handle("/api/ws/blackjackwebsocket/{room_code}", testUserMiddleware(BlackjackWebSocketHandle(repo)))
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	bjHost := dialBlackjackWS(t, srv, code, host)
+	readRoomMessage(t, bjHost, "player_order", nil)
+	bjGuest := dialBlackjackWS(t, srv, code, guest)
+	readRoomMessage(t, bjGuest, "player_order", nil)
+	if err := bjGuest.WriteJSON(BetCommand{Type: "bet_update", Bet: 150, Confirm: true}); err != nil {
+		t.Fatal(err)
+	}
+	saved := waitSnapshot(t, repo, code, func(s bjSnapshot) bool {
+		return len(s.Players) == 2 && s.Players[1].Bet == 150
+	})
+	if saved.DealerID != host {
+		t.Fatalf("saved dealer = %d, want host %d", saved.DealerID, host)
+	}
+
+	// クラッシュ相当：メモリ上の状態を消してから復元する
+	bjHost.Close()
+	bjGuest.Close()
+	bjMu.Lock()
+	delete(bjRoomStates, code)
+	bjMu.Unlock()
+
+	restored, discarded, err := RecoverBJRooms(repo)
+	if err != nil || restored != 1 || discarded != 0 {
+		t.Fatalf("RecoverBJRooms = (%d, %d, %v), want (1, 0, nil)", restored, discarded, err)
+	}
+	bjMu.Lock()
+	st := bjRoomStates[code]
+	var p BJBetPlayerState
+	if st != nil && st.Players[guest] != nil {
+		p = *st.Players[guest]
+	}
+	bjMu.Unlock()
+	if st == nil {
+		t.Fatal("room state was not restored")
+	}
+	if st.DealerID != host || p.Bet != 150 || !p.Confirmed || p.TotalChips != cfg.Chips.TableStart-150 {
+		t.Fatalf("restored dealer=%d guest=%+v", st.DealerID, p)
+	}
+	if len(st.Seats) != 2 || st.Seats[0] != host {
+		t.Fatalf("restored seats = %v", st.Seats)
+	}
+
+	// ルームが閉じていればスナップショットは捨てられる
+	room, err := repo.Rooms().GetByCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Rooms().UpdateStatus(room.ID, "closed"); err != nil {
+		t.Fatal(err)
+	}
+	bjMu.Lock()
+	delete(bjRoomStates, code)
+	bjMu.Unlock()
+	restored, discarded, err = RecoverBJRooms(repo)
+	if err != nil || restored != 0 || discarded != 1 {
+		t.Fatalf("RecoverBJRooms on closed room = (%d, %d, %v), want (0, 1, nil)", restored, discarded, err)
+	}
+	if list, _ := repo.Snapshots().List(); len(list) != 0 {
+		t.Fatalf("snapshots left after discard: %d", len(list))
+	}
+}
+
+func TestLeaveLastPlayerForgetsBJState(t *testing.T) {
+	repo := store.NewMemory()
+
+	host := createTestUser(t, repo, "host")
+	code := createTestRoom(t, repo, host, 4)
+
+	bjMu.Lock()
+	bjRoomStates[code] = &BJRoomState{Players: map[int64]*BJBetPlayerState{host: {UserID: host, TotalChips: 1000}}}
+	bjMu.Unlock()
+	persistBJState(repo, code)
+	if list, _ := repo.Snapshots().List(); len(list) != 1 {
+		t.Fatalf("snapshots = %d, want 1", len(list))
+	}
+
+	rec := callAsUser(LeaveRoomHandler(repo), host, LeaveRoomRequest{RoomCode: code})
+	if rec.Code != 200 {
+		t.Fatalf("leave: status = %d body=%s", rec.Code, rec.Body.String())
+	}
+	bjMu.Lock()
+	_, ok := bjRoomStates[code]
+	bjMu.Unlock()
+	if ok {
+		t.Fatal("room state kept after the room closed")
+	}
+	if list, _ := repo.Snapshots().List(); len(list) != 0 {
+		t.Fatalf("snapshots = %d after the room closed, want 0", len(list))
+	}
+}
+
+// roomsDown は rooms の読み出しだけ失敗する Store（起動直後の DB の一時的な障害）
+type roomsDown struct{ store.Store }
+
+func (s roomsDown) Rooms() store.RoomRepository { return failingRooms{s.Store.Rooms()} }
+
+type failingRooms struct{ store.RoomRepository }
+
+func (failingRooms) GetByCode(string) (*models.Room, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestRecoverBJRoomsKeepsSnapshotOnDBError(t *testing.T) {
+	repo := store.NewMemory()
+	raw, _ := json.Marshal(bjSnapshot{Players: []BJBetPlayerState{{UserID: 1, Bet: 100, TotalChips: 900}}})
+	if err := repo.Snapshots().Save(models.BJSnapshot{RoomCode: "GONE01", Seq: 1, State: raw, UpdatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// ルームを読めないときは捨てずにエラーを返す
+	restored, discarded, err := RecoverBJRooms(roomsDown{repo})
+	if err == nil || restored != 0 || discarded != 0 {
+		t.Fatalf("RecoverBJRooms with rooms down = (%d, %d, %v), want (0, 0, error)", restored, discarded, err)
+	}
+	if list, _ := repo.Snapshots().List(); len(list) != 1 {
+		t.Fatalf("snapshots after DB error = %d, want 1", len(list))
+	}
+
+	// ルームが無いと分かれば捨てる
+	restored, discarded, err = RecoverBJRooms(repo)
+	if err != nil || restored != 0 || discarded != 1 {
+		t.Fatalf("RecoverBJRooms on missing room = (%d, %d, %v), want (0, 1, nil)", restored, discarded, err)
+	}
+	if list, _ := repo.Snapshots().List(); len(list) != 0 {
+		t.Fatalf("snapshots left after discard: %d", len(list))
+	}
+}
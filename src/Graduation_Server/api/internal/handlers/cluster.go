@@ -0,0 +1,370 @@
+// 複数インスタンス構成（internal/backplane）
+//
+// ルームWS・ブラックジャックWSの接続は各インスタンスのメモリにあるので、
+// ブロードキャストは「自分の接続へ送る」＋「他インスタンスへイベントを流す」の2段で行う。
+// イベントを受けたインスタンスは、自分の接続に対して同じ送信をやり直す。
+//
+// ブラックジャック卓の状態（bjRoomStates）はルームごとに担当インスタンス（オーナー）だけが持つ。
+// 担当でないインスタンスは入室・ベット・ゲーム開始・ラウンドの経過と結果をコマンドとして担当へ送る。
+// 担当はリースで持ち、担当が落ちたら期限切れの後に別インスタンスがスナップショット
+// （bj_snapshot.go）から引き継ぐ。
+//
+// InitBackplane を呼ばなければ（テストなど）1台構成として動き、何も流さない。
+
+package handlers
+
+import (
+	"api/internal/backplane"
+	"api/internal/response"
+	"api/internal/store"
+	"api/internal/tracing"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	eventChannel   = "graduation:room-events"
+	commandChannel = "graduation:table-commands"
+	ownerKeyPrefix = "graduation:table-owner:"
+)
+
+// インスタンス間で流すイベント（受けた側は自分の接続へ同じものを送る）
+type roomEvent struct {
+	Origin string          `json:"origin"`
+	Kind   string          `json:"kind"` // room_status / start_game / notify_user / close_user / close_room / disconnect_user / broadcast / table
+	Room   string          `json:"room"`
+	UserID int64           `json:"user_id,omitempty"` // 宛先（table で 0 なら卓の全員）
+	GameID int64           `json:"game_id,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`  // notify_user / broadcast / table でそのまま送るメッセージ
+	Trace  string          `json:"trace,omitempty"` // 送信元スパンの traceparent
+}
+
+// 卓の担当インスタンスへのコマンド
+type tableCommand struct {
+	Origin  string `json:"origin"`
+	Kind    string `json:"kind"` // join / bet / start / round_result / round_commit / round_action / forget
+	Room    string `json:"room"`
+	UserID  int64  `json:"user_id,omitempty"`
+	Bet     int    `json:"bet,omitempty"`
+	Confirm bool   `json:"confirm,omitempty"`
+	GameID  int64  `json:"game_id,omitempty"` // start
+	ModeID  int    `json:"mode_id,omitempty"` // start
+	// round_result / round_commit / round_action：送られたメッセージと、エラーを返すときの言語
+	Result *RoundResultCommand `json:"result,omitempty"`
+	Commit *RoundCommitCommand `json:"commit,omitempty"`
+	Action *RoundActionCommand `json:"action,omitempty"`
+	Lang   response.Lang       `json:"lang,omitempty"`
+	Trace  string              `json:"trace,omitempty"` // 送信元スパンの traceparent
+}
+
+type cluster struct {
+	bp   backplane.Backplane
+	id   string
+	ttl  time.Duration
+	repo store.Store
+
+	stop context.CancelFunc
+
+	mu    sync.Mutex
+	owned map[string]time.Time // 担当中のルームと、最後にリースを取れた（延ばせた）時刻
+}
+
+var clusterState atomic.Pointer[cluster]
+
+// InitBackplane はインスタンス間の配送を始める。
+// ttl は卓の担当のリース期間（ttl/3 ごとに延長する）。
+func InitBackplane(bp backplane.Backplane, instanceID string, ttl time.Duration, repo store.Store) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &cluster{bp: bp, id: instanceID, ttl: ttl, repo: repo, stop: cancel, owned: make(map[string]time.Time)}
+
+	subCtx, subCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer subCancel()
+	if err := bp.Subscribe(subCtx, eventChannel, c.onEvent); err != nil {
+		cancel()
+		return err
+	}
+	if err := bp.Subscribe(subCtx, commandChannel, c.onCommand); err != nil {
+		cancel()
+		return err
+	}
+	clusterState.Store(c)
+	go c.renewLoop(ctx)
+	return nil
+}
+
+// LeaveCluster は担当中の卓のリースを手放す（停止時。他のインスタンスがすぐ引き継げる）
+func LeaveCluster() {
+	c := clusterState.Load()
+	if c == nil {
+		return
+	}
+	c.stop()
+	c.mu.Lock()
+	codes := make([]string, 0, len(c.owned))
+	for code := range c.owned {
+		codes = append(codes, code)
+	}
+	c.owned = make(map[string]time.Time)
+	c.mu.Unlock()
+
+	for _, code := range codes {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		if err := c.bp.Release(ctx, ownerKeyPrefix+code, c.id); err != nil {
+			slog.Warn("cluster: table release failed", "room", code, "err", err)
+		}
+		cancel()
+	}
+	slog.Info("cluster: released tables", "tables", len(codes))
+}
+
+// ---- 送信側 ----
+
+func publishEvent(ctx context.Context, ev roomEvent) {
+	c := clusterState.Load()
+	if c == nil {
+		return
+	}
+	ev.Origin = c.id
+	ev.Trace = tracing.Traceparent(ctx)
+	c.publish(eventChannel, ev)
+}
+
+func sendTableCommand(ctx context.Context, cmd tableCommand) {
+	c := clusterState.Load()
+	if c == nil {
+		return
+	}
+	cmd.Origin = c.id
+	cmd.Trace = tracing.Traceparent(ctx)
+	c.publish(commandChannel, cmd)
+}
+
+func (c *cluster) publish(channel string, v interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("backplane encode failed", "channel", channel, "err", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := c.bp.Publish(ctx, channel, raw); err != nil {
+		slog.Warn("backplane publish failed", "channel", channel, "err", err)
+	}
+}
+
+// ---- 受信側 ----
+
+func (c *cluster) onEvent(raw []byte) {
+	var ev roomEvent
+	if err := json.Unmarshal(raw, &ev); err != nil {
+		slog.Warn("backplane event decode failed", "err", err)
+		return
+	}
+	if ev.Origin == c.id {
+		return // 自分の接続には送信済み
+	}
+	ctx, span := consumerSpan(ev.Trace, "backplane event "+ev.Kind, ev.Room)
+	defer span.End()
+
+	switch ev.Kind {
+	case "room_status":
+		localRoomStatus(ctx, ev.Room, c.repo)
+	case "start_game":
+		localStartGame(ev.Room, ev.GameID)
+	case "notify_user":
+		localNotifyUser(ev.Room, ev.UserID, ev.Data)
+	case "close_user":
+		localCloseUser(ev.Room, ev.UserID)
+	case "close_room":
+		localCloseRoom(ev.Room, ev.Data)
+	case "disconnect_user":
+		localDisconnectUser(ev.UserID)
+	case "broadcast":
+		localBroadcastAll(ev.Data)
+	case "table":
+		localTableSend(ev.Room, ev.UserID, ev.Data)
+	}
+}
+
+func (c *cluster) onCommand(raw []byte) {
+	var cmd tableCommand
+	if err := json.Unmarshal(raw, &cmd); err != nil {
+		slog.Warn("backplane command decode failed", "err", err)
+		return
+	}
+	c.mu.Lock()
+	_, owned := c.owned[cmd.Room]
+	c.mu.Unlock()
+	if !owned {
+		return
+	}
+	ctx, span := consumerSpan(cmd.Trace, "backplane command "+cmd.Kind, cmd.Room)
+	defer span.End()
+	repo := c.repo.WithContext(ctx)
+
+	switch cmd.Kind {
+	case "join":
+		players, ok := joinTableFromDB(repo, cmd.Room)
+		if !ok {
+			return
+		}
+		sendTable(ctx, cmd.Room, cmd.UserID, PlayerOrderMessage{Type: "player_order", Players: players})
+		broadcastBetState(ctx, cmd.Room)
+	case "bet":
+		applyBet(ctx, repo, cmd.Room, cmd.UserID, BetCommand{Type: "bet_update", Bet: cmd.Bet, Confirm: cmd.Confirm})
+	case "start":
+		startTable(repo, cmd.Room, cmd.GameID, cmd.ModeID)
+	case "round_result":
+		if cmd.Result != nil {
+			applyRoundResult(ctx, repo, cmd.Room, cmd.UserID, cmd.Lang, *cmd.Result)
+		}
+	case "round_commit":
+		if cmd.Commit != nil {
+			applyRoundCommit(ctx, repo, cmd.Room, cmd.UserID, cmd.Lang, *cmd.Commit)
+		}
+	case "round_action":
+		if cmd.Action != nil {
+			applyRoundAction(ctx, repo, cmd.Room, cmd.UserID, cmd.Lang, *cmd.Action)
+		}
+	case "forget":
+		dropTableState(cmd.Room)
+		c.release(cmd.Room)
+	}
+}
+
+// 送信元の traceparent を親にして受信処理のスパンを始める
+func consumerSpan(traceparent, name, room string) (context.Context, trace.Span) {
+	ctx := tracing.ContextWithTraceparent(context.Background(), traceparent)
+	return tracing.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attribute.String("room", room)))
+}
+
+// ---- 卓の担当 ----
+
+// ownsTable はこのインスタンスがルームの卓の担当かどうか。
+// 誰も担当していなければ引き受け、保存済みの状態があれば読み戻す。
+func ownsTable(repo store.Store, roomCode string) bool {
+	c := clusterState.Load()
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	_, owned := c.owned[roomCode]
+	c.mu.Unlock()
+	if owned {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	start := time.Now()
+	ok, err := c.bp.Acquire(ctx, ownerKeyPrefix+roomCode, c.id, c.ttl)
+	if err != nil {
+		// 担当が分からないまま状態を持つと二重管理になるので引き受けない
+		slog.Warn("cluster: table acquire failed", "room", roomCode, "err", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	c.mu.Lock()
+	c.owned[roomCode] = start
+	c.mu.Unlock()
+	restoreBJRoom(repo, roomCode)
+	slog.Info("cluster: now owns table", "room", roomCode)
+	return true
+}
+
+// releaseTable は担当していれば卓のリースを手放す
+func releaseTable(roomCode string) {
+	if c := clusterState.Load(); c != nil {
+		c.release(roomCode)
+	}
+}
+
+func (c *cluster) release(roomCode string) {
+	c.mu.Lock()
+	_, owned := c.owned[roomCode]
+	delete(c.owned, roomCode)
+	c.mu.Unlock()
+	if !owned {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := c.bp.Release(ctx, ownerKeyPrefix+roomCode, c.id); err != nil {
+		slog.Warn("cluster: table release failed", "room", roomCode, "err", err)
+	}
+}
+
+// renewLoop は担当中の卓のリースを延ばし続け、他に取られた卓の状態は捨てる。
+// 延長に失敗し続けて最後に延ばせてから ttl 経った卓は、リースが切れて他が引き継げるので同じく捨てる。
+func (c *cluster) renewLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		c.mu.Lock()
+		codes := make([]string, 0, len(c.owned))
+		for code := range c.owned {
+			codes = append(codes, code)
+		}
+		c.mu.Unlock()
+
+		for _, code := range codes {
+			// リースは呼ぶ前の時刻から ttl より後まで続くので、呼ぶ前の時刻を延ばせた時刻にする
+			start := time.Now()
+			actx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			ok, err := c.bp.Acquire(actx, ownerKeyPrefix+code, c.id, c.ttl)
+			cancel()
+			switch {
+			case err != nil:
+				c.mu.Lock()
+				last, owned := c.owned[code]
+				expired := owned && time.Since(last) > c.ttl
+				if expired {
+					delete(c.owned, code)
+				}
+				c.mu.Unlock()
+				if !expired {
+					// 一時的な障害ならリースが切れる前に回復すればよい
+					slog.Warn("cluster: table lease renew failed", "room", code, "err", err)
+					continue
+				}
+				slog.Warn("cluster: table lease expired while renew kept failing", "room", code, "err", err)
+				dropTableState(code)
+			case !ok:
+				slog.Warn("cluster: lost table ownership", "room", code)
+				c.mu.Lock()
+				delete(c.owned, code)
+				c.mu.Unlock()
+				dropTableState(code)
+			default:
+				c.mu.Lock()
+				if _, owned := c.owned[code]; owned {
+					c.owned[code] = start
+				}
+				c.mu.Unlock()
+			}
+		}
+	}
+}
+
+// dropTableState はメモリ上の卓の状態だけを捨てる（スナップショットは残す）
+func dropTableState(roomCode string) {
+	bjMu.Lock()
+	delete(bjRoomStates, roomCode)
+	bjMu.Unlock()
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"api/internal/backplane"
+	"api/internal/models"
+	"api/internal/store"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// このプロセスを "self" として backplane につなぐ。テストは同じ Local を使って別インスタンス "peer" を演じる。
+func useCluster(t *testing.T, repo store.Store) *backplane.Local {
+	t.Helper()
+	bp := backplane.NewLocal()
+	if err := InitBackplane(bp, "self", 3*time.Second, repo); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		LeaveCluster()
+		clusterState.Store(nil)
+		bp.Close()
+	})
+	return bp
+}
+
+func subscribeChannel[T any](t *testing.T, bp backplane.Backplane, channel string) <-chan T {
+	t.Helper()
+	ch := make(chan T, 100)
+	err := bp.Subscribe(context.Background(), channel, func(p []byte) {
+		var v T
+		if json.Unmarshal(p, &v) == nil {
+			ch <- v
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ch
+}
+
+func waitFor[T any](t *testing.T, ch <-chan T, what string, match func(T) bool) T {
+	t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case v := <-ch:
+			if match(v) {
+				return v
+			}
+		case <-timeout:
+			t.Fatalf("%s never arrived", what)
+		}
+	}
+}
+
+func peerPublish(t *testing.T, bp backplane.Backplane, channel string, v interface{}) {
+	t.Helper()
+	raw, _ := json.Marshal(v)
+	if err := bp.Publish(context.Background(), channel, raw); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterForwardsToTableOwner(t *testing.T) {
+	repo := store.NewMemory()
+	host := createTestUser(t, repo, "host")
+	guest := createTestUser(t, repo, "guest")
+	code := createTestRoom(t, repo, host, 4)
+	joinTestRoom(t, repo, guest, code)
+	t.Cleanup(func() { dropTableState(code) })
+
+	bp := useCluster(t, repo)
+	ctx := context.Background()
+	commands := subscribeChannel[tableCommand](t, bp, commandChannel)
+	events := subscribeChannel[roomEvent](t, bp, eventChannel)
+
+	// 卓の担当は peer
+	if ok, err := bp.Acquire(ctx, ownerKeyPrefix+code, "peer", time.Minute); err != nil || !ok {
+		t.Fatalf("peer acquire = %v, %v", ok, err)
+	}
+
+	r := mux.NewRouter()
+	r.Handle("/api/ws/room/{room_code}", testUserMiddleware(GameRoomWebSocketHandler(repo)))
+	r.Handle("/api/ws/blackjackwebsocket/{room_code}", testUserMiddleware(BlackjackWebSocketHandle(repo)))
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	// ルームの状態変化は他インスタンスへも流れる
+	roomConn := dialRoomWS(t, srv, code, host)
+	waitRoomStatus(t, roomConn, func(s RoomStatusResponse) bool { return len(s.Players) == 2 })
+	waitFor(t, events, "room_status event", func(ev roomEvent) bool {
+		return ev.Origin == "self" && ev.Kind == "room_status" && ev.Room == code
+	})
+
+	// 担当でないので、入室とベットは peer へのコマンドになる
+	bjGuest := dialBlackjackWS(t, srv, code, guest)
+	waitFor(t, commands, "join command", func(c tableCommand) bool {
+		return c.Kind == "join" && c.Room == code && c.UserID == guest && c.Origin == "self"
+	})
+	order := PlayerOrderMessage{Type: "player_order", Players: []PlayerInfo{{UserID: host, IsDealer: true}, {UserID: guest}}}
+	raw, _ := json.Marshal(order)
+	peerPublish(t, bp, eventChannel, roomEvent{Origin: "peer", Kind: "table", Room: code, UserID: guest, Data: raw})
+	readRoomMessage(t, bjGuest, "player_order", nil)
+
+	if err := bjGuest.WriteJSON(BetCommand{Type: "bet_update", Bet: 100}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, commands, "bet command", func(c tableCommand) bool {
+		return c.Kind == "bet" && c.UserID == guest && c.Bet == 100
+	})
+	bjMu.Lock()
+	_, hasState := bjRoomStates[code]
+	bjMu.Unlock()
+	if hasState {
+		t.Fatal("non-owner instance created table state")
+	}
+
+	// peer からの個別通知はこのインスタンスの接続に届く
+	hello, _ := json.Marshal(map[string]string{"type": "peer_hello"})
+	peerPublish(t, bp, eventChannel, roomEvent{Origin: "peer", Kind: "notify_user", Room: code, UserID: host, Data: hello})
+	readRoomMessage(t, roomConn, "peer_hello", nil)
+
+	// peer が落ちて担当が空いたら、次のベットで引き継いでスナップショットから続ける
+	snap, _ := json.Marshal(bjSnapshot{
+		Players: []BJBetPlayerState{
+			{UserID: host, TotalChips: 1000, SeatNo: 1, Confirmed: true},
+			{UserID: guest, Bet: 100, TotalChips: 900, SeatNo: 2},
+		},
+		DealerID:     host,
+		DealerSeatNo: 1,
+	})
+	if err := repo.Snapshots().Save(models.BJSnapshot{RoomCode: code, Seq: 5, State: snap, UpdatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bp.Release(ctx, ownerKeyPrefix+code, "peer"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bjGuest.WriteJSON(BetCommand{Type: "bet_update", Bet: 200}); err != nil {
+		t.Fatal(err)
+	}
+	readRoomMessage(t, bjGuest, "bet_state", func(raw []byte) bool {
+		bet, chips, ok := betOf(raw, guest)
+		return ok && bet == 200 && chips == 800
+	})
+	waitFor(t, events, "bet_state event", func(ev roomEvent) bool {
+		return ev.Origin == "self" && ev.Kind == "table" && ev.UserID == 0
+	})
+	if owner, _ := bp.Owner(ctx, ownerKeyPrefix+code); owner != "self" {
+		t.Fatalf("owner after takeover = %q, want self", owner)
+	}
+
+	// 担当として peer からのコマンドを処理する
+	peerPublish(t, bp, commandChannel, tableCommand{Origin: "peer", Kind: "bet", Room: code, UserID: guest, Bet: 50})
+	readRoomMessage(t, bjGuest, "bet_state", func(raw []byte) bool {
+		bet, chips, ok := betOf(raw, guest)
+		return ok && bet == 50 && chips == 950
+	})
+}
+
+// renewFails は flaky が立っている間だけリースの取得・延長が失敗する backplane
+type renewFails struct {
+	*backplane.Local
+	flaky atomic.Bool
+}
+
+func (b *renewFails) Acquire(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	if b.flaky.Load() {
+		return false, errors.New("backplane unreachable")
+	}
+	return b.Local.Acquire(ctx, key, owner, ttl)
+}
+
+func TestClusterDropsTableWhenRenewKeepsFailing(t *testing.T) {
+	repo := store.NewMemory()
+	bp := &renewFails{Local: backplane.NewLocal()}
+	const ttl = 150 * time.Millisecond
+	if err := InitBackplane(bp, "self", ttl, repo); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		LeaveCluster()
+		clusterState.Store(nil)
+		bp.Close()
+	})
+
+	const code = "RENEW1"
+	if !ownsTable(repo, code) {
+		t.Fatal("could not take a free table")
+	}
+	bjMu.Lock()
+	bjRoomStates[code] = &BJRoomState{Players: map[int64]*BJBetPlayerState{}}
+	bjMu.Unlock()
+	t.Cleanup(func() { dropTableState(code) })
+
+	// 延長に失敗しても ttl が過ぎるまでは持ち続け、過ぎたら状態を捨てる
+	bp.flaky.Store(true)
+	time.Sleep(ttl / 2)
+	bjMu.Lock()
+	_, held := bjRoomStates[code]
+	bjMu.Unlock()
+	if !held {
+		t.Fatal("table dropped before the lease could have expired")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c := clusterState.Load()
+		c.mu.Lock()
+		_, owned := c.owned[code]
+		c.mu.Unlock()
+		bjMu.Lock()
+		_, held = bjRoomStates[code]
+		bjMu.Unlock()
+		if !owned && !held {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("table still held after renew kept failing: owned=%v state=%v", owned, held)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
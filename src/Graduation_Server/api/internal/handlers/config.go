@@ -0,0 +1,11 @@
+package handlers
+
+import "api/internal/config"
+
+// 起動時に読み込んだ設定（タイマー・初期チップ・トークン有効期間など）
+// InitConfig 前はデフォルト値で動く
+var cfg = config.Default()
+
+func InitConfig(c *config.Config) {
+	cfg = c
+}
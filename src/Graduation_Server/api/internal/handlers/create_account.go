@@ -3,12 +3,13 @@
 package handlers
 
 import (
+	"api/internal/models"
+	"api/internal/response"
 	"encoding/json"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -22,23 +23,21 @@ type CreateAccountRequest struct {
 	AutoFlg  bool   `json:"auto_flg"`
 }
 
-// JWT署名用シークレットキー
-var jwtSecret = []byte("your_secret_key")
-
-// CreateAccountHandler はユーザーアカウントを新規作成し、24時間有効のJWTを返す。
+// CreateAccountHandler はユーザーアカウントを新規作成し、アクセストークンとリフレッシュトークンを返す。
 func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
+	repo := repo.WithContext(r.Context())
 	var req CreateAccountRequest
 	//Headersがjsonの場合
 	ct := r.Header.Get("Content-Type")
 	if strings.HasPrefix(ct, "application/json") {
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request (json)", http.StatusBadRequest)
+			response.BadJSON(w, r)
 			return
 		}
 		//Headersがx-www-form-urlencodedの場合
 	} else if strings.HasPrefix(ct, "application/x-www-form-urlencoded") {
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, "Invalid request (form)", http.StatusBadRequest)
+			response.BadJSON(w, r)
 			return
 		}
 		req.Name = r.FormValue("name")
@@ -46,33 +45,27 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 		req.AutoFlg = r.FormValue("auto_flg") == "true"
 		//それ以外
 	} else {
-		http.Error(w, "Unsupported Content-Type", http.StatusUnsupportedMediaType)
+		response.Fail(w, r, http.StatusUnsupportedMediaType, response.CodeUnsupportedMedia)
 		return
 	}
 	// バリデーションのメッセージ追加
 	// 名前がない場合、パスワードがない場合、名前が使われている場合
 	if strings.TrimSpace(req.Name) == "" {
-		http.Error(w, "名前の入力をしてください", http.StatusBadRequest)
+		response.Invalid(w, r, response.Field("name", response.FieldRequired))
 		return
 	}
 	if !req.AutoFlg && strings.TrimSpace(req.Password) == "" {
-		http.Error(w, "パスワードの入力をしてください", http.StatusBadRequest)
+		response.Invalid(w, r, response.Field("password", response.FieldRequired))
 		return
 	}
 
-	var exists bool
-	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE name = ?)", req.Name).Scan(&exists)
+	exists, err := repo.Users().NameExists(req.Name)
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		response.Internal(w, r)
 		return
 	}
 	if exists {
-		resp := map[string]interface{}{
-			"result":  "NG",
-			"message": "この名前は既に使用されています",
-		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(resp)
+		response.NG(w, r, http.StatusConflict, response.CodeNameTaken)
 		return
 	}
 	// ---- パスワードハッシュ化 ----
@@ -83,7 +76,7 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
-			http.Error(w, "Password hashing failed", http.StatusInternalServerError)
+			response.Internal(w, r)
 			return
 		}
 		hashedPassword = string(hash)
@@ -91,58 +84,31 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 
 	now := time.Now()
 	// ---- users にレコード挿入 ----
-	result, err := db.Exec(`
-		INSERT INTO users (name, password, created_at, last_login)
-		VALUES (?, ?, ?, ?)`,
-		req.Name, hashedPassword, now, now)
+	userID, err := repo.Users().Create(req.Name, hashedPassword, now)
 	if err != nil {
-		http.Error(w, "ユーザーの作成に失敗しました", http.StatusInternalServerError)
-		return
-	}
-	// 生成されたユーザーIDを取得
-
-	userID, err := result.LastInsertId()
-	if err != nil {
-		http.Error(w, "ユーザーの作成に失敗しました", http.StatusInternalServerError)
+		response.Internal(w, r)
 		return
 	}
 
 	// settings テーブルに初期データを挿入
-	_, err = db.Exec(`
-		INSERT INTO settings (user_id, bgm_volume, se_volume, icon)
-		VALUES (?, ?, ?, ?)`,
-		userID, 1.0, 1.0, "default_icon")
-	if err != nil {
-		http.Error(w, "設定データの作成に失敗しました", http.StatusInternalServerError)
+	if err := repo.Settings().Create(userID, models.DefaultSetting); err != nil {
+		response.Internal(w, r)
 		return
 	}
 	// tips 初期データ挿入
-	_, err = db.Exec(`
-		INSERT INTO tips (user_id, solo_tip_count, multi_tip_count, updated_at)
-		VALUES (?, ?, ?, ?)`,
-		userID, 10000, 10000, now)
-	if err != nil {
-		http.Error(w, "チップデータの作成に失敗しました", http.StatusInternalServerError)
+	tip := models.Tip{SoloTipCount: cfg.Chips.SoloStart, MultiTipCount: cfg.Chips.MultiStart}
+	if err := repo.Tips().Create(userID, tip, now); err != nil {
+		response.Internal(w, r)
 		return
 	}
 
-	// 24時間有効のJWT生成
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"name":    req.Name,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
-	})
-	signedToken, err := token.SignedString(jwtSecret)
+	// アクセストークン＋リフレッシュトークン発行
+	pair, err := issueTokenPair(r.Context(), userID)
 	if err != nil {
-		http.Error(w, "トークンの作成失敗", http.StatusInternalServerError)
+		response.Internal(w, r)
 		return
 	}
 
 	// 成功時は result=OK と JWT を返す
-	resp := map[string]interface{}{
-		"result": "OK",
-		"token":  signedToken,
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	response.OK(w, r, pair.response())
 }
@@ -1,9 +1,22 @@
 package handlers
 
-import "database/sql"
+import (
+	"api/internal/response"
+	"api/internal/store"
+	"database/sql"
+)
 
 var db *sql.DB
 
 func InitDB(database *sql.DB) {
 	db = database
 }
+
+// users / settings / tips などのリポジトリ（グローバル版ハンドラ用。テストではメモリ実装を入れる）
+var repo store.Store
+
+func InitStore(s store.Store) {
+	repo = s
+	// エラーなどの文言はユーザーが設定した言語で返す（未設定・未ログインなら Accept-Language）
+	response.InitUserLanguage(userLanguage)
+}
@@ -3,8 +3,8 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
+	"api/internal/response"
+	"log/slog"
 	"net/http"
 )
 
@@ -17,37 +17,28 @@ func FriendGameListHandler(w http.ResponseWriter, r *http.Request) {
 	WHERE t.mode_id = (SELECT id FROM modes WHERE mode = 'フレンド') AND t.is_can_play = TRUE
 	`
 
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(r.Context(), query)
 	if err != nil {
-		log.Printf("[ERROR] multi select failed: %v", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "friend games select failed", "err", err)
+		response.Internal(w, r)
 		return
 	}
 	defer rows.Close()
 
-	var games []map[string]interface{}
+	var games []GameListItem
 	for rows.Next() {
 		var id int
 		var typeCode, name, rule string
 		if err := rows.Scan(&id, &typeCode, &name, &rule); err != nil {
-			log.Printf("[ERROR] friend scan failed: %v", err)
-			http.Error(w, "Scan error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "friend games scan failed", "err", err)
+			response.Internal(w, r)
 			return
 		}
 
 		//レスポンスデータ
-		games = append(games, map[string]interface{}{
-			"id":   id,
-			"type": typeCode,
-			"name": name,
-			"rule": rule,
-		})
+		games = append(games, GameListItem{ID: id, Type: typeCode, Name: name, Rule: rule})
 	}
 
-	resp := map[string]interface{}{
-		"result": "OK",
-		"games":  games,
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	resp := GameListResponse{Games: games}
+	response.OK(w, r, resp)
 }
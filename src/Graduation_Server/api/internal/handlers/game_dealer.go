@@ -1,37 +1,70 @@
 // internal/handlers/blackjack_dealer.go
 package handlers
 
-import (
-	"math/rand"
-	"time"
-)
-
-// このファイル内だけの初期化（乱数シード）
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
-// players スライスの中からランダムに1人をディーラーにして IsDealer=true を付ける。
-// すでに IsDealer がついていても一旦クリアしてから再度付け直す。
-// 戻り値: 更新済み players, dealerIndex (ディーラーの index / 見つからなければ -1)
+// ディーラーは席順で決める。
+// 最初のラウンドは一番若い席の人、以降はラウンドごとに次の席へ回す。
+// 戻り値: ディーラーの userID（プレイヤーがいなければ 0）
 func EnsureDealerAssigned(state *BJRoomState) int64 {
 	if state.DealerID != 0 {
-		return state.DealerID
+		if _, ok := state.Players[state.DealerID]; ok {
+			return state.DealerID
+		}
+		// ディーラーが抜けていたら次の席の人に交代
+		return RotateDealer(state)
 	}
 
-	ids := make([]int64, 0, len(state.Players))
-	for id := range state.Players {
-		ids = append(ids, id)
+	if len(state.Seats) == 0 {
+		state.refreshSeatOrder()
 	}
-	if len(ids) == 0 {
+	if len(state.Seats) == 0 {
+		return 0
+	}
+
+	setDealer(state, state.Seats[0])
+	return state.DealerID
+}
+
+// 現在のディーラーの次の席の人をディーラーにする（最後の席の次は先頭）。
+// 現ディーラーが席にいない場合は、その席番号より後ろで一番近い人が引き継ぐ。
+func RotateDealer(state *BJRoomState) int64 {
+	state.refreshSeatOrder()
+	if len(state.Seats) == 0 {
+		state.DealerID = 0
 		return 0
 	}
 
-	dealerID := ids[rand.Intn(len(ids))]
-	state.DealerID = dealerID
-	if p, ok := state.Players[dealerID]; ok {
+	next := state.Seats[0]
+	if prev, ok := state.Players[state.DealerID]; ok {
+		for i, id := range state.Seats {
+			if id == prev.UserID {
+				next = state.Seats[(i+1)%len(state.Seats)]
+				break
+			}
+		}
+		// 前ディーラーは次のラウンドではベットする側
+		prev.Confirmed = false
+	} else if state.DealerID != 0 {
+		prevSeat := state.dealerSeatNo
+		for _, id := range state.Seats {
+			if state.Players[id].SeatNo > prevSeat {
+				next = id
+				break
+			}
+		}
+	}
+
+	setDealer(state, next)
+	return next
+}
+
+// ディーラーはベットしないので、賭けていた分があればチップに戻して確定扱いにする
+func setDealer(state *BJRoomState, userID int64) {
+	state.DealerID = userID
+	if p, ok := state.Players[userID]; ok {
+		recordChips("bet_returned", p.Bet)
+		p.TotalChips += p.Bet
 		p.Bet = 0
 		p.Confirmed = true
+		state.dealerSeatNo = p.SeatNo
 	}
-	return dealerID
 }
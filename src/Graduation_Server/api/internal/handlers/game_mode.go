@@ -2,36 +2,40 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
+	"api/internal/response"
+	"api/internal/store"
+	"log/slog"
 	"net/http"
 )
 
-func GameModeHandler(w http.ResponseWriter, r *http.Request) {
-	//遊べるゲームの確認
-	var canRank, canFriend bool
-	// ランクの可否確認
-	err := db.QueryRow(`SELECT is_can_play FROM modes WHERE mode = 'ランク'`).Scan(&canRank)
-	if err != nil {
-		log.Printf("[ERROR] ランクモード取得失敗: %v", err)
-		http.Error(w, "ランクモード取得失敗", http.StatusInternalServerError)
-		return
-	}
+// ランク・フレンドが遊べるか（maintenance 中は新しいルームを作れない）
+type GameModeResponse struct {
+	CanRank     bool `json:"can_rank"`
+	CanFriend   bool `json:"can_friend"`
+	Maintenance bool `json:"maintenance"`
+}
 
-	// フレンドの可否確認
-	err = db.QueryRow(`SELECT is_can_play FROM modes WHERE mode = 'フレンド'`).Scan(&canFriend)
-	if err != nil {
-		log.Printf("[ERROR] フレンドモード取得失敗: %v", err)
-		http.Error(w, "フレンドモード取得失敗", http.StatusInternalServerError)
-		return
+func GameModeHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		//遊べるゲームの確認
+		modes, ok := playableModes(w, r, repo, "ランク", "フレンド")
+		if !ok {
+			return
+		}
+		// メンテナンス中は新しいルームを作れない（クライアントの表示用）
+		maintenance, err := repo.Notices().Maintenance()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "メンテナンス状態取得失敗", "err", err)
+			response.Internal(w, r)
+			return
+		}
+		//レスポンスデータ
+		resp := GameModeResponse{
+			CanRank:     modes[0],
+			CanFriend:   modes[1],
+			Maintenance: maintenance.Enabled,
+		}
+		response.OK(w, r, resp)
 	}
-	//レスポンスデータ
-	resp := map[string]interface{}{
-		"result":     "OK",
-		"can_rank":   canRank,
-		"can_friend": canFriend,
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
-
 }
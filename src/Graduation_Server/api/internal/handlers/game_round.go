@@ -0,0 +1,428 @@
+// ラウンドの進行と精算
+//
+// 手札の勝負はクライアントで行い、ディーラーの端末がプレイヤーごとの結果
+// （勝ち・負け・引き分け、ブラックジャック・バースト）を round_result で送る。
+// サーバーは自分が持っているベットから払い戻しを計算して手持ちチップを動かし、
+// 参加者ごとの結果を round_results に残す（戦績の集計は stats.go）。
+//
+// 払い戻し（ベット分を含む）：勝ち 2倍、ブラックジャックの勝ち 2.5倍（3:2、端数切り捨て）、
+// 引き分け ベットを返す、負け 0。
+// ディーラーはプレイヤーの収支の合計の逆を受け取る（手持ちがマイナスになることもある）。
+//
+// 信用するのはディーラーの端末が送った勝ち負けまでで、払い戻しの額は受け取らない。
+// 1人のプレイヤーが1ラウンドで動かせるチップは、サーバーが持っているベットの 1.5 倍が上限になる。
+// 勝ち負けはディーラー自身の結果（ブラックジャック・バースト）と矛盾しないものだけ受け付ける
+// （validAgainstDealer）。それでもディーラーと組めば勝ち負けは偽れるので、経過（下記）を残して後から確かめる。
+//
+// ラウンドの経過（models.RoundLog）も卓の状態に持ち、精算と一緒に round_logs へ保存する（history.go で読む）。
+//   - ベットの変更はサーバーが見たまま残す
+//   - ディーラーは配る前に round_commit でシードの SHA-256 を送り、round_result でシードを明かす
+//     （commit したラウンドは、一致するシードが無ければ seed_mismatch で精算しない）
+//   - 配った札とプレイヤーの操作は各クライアントが round_action で送る（記録だけで他の人へは送らない）
+
+package handlers
+
+import (
+	"api/internal/models"
+	"api/internal/response"
+	"api/internal/store"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// クライアント→サーバー：ラウンドの結果（ディーラーだけが送れる）
+type RoundResultCommand struct {
+	Type            string              `json:"type"`    // "round_result"
+	Results         []PlayerRoundResult `json:"results"` // ベットを確定したプレイヤー全員分
+	DealerBlackjack bool                `json:"dealer_blackjack"`
+	DealerBust      bool                `json:"dealer_bust"`
+	Seed            string              `json:"seed,omitempty"` // round_commit で送った SHA-256 の元（送ったラウンドは必須）
+}
+
+type PlayerRoundResult struct {
+	UserID    int64  `json:"user_id"`
+	Result    string `json:"result"` // "win" / "lose" / "push"
+	Blackjack bool   `json:"blackjack"`
+	Bust      bool   `json:"bust"` // バーストなら result は "lose"
+}
+
+// クライアント→サーバー：配る前のシードの SHA-256（ディーラーだけ・1ラウンドに1回）
+type RoundCommitCommand struct {
+	Type           string `json:"type"`            // "round_commit"
+	SeedCommitment string `json:"seed_commitment"` // 16進 64 文字
+}
+
+// クライアント→サーバー：配った札・自分の操作（経過に残すだけ）
+// deal / reveal はディーラーだけ。hit / stand / double / split / surrender はディーラーとベットを確定した人。
+type RoundActionCommand struct {
+	Type string `json:"type"` // "round_action"
+	models.RoundActionEvent
+}
+
+// サーバー→クライアント：精算の結果（卓の全員へ。この後に bet_state も届く）
+type RoundSettledBroadcast struct {
+	Type      string                 `json:"type"` // "round_settled"
+	RoomCode  string                 `json:"room_code"`
+	GameID    int64                  `json:"game_id"`
+	Round     int                    `json:"round"`              // ゲームの中で何ラウンド目か（1〜）
+	RoundID   int64                  `json:"round_id,omitempty"` // 経過の ID（/history/rounds/{round_id}。保存に失敗したら無し）
+	DealerID  int64                  `json:"dealer_id"`
+	DealerNet int                    `json:"dealer_net"` // ディーラーの収支
+	Results   []models.SettledResult `json:"results"`    // 席順
+}
+
+// サーバー→クライアント：round_commit / round_action / round_result を受け付けなかった（送った人にだけ返す）
+type TableErrorResponse struct {
+	Type    string        `json:"type"` // "table_error"
+	Code    response.Code `json:"code"`
+	Message string        `json:"message"` // 接続した人の言語の文言
+}
+
+// handleRoundResult は受けた round_result を卓の担当で精算する（担当が別インスタンスなら転送）
+func handleRoundResult(r *http.Request, repo store.Store, roomCode string, userID int64, lang response.Lang, cmd RoundResultCommand) {
+	ctx, span := startMessageSpan(r, "WS blackjack "+cmd.Type, roomCode)
+	defer span.End()
+	repo = repo.WithContext(ctx)
+
+	if !ownsTable(repo, roomCode) {
+		sendTableCommand(ctx, tableCommand{Kind: "round_result", Room: roomCode, UserID: userID, Result: &cmd, Lang: lang})
+		return
+	}
+	applyRoundResult(ctx, repo, roomCode, userID, lang, cmd)
+}
+
+// handleRoundCommit は受けた round_commit を卓の担当で経過に残す（担当が別インスタンスなら転送）
+func handleRoundCommit(r *http.Request, repo store.Store, roomCode string, userID int64, lang response.Lang, cmd RoundCommitCommand) {
+	ctx, span := startMessageSpan(r, "WS blackjack "+cmd.Type, roomCode)
+	defer span.End()
+	repo = repo.WithContext(ctx)
+
+	if !ownsTable(repo, roomCode) {
+		sendTableCommand(ctx, tableCommand{Kind: "round_commit", Room: roomCode, UserID: userID, Commit: &cmd, Lang: lang})
+		return
+	}
+	applyRoundCommit(ctx, repo, roomCode, userID, lang, cmd)
+}
+
+// handleRoundAction は受けた round_action を卓の担当で経過に残す（担当が別インスタンスなら転送）
+func handleRoundAction(r *http.Request, repo store.Store, roomCode string, userID int64, lang response.Lang, cmd RoundActionCommand) {
+	ctx, span := startMessageSpan(r, "WS blackjack "+cmd.Type, roomCode)
+	defer span.End()
+	repo = repo.WithContext(ctx)
+
+	if !ownsTable(repo, roomCode) {
+		sendTableCommand(ctx, tableCommand{Kind: "round_action", Room: roomCode, UserID: userID, Action: &cmd, Lang: lang})
+		return
+	}
+	applyRoundAction(ctx, repo, roomCode, userID, lang, cmd)
+}
+
+// applyRoundResult はラウンドを精算して結果を保存し、全員へ通知する（担当インスタンスで呼ぶ）
+func applyRoundResult(ctx context.Context, repo store.Store, roomCode string, userID int64, lang response.Lang, cmd RoundResultCommand) {
+	bjMu.Lock()
+	st, ok := bjRoomStates[roomCode]
+	if !ok {
+		bjMu.Unlock()
+		return
+	}
+	settled, log, records, code := settleRound(st, roomCode, userID, cmd, time.Now())
+	bjMu.Unlock()
+
+	if code != "" {
+		sendTableError(ctx, roomCode, userID, lang, code)
+		return
+	}
+	settled.RoomCode = roomCode
+	roundsSettled.Inc()
+	slog.Info("round settled", "room", roomCode, "game_id", settled.GameID, "round", settled.Round, "dealer_net", settled.DealerNet)
+
+	// 卓の状態は精算済みなので、保存に失敗しても戦績と経過が欠けるだけにする
+	if id, err := repo.Rounds().Record(log, records); err != nil {
+		slog.Error("round results save failed", "room", roomCode, "game_id", settled.GameID, "round", settled.Round, "err", err)
+	} else {
+		settled.RoundID = id
+	}
+	sendTable(ctx, roomCode, 0, settled)
+	broadcastBetState(ctx, roomCode)
+	persistBJState(repo, roomCode)
+}
+
+// applyRoundCommit はシードの SHA-256 を経過に残す（担当インスタンスで呼ぶ）
+func applyRoundCommit(ctx context.Context, repo store.Store, roomCode string, userID int64, lang response.Lang, cmd RoundCommitCommand) {
+	bjMu.Lock()
+	st, ok := bjRoomStates[roomCode]
+	if !ok {
+		bjMu.Unlock()
+		return
+	}
+	code := commitRound(st, roomCode, userID, cmd, time.Now())
+	bjMu.Unlock()
+
+	if code != "" {
+		sendTableError(ctx, roomCode, userID, lang, code)
+		return
+	}
+	persistBJState(repo, roomCode)
+}
+
+// applyRoundAction は配った札・操作を経過に残す（担当インスタンスで呼ぶ）
+func applyRoundAction(ctx context.Context, repo store.Store, roomCode string, userID int64, lang response.Lang, cmd RoundActionCommand) {
+	bjMu.Lock()
+	st, ok := bjRoomStates[roomCode]
+	if !ok {
+		bjMu.Unlock()
+		return
+	}
+	code := recordRoundAction(st, roomCode, userID, cmd, time.Now())
+	bjMu.Unlock()
+
+	if code != "" {
+		sendTableError(ctx, roomCode, userID, lang, code)
+		return
+	}
+	persistBJState(repo, roomCode)
+}
+
+func sendTableError(ctx context.Context, roomCode string, userID int64, lang response.Lang, code response.Code) {
+	slog.Info("table command rejected", "room", roomCode, "user_id", userID, "code", code)
+	sendTable(ctx, roomCode, userID, TableErrorResponse{Type: "table_error", Code: code, Message: response.MessageIn(lang, code)})
+}
+
+// ---- 卓の状態の操作（bjMu を持って呼ぶ。受け付けられなければ何も変えずに code を返す）----
+
+// settleRound は dealerID が送った結果で st を精算し、閉じたラウンドの経過と保存する結果を返す
+func settleRound(st *BJRoomState, roomCode string, dealerID int64, cmd RoundResultCommand, now time.Time) (RoundSettledBroadcast, models.RoundLog, []models.RoundResult, response.Code) {
+	var out RoundSettledBroadcast
+	var log models.RoundLog
+	if st.DealerID != dealerID {
+		return out, log, nil, response.CodeNotDealer
+	}
+	if st.gameID == 0 {
+		return out, log, nil, response.CodeGameNotFound
+	}
+
+	// 精算するのはベットを確定したディーラー以外の全員（未確定のベットは次のラウンドへ持ち越す）
+	bettors := roundBettors(st)
+	if len(bettors) == 0 {
+		return out, log, nil, response.CodeNoBets
+	}
+	if len(cmd.Results) != len(bettors) || (cmd.DealerBlackjack && cmd.DealerBust) || len(cmd.Seed) > models.MaxSeedLength {
+		return out, log, nil, response.CodeInvalidRoundResult
+	}
+	byUser := make(map[int64]PlayerRoundResult, len(cmd.Results))
+	for _, res := range cmd.Results {
+		_, isBettor := bettors[res.UserID]
+		_, dup := byUser[res.UserID]
+		if !isBettor || dup || !validOutcome(res.Result, res.Blackjack, res.Bust) ||
+			!validAgainstDealer(res, cmd.DealerBlackjack, cmd.DealerBust) {
+			return out, log, nil, response.CodeInvalidRoundResult
+		}
+		byUser[res.UserID] = res
+	}
+	// round_commit を送ったラウンドはシードを明かさないと精算できない
+	if st.log != nil && st.log.SeedCommitment != "" && (cmd.Seed == "" || !models.SeedMatches(cmd.Seed, st.log.SeedCommitment)) {
+		return out, log, nil, response.CodeSeedMismatch
+	}
+
+	// ---- ここから先は失敗しない ----
+	beginRoundLog(st, roomCode, now)
+	st.round++
+	out = RoundSettledBroadcast{Type: "round_settled", GameID: st.gameID, Round: st.round, DealerID: st.DealerID}
+	var records []models.RoundResult
+	for _, id := range st.Seats {
+		res, ok := byUser[id]
+		if !ok {
+			continue
+		}
+		p := bettors[id]
+		payout := roundPayout(p.Bet, res.Result, res.Blackjack)
+		net := payout - p.Bet
+		p.TotalChips += payout
+		recordChips("round_payout", payout)
+		out.Results = append(out.Results, models.SettledResult{
+			UserID: id, Result: res.Result, Blackjack: res.Blackjack, Bust: res.Bust,
+			Bet: p.Bet, Payout: payout, Net: net, TotalChips: p.TotalChips,
+		})
+		records = append(records, models.RoundResult{
+			UserID: id, ModeID: st.modeID, GameID: st.gameID, RoundNo: st.round, Role: models.RoundRolePlayer,
+			Result: res.Result, Bet: p.Bet, Payout: payout, Net: net, Blackjack: res.Blackjack, Bust: res.Bust, CreatedAt: now,
+		})
+		out.DealerNet -= net
+		p.Bet, p.Confirmed = 0, false
+	}
+
+	if dealer, ok := st.Players[st.DealerID]; ok {
+		dealer.TotalChips += out.DealerNet
+	}
+	if out.DealerNet > 0 {
+		recordChips("dealer_gain", out.DealerNet)
+	} else {
+		recordChips("dealer_loss", -out.DealerNet)
+	}
+	records = append(records, models.RoundResult{
+		UserID: st.DealerID, ModeID: st.modeID, GameID: st.gameID, RoundNo: st.round, Role: models.RoundRoleDealer,
+		Result: netResult(out.DealerNet), Net: out.DealerNet, Blackjack: cmd.DealerBlackjack, Bust: cmd.DealerBust, CreatedAt: now,
+	})
+
+	// 経過を閉じる（次のイベントで次のラウンドの経過が始まる）
+	appendRoundEvent(st, roomCode, models.RoundEvent{At: now, Kind: models.RoundEventSettle, UserID: dealerID, Settle: &models.RoundSettleEvent{
+		DealerNet: out.DealerNet, DealerBlackjack: cmd.DealerBlackjack, DealerBust: cmd.DealerBust, Results: out.Results,
+	}})
+	log = *st.log
+	st.log = nil
+	log.GameID, log.ModeID, log.RoundNo = st.gameID, st.modeID, st.round
+	log.Seed, log.SettledAt = cmd.Seed, now
+	return out, log, records, ""
+}
+
+// commitRound はディーラーが送ったシードの SHA-256 を経過に残す
+func commitRound(st *BJRoomState, roomCode string, userID int64, cmd RoundCommitCommand, now time.Time) response.Code {
+	if st.DealerID != userID {
+		return response.CodeNotDealer
+	}
+	if st.gameID == 0 {
+		return response.CodeGameNotFound
+	}
+	if !models.IsSeedCommitment(cmd.SeedCommitment) || (st.log != nil && st.log.SeedCommitment != "") {
+		return response.CodeInvalidRoundAction
+	}
+	commitment := strings.ToLower(cmd.SeedCommitment)
+	beginRoundLog(st, roomCode, now)
+	st.log.SeedCommitment = commitment
+	appendRoundEvent(st, roomCode, models.RoundEvent{At: now, Kind: models.RoundEventCommit, UserID: userID,
+		Commit: &models.RoundCommitEvent{SeedCommitment: commitment}})
+	return ""
+}
+
+// recordRoundAction は配った札・操作を経過に残す
+func recordRoundAction(st *BJRoomState, roomCode string, userID int64, cmd RoundActionCommand, now time.Time) response.Code {
+	if st.gameID == 0 {
+		return response.CodeGameNotFound
+	}
+	a := cmd.RoundActionEvent
+	if !models.IsRoundAction(a.Action) || (a.Card != "" && !models.IsCard(a.Card)) {
+		return response.CodeInvalidRoundAction
+	}
+	bettors := roundBettors(st)
+	_, isBettor := bettors[userID]
+	switch a.Action {
+	case "deal":
+		// 配り先はディーラー自身かベットを確定した人
+		if st.DealerID != userID {
+			return response.CodeNotDealer
+		}
+		if _, ok := bettors[a.To]; !ok && a.To != st.DealerID {
+			return response.CodeInvalidRoundAction
+		}
+	case "reveal":
+		// 伏せていたディーラーの札を開く
+		if st.DealerID != userID {
+			return response.CodeNotDealer
+		}
+		if a.Card == "" || a.To != 0 {
+			return response.CodeInvalidRoundAction
+		}
+	default:
+		if (st.DealerID != userID && !isBettor) || a.To != 0 {
+			return response.CodeInvalidRoundAction
+		}
+	}
+	beginRoundLog(st, roomCode, now)
+	appendRoundEvent(st, roomCode, models.RoundEvent{At: now, Kind: models.RoundEventAction, UserID: userID, Action: &a})
+	return ""
+}
+
+// roundBettors はこのラウンドで精算するプレイヤー（ディーラー以外でベットを確定した人）
+func roundBettors(st *BJRoomState) map[int64]*BJBetPlayerState {
+	bettors := make(map[int64]*BJBetPlayerState)
+	for id, p := range st.Players {
+		if id != st.DealerID && p.Confirmed && p.Bet > 0 {
+			bettors[id] = p
+		}
+	}
+	return bettors
+}
+
+// beginRoundLog はラウンドの経過が無ければ始め、その時点の席と手持ちを start として残す
+func beginRoundLog(st *BJRoomState, roomCode string, now time.Time) {
+	if st.log != nil {
+		return
+	}
+	st.log = &models.RoundLog{ModeID: st.modeID, GameID: st.gameID, RoundNo: st.round + 1, RoomCode: roomCode, StartedAt: now}
+	start := &models.RoundStartEvent{DealerID: st.DealerID, Players: []models.RoundSeat{}}
+	for _, id := range st.Seats {
+		if p, ok := st.Players[id]; ok {
+			start.Players = append(start.Players, models.RoundSeat{UserID: id, SeatNo: p.SeatNo, Chips: p.TotalChips, Bet: p.Bet})
+		}
+	}
+	st.log.Append(models.RoundEvent{At: now, Kind: models.RoundEventStart, Start: start})
+}
+
+// appendRoundEvent は経過にイベントを足す（beginRoundLog の後に呼ぶ。上限を超えた分は捨てる）
+func appendRoundEvent(st *BJRoomState, roomCode string, ev models.RoundEvent) {
+	if !st.log.Append(ev) {
+		slog.Warn("round log is full, event dropped", "room", roomCode, "kind", ev.Kind, "user_id", ev.UserID)
+	}
+}
+
+// validOutcome は結果とブラックジャック・バーストの組み合わせがあり得るか
+func validOutcome(result string, blackjack, bust bool) bool {
+	switch result {
+	case models.RoundWin, models.RoundLose, models.RoundPush:
+	default:
+		return false
+	}
+	if blackjack && bust {
+		return false
+	}
+	// バーストは必ず負け、ブラックジャックは負けない（相手もブラックジャックなら引き分け）
+	if bust && result != models.RoundLose {
+		return false
+	}
+	return !(blackjack && result == models.RoundLose)
+}
+
+// validAgainstDealer はプレイヤーの結果がディーラーの手と矛盾しないか
+//   - ディーラーがブラックジャック：ブラックジャックなら引き分け、それ以外は負け
+//   - プレイヤーだけブラックジャック：勝ち
+//   - ディーラーがバースト：バーストしていなければ勝ち
+func validAgainstDealer(res PlayerRoundResult, dealerBlackjack, dealerBust bool) bool {
+	switch {
+	case dealerBlackjack && res.Blackjack:
+		return res.Result == models.RoundPush
+	case dealerBlackjack:
+		return res.Result == models.RoundLose
+	case res.Blackjack:
+		return res.Result == models.RoundWin
+	case dealerBust && !res.Bust:
+		return res.Result == models.RoundWin
+	}
+	return true
+}
+
+// roundPayout はベット bet に対して戻すチップ（ベット分を含む）
+func roundPayout(bet int, result string, blackjack bool) int {
+	switch {
+	case result == models.RoundWin && blackjack:
+		return bet + bet*3/2
+	case result == models.RoundWin:
+		return bet * 2
+	case result == models.RoundPush:
+		return bet
+	}
+	return 0
+}
+
+// netResult は収支から勝ち・負け・引き分けを決める（ディーラー用）
+func netResult(net int) string {
+	switch {
+	case net > 0:
+		return models.RoundWin
+	case net < 0:
+		return models.RoundLose
+	}
+	return models.RoundPush
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"api/internal/models"
+	"testing"
+)
+
+// ソロの chip_diff はベットと結果で動きうる範囲まで（スプリット・ダブルは actions の分だけ）
+func TestCheckSoloRoundCapsChipDiff(t *testing.T) {
+	actions := func(names ...string) []SoloRoundAction {
+		var list []SoloRoundAction
+		for _, n := range names {
+			list = append(list, SoloRoundAction{RoundActionEvent: models.RoundActionEvent{Action: n}})
+		}
+		return list
+	}
+	cases := []struct {
+		name     string
+		round    SoloRoundResult
+		chipDiff int
+		ok       bool
+	}{
+		{"win", SoloRoundResult{Bet: 10, Result: "win"}, 10, true},
+		{"win over the bet", SoloRoundResult{Bet: 1, Result: "win"}, 1000000, false},
+		{"win nothing", SoloRoundResult{Bet: 10, Result: "win"}, 0, false},
+		{"blackjack", SoloRoundResult{Bet: 15, Result: "win", Blackjack: true}, 22, true},
+		{"blackjack paid 1x", SoloRoundResult{Bet: 15, Result: "win", Blackjack: true}, 15, false},
+		{"blackjack overpaid", SoloRoundResult{Bet: 15, Result: "win", Blackjack: true}, 30, false},
+		{"double", SoloRoundResult{Bet: 10, Result: "win", Actions: actions("double")}, 20, true},
+		{"double twice on one hand", SoloRoundResult{Bet: 10, Result: "win", Actions: actions("double", "double")}, 30, false},
+		{"split and double", SoloRoundResult{Bet: 10, Result: "win", Actions: actions("split", "double", "double")}, 40, true},
+		{"split over", SoloRoundResult{Bet: 10, Result: "win", Actions: actions("split")}, 21, false},
+		{"lose", SoloRoundResult{Bet: 10, Result: "lose"}, -10, true},
+		{"lose over the bet", SoloRoundResult{Bet: 10, Result: "lose"}, -11, false},
+		{"lose doubled", SoloRoundResult{Bet: 10, Result: "lose", Actions: actions("double")}, -20, true},
+		{"push", SoloRoundResult{Bet: 10, Result: "push"}, 0, true},
+		{"push with a gain", SoloRoundResult{Bet: 10, Result: "push"}, 5, false},
+	}
+	for _, c := range cases {
+		fields := checkSoloRound(c.round, 1, c.chipDiff)
+		if ok := len(fields) == 0; ok != c.ok {
+			t.Errorf("%s: fields = %v, want ok=%v", c.name, fields, c.ok)
+		}
+	}
+}
+
+// プレイヤーの結果はディーラーのブラックジャック・バーストと矛盾しないものだけ
+func TestValidAgainstDealer(t *testing.T) {
+	cases := []struct {
+		res                  PlayerRoundResult
+		dealerBJ, dealerBust bool
+		want                 bool
+	}{
+		{PlayerRoundResult{Result: "win"}, false, false, true},
+		{PlayerRoundResult{Result: "lose"}, false, false, true},
+		{PlayerRoundResult{Result: "win"}, true, false, false},
+		{PlayerRoundResult{Result: "push"}, true, false, false},
+		{PlayerRoundResult{Result: "lose"}, true, false, true},
+		{PlayerRoundResult{Result: "push", Blackjack: true}, true, false, true},
+		{PlayerRoundResult{Result: "win", Blackjack: true}, true, false, false},
+		{PlayerRoundResult{Result: "push", Blackjack: true}, false, false, false},
+		{PlayerRoundResult{Result: "win", Blackjack: true}, false, false, true},
+		{PlayerRoundResult{Result: "win"}, false, true, true},
+		{PlayerRoundResult{Result: "lose"}, false, true, false},
+		{PlayerRoundResult{Result: "lose", Bust: true}, false, true, true},
+	}
+	for _, c := range cases {
+		if got := validAgainstDealer(c.res, c.dealerBJ, c.dealerBust); got != c.want {
+			t.Errorf("validAgainstDealer(%+v, bj=%v, bust=%v) = %v, want %v", c.res, c.dealerBJ, c.dealerBust, got, c.want)
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -30,15 +30,13 @@ func startActionTimer(conn *websocket.Conn, totalSec float64) {
 
 			// WebSocket送信
 			if err := writeJSONSafe(conn, msg); err != nil {
-				log.Println("timer send error:", err)
+				slog.Debug("timer send failed", "err", err)
 				return
 			}
 
-			log.Printf("timer: remaining=%.1f", remaining)
-
 			// 終了
 			if remaining <= 0 {
-				log.Println("timer finished")
+				slog.Debug("action timer finished")
 				return
 			}
 
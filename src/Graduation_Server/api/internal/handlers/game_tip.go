@@ -1,10 +1,17 @@
 package handlers
 
 import (
-	"log"
+	"api/internal/models"
+	"api/internal/tracing"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sort"
 	"sync"
 
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // プレイヤーごとのベット状態
@@ -14,12 +21,38 @@ type BJBetPlayerState struct {
 	Bet        int    `json:"bet"`
 	Confirmed  bool   `json:"confirmed"`
 	TotalChips int    `json:"total_chips"`
+	SeatNo     int    `json:"seat_no"`
 }
 
 // ルームごとのブラックジャック状態
 type BJRoomState struct {
 	Players  map[int64]*BJBetPlayerState // userID -> state
 	DealerID int64
+	Seats    []int64 // 席順に並べた userID（手番・ディーラー交代の順番）
+
+	dealerSeatNo int   // ディーラーの席番号（ディーラーが抜けた後の交代先を決めるため）
+	seq          int64 // 保存のたびに増やす通し番号（bj_snapshot.go）
+
+	gameID int64 // 卓で遊んでいるゲーム（games.id。ルームの開始で決まる）
+	modeID int
+	round  int              // 精算済みのラウンド数（game_round.go）
+	log    *models.RoundLog // 進行中のラウンドの経過（精算で閉じる。nil なら次のイベントで始める）
+}
+
+// Players の SeatNo から Seats（席順の userID 一覧）を作り直す
+func (s *BJRoomState) refreshSeatOrder() {
+	seats := make([]int64, 0, len(s.Players))
+	for id := range s.Players {
+		seats = append(seats, id)
+	}
+	sort.Slice(seats, func(i, j int) bool {
+		a, b := s.Players[seats[i]], s.Players[seats[j]]
+		if a.SeatNo != b.SeatNo {
+			return a.SeatNo < b.SeatNo
+		}
+		return a.UserID < b.UserID
+	})
+	s.Seats = seats
 }
 
 // ルームコードごとの状態・接続
@@ -44,19 +77,35 @@ type BetStateBroadcast struct {
 	AllConfirmed bool               `json:"all_confirmed"` // 全員決定済みか
 }
 
-// 全員の状態をそのルームの全WS接続へブロードキャスト
-func broadcastBetState(roomCode string) {
+// 全員の状態をそのルームの全WS接続へブロードキャスト。
+// スナップショット作成から送信までを bjWriteMu で囲み、古い状態が新しい状態を追い越さないようにする。
+func broadcastBetState(ctx context.Context, roomCode string) {
+	ctx, span := tracing.Start(ctx, "broadcast bet_state",
+		trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(attribute.String("room", roomCode)))
+	defer span.End()
+
+	bjWriteMu.Lock()
+	defer bjWriteMu.Unlock()
+
 	bjMu.Lock()
 	state, ok := bjRoomStates[roomCode]
 	if !ok {
 		bjMu.Unlock()
 		return
 	}
-	conns := bjRoomConns[roomCode]
-	// スナップショット作成
+	// 接続一覧もロック中にコピーする（マップのまま持ち出すと登録・削除と競合する）
+	conns := make([]*websocket.Conn, 0, len(bjRoomConns[roomCode]))
+	for c := range bjRoomConns[roomCode] {
+		conns = append(conns, c)
+	}
+	// スナップショット作成（席順）
 	var players []BJBetPlayerState
 	allConfirmed := true
-	for _, p := range state.Players {
+	for _, id := range state.Seats {
+		p, ok := state.Players[id]
+		if !ok {
+			continue
+		}
 		players = append(players, *p)
 		// ★ ディーラーは allConfirmed 判定から除外
 		if p.UserID == state.DealerID {
@@ -77,12 +126,16 @@ func broadcastBetState(roomCode string) {
 
 	var toDelete []*websocket.Conn
 
-	for c := range conns {
-		if err := writeJSONSafe(c, res); err != nil {
-			log.Println("bet_state send error:", err)
+	for _, c := range conns {
+		if err := c.WriteJSON(res); err != nil {
+			slog.Info("bet_state send failed", "room", roomCode, "err", err)
 			toDelete = append(toDelete, c)
 		}
 	}
+	// 他インスタンスにつないでいる人へ（bjWriteMu の中で流し、送信順を保つ）
+	if raw, err := json.Marshal(res); err == nil {
+		publishEvent(ctx, roomEvent{Kind: "table", Room: roomCode, Data: raw})
+	}
 
 	if len(toDelete) > 0 {
 		bjMu.Lock()
@@ -93,3 +146,32 @@ func broadcastBetState(roomCode string) {
 		}
 	}
 }
+
+// sendTable は卓の userID（0 なら全員）へメッセージを送る（他インスタンスにつないでいても届く）
+func sendTable(ctx context.Context, roomCode string, userID int64, v interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("table message encode failed", "room", roomCode, "err", err)
+		return
+	}
+	localTableSend(roomCode, userID, raw)
+	publishEvent(ctx, roomEvent{Kind: "table", Room: roomCode, UserID: userID, Data: raw})
+}
+
+// localTableSend はこのインスタンスの卓の接続へ送る（送れなかった接続は受信ループ側で片付く）
+func localTableSend(roomCode string, userID int64, raw json.RawMessage) {
+	bjMu.Lock()
+	var targets []*websocket.Conn
+	for c, uid := range bjRoomConns[roomCode] {
+		if userID == 0 || uid == userID {
+			targets = append(targets, c)
+		}
+	}
+	bjMu.Unlock()
+
+	for _, c := range targets {
+		if err := writeJSONSafe(c, raw); err != nil {
+			slog.Info("table send failed", "room", roomCode, "err", err)
+		}
+	}
+}
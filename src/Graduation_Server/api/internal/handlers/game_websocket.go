@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"api/internal/logging"
 	"api/internal/middleware"
 	"api/internal/models"
-	"database/sql"
+	"api/internal/response"
+	"api/internal/store"
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"math/rand"
 	"net/http"
 	"sync"
@@ -25,9 +28,7 @@ type PlayerMessage struct {
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Unityからの接続を許可
-	},
+	CheckOrigin:     middleware.CheckOrigin, // Unity（Origin無し）と設定で許可したOriginのみ
 }
 
 // ブラックジャック用：書き込み排他制御
@@ -43,179 +44,130 @@ func init() {
 	rand.Seed(time.Now().UnixNano())
 }
 
-func BlackjackWebSocketHandle(db *sql.DB) http.HandlerFunc {
+func BlackjackWebSocketHandle(repo store.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
+		// ルームコード取得（/api/ws/blackjackwebsocket/{room_code}）
+		roomCode := mux.Vars(r)["room_code"]
+		// 以降のログには request_id / user_id / room が付く
+		logger := logging.FromContext(logging.With(r.Context(), "room", roomCode))
+
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
-			log.Println("WebSocket upgrade error:", err)
+			logger.Warn("blackjack ws upgrade failed", "err", err)
 			return
 		}
 		defer conn.Close()
 
-		log.Println("Blackjack WS connected")
-
-		// ルームコード取得（/api/ws/blackjackwebsocket/{room_code}）
-		roomCode := mux.Vars(r)["room_code"]
-		log.Println("[BJWS] roomCode =", roomCode)
-
 		// ユーザーID取得（JWTから）
 		userID := middleware.GetUserID(r)
 		if userID == 0 {
-			log.Println("Unauthorized")
+			logger.Warn("blackjack ws unauthorized")
 			return
 		}
-		log.Println("[BJWS] userID =", userID)
+		logger.Info("blackjack ws connected")
+		// エラーの文言の言語は接続時に決める
+		lang := response.Language(r)
+		limiter := newWSMessageLimiter(conn, lang)
+
+		// 入室時の処理は接続（アップグレードした HTTP リクエスト）のスパンにぶら下げる
+		repo := repo.WithContext(r.Context())
 
 		// ルーム情報取得
-		room, err := models.GetRoomByCode(db, roomCode)
+		room, err := repo.Rooms().GetByCode(roomCode)
 		if err != nil {
-			log.Println("Room not found:", err)
+			logger.Warn("blackjack ws room not found", "err", err)
 			return
 		}
 
 		// DBから現在のプレイヤー一覧取得
-		users, err := models.GetUsersInRoom(db, room.ID)
+		users, err := repo.RoomUsers().List(room.ID)
 		if err != nil {
-			log.Println("GetUsersInRoom failed:", err)
+			logger.Error("room users lookup failed", "err", err)
 			return
 		}
 		if len(users) == 0 {
-			log.Println("[BJWS] no users in room")
+			logger.Warn("blackjack ws: no users in room")
 			return
 		}
 
-		// ===== ブラックジャック用メモリ状態初期化 =====
-		bjMu.Lock()
+		// ===== 卓への参加 =====
+		if ownsTable(repo, roomCode) {
+			players, dealerID := joinTable(repo, roomCode, users)
 
-		state, ok := bjRoomStates[roomCode]
-		if !ok {
-			state = &BJRoomState{
-				Players: make(map[int64]*BJBetPlayerState),
+			// ===== 最初にプレイヤー並び情報を送信 =====
+			// 接続登録より先に送り、他の人のブロードキャストが player_order を追い越さないようにする
+			if err := SendPlayerOrder(conn, players); err != nil {
+				logger.Warn("player_order send failed", "err", err)
+			} else {
+				logger.Debug("player_order sent", "dealer_id", dealerID)
 			}
-			bjRoomStates[roomCode] = state
-		}
-
-		// プレイヤー状態を BJRoomState に登録
-		for _, u := range users {
-			if _, exists := state.Players[u.UserID]; !exists {
-				state.Players[u.UserID] = &BJBetPlayerState{
-					UserID:     u.UserID,
-					Name:       u.UserName,
-					Bet:        0,
-					Confirmed:  false,
-					TotalChips: 1000,
-				}
-			}
-		}
-
-		// ★ ここで DealerID を 1 回だけ決定 or 既存のものを使う
-		dealerID := EnsureDealerAssigned(state)
-
-		// 接続管理
-		if bjRoomConns[roomCode] == nil {
-			bjRoomConns[roomCode] = make(map[*websocket.Conn]int64)
-		}
-		bjRoomConns[roomCode][conn] = userID
-
-		bjMu.Unlock()
-
-		// ===== PlayerInfo配列を作成 =====
-		players := make([]PlayerInfo, 0, len(users))
-		for _, u := range users {
-			players = append(players, PlayerInfo{
-				UserID:   u.UserID,
-				Name:     u.UserName,
-				IsReady:  u.IsReady,
-				IsHost:   u.IsHost,
-				IsDealer: (u.UserID == dealerID), // ★ ここだけでOK
-			})
-		}
+			addTableConn(roomCode, conn, userID)
 
-		// ===== 最初にプレイヤー並び情報を送信 =====
-		if err := SendPlayerOrder(conn, players); err != nil {
-			log.Println("player_order send error:", err)
+			// 入室直後に現在のベット状態を送る
+			broadcastBetState(r.Context(), roomCode)
 		} else {
-			log.Printf("[BJWS] player_order sent (dealerID=%d)\n", dealerID)
+			// 卓の担当は別インスタンス：接続だけ登録し、player_order と bet_state は担当から届く
+			addTableConn(roomCode, conn, userID)
+			sendTableCommand(r.Context(), tableCommand{Kind: "join", Room: roomCode, UserID: userID})
 		}
 
-		// 入室直後に現在のベット状態を送る
-		broadcastBetState(roomCode)
-
-		// ゲーム開始タイミングで 15秒タイマー開始（暫定：接続時）
-		startActionTimer(conn, 15.0)
+		// ゲーム開始タイミングで行動タイマー開始（暫定：接続時）
+		startActionTimer(conn, cfg.Timers.Action.Seconds())
 
 		// ===== 受信ループ =====
 		for {
 			_, raw, err := conn.ReadMessage()
 			if err != nil {
-				log.Println("Blackjack WS closed:", err)
+				logger.Info("blackjack ws closed", "err", err)
 				break
 			}
 
-			log.Println("Blackjack Received:", string(raw))
-
-			var cmd BetCommand
-			if err := json.Unmarshal(raw, &cmd); err != nil {
-				log.Println("BetCommand json error:", err)
-				continue
-			}
-
-			if cmd.Type != "bet_update" {
-				log.Println("Unknown blackjack ws message type:", cmd.Type)
+			logger.Debug("blackjack ws received", "msg_bytes", len(raw))
+			if !limiter.allow(logger, func(v interface{}) error { return writeJSONSafe(conn, v) }) {
 				continue
 			}
 
-			// ==== ベット更新処理 ====
-			bjMu.Lock()
-			st, ok := bjRoomStates[roomCode]
-			if !ok {
-				bjMu.Unlock()
-				continue
+			var head struct {
+				Type string `json:"type"`
 			}
-			p, ok := st.Players[userID]
-			if !ok {
-				bjMu.Unlock()
+			if err := json.Unmarshal(raw, &head); err != nil {
+				logger.Warn("invalid blackjack ws message", "err", err)
 				continue
 			}
 
-			// ディーラーはベット不可（サーバー側でも念のため弾く）
-			if st.DealerID == userID {
-				log.Println("[BJWS] dealer tried to bet, ignored")
-				bjMu.Unlock()
-				continue
-			}
-			if cmd.Bet < 0 {
-				log.Println("[BJWS] negative bet ignored:", cmd.Bet)
-				bjMu.Unlock()
-				continue
-			}
-			oldBet := p.Bet
-			newBet := cmd.Bet
-			delta := newBet - oldBet // 例: old=100 new=300 → delta=+200 (追加で200)
-
-			if delta > 0 {
-				// 追加で賭ける分のチップが足りるかチェック
-				if p.TotalChips < delta {
-					log.Printf("[BJWS] user %d not enough chips. have=%d, need=%d\n",
-						p.UserID, p.TotalChips, delta)
-					bjMu.Unlock()
+			switch head.Type {
+			case "bet_update":
+				var cmd BetCommand
+				if err := json.Unmarshal(raw, &cmd); err != nil {
+					logger.Warn("invalid blackjack ws message", "err", err)
 					continue
 				}
-				p.TotalChips -= delta
-			} else if delta < 0 {
-				// ベット額を減らした場合は、差分だけチップを戻す（必要なら）
-				// もし「一度確定したら減らせない」仕様にするなら、ここは無視してもOK
-				p.TotalChips -= delta // delta はマイナスなので実質 +abs(delta)
+				handleBetCommand(r, repo, roomCode, userID, cmd)
+			case "round_result":
+				var cmd RoundResultCommand
+				if err := json.Unmarshal(raw, &cmd); err != nil {
+					logger.Warn("invalid blackjack ws message", "err", err)
+					continue
+				}
+				handleRoundResult(r, repo, roomCode, userID, lang, cmd)
+			case "round_commit":
+				var cmd RoundCommitCommand
+				if err := json.Unmarshal(raw, &cmd); err != nil {
+					logger.Warn("invalid blackjack ws message", "err", err)
+					continue
+				}
+				handleRoundCommit(r, repo, roomCode, userID, lang, cmd)
+			case "round_action":
+				var cmd RoundActionCommand
+				if err := json.Unmarshal(raw, &cmd); err != nil {
+					logger.Warn("invalid blackjack ws message", "err", err)
+					continue
+				}
+				handleRoundAction(r, repo, roomCode, userID, lang, cmd)
+			default:
+				logger.Warn("unknown blackjack ws message type", "type", head.Type)
 			}
-
-			p.Bet = cmd.Bet
-			p.Confirmed = cmd.Confirm
-
-			bjMu.Unlock()
-
-			// 全員分の最新状態を broadcast
-			broadcastBetState(roomCode)
 		}
 
 		// ===== 接続終了処理 =====
@@ -231,3 +183,160 @@ func BlackjackWebSocketHandle(db *sql.DB) http.HandlerFunc {
 		bjMu.Unlock()
 	}
 }
+
+func addTableConn(roomCode string, conn *websocket.Conn, userID int64) {
+	bjMu.Lock()
+	if bjRoomConns[roomCode] == nil {
+		bjRoomConns[roomCode] = make(map[*websocket.Conn]int64)
+	}
+	bjRoomConns[roomCode][conn] = userID
+	bjMu.Unlock()
+}
+
+// joinTable はルームの参加者を卓の状態に登録し、席順の並びとディーラーを返す（担当インスタンスで呼ぶ）
+func joinTable(repo store.Store, roomCode string, users []models.RoomUser) ([]PlayerInfo, int64) {
+	// ===== ブラックジャック用メモリ状態初期化 =====
+	bjMu.Lock()
+
+	state, ok := bjRoomStates[roomCode]
+	if !ok {
+		state = &BJRoomState{
+			Players: make(map[int64]*BJBetPlayerState),
+		}
+		bjRoomStates[roomCode] = state
+	}
+
+	// プレイヤー状態を BJRoomState に登録（席番号は毎回 DB の値に合わせる）
+	for _, u := range users {
+		if p, exists := state.Players[u.UserID]; exists {
+			p.SeatNo = u.SeatNo
+			continue
+		}
+		state.Players[u.UserID] = &BJBetPlayerState{
+			UserID:     u.UserID,
+			Name:       u.UserName,
+			Bet:        0,
+			Confirmed:  false,
+			TotalChips: cfg.Chips.TableStart,
+			SeatNo:     u.SeatNo,
+		}
+	}
+	state.refreshSeatOrder()
+
+	// ★ DealerID は席順で決定 or 既存のものを使う
+	dealerID := EnsureDealerAssigned(state)
+
+	bjMu.Unlock()
+	persistBJState(repo, roomCode)
+
+	// ===== PlayerInfo配列を作成 =====
+	players := make([]PlayerInfo, 0, len(users))
+	for _, u := range users {
+		players = append(players, PlayerInfo{
+			UserID:   u.UserID,
+			Name:     u.UserName,
+			IsReady:  u.IsReady,
+			IsHost:   u.IsHost,
+			IsDealer: (u.UserID == dealerID), // ★ ここだけでOK
+			SeatNo:   u.SeatNo,
+		})
+	}
+	return players, dealerID
+}
+
+// joinTableFromDB は他インスタンスからの入室コマンド用に、参加者を DB から読んで joinTable する
+func joinTableFromDB(repo store.Store, roomCode string) ([]PlayerInfo, bool) {
+	room, err := repo.Rooms().GetByCode(roomCode)
+	if err != nil {
+		slog.Warn("table join: room not found", "room", roomCode, "err", err)
+		return nil, false
+	}
+	users, err := repo.RoomUsers().List(room.ID)
+	if err != nil || len(users) == 0 {
+		slog.Warn("table join: room users lookup failed", "room", roomCode, "users", len(users), "err", err)
+		return nil, false
+	}
+	players, _ := joinTable(repo, roomCode, users)
+	return players, true
+}
+
+// handleBetCommand は受けた bet_update を卓の担当で反映する（担当が別インスタンスなら転送）
+func handleBetCommand(r *http.Request, repo store.Store, roomCode string, userID int64, cmd BetCommand) {
+	ctx, span := startMessageSpan(r, "WS blackjack "+cmd.Type, roomCode)
+	defer span.End()
+	repo = repo.WithContext(ctx)
+
+	if !ownsTable(repo, roomCode) {
+		sendTableCommand(ctx, tableCommand{Kind: "bet", Room: roomCode, UserID: userID, Bet: cmd.Bet, Confirm: cmd.Confirm})
+		return
+	}
+	applyBet(ctx, repo, roomCode, userID, cmd)
+}
+
+// applyBet はベット更新を卓の状態に反映し、全員へ通知する（担当インスタンスで呼ぶ）
+func applyBet(ctx context.Context, repo store.Store, roomCode string, userID int64, cmd BetCommand) {
+	// ==== ベット更新処理 ====
+	bjMu.Lock()
+	st, ok := bjRoomStates[roomCode]
+	if !ok {
+		bjMu.Unlock()
+		return
+	}
+	p, ok := st.Players[userID]
+	if !ok {
+		bjMu.Unlock()
+		return
+	}
+
+	// ディーラーはベット不可（サーバー側でも念のため弾く）
+	if st.DealerID == userID {
+		slog.Warn("dealer tried to bet, ignored", "room", roomCode, "user_id", userID)
+		bjMu.Unlock()
+		return
+	}
+	if cmd.Bet < 0 {
+		slog.Warn("negative bet ignored", "room", roomCode, "user_id", userID, "bet", cmd.Bet)
+		bjMu.Unlock()
+		return
+	}
+	// 停止処理中は新しいラウンドを始めさせない（減らす・取り消すのだけ受け付ける）
+	if IsShuttingDown() && (cmd.Bet > p.Bet || cmd.Confirm && !p.Confirmed) {
+		slog.Info("bet rejected: shutting down", "room", roomCode, "user_id", userID, "bet", cmd.Bet)
+		bjMu.Unlock()
+		return
+	}
+	// ラウンドの経過はベットを動かす前の席と手持ちから始める
+	now := time.Now()
+	beginRoundLog(st, roomCode, now)
+	oldBet := p.Bet
+	newBet := cmd.Bet
+	delta := newBet - oldBet // 例: old=100 new=300 → delta=+200 (追加で200)
+
+	if delta > 0 {
+		// 追加で賭ける分のチップが足りるかチェック
+		if p.TotalChips < delta {
+			slog.Info("bet rejected: not enough chips", "room", roomCode, "user_id", p.UserID,
+				"have", p.TotalChips, "need", delta)
+			bjMu.Unlock()
+			return
+		}
+		p.TotalChips -= delta
+		recordChips("bet_placed", delta)
+	} else if delta < 0 {
+		// ベット額を減らした場合は、差分だけチップを戻す（必要なら）
+		// もし「一度確定したら減らせない」仕様にするなら、ここは無視してもOK
+		p.TotalChips -= delta // delta はマイナスなので実質 +abs(delta)
+		recordChips("bet_returned", -delta)
+	}
+
+	p.Bet = cmd.Bet
+	p.Confirmed = cmd.Confirm
+	appendRoundEvent(st, roomCode, models.RoundEvent{At: now, Kind: models.RoundEventBet, UserID: userID,
+		Bet: &models.RoundBetEvent{Bet: p.Bet, Confirmed: p.Confirmed, Chips: p.TotalChips}})
+
+	bjMu.Unlock()
+
+	// 全員分の最新状態を broadcast
+	broadcastBetState(ctx, roomCode)
+	persistBJState(repo, roomCode)
+}
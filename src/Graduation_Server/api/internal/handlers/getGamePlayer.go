@@ -2,36 +2,46 @@ package handlers
 
 import (
 	"api/internal/models"
-	"database/sql"
-	"encoding/json"
-	"log"
+	"api/internal/response"
+	"api/internal/store"
+	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 )
 
+// v1 の応答（旧ルートは players の配列だけを返す）
+type PlayersForGameResponse struct {
+	Players []models.PlayerGameInfo `json:"players"`
+}
+
 // GetPlayersForGameHandler は指定された room_id に所属するプレイヤー一覧を取得して返すハンドラ。
-func GetPlayersForGameHandler(db *sql.DB) http.HandlerFunc {
+func GetPlayersForGameHandler(repo store.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
 		// ---- URLパラメータから room_id を取得 ----
 		vars := mux.Vars(r)
 		roomIDStr := vars["room_id"]
 
 		roomID, err := strconv.ParseInt(roomIDStr, 10, 64)
 		if err != nil {
-			http.Error(w, "Invalid room_id", http.StatusBadRequest)
+			response.Invalid(w, r, response.Field("room_id", response.FieldInvalid))
 			return
 		}
 		// ---- DBからプレイヤー一覧を取得 ----
-		players, err := models.GetPlayersForGame(db, roomID)
+		players, err := repo.Games().PlayersForGame(roomID)
 		if err != nil {
-			log.Println("GetPlayersForGame error:", err)
-			http.Error(w, "Failed to get players", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "GetPlayersForGame failed", "room_id", roomID, "err", err)
+			response.Internal(w, r)
 			return
 		}
 		// ---- JSONレスポンス返却 ----
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(players)
+		// 旧ルートは配列をそのまま返していたので、そのまま残す
+		if response.IsLegacy(r) {
+			response.OK(w, r, players)
+			return
+		}
+		response.OK(w, r, PlayersForGameResponse{Players: players})
 	}
 }
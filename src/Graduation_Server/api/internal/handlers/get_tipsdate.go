@@ -2,9 +2,10 @@ package handlers
 
 import (
 	"api/internal/middleware"
+	"api/internal/response"
+	"api/internal/store"
 	"database/sql"
-	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
@@ -16,39 +17,34 @@ type ChipResponse struct {
 
 // GetChipDataHandler は認証済みユーザーのチップ数を返すHTTPハンドラ。
 // JWT などの認証を middleware.GetUserID で確認し、DBからtipsテーブルを参照する。
-func GetChipDataHandler(db *sql.DB) http.Handler {
+func GetChipDataHandler(repo store.Store) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
 		// ---- 認証確認 ----
 		userID := middleware.GetUserID(r)
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
 			return
 		}
 
-		var soloChip, multiChip int
-		err := db.QueryRow(`
-            SELECT solo_tip_count, multi_tip_count
-            FROM tips
-            WHERE user_id = ?
-        `, userID).Scan(&soloChip, &multiChip)
+		tip, err := repo.Tips().Get(userID)
 		// ---- エラーハンドリング ----
 		if err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "Chip data not found", http.StatusNotFound)
+				response.Fail(w, r, http.StatusNotFound, response.CodeNotFound)
 			} else {
-				log.Printf("[DB ERROR] %v", err)
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				slog.ErrorContext(r.Context(), "chip data lookup failed", "err", err)
+				response.Internal(w, r)
 			}
 			return
 		}
 		// ---- レスポンス作成 ----
-		response := ChipResponse{
-			SoloChip:  soloChip,
-			MultiChip: multiChip,
+		resp := ChipResponse{
+			SoloChip:  tip.SoloTipCount,
+			MultiChip: tip.MultiTipCount,
 		}
 
-		w.Header().Set("Content-Type", "application/json")
 		// JSONでレスポンス返却
-		json.NewEncoder(w).Encode(response)
+		response.OK(w, r, resp)
 	})
 }
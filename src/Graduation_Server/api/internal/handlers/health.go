@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// ---- ヘルスチェック（コンテナのオーケストレーター向け）----
+//
+//	/healthz : プロセスが応答できるか（常に 200。依存先の異常は status=degraded で知らせる）
+//	/readyz  : トラフィックを受けてよいか（依存先の異常・停止処理中は 503）
+
+type HealthResponse struct {
+	Status string            `json:"status"` // "ok" / "degraded" / "shutting_down"
+	Checks map[string]string `json:"checks"` // 依存先 -> "ok" またはエラー内容
+}
+
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	res := checkHealth(r.Context())
+	writeHealth(w, http.StatusOK, res)
+}
+
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	res := checkHealth(r.Context())
+	status := http.StatusOK
+	if res.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeHealth(w, status, res)
+}
+
+func checkHealth(ctx context.Context) HealthResponse {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	res := HealthResponse{Status: "ok", Checks: make(map[string]string)}
+	check := func(name string, err error) {
+		if err != nil {
+			res.Checks[name] = err.Error()
+			res.Status = "degraded"
+			return
+		}
+		res.Checks[name] = "ok"
+	}
+
+	if db != nil {
+		check("db", db.PingContext(ctx))
+	}
+	// Redis など疎通確認できる backplane のみ
+	if c := clusterState.Load(); c != nil {
+		if p, ok := c.bp.(interface{ Ping(context.Context) error }); ok {
+			check("backplane", p.Ping(ctx))
+		}
+	}
+	if IsShuttingDown() {
+		res.Status = "shutting_down"
+	}
+	return res
+}
+
+func writeHealth(w http.ResponseWriter, status int, res HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadyzWhileShuttingDown(t *testing.T) {
+	shuttingDown.Store(true)
+	t.Cleanup(func() { shuttingDown.Store(false) })
+
+	// 停止処理中も生存確認は通し、受け付けだけ止める
+	for path, want := range map[string]int{"/healthz": http.StatusOK, "/readyz": http.StatusServiceUnavailable} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		if path == "/healthz" {
+			HealthzHandler(rec, req)
+		} else {
+			ReadyzHandler(rec, req)
+		}
+		var h HealthResponse
+		if err := json.NewDecoder(rec.Body).Decode(&h); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != want || h.Status != "shutting_down" {
+			t.Errorf("%s = %d %+v", path, rec.Code, h)
+		}
+	}
+}
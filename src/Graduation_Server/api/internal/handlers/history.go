@@ -0,0 +1,206 @@
+//ゲーム履歴とリプレイAPI（round_results / round_logs）
+
+package handlers
+
+import (
+	"api/internal/middleware"
+	"api/internal/models"
+	"api/internal/response"
+	"api/internal/store"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	historyDefaultLimit = 20
+	historyMaxLimit     = 100
+)
+
+// HistoryGame は遊んだゲーム1件（ルームのゲームは1回の開始、ソロは1ラウンドを1件とする）
+type HistoryGame struct {
+	GameID        int64          `json:"game_id,omitempty"` // ソロは無し
+	ModeID        int            `json:"mode_id"`
+	Mode          string         `json:"mode"`
+	Net           int            `json:"net"` // 自分の収支の合計
+	FirstPlayedAt time.Time      `json:"first_played_at"`
+	LastPlayedAt  time.Time      `json:"last_played_at"`
+	Rounds        []HistoryRound `json:"rounds"` // 古い順
+}
+
+type HistoryRound struct {
+	RoundID   int64     `json:"round_id,omitempty"` // 経過の ID（リプレイ。経過を残す前のラウンドは無し）
+	RoundNo   int       `json:"round_no"`
+	Role      string    `json:"role"` // "player" / "dealer"
+	Result    string    `json:"result"`
+	Blackjack bool      `json:"blackjack"`
+	Bust      bool      `json:"bust"`
+	Bet       int       `json:"bet"`
+	Payout    int       `json:"payout"`
+	Net       int       `json:"net"`
+	SettledAt time.Time `json:"settled_at"`
+}
+
+type HistoryResponse struct {
+	Games      []HistoryGame `json:"games"`                 // 新しい順
+	NextBefore int64         `json:"next_before,omitempty"` // 続きがあれば ?before= に渡す
+}
+
+// RoundReplayResponse はラウンドの経過（クライアントは events を順に再生する）
+type RoundReplayResponse struct {
+	models.RoundLog
+	SeedVerified bool `json:"seed_verified"` // 明かしたシードが配る前の SHA-256 と一致した
+}
+
+// HistoryHandler は自分が遊んだゲームを新しい順に返す（?before=<next_before>&limit=、既定 20・最大 100）
+func HistoryHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		userID := middleware.GetUserID(r)
+		if userID == 0 {
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
+			return
+		}
+		limit := historyDefaultLimit
+		if s := r.URL.Query().Get("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 || n > historyMaxLimit {
+				response.Invalid(w, r, response.Field("limit", response.FieldOutOfRange))
+				return
+			}
+			limit = n
+		}
+		var before int64
+		if s := r.URL.Query().Get("before"); s != "" {
+			v, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || v < 0 {
+				response.Invalid(w, r, response.Field("before", response.FieldInvalid))
+				return
+			}
+			before = v
+		}
+
+		// 件数の絞り込みは store で行う（結果はその limit ゲーム分だけ）
+		results, err := repo.Rounds().ListRecentGames(userID, before, limit)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "list round results failed", "user_id", userID, "err", err)
+			response.Internal(w, r)
+			return
+		}
+		modes, err := repo.Games().Modes()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "list modes failed", "err", err)
+			response.Internal(w, r)
+			return
+		}
+		games, oldest := groupHistory(results, modes)
+		resp := HistoryResponse{Games: games}
+		if len(games) == limit {
+			resp.NextBefore = oldest
+		}
+		response.OK(w, r, resp)
+	}
+}
+
+// RoundReplayHandler はラウンドの経過を返す（参加した人だけ。他の人のラウンドは 404）
+func RoundReplayHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		userID := middleware.GetUserID(r)
+		if userID == 0 {
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
+			return
+		}
+		id, ok := roundIDParam(w, r)
+		if !ok {
+			return
+		}
+		results, err := repo.Rounds().ListByLog(id)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "list round participants failed", "round_id", id, "err", err)
+			response.Internal(w, r)
+			return
+		}
+		joined := false
+		for _, res := range results {
+			joined = joined || res.UserID == userID
+		}
+		if !joined {
+			response.Fail(w, r, http.StatusNotFound, response.CodeNotFound)
+			return
+		}
+		writeRoundReplay(w, r, repo, id)
+	}
+}
+
+// writeRoundReplay は経過を読んで返す（運営用の AdminRoundReplayHandler と共通）
+func writeRoundReplay(w http.ResponseWriter, r *http.Request, repo store.Store, id int64) {
+	log, err := repo.Rounds().Log(id)
+	if err == store.ErrNotFound {
+		response.Fail(w, r, http.StatusNotFound, response.CodeNotFound)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "round log load failed", "round_id", id, "err", err)
+		response.Internal(w, r)
+		return
+	}
+	response.OK(w, r, RoundReplayResponse{RoundLog: *log, SeedVerified: log.SeedVerified()})
+}
+
+func roundIDParam(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["round_id"], 10, 64)
+	if err != nil || id <= 0 {
+		response.Invalid(w, r, response.Field("round_id", response.FieldInvalid))
+		return 0, false
+	}
+	return id, true
+}
+
+// groupHistory は古い順の結果をゲームごとにまとめて最後に遊んだのが新しい順に並べ、
+// 一番古いゲームの最後の結果の ID（次のページの before）と一緒に返す
+func groupHistory(results []models.RoundResult, modes []models.Mode) ([]HistoryGame, int64) {
+	names := make(map[int]string, len(modes))
+	for _, m := range modes {
+		names[m.ID] = m.Mode
+	}
+
+	// last は最後のラウンドの結果の ID（保存順なので、大きいほど新しい）
+	type entry struct {
+		game *HistoryGame
+		last int64
+	}
+	var games []*entry
+	byGame := make(map[int64]*entry)
+	for _, res := range results {
+		e, ok := byGame[res.GameID]
+		if !ok || res.GameID == 0 {
+			e = &entry{game: &HistoryGame{GameID: res.GameID, ModeID: res.ModeID, Mode: names[res.ModeID], FirstPlayedAt: res.CreatedAt}}
+			games = append(games, e)
+			if res.GameID != 0 {
+				byGame[res.GameID] = e
+			}
+		}
+		e.last = res.ID
+		g := e.game
+		g.Net += res.Net
+		g.LastPlayedAt = res.CreatedAt
+		g.Rounds = append(g.Rounds, HistoryRound{
+			RoundID: res.LogID, RoundNo: res.RoundNo, Role: res.Role, Result: res.Result,
+			Blackjack: res.Blackjack, Bust: res.Bust, Bet: res.Bet, Payout: res.Payout, Net: res.Net, SettledAt: res.CreatedAt,
+		})
+	}
+
+	sort.Slice(games, func(i, j int) bool { return games[i].last > games[j].last })
+	out := make([]HistoryGame, 0, len(games))
+	var oldest int64
+	for _, e := range games {
+		out = append(out, *e.game)
+		oldest = e.last
+	}
+	return out, oldest
+}
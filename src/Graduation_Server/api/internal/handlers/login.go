@@ -2,25 +2,19 @@
 package handlers
 
 import (
+	"api/internal/response"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
-	"time"
 
-	"github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// JWT署名用の秘密鍵
-var jwtKey = []byte("your_secret_key")
-
-// JWTに含めるクレーム構造体
-type Claims struct {
-	UserID   int64  `json:"user_id"`
-	Username string `json:"name"`
-	jwt.RegisteredClaims
-}
+// ユーザーが存在しないときに照合するダミーのハッシュ（応答時間をそろえるため）
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
 
 // ログインリクエストの構造体
 type LoginRequest struct {
@@ -28,80 +22,90 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// ログインの応答（message は旧クライアント向けに空文字で残している）
+type LoginResponse struct {
+	TokenResponse
+	Message string `json:"message"`
+}
+
 // LoginHandler handles log inとJWTの発行
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
+	repo := repo.WithContext(r.Context())
 	var req LoginRequest
 
 	ct := r.Header.Get("Content-Type")
 	if strings.HasPrefix(ct, "application/json") {
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			response.BadJSON(w, r)
 			return
 		}
 	} else if strings.HasPrefix(ct, "application/x-www-form-urlencoded") {
 		if err := r.ParseForm(); err != nil {
-			http.Error(w, "Invalid form", http.StatusBadRequest)
+			response.BadJSON(w, r)
 			return
 		}
 		req.Name = r.FormValue("name")
 		req.Password = r.FormValue("password")
 	} else {
-		http.Error(w, "Unsupported Content-Type", http.StatusUnsupportedMediaType)
+		response.Fail(w, r, http.StatusUnsupportedMediaType, response.CodeUnsupportedMedia)
 		return
 	}
 
-	if req.Name == "" || req.Password == "" {
-		http.Error(w, "名前かパスワードがないよ", http.StatusBadRequest)
+	var missing []response.FieldError
+	if req.Name == "" {
+		missing = append(missing, response.Field("name", response.FieldRequired))
+	}
+	if req.Password == "" {
+		missing = append(missing, response.Field("password", response.FieldRequired))
+	}
+	if len(missing) > 0 {
+		response.Invalid(w, r, missing...)
 		return
 	}
 
-	// ユーザーのパスワードを取得
-	var hashedPassword string
-	var userID int64
-	err := db.QueryRow("SELECT id, password FROM users WHERE name = ?", req.Name).Scan(&userID, &hashedPassword)
-	if err == sql.ErrNoRows {
-		http.Error(w, "ユーザーがありません", http.StatusUnauthorized)
-		return
-	} else if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+	// 失敗が続いている名前・IPは一定時間受け付けない
+	ip := clientIP(r)
+	if wait := loginRetryAfter(req.Name, ip); wait > 0 {
+		writeTooManyAttempts(w, r, wait)
 		return
 	}
 
-	// ハッシュが空の場合（AutoFlg = true で作成されたユーザー）は拒否
-	if hashedPassword == "" {
-		http.Error(w, "このアカウントではパスワードログインは無効です。", http.StatusUnauthorized)
+	// ユーザーのパスワードを取得
+	// 名前が存在するかどうかを推測されないよう、失敗理由はすべて同じメッセージにする
+	userID, hashedPassword, err := repo.Users().Credentials(req.Name)
+	if err != nil && err != sql.ErrNoRows {
+		response.Internal(w, r)
+		return
+	}
+	if err == sql.ErrNoRows || hashedPassword == "" {
+		// ユーザー無し / ゲストアカウント（AutoFlg = true で作成）でも
+		// 応答時間で区別できないようにダミーのハッシュと照合しておく
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+		recordLoginFailure(req.Name, ip)
+		response.Fail(w, r, http.StatusUnauthorized, response.CodeInvalidCredentials)
 		return
 	}
 
 	// パスワード照合
 	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(req.Password)); err != nil {
-		http.Error(w, "パスワードが正しくありません", http.StatusUnauthorized)
+		recordLoginFailure(req.Name, ip)
+		response.Fail(w, r, http.StatusUnauthorized, response.CodeInvalidCredentials)
 		return
 	}
+	recordLoginSuccess(req.Name)
 
-	// ログイン成功時JWT作成
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &Claims{
-		Username: req.Name,
-		UserID:   userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	// ログイン成功時：アクセストークン＋リフレッシュトークンを発行
+	pair, err := issueTokenPair(r.Context(), userID)
+	if errors.Is(err, errAccountBanned) {
+		response.Fail(w, r, http.StatusForbidden, response.CodeAccountBanned)
+		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
 	if err != nil {
-		http.Error(w, "Token generation failed", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "token issue failed", "user_id", userID, "err", err)
+		response.Internal(w, r)
 		return
 	}
 
 	// JSONで返す
-	resp := map[string]interface{}{
-		"result":  "OK",
-		"token":   tokenString,
-		"message": "",
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	response.OK(w, r, LoginResponse{TokenResponse: pair.response()})
 }
@@ -0,0 +1,189 @@
+// ログイン試行の制限（総当たり対策）
+
+package handlers
+
+import (
+	"api/internal/response"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 失敗回数に応じたロック方針
+// freeFailures 回までは即時に再試行でき、それを超えると
+// baseLockout × 2^(超過回数-1) だけロック（maxLockout で頭打ち）。
+// 最後の失敗から resetAfter 経てばカウントは消える。
+type throttlePolicy struct {
+	freeFailures int
+	baseLockout  time.Duration
+	maxLockout   time.Duration
+	resetAfter   time.Duration
+}
+
+type throttleEntry struct {
+	failures    int
+	lockedUntil time.Time
+	lastFailure time.Time
+}
+
+// キー（名前 or IP）ごとの失敗回数を数える
+type loginThrottle struct {
+	mu      sync.Mutex
+	policy  throttlePolicy
+	entries map[string]*throttleEntry
+	ops     int
+}
+
+func newLoginThrottle(p throttlePolicy) *loginThrottle {
+	return &loginThrottle{policy: p, entries: make(map[string]*throttleEntry)}
+}
+
+var (
+	// 名前ごと：1アカウントへの総当たりを防ぐ
+	loginNameThrottle = newLoginThrottle(throttlePolicy{
+		freeFailures: 5,
+		baseLockout:  30 * time.Second,
+		maxLockout:   15 * time.Minute,
+		resetAfter:   15 * time.Minute,
+	})
+	// ユーザーIDごと：ログイン済みでの現在のパスワード確認（名前変更で回避されないよう ID で数える）。
+	// 名前と同じ表にすると、その ID に見える名前でログインに失敗し続けて本人を締め出せるので分けておく
+	passwordUserThrottle = newLoginThrottle(throttlePolicy{
+		freeFailures: 5,
+		baseLockout:  30 * time.Second,
+		maxLockout:   15 * time.Minute,
+		resetAfter:   15 * time.Minute,
+	})
+	// IPごと：1つのIPから多数の名前を試すのを防ぐ（NAT配下を考えて緩め）
+	loginIPThrottle = newLoginThrottle(throttlePolicy{
+		freeFailures: 20,
+		baseLockout:  30 * time.Second,
+		maxLockout:   30 * time.Minute,
+		resetAfter:   30 * time.Minute,
+	})
+)
+
+// ロック中なら残り時間を返す
+func (t *loginThrottle) retryAfter(key string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok {
+		return 0
+	}
+	if now.Sub(e.lastFailure) > t.policy.resetAfter {
+		delete(t.entries, key)
+		return 0
+	}
+	if now.Before(e.lockedUntil) {
+		return e.lockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// 失敗を記録し、必要ならロックする
+func (t *loginThrottle) fail(key string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok || now.Sub(e.lastFailure) > t.policy.resetAfter {
+		e = &throttleEntry{}
+		t.entries[key] = e
+	}
+	e.failures++
+	e.lastFailure = now
+	if over := e.failures - t.policy.freeFailures; over > 0 {
+		lock := t.policy.baseLockout
+		for i := 1; i < over && lock < t.policy.maxLockout; i++ {
+			lock *= 2
+		}
+		if lock > t.policy.maxLockout {
+			lock = t.policy.maxLockout
+		}
+		e.lockedUntil = now.Add(lock)
+	}
+
+	// たまに古いエントリを掃除（map が増え続けないように）
+	t.ops++
+	if t.ops%1000 == 0 {
+		for k, v := range t.entries {
+			if now.Sub(v.lastFailure) > t.policy.resetAfter {
+				delete(t.entries, k)
+			}
+		}
+	}
+}
+
+// 成功したらカウントを消す
+func (t *loginThrottle) succeed(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.entries, key)
+}
+
+// 名前・IPの両方を見て、ロック中なら残り時間を返す
+func loginRetryAfter(name, ip string) time.Duration {
+	now := time.Now()
+	wait := loginNameThrottle.retryAfter(name, now)
+	if ipWait := loginIPThrottle.retryAfter(ip, now); ipWait > wait {
+		wait = ipWait
+	}
+	return wait
+}
+
+func recordLoginFailure(name, ip string) {
+	now := time.Now()
+	loginNameThrottle.fail(name, now)
+	loginIPThrottle.fail(ip, now)
+}
+
+// 成功したら名前側のカウントだけ消す
+// （IP側まで消すと、自分のアカウントで時々ログインして総当たりを続けられてしまう）
+func recordLoginSuccess(name string) {
+	loginNameThrottle.succeed(name)
+}
+
+// パスワード変更の現在のパスワード確認用（ユーザーID・IP の両方を見る。IP はログインと共有）
+func passwordRetryAfter(userID int64, ip string) time.Duration {
+	now := time.Now()
+	wait := passwordUserThrottle.retryAfter(userKey(userID), now)
+	if ipWait := loginIPThrottle.retryAfter(ip, now); ipWait > wait {
+		wait = ipWait
+	}
+	return wait
+}
+
+func recordPasswordFailure(userID int64, ip string) {
+	now := time.Now()
+	passwordUserThrottle.fail(userKey(userID), now)
+	loginIPThrottle.fail(ip, now)
+}
+
+func recordPasswordSuccess(userID int64) {
+	passwordUserThrottle.succeed(userKey(userID))
+}
+
+func userKey(userID int64) string { return strconv.FormatInt(userID, 10) }
+
+// 429 と Retry-After を返す
+func writeTooManyAttempts(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	secs := int(wait.Round(time.Second) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	response.Fail(w, r, http.StatusTooManyRequests, response.CodeLoginLocked)
+}
+
+// 接続元IP（ポートを除いたもの）
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"api/internal/store"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// freshThrottles はテストの間だけ制限の表を空のものに差し替える
+func freshThrottles(t *testing.T) {
+	t.Helper()
+	name, user, ip := loginNameThrottle, passwordUserThrottle, loginIPThrottle
+	loginNameThrottle = newLoginThrottle(name.policy)
+	passwordUserThrottle = newLoginThrottle(user.policy)
+	loginIPThrottle = newLoginThrottle(ip.policy)
+	t.Cleanup(func() { loginNameThrottle, passwordUserThrottle, loginIPThrottle = name, user, ip })
+}
+
+func TestThrottleLockout(t *testing.T) {
+	th := newLoginThrottle(throttlePolicy{freeFailures: 2, baseLockout: time.Second, maxLockout: 3 * time.Second, resetAfter: time.Minute})
+	now := time.Now()
+
+	for i, want := range []time.Duration{0, 0, time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		th.fail("taro", now)
+		if got := th.retryAfter("taro", now); got != want {
+			t.Fatalf("after %d failures: retryAfter = %v, want %v", i+1, got, want)
+		}
+	}
+	if got := th.retryAfter("hanako", now); got != 0 {
+		t.Errorf("other key locked: %v", got)
+	}
+	// 最後の失敗から resetAfter 経てば数え直す
+	if got := th.retryAfter("taro", now.Add(2*time.Minute)); got != 0 {
+		t.Errorf("after resetAfter: retryAfter = %v", got)
+	}
+	th.fail("hanako", now)
+	th.fail("hanako", now)
+	th.fail("hanako", now)
+	th.succeed("hanako")
+	if got := th.retryAfter("hanako", now); got != 0 {
+		t.Errorf("after success: retryAfter = %v", got)
+	}
+}
+
+// ログイン名の失敗はパスワード変更の制限と別に数える（ID に見える名前で本人を締め出せない）
+func TestPasswordThrottleIsSeparateFromLoginNames(t *testing.T) {
+	freshThrottles(t)
+	repo := store.NewMemory()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("current-password"), bcrypt.MinCost)
+	userID, err := repo.Users().Create("throttled", string(hash), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.FormatInt(userID, 10)
+	for _, name := range []string{id, "user:" + id} {
+		for i := 0; i < 10; i++ {
+			recordLoginFailure(name, "203.0.113.9")
+		}
+	}
+	if wait := passwordRetryAfter(userID, "192.0.2.1"); wait != 0 {
+		t.Fatalf("password change locked by login failures: %v", wait)
+	}
+
+	change := ChangePasswordHandler(repo)
+	wrong := ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "next-password"}
+	for i := 0; i < passwordUserThrottle.policy.freeFailures; i++ {
+		if rec := callAsUser(change, userID, wrong); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: status = %d", i+1, rec.Code)
+		}
+	}
+	// 制限を超えた失敗の後は正しいパスワードでも待たせる
+	if rec := callAsUser(change, userID, wrong); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("locking attempt: status = %d", rec.Code)
+	}
+	right := ChangePasswordRequest{CurrentPassword: "current-password", NewPassword: "next-password"}
+	rec := callAsUser(change, userID, right)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("locked attempt: status = %d, Retry-After = %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	// 逆にパスワード変更の失敗でログインは止まらない
+	if wait := loginNameThrottle.retryAfter("throttled", time.Now()); wait != 0 {
+		t.Fatalf("login by name locked by password change failures: %v", wait)
+	}
+}
@@ -0,0 +1,84 @@
+// ログアウトAPI
+
+package handlers
+
+import (
+	"api/internal/auth"
+	"api/internal/middleware"
+	"api/internal/response"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// refresh_token: この端末で持っているリフレッシュトークン（省略可）
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// LogoutHandler はこの端末のセッションを終了する。
+// 認証に使ったアクセストークンを失効リストに載せ、
+// リフレッシュトークンが送られてきたらその family ごと失効させる。
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	repo := repo.WithContext(r.Context())
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
+		return
+	}
+
+	// ボディは任意（空ならアクセストークンだけ失効）
+	var req LogoutRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.BadJSON(w, r)
+			return
+		}
+	}
+
+	if req.RefreshToken != "" {
+		rt, err := repo.Tokens().GetRefresh(hashToken(req.RefreshToken))
+		// 他人のトークンは触らない（見つからない場合も成功扱い）
+		if err == nil && rt.UserID == userID {
+			if err := repo.Tokens().RevokeFamily(rt.FamilyID); err != nil {
+				slog.ErrorContext(r.Context(), "revoke refresh token failed", "err", err)
+				response.Internal(w, r)
+				return
+			}
+		}
+	}
+
+	jti, exp := middleware.GetTokenID(r)
+	if jti != "" {
+		if exp.IsZero() {
+			exp = time.Now().Add(auth.Default().TTL())
+		}
+		if err := repo.Tokens().RevokeAccess(jti, userID, exp); err != nil {
+			slog.ErrorContext(r.Context(), "revoke access token failed", "err", err)
+			response.Internal(w, r)
+			return
+		}
+	}
+
+	response.OK(w, r, struct{}{})
+}
+
+// LogoutAllHandler は全端末からログアウトさせる。
+// 全リフレッシュトークンを失効させ、token_version を上げて発行済みアクセストークンも無効にする。
+func LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	repo := repo.WithContext(r.Context())
+	userID := middleware.GetUserID(r)
+	if userID == 0 {
+		response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
+		return
+	}
+
+	if err := repo.Tokens().RevokeAll(userID); err != nil {
+		slog.ErrorContext(r.Context(), "revoke all tokens failed", "err", err)
+		response.Internal(w, r)
+		return
+	}
+
+	response.OK(w, r, struct{}{})
+}
@@ -3,97 +3,155 @@
 package handlers
 
 import (
+	"api/internal/auth"
+	"api/internal/middleware"
+	"api/internal/models"
+	"api/internal/response"
 	"database/sql"
-	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
-
-	"github.com/golang-jwt/jwt/v4"
+	"time"
 )
 
+// メインデータ（ホーム画面の表示に使う一式）
+type MainDataResponse struct {
+	Message          string                `json:"message"` // 旧クライアント向け（常に "Success"）
+	Settings         MainDataSettings      `json:"settings"`
+	Tips             MainDataTips          `json:"tips"`
+	Username         string                `json:"username"`
+	NameChangeableAt *time.Time            `json:"name_changeable_at"` // 次に名前を変えられる日時（null なら今すぐ変えられる）
+	Maintenance      MaintenanceStatus     `json:"maintenance"`
+	Announcements    []models.Announcement `json:"announcements"` // 表示期間中のお知らせ
+}
+
+type MainDataSettings struct {
+	BgmVolume float64 `json:"bgm_volume"`
+	SeVolume  float64 `json:"se_volume"`
+	Icon      string  `json:"icon"`
+	Language  string  `json:"language"` // "ja" / "en"。"" は未設定（端末の言語に従う）
+	Vibration bool    `json:"vibration"`
+	CardBack  string  `json:"card_back"`
+	UserID    int64   `json:"user_id"`
+}
+
+func settingsResponse(userID int64, s *models.Setting) MainDataSettings {
+	return MainDataSettings{
+		BgmVolume: s.BgmVolume,
+		SeVolume:  s.SeVolume,
+		Icon:      s.Icon,
+		Language:  s.Language,
+		Vibration: s.Vibration,
+		CardBack:  s.CardBack,
+		UserID:    userID,
+	}
+}
+
+type MainDataTips struct {
+	SoloTip  int `json:"solotip"`
+	MultiTip int `json:"multitip"`
+}
+
+// メンテナンス中かどうかと表示する文言
+type MaintenanceStatus struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message"`
+}
+
 func GetMainDataHandler(w http.ResponseWriter, r *http.Request) {
-	if db == nil {
-		log.Println("[ERROR] handlers.db is nil!")
-		http.Error(w, "エラー:サーバーがない", http.StatusInternalServerError)
+	if repo == nil {
+		slog.ErrorContext(r.Context(), "handlers.repo is nil")
+		response.Internal(w, r)
 		return
 	}
+	repo := repo.WithContext(r.Context())
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-		http.Error(w, "Missing or invalid Authorization header", http.StatusUnauthorized)
+		response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
 		return
 	}
 
 	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
-	if err != nil || !token.Valid {
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+	claims, err := auth.Default().Parse(tokenString)
+	if err != nil {
+		response.Fail(w, r, http.StatusUnauthorized, response.CodeTokenInvalid)
+		return
+	}
+	revoked, err := middleware.IsTokenRevoked(claims.UserID, claims.ID, claims.TokenVersion)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "token revocation check failed", "err", err)
+		response.Internal(w, r)
+		return
+	}
+	if revoked {
+		response.Fail(w, r, http.StatusUnauthorized, response.CodeTokenRevoked)
 		return
 	}
 
-	// ユーザー名からユーザーIDを取得
-	var userID int
-	err = db.QueryRow("SELECT id FROM users WHERE name = ?", claims.Username).Scan(&userID)
+	// トークンの user_id から現在のユーザー名を取得
+	userID := claims.UserID
+	user, err := repo.Users().GetByID(userID)
 	if err != nil {
-		log.Printf("[ERROR] Failed to get user id for %s: %v", claims.Username, err)
-		http.Error(w, "Failed to retrieve user id", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "user lookup failed", "user_id", userID, "err", err)
+		response.Internal(w, r)
 		return
 	}
+	username := user.Name
 
 	// user_idでsettingsテーブルから設定を取得
-	var bgmVolume, seVolume float64
-	var icon string
-	err = db.QueryRow("SELECT bgm_volume, se_volume, icon FROM settings WHERE user_id = ?", userID).
-		Scan(&bgmVolume, &seVolume, &icon)
+	setting, err := repo.Settings().Get(userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			// 設定がなければデフォルト値を返す
-			bgmVolume = 0.5
-			seVolume = 0.5
-			icon = "default_icon"
+			// 設定がなければ、アカウント作成時と同じデフォルト値を返す
+			setting = &models.Setting{}
+			*setting = models.DefaultSetting
 		} else {
-			log.Printf("[ERROR] Failed to get settings for user %s: %v", claims.Username, err)
-			http.Error(w, "Failed to retrieve settings", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "settings lookup failed", "user_id", userID, "err", err)
+			response.Internal(w, r)
 			return
 		}
 	}
 
 	// ③ user_idでtipsテーブルからチップ情報を取得
-	var soloTip, multiTip int
-	err = db.QueryRow("SELECT solo_tip_count, multi_tip_count FROM tips WHERE user_id = ?", userID).
-		Scan(&soloTip, &multiTip)
+	tip, err := repo.Tips().Get(userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// チップがなければデフォルト値
-			soloTip = 10000
-			multiTip = 10000
+			tip = &models.Tip{SoloTipCount: cfg.Chips.SoloStart, MultiTipCount: cfg.Chips.MultiStart}
 		} else {
-			log.Printf("[ERROR] Failed to get tips for user %s: %v", claims.Username, err)
-			http.Error(w, "Failed to retrieve tips", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "tips lookup failed", "user_id", userID, "err", err)
+			response.Internal(w, r)
 			return
 		}
 	}
 
-	response := map[string]interface{}{
-		"result":  "OK",
-		"message": "Success",
-		"settings": map[string]interface{}{
-			"bgm_volume": bgmVolume,
-			"se_volume":  seVolume,
-			"icon":       icon,
-			"user_id":    userID,
-		},
-		"tips": map[string]interface{}{
-			"solotip":  soloTip,
-			"multitip": multiTip,
-		},
+	// メンテナンス状態と表示中のお知らせ（取れなくてもメインデータは返す）
+	maintenance := &models.Maintenance{}
+	if m, err := repo.Notices().Maintenance(); err != nil {
+		slog.WarnContext(r.Context(), "maintenance lookup failed", "err", err)
+	} else if m.Enabled {
+		maintenance = &models.Maintenance{Enabled: true, Message: maintenanceMessage(m)}
+	}
+	announcements, err := repo.Notices().ActiveAnnouncements(time.Now())
+	if err != nil {
+		slog.WarnContext(r.Context(), "announcements lookup failed", "err", err)
+	}
+	if announcements == nil {
+		announcements = []models.Announcement{}
+	}
 
-		"username": claims.Username,
+	resp := MainDataResponse{
+		Message:  "Success",
+		Settings: settingsResponse(userID, setting),
+		Tips: MainDataTips{
+			SoloTip:  tip.SoloTipCount,
+			MultiTip: tip.MultiTipCount,
+		},
+		Username:         username,
+		NameChangeableAt: nameChangeableAt(user, time.Now()),
+		Maintenance:      MaintenanceStatus{Enabled: maintenance.Enabled, Message: maintenance.Message},
+		Announcements:    announcements,
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	response.OK(w, r, resp)
 }
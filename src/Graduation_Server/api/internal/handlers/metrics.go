@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"api/internal/metrics"
+	"log/slog"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ---- ゲーム・接続のメトリクス（/metrics）----
+
+var (
+	roundsStarted = metrics.Factory.NewCounter(prometheus.CounterOpts{
+		Name: "blackjack_rounds_started_total",
+		Help: "Blackjack rounds started (each successful room start).",
+	})
+	roundsSettled = metrics.Factory.NewCounter(prometheus.CounterOpts{
+		Name: "blackjack_rounds_settled_total",
+		Help: "Blackjack rounds settled by the dealer (round_result).",
+	})
+	// kind: bet_placed（卓に乗せた）/ bet_returned（減額・ディーラー交代で戻した）/ refund（停止時の返却）
+	//       solo_gain / solo_loss（ソロの増減）
+	//       round_payout（精算でプレイヤーへ戻した）/ dealer_gain / dealer_loss（精算でのディーラーの増減）
+	chipFlow = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "chip_flow_total",
+		Help: "Chips moved, by kind (bet_placed, bet_returned, refund, solo_gain, solo_loss, round_payout, dealer_gain, dealer_loss, admin_granted, admin_removed).",
+	}, []string{"kind"})
+)
+
+func init() {
+	metrics.NewGaugeFunc(metrics.Default, "websocket_connections",
+		"Open WebSocket connections on this instance, by endpoint.", []string{"endpoint"},
+		func(emit metrics.Emit) {
+			room, bj := allConnections()
+			emit(float64(len(room)), "room")
+			emit(float64(len(bj)), "blackjack")
+		})
+	metrics.Factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "blackjack_tables",
+		Help: "Blackjack tables whose state is held by this instance.",
+	}, func() float64 {
+		bjMu.Lock()
+		defer bjMu.Unlock()
+		return float64(len(bjRoomStates))
+	})
+	metrics.NewGaugeFunc(metrics.Default, "rooms",
+		"Rooms by status (from the database).", []string{"status"},
+		func(emit metrics.Emit) {
+			if repo == nil {
+				return
+			}
+			counts, err := repo.Rooms().CountByStatus()
+			if err != nil {
+				slog.Warn("metrics: count rooms failed", "err", err)
+				return
+			}
+			for _, status := range []string{"waiting", "playing", "closed"} {
+				emit(float64(counts[status]), status)
+			}
+		})
+}
+
+// recordChips はチップの移動量を kind ごとに足す（0 以下は無視）
+func recordChips(kind string, amount int) {
+	if amount > 0 {
+		chipFlow.WithLabelValues(kind).Add(float64(amount))
+	}
+}
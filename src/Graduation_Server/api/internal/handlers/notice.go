@@ -0,0 +1,333 @@
+// メンテナンスとお知らせ
+//
+// メンテナンス中は新しいルームの作成・参加を断る（進行中のルーム・卓はそのまま最後まで遊べる）。
+// 状態は service_status に持つので、どのインスタンスでも同じ判定になる。
+//
+// お知らせは announcements に保存し、表示期間の間は get_main_data で返す。
+// 作成時（開始が先なら開始時刻）・削除時・メンテナンスの切り替え時には、
+// 全インスタンスの接続中のルームWS・ブラックジャックWSへ送る。
+
+package handlers
+
+import (
+	"api/internal/middleware"
+	"api/internal/models"
+	"api/internal/response"
+	"api/internal/store"
+	"api/internal/tracing"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultMaintenanceMessage = "メンテナンス中のため新しいルームの作成・参加はできません"
+	maxAnnouncementLength     = 500 // announcements.message の長さ（文字数）
+)
+
+// メンテナンスの切り替え（WSの全接続へ送る）
+type MaintenanceNotice struct {
+	Type    string `json:"type"` // "maintenance"
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message"`
+}
+
+// お知らせの表示開始（WSの全接続へ送る）
+type AnnouncementNotice struct {
+	Type         string              `json:"type"` // "announcement"
+	Announcement models.Announcement `json:"announcement"`
+}
+
+// お知らせの取り下げ（表示中なら消してもらう）
+type AnnouncementRemovedNotice struct {
+	Type string `json:"type"` // "announcement_removed"
+	ID   int64  `json:"id"`
+}
+
+// rejectDuringMaintenance はメンテナンス中なら 503 を返して true を返す
+func rejectDuringMaintenance(w http.ResponseWriter, r *http.Request, repo store.Store) bool {
+	m, err := repo.Notices().Maintenance()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "maintenance lookup failed", "err", err)
+		response.Internal(w, r)
+		return true
+	}
+	if !m.Enabled {
+		return false
+	}
+	if m.Message == "" {
+		// 運営が文言を決めていなければ、既定の文言を相手の言語で返す
+		response.Fail(w, r, http.StatusServiceUnavailable, response.CodeMaintenance)
+		return true
+	}
+	response.FailMessage(w, r, http.StatusServiceUnavailable, response.CodeMaintenance, m.Message)
+	return true
+}
+
+func maintenanceMessage(m *models.Maintenance) string {
+	if m.Message == "" {
+		return defaultMaintenanceMessage
+	}
+	return m.Message
+}
+
+// ---- 全接続へのブロードキャスト ----
+
+// broadcastAll は v を全インスタンスの全WS接続へ送る
+func broadcastAll(ctx context.Context, typ string, v interface{}) {
+	ctx, span := tracing.Start(ctx, "broadcast "+typ, trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		slog.ErrorContext(ctx, "broadcast encode failed", "type", typ, "err", err)
+		return
+	}
+	localBroadcastAll(raw)
+	publishEvent(ctx, roomEvent{Kind: "broadcast", Data: raw})
+}
+
+func localBroadcastAll(raw json.RawMessage) {
+	roomConns, bjConns := allConnections()
+	for _, c := range roomConns {
+		if err := writeRoomJSON(c, raw); err != nil {
+			slog.Debug("broadcast send failed", "err", err)
+		}
+	}
+	for _, c := range bjConns {
+		if err := writeJSONSafe(c, raw); err != nil {
+			slog.Debug("broadcast send failed", "err", err)
+		}
+	}
+}
+
+// ---- 開始時刻待ちのお知らせ ----
+
+// 作成したインスタンスだけがタイマーを持つ（再起動で失われても get_main_data には出る）
+var (
+	announceTimersMu sync.Mutex
+	announceTimers   = make(map[int64]*time.Timer)
+)
+
+// scheduleAnnouncement は開始済みならすぐ、開始前なら開始時刻にお知らせを送る
+func scheduleAnnouncement(ctx context.Context, repo store.Store, a models.Announcement) {
+	notice := AnnouncementNotice{Type: "announcement", Announcement: a}
+	wait := time.Until(a.StartsAt)
+	if wait <= 0 {
+		broadcastAll(ctx, notice.Type, notice)
+		return
+	}
+	announceTimersMu.Lock()
+	defer announceTimersMu.Unlock()
+	announceTimers[a.ID] = time.AfterFunc(wait, func() {
+		announceTimersMu.Lock()
+		delete(announceTimers, a.ID)
+		announceTimersMu.Unlock()
+
+		// 他のインスタンスで削除されていたら送らない
+		active, err := repo.Notices().ActiveAnnouncements(time.Now())
+		if err != nil {
+			slog.Warn("scheduled announcement lookup failed", "id", a.ID, "err", err)
+			return
+		}
+		for _, x := range active {
+			if x.ID == a.ID {
+				broadcastAll(context.Background(), notice.Type, notice)
+				return
+			}
+		}
+	})
+}
+
+func cancelAnnouncement(id int64) {
+	announceTimersMu.Lock()
+	defer announceTimersMu.Unlock()
+	if t, ok := announceTimers[id]; ok {
+		t.Stop()
+		delete(announceTimers, id)
+	}
+}
+
+// ---- 運営API ----
+
+type AdminMaintenanceRequest struct {
+	Enabled *bool  `json:"enabled"`
+	Message string `json:"message"` // 空なら既定の文言
+}
+
+type AdminAnnouncementRequest struct {
+	Message  string     `json:"message"`
+	Severity string     `json:"severity"`  // info（省略時）/ warning / critical
+	StartsAt *time.Time `json:"starts_at"` // 省略時は今から
+	EndsAt   time.Time  `json:"ends_at"`
+}
+
+type AdminMaintenanceResponse struct {
+	Maintenance *models.Maintenance `json:"maintenance"`
+}
+
+type AdminAnnouncementsResponse struct {
+	Announcements []models.Announcement `json:"announcements"`
+}
+
+type AdminAnnouncementResponse struct {
+	Announcement models.Announcement `json:"announcement"`
+}
+
+// 取り下げたお知らせ
+type AdminDeleteAnnouncementResponse struct {
+	ID int64 `json:"id"`
+}
+
+// GET /api/admin/maintenance
+func AdminGetMaintenanceHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		m, err := repo.Notices().Maintenance()
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		response.OK(w, r, AdminMaintenanceResponse{Maintenance: m})
+	}
+}
+
+// POST /api/admin/maintenance : メンテナンスの開始・終了（接続中の全員に知らせる）
+func AdminSetMaintenanceHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		var req AdminMaintenanceRequest
+		if !decodeAdminRequest(w, r, &req) {
+			return
+		}
+		if req.Enabled == nil {
+			response.Invalid(w, r, response.Field("enabled", response.FieldRequired))
+			return
+		}
+		m := models.Maintenance{
+			Enabled:   *req.Enabled,
+			Message:   strings.TrimSpace(req.Message),
+			UpdatedBy: middleware.GetUserID(r),
+			UpdatedAt: time.Now(),
+		}
+		if err := repo.Notices().SetMaintenance(m); err != nil {
+			slog.ErrorContext(r.Context(), "admin: set maintenance failed", "err", err)
+			response.Internal(w, r)
+			return
+		}
+		notice := MaintenanceNotice{Type: "maintenance", Enabled: m.Enabled, Message: maintenanceMessage(&m)}
+		broadcastAll(r.Context(), notice.Type, notice)
+
+		recordAudit(r, repo, "maintenance.set", "", map[string]interface{}{"enabled": m.Enabled, "message": m.Message})
+		response.OK(w, r, AdminMaintenanceResponse{Maintenance: &m})
+	}
+}
+
+// GET /api/admin/announcements : 終わっていないお知らせ（開始前の予約も含む）
+func AdminListAnnouncementsHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		list, err := repo.Notices().PendingAnnouncements(time.Now())
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		if list == nil {
+			list = []models.Announcement{}
+		}
+		response.OK(w, r, AdminAnnouncementsResponse{Announcements: list})
+	}
+}
+
+// POST /api/admin/announcements : お知らせを登録する（表示開始時に接続中の全員へ送る）
+func AdminCreateAnnouncementHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		var req AdminAnnouncementRequest
+		if !decodeAdminRequest(w, r, &req) {
+			return
+		}
+		now := time.Now()
+		a := models.Announcement{
+			Message:   strings.TrimSpace(req.Message),
+			Severity:  req.Severity,
+			StartsAt:  now,
+			EndsAt:    req.EndsAt,
+			CreatedBy: middleware.GetUserID(r),
+			CreatedAt: now,
+		}
+		if a.Severity == "" {
+			a.Severity = models.SeverityInfo
+		}
+		if req.StartsAt != nil {
+			a.StartsAt = *req.StartsAt
+		}
+		switch {
+		case a.Message == "":
+			response.Invalid(w, r, response.Field("message", response.FieldRequired))
+			return
+		case utf8.RuneCountInString(a.Message) > maxAnnouncementLength:
+			response.Invalid(w, r, response.Field("message", response.FieldTooLong))
+			return
+		case a.Severity != models.SeverityInfo && a.Severity != models.SeverityWarning && a.Severity != models.SeverityCritical:
+			response.Invalid(w, r, response.Field("severity", response.FieldInvalid))
+			return
+		case !a.EndsAt.After(a.StartsAt) || !a.EndsAt.After(now):
+			response.Invalid(w, r, response.Field("ends_at", response.FieldInvalid))
+			return
+		}
+
+		id, err := repo.Notices().CreateAnnouncement(a)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "admin: create announcement failed", "err", err)
+			response.Internal(w, r)
+			return
+		}
+		a.ID = id
+		scheduleAnnouncement(r.Context(), repo.WithContext(context.Background()), a)
+
+		recordAudit(r, repo, "announcement.create", strconv.FormatInt(id, 10), map[string]interface{}{
+			"message":   a.Message,
+			"severity":  a.Severity,
+			"starts_at": a.StartsAt.Format(time.RFC3339),
+			"ends_at":   a.EndsAt.Format(time.RFC3339),
+		})
+		response.OK(w, r, AdminAnnouncementResponse{Announcement: a})
+	}
+}
+
+// DELETE /api/admin/announcements/{id} : 取り下げ（表示中なら接続中の全員に消してもらう）
+func AdminDeleteAnnouncementHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil || id <= 0 {
+			response.Invalid(w, r, response.Field("id", response.FieldInvalid))
+			return
+		}
+		err = repo.Notices().DeleteAnnouncement(id)
+		if err == store.ErrNotFound {
+			response.Fail(w, r, http.StatusNotFound, response.CodeNotFound)
+			return
+		}
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		cancelAnnouncement(id)
+		notice := AnnouncementRemovedNotice{Type: "announcement_removed", ID: id}
+		broadcastAll(r.Context(), notice.Type, notice)
+
+		recordAudit(r, repo, "announcement.delete", strconv.FormatInt(id, 10), nil)
+		response.OK(w, r, AdminDeleteAnnouncementResponse{ID: id})
+	}
+}
@@ -1,8 +1,6 @@
 package handlers
 
 import (
-	"encoding/json"
-
 	"github.com/gorilla/websocket"
 )
 
@@ -13,6 +11,7 @@ type PlayerOrderMessage struct {
 }
 
 // --- プレイヤー順をWebSocketへ送信 ---
+// 他のブロードキャストと同時に書き込まないよう writeJSONSafe を通す
 func SendPlayerOrder(conn *websocket.Conn, players []PlayerInfo) error {
 
 	msg := PlayerOrderMessage{
@@ -20,10 +19,5 @@ func SendPlayerOrder(conn *websocket.Conn, players []PlayerInfo) error {
 		Players: players,
 	}
 
-	data, err := json.Marshal(msg)
-	if err != nil {
-		return err
-	}
-
-	return conn.WriteMessage(websocket.TextMessage, data)
+	return writeJSONSafe(conn, msg)
 }
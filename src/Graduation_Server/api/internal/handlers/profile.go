@@ -0,0 +1,157 @@
+//プロフィールAPI（名前の変更・設定で選べる値の一覧）
+
+package handlers
+
+import (
+	"api/internal/middleware"
+	"api/internal/models"
+	"api/internal/response"
+	"api/internal/store"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 名前の最大文字数（users.name は VARCHAR(64) だが、画面に収まる長さに絞る）
+const maxNameLength = 16
+
+// ProfileCatalogResponse は設定で選べる値の一覧
+type ProfileCatalogResponse struct {
+	Icons         []string `json:"icons"`
+	CardBacks     []string `json:"card_backs"`
+	Languages     []string `json:"languages"`
+	NameMaxLength int      `json:"name_max_length"`
+}
+
+// ProfileCatalogHandler は設定で選べるアイコン・カードの裏面・言語の一覧を返す（認証不要）
+func ProfileCatalogHandler(w http.ResponseWriter, r *http.Request) {
+	langs := make([]string, 0, len(response.Langs()))
+	for _, l := range response.Langs() {
+		langs = append(langs, string(l))
+	}
+	response.OK(w, r, ProfileCatalogResponse{
+		Icons:         models.Icons,
+		CardBacks:     models.CardBacks,
+		Languages:     langs,
+		NameMaxLength: maxNameLength,
+	})
+}
+
+// ---- 名前の変更 ----
+
+// name: 新しい名前（前後の空白は除く。他のユーザーと重複不可）
+type ChangeNameRequest struct {
+	Name string `json:"name"`
+}
+
+type ChangeNameResponse struct {
+	Name             string     `json:"name"`
+	NameChangeableAt *time.Time `json:"name_changeable_at"` // 次に変えられる日時（null なら今すぐ変えられる）
+	TokenResponse               // 新しい名前を埋め込んだトークン
+}
+
+// ChangeNameHandler は表示名を変更する。
+// 一度変えたら profile.name_change_cooldown の間は変えられない（429、Retry-After 付き）。
+func ChangeNameHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		userID := middleware.GetUserID(r)
+		if userID == 0 {
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
+			return
+		}
+		var req ChangeNameRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.BadJSON(w, r)
+			return
+		}
+		name := strings.TrimSpace(req.Name)
+		if code, ok := checkName(name); !ok {
+			response.Invalid(w, r, response.Field("name", code))
+			return
+		}
+
+		user, err := repo.Users().GetByID(userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "user lookup failed", "user_id", userID, "err", err)
+			response.Internal(w, r)
+			return
+		}
+		now := time.Now()
+		// 同じ名前なら何も変えない（間隔の制限も数えない）
+		if name != user.Name {
+			if until := nameChangeableAt(user, now); until != nil {
+				failNameChangeTooSoon(w, r, *until, now)
+				return
+			}
+			// 間隔の制限は UPDATE の条件でも確かめる（同時に来た変更で両方通らないように）
+			err := repo.Users().Rename(userID, name, now, now.Add(-max(cfg.Profile.NameChangeCooldown, 0)))
+			if errors.Is(err, models.ErrNameChangeTooSoon) {
+				failNameChangeTooSoon(w, r, now.Add(cfg.Profile.NameChangeCooldown), now)
+				return
+			}
+			if errors.Is(err, models.ErrNameTaken) {
+				response.NG(w, r, http.StatusConflict, response.CodeNameTaken)
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "rename failed", "user_id", userID, "err", err)
+				response.Internal(w, r)
+				return
+			}
+			slog.InfoContext(r.Context(), "user renamed", "user_id", userID, "from", user.Name, "to", name)
+			user.Name = name
+			user.NameChanged.Time, user.NameChanged.Valid = now, true
+		}
+
+		// トークンには名前が入っているので発行し直す
+		pair, err := issueTokenPair(r.Context(), userID)
+		if err != nil {
+			response.Internal(w, r)
+			return
+		}
+		response.OK(w, r, ChangeNameResponse{
+			Name:             user.Name,
+			NameChangeableAt: nameChangeableAt(user, now),
+			TokenResponse:    pair.response(),
+		})
+	}
+}
+
+// failNameChangeTooSoon は until まで待つよう 429 を返す
+func failNameChangeTooSoon(w http.ResponseWriter, r *http.Request, until, now time.Time) {
+	secs := int((until.Sub(now) + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	response.Fail(w, r, http.StatusTooManyRequests, response.CodeNameChangeTooSoon)
+}
+
+// checkName は名前に使えるか（空・長すぎ・制御文字を含むものは不可）
+func checkName(name string) (response.FieldCode, bool) {
+	switch {
+	case name == "":
+		return response.FieldRequired, false
+	case utf8.RuneCountInString(name) > maxNameLength:
+		return response.FieldTooLong, false
+	case strings.IndexFunc(name, unicode.IsControl) >= 0:
+		return response.FieldInvalid, false
+	}
+	return "", true
+}
+
+// nameChangeableAt は次に名前を変えられる日時（今すぐ変えられるなら nil）
+func nameChangeableAt(u *models.User, now time.Time) *time.Time {
+	if !u.NameChanged.Valid || cfg.Profile.NameChangeCooldown <= 0 {
+		return nil
+	}
+	at := u.NameChanged.Time.Add(cfg.Profile.NameChangeCooldown)
+	if !at.After(now) {
+		return nil
+	}
+	return &at
+}
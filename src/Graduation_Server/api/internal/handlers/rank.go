@@ -3,37 +3,66 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
+	"api/internal/models"
+	"api/internal/response"
+	"api/internal/store"
+	"log/slog"
 	"net/http"
 )
 
-func RankModeHandler(w http.ResponseWriter, r *http.Request) {
+// ソロ・マルチが遊べるか（maintenance 中は新しいルームを作れない）
+type RankModeResponse struct {
+	CanSolo     bool `json:"can_solo"`
+	CanMulti    bool `json:"can_multi"`
+	Maintenance bool `json:"maintenance"`
+}
 
-	var canSolo, canMulti bool
+func RankModeHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		modes, ok := playableModes(w, r, repo, "ソロ", "マルチ")
+		if !ok {
+			return
+		}
+		// メンテナンス中は新しいルームを作れない（クライアントの表示用）
+		maintenance, err := repo.Notices().Maintenance()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "メンテナンス状態取得失敗", "err", err)
+			response.Internal(w, r)
+			return
+		}
+		//レスポンス
+		resp := RankModeResponse{
+			CanSolo:     modes[0],
+			CanMulti:    modes[1],
+			Maintenance: maintenance.Enabled,
+		}
 
-	// ソロの可否確認
-	err := db.QueryRow(`SELECT is_can_play FROM modes WHERE mode = 'ソロ'`).Scan(&canSolo)
-	if err != nil {
-		log.Printf("[ERROR] ソロモード取得失敗: %v", err)
-		http.Error(w, "ソロモード取得失敗", http.StatusInternalServerError)
-		return
+		response.OK(w, r, resp)
 	}
+}
 
-	// マルチの可否確認
-	err = db.QueryRow(`SELECT is_can_play FROM modes WHERE mode = 'マルチ'`).Scan(&canMulti)
+// playableModes は names の順にモードが遊べるかを返す（無いモードがあれば 500 を返して false）
+func playableModes(w http.ResponseWriter, r *http.Request, repo store.Store, names ...string) ([]bool, bool) {
+	all, err := repo.Games().Modes()
 	if err != nil {
-		log.Printf("[ERROR] マルチモード取得失敗: %v", err)
-		http.Error(w, "マルチモード取得失敗", http.StatusInternalServerError)
-		return
+		slog.ErrorContext(r.Context(), "モード取得失敗", "err", err)
+		response.Internal(w, r)
+		return nil, false
 	}
-	//レスポンス
-	resp := map[string]interface{}{
-		"result":    "OK",
-		"can_solo":  canSolo,
-		"can_multi": canMulti,
+	byName := make(map[string]models.Mode, len(all))
+	for _, m := range all {
+		byName[m.Mode] = m
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	canPlay := make([]bool, len(names))
+	for i, name := range names {
+		m, ok := byName[name]
+		if !ok {
+			slog.ErrorContext(r.Context(), "モードが無い", "mode", name)
+			response.Internal(w, r)
+			return nil, false
+		}
+		canPlay[i] = m.CanPlay
+	}
+	return canPlay, true
 }
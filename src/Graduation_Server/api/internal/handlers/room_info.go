@@ -2,37 +2,37 @@ package handlers
 
 import (
 	"api/internal/middleware"
-	"api/internal/models"
-	"database/sql"
-	"encoding/json"
+	"api/internal/response"
+	"api/internal/store"
 	"net/http"
 )
 
-func RoomStatusHandler(db *sql.DB) http.HandlerFunc {
+func RoomStatusHandler(repo store.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
 		// 認証チェック
 		// 未認証は401
 		userID := middleware.GetUserID(r)
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
 			return
 		}
 		// ルームコード取得
 		roomCode := r.URL.Query().Get("code")
 		if roomCode == "" {
-			http.Error(w, "Missing room code", http.StatusBadRequest)
+			response.Invalid(w, r, response.Field("room_code", response.FieldRequired))
 			return
 		}
 		// DB からルーム情報を取得
-		room, err := models.GetRoomByCode(db, roomCode)
+		room, err := repo.Rooms().GetByCode(roomCode)
 		if err != nil {
-			http.Error(w, "Room not found", http.StatusNotFound)
+			response.Fail(w, r, http.StatusNotFound, response.CodeRoomNotFound)
 			return
 		}
 		// ルーム内の参加メンバー一覧を取得
-		users, err := models.GetUsersInRoom(db, room.ID)
+		users, err := repo.RoomUsers().List(room.ID)
 		if err != nil {
-			http.Error(w, "DB error", http.StatusInternalServerError)
+			response.Internal(w, r)
 			return
 		}
 
@@ -46,6 +46,7 @@ func RoomStatusHandler(db *sql.DB) http.HandlerFunc {
 				Name:    u.UserName,
 				IsReady: u.IsReady,
 				IsHost:  u.IsHost,
+				SeatNo:  u.SeatNo,
 			})
 		}
 		// レスポンスを返す
@@ -55,7 +56,6 @@ func RoomStatusHandler(db *sql.DB) http.HandlerFunc {
 			Players:  players,
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(resp)
+		response.OK(w, r, resp)
 	}
 }
@@ -3,9 +3,11 @@ package handlers
 import (
 	"api/internal/middleware"
 	"api/internal/models"
-	"database/sql"
+	"api/internal/response"
+	"api/internal/store"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 )
 
@@ -16,109 +18,112 @@ type JoinRoomRequest struct {
 
 // レスポンス
 type JoinRoomResponse struct {
-	Result   string `json:"result"`
+	Result   string `json:"result,omitempty"` // 旧ルートのみ（response.OK が "OK" を入れる）
 	RoomID   int64  `json:"room_id"`
 	RoomCode string `json:"room_code"`
 	UserID   int64  `json:"user_id"`
 	GameName string `json:"game_name"`
 }
 
-func JoinRoomHandler(db *sql.DB) http.HandlerFunc {
+func JoinRoomHandler(repo store.Store) http.HandlerFunc {
 	// 認証チェック
 	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
 		userID := middleware.GetUserID(r)
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
+			return
+		}
+		// 停止処理中は新しいルームを受け付けない
+		if rejectDuringShutdown(w, r) {
+			return
+		}
+		// メンテナンス中も新しいルームは受け付けない（進行中のルームはそのまま）
+		if rejectDuringMaintenance(w, r, repo) {
 			return
 		}
 		// リクエスト JSON のデコード & バリデーション
 		var req JoinRoomRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomCode == "" {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.BadJSON(w, r)
+			return
+		}
+		if req.RoomCode == "" {
+			response.Invalid(w, r, response.Field("room_code", response.FieldRequired))
 			return
 		}
 		// ルーム取得 & 状態チェック
-		room, err := models.GetRoomByCode(db, req.RoomCode)
+		room, err := repo.Rooms().GetByCode(req.RoomCode)
 		if err != nil {
-			http.Error(w, "Room not found", http.StatusNotFound)
+			response.Fail(w, r, http.StatusNotFound, response.CodeRoomNotFound)
 			return
 		}
 		// 待機中以外は参加不可
 		if room.Status != "waiting" {
-			http.Error(w, "Room not accepting joins", http.StatusForbidden)
+			response.Fail(w, r, http.StatusForbidden, response.CodeRoomNotWaiting)
 			return
 		}
 
 		// すでに参加していないか
-		inRoom, err := models.IsUserInRoom(db, room.ID, userID)
+		inRoom, err := repo.RoomUsers().IsMember(room.ID, userID)
 		if err != nil {
-			http.Error(w, "DB error", http.StatusInternalServerError)
+			response.Internal(w, r)
 			return
 		}
 		if inRoom {
 			// 既参加ならOKで返す（重複insertを避ける）
-			var gameName string
-			if err := db.QueryRow(`
-				SELECT gt.name
-				  FROM game_types gt
-				  JOIN rooms r ON r.game_type_id = gt.id
-				 WHERE r.id = ?
-			`, room.ID).Scan(&gameName); err != nil {
-				http.Error(w, "Lookup error", http.StatusInternalServerError)
+			gameName, err := repo.Games().GameTypeName(room.GameTypeID)
+			if err != nil {
+				response.Internal(w, r)
 				return
 			}
 			resp := JoinRoomResponse{
-				Result:   "OK",
 				RoomID:   room.ID,
 				RoomCode: room.RoomCode,
 				UserID:   userID,
 				GameName: gameName,
 			}
-			w.Header().Set("Content-Type", "application/json")
-			_ = json.NewEncoder(w).Encode(resp)
+			response.OK(w, r, resp)
 			return
 		}
 
-		count, err := models.CountUsersInRoom(db, room.ID)
+		count, err := repo.RoomUsers().Count(room.ID)
 		if err != nil {
-			http.Error(w, "DB error", http.StatusInternalServerError)
+			response.Internal(w, r)
 			return
 		}
 		// 人数上限チェック
 		if count >= room.MaxPlayers {
-			http.Error(w, "Room full", http.StatusForbidden)
+			response.Fail(w, r, http.StatusForbidden, response.CodeRoomFull)
 			return
 		}
-		// room_users に新規参加登録
-		if err := models.AddUserToRoom(db, room.ID, userID); err != nil {
-			log.Printf("AddUserToRoom error: %v", err)
-			http.Error(w, "DB error", http.StatusInternalServerError)
+		// room_users に新規参加登録（一番小さい空席に座る）
+		if _, err := repo.RoomUsers().Join(room.ID, userID, room.MaxPlayers); err != nil {
+			if errors.Is(err, models.ErrNoFreeSeat) {
+				response.Fail(w, r, http.StatusForbidden, response.CodeRoomFull)
+				return
+			}
+			slog.ErrorContext(r.Context(), "JoinRoom failed", "err", err)
+			response.Internal(w, r)
 			return
 		}
 		// game_name を取得してレスポンス
-		var gameName string
-		if err := db.QueryRow(`
-			SELECT gt.name
-			  FROM game_types gt
-			  JOIN rooms r ON r.game_type_id = gt.id
-			 WHERE r.id = ?
-		`, room.ID).Scan(&gameName); err != nil {
-			log.Printf("lookup game_name failed: %v", err)
-			http.Error(w, "Lookup error", http.StatusInternalServerError)
+		gameName, err := repo.Games().GameTypeName(room.GameTypeID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "game_name lookup failed", "err", err)
+			response.Internal(w, r)
 			return
 		}
 
 		resp := JoinRoomResponse{
-			Result:   "OK",
 			RoomID:   room.ID,
 			RoomCode: room.RoomCode,
 			UserID:   userID,
 			GameName: gameName,
 		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(resp)
+		response.OK(w, r, resp)
 
 		// 参加後はWS側にも反映
-		broadcastRoomStatus(room.RoomCode, db)
+		broadcastRoomStatus(r.Context(), room.RoomCode, repo)
 	}
 }
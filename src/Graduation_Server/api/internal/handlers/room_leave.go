@@ -2,8 +2,8 @@ package handlers
 
 import (
 	"api/internal/middleware"
-	"api/internal/models"
-	"database/sql"
+	"api/internal/response"
+	"api/internal/store"
 	"encoding/json"
 	"net/http"
 )
@@ -15,111 +15,75 @@ type LeaveRoomRequest struct {
 
 // レスポンス
 type LeaveRoomResponse struct {
-	Result         string `json:"result"` // "OK"
+	Result         string `json:"result,omitempty"` // 旧ルートのみ（response.OK が "OK" を入れる）
 	RoomCode       string `json:"room_code"`
 	HostChanged    bool   `json:"host_changed"`
 	NewHostUserID  int64  `json:"new_host_user_id,omitempty"`
 	RoomBecameZero bool   `json:"room_became_zero"`
 }
 
-func LeaveRoomHandler(db *sql.DB) http.HandlerFunc {
+func LeaveRoomHandler(repo store.Store) http.HandlerFunc {
 	// 認証 & リクエストチェック
 	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
 		userID := middleware.GetUserID(r)
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
 			return
 		}
 
 		var req LeaveRoomRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomCode == "" {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.BadJSON(w, r)
+			return
+		}
+		if req.RoomCode == "" {
+			response.Invalid(w, r, response.Field("room_code", response.FieldRequired))
 			return
 		}
 
 		// ルーム取得
-		room, err := models.GetRoomByCode(db, req.RoomCode)
+		room, err := repo.Rooms().GetByCode(req.RoomCode)
 		if err != nil {
-			http.Error(w, "Room not found", http.StatusNotFound)
+			response.Fail(w, r, http.StatusNotFound, response.CodeRoomNotFound)
 			return
 		}
 
 		// 参加確認
-		inRoom, err := models.IsUserInRoom(db, room.ID, userID)
+		inRoom, err := repo.RoomUsers().IsMember(room.ID, userID)
 		if err != nil {
-			http.Error(w, "DB error", http.StatusInternalServerError)
+			response.Internal(w, r)
 			return
 		}
 		if !inRoom {
-			http.Error(w, "Not in room", http.StatusForbidden)
+			response.Fail(w, r, http.StatusForbidden, response.CodeNotInRoom)
 			return
 		}
 
-		// Tx: 退出・ホスト交代・クローズ判定
-		tx, err := db.Begin()
+		// 退出・ホスト交代・クローズ判定（0人ならルームを status='closed' へ）
+		result, err := repo.RoomUsers().Leave(room, userID)
 		if err != nil {
-			http.Error(w, "DB error", http.StatusInternalServerError)
-			return
-		}
-		defer func() { _ = tx.Rollback() }()
-
-		// 退出
-		affected, err := models.RemoveUserFromRoomTx(tx, room.ID, userID)
-		if err != nil || affected == 0 {
-			http.Error(w, "Leave failed", http.StatusInternalServerError)
+			response.Internal(w, r)
 			return
 		}
-
-		// 残人数チェック
-		leftCount, err := models.CountUsersInRoomTx(tx, room.ID)
-		if err != nil {
-			http.Error(w, "DB error", http.StatusInternalServerError)
-			return
-		}
-
 		resp := LeaveRoomResponse{
-			Result:   "OK",
-			RoomCode: room.RoomCode,
+			RoomCode:       room.RoomCode,
+			HostChanged:    result.HostChanged,
+			NewHostUserID:  result.NewHostUserID,
+			RoomBecameZero: result.RoomBecameZero,
 		}
 
-		// 0人ならルームを閉じる（削除でもOK。ここでは status='closed' へ）
-		if leftCount == 0 {
-			if err := models.UpdateRoomStatusTx(tx, room.ID, "closed"); err != nil {
-				http.Error(w, "DB error", http.StatusInternalServerError)
-				return
-			}
-			resp.RoomBecameZero = true
-		} else {
-			// ホストが抜けたら交代
-			if userID == room.OwnerID {
-				newOwnerID, found, err := models.PickNextOwnerTx(tx, room.ID)
-				if err != nil {
-					http.Error(w, "DB error", http.StatusInternalServerError)
-					return
-				}
-				if found {
-					if err := models.SetRoomOwnerTx(tx, room.ID, newOwnerID); err != nil {
-						http.Error(w, "DB error", http.StatusInternalServerError)
-						return
-					}
-					resp.HostChanged = true
-					resp.NewHostUserID = newOwnerID
-				}
-			}
-		}
+		// このユーザーのWSを強制切断（ルーム側のコネクション表から掃除）
+		closeUserConnections(r.Context(), req.RoomCode, userID)
 
-		if err := tx.Commit(); err != nil {
-			http.Error(w, "DB error", http.StatusInternalServerError)
-			return
+		// 誰もいなくなったルームの卓の状態は捨てる
+		if result.RoomBecameZero {
+			forgetBJState(r.Context(), repo, req.RoomCode)
 		}
 
-		// このユーザーのWSを強制切断（ルーム側のコネクション表から掃除）
-		closeUserConnections(req.RoomCode, userID)
-
 		// 残メンバーへ最新状態を通知
-		broadcastRoomStatus(req.RoomCode, db)
+		broadcastRoomStatus(r.Context(), req.RoomCode, repo)
 
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(resp)
+		response.OK(w, r, resp)
 	}
 }
@@ -2,36 +2,37 @@ package handlers
 
 import (
 	"api/internal/middleware"
-	"api/internal/models"
-	"database/sql"
+	"api/internal/response"
+	"api/internal/store"
 	"encoding/json"
 	"net/http"
 )
 
 // ReadyResponse は「準備完了」API成功時に返すレスポンス。
 type ReadyResponse struct {
-	Result string `json:"result"`
+	Result string `json:"result,omitempty"` // 旧ルートのみ（response.OK が "OK" を入れる）
 }
 
 // ReadyHandler は「ルーム内でユーザーが準備完了状態になった」ことを更新するハンドラ。
-func ReadyHandler(db *sql.DB) http.HandlerFunc {
+func ReadyHandler(repo store.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
 		// ユーザーID取得
 		userID := middleware.GetUserID(r)
 		var req ReadyRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
+			response.BadJSON(w, r)
 			return
 		}
-		room, err := models.GetRoomByCode(db, req.RoomCode)
+		room, err := repo.Rooms().GetByCode(req.RoomCode)
 		if err != nil {
-			http.Error(w, "Room not found", http.StatusNotFound)
+			response.Fail(w, r, http.StatusNotFound, response.CodeRoomNotFound)
 			return
 		}
-		if err := models.UpdateUserReady(db, room.ID, userID, req.IsReady); err != nil {
-			http.Error(w, "DB error", http.StatusInternalServerError)
+		if err := repo.RoomUsers().SetReady(room.ID, userID, req.IsReady); err != nil {
+			response.Internal(w, r)
 			return
 		}
-		json.NewEncoder(w).Encode(ReadyResponse{Result: "OK"})
+		response.OK(w, r, ReadyResponse{})
 	}
 }
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"api/internal/models"
+	"api/internal/response"
+	"api/internal/store"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+)
+
+// 席替えは待機中（status='waiting'）のルームでのみ可能
+var errSeatChangeNotWaiting = errors.New("seat change is only allowed while waiting")
+var errInvalidSeat = errors.New("invalid seat number")
+var errSwapTarget = errors.New("invalid swap target")
+
+// 席入れ替え申請：roomCode -> (申請者userID -> 相手userID)
+var (
+	seatSwapRequests = make(map[string]map[int64]int64)
+	seatSwapMu       sync.Mutex
+)
+
+// サーバー→クライアント：席入れ替えの申請が来たことを相手に通知
+type SeatSwapRequestNotice struct {
+	Type       string `json:"type"` // "seat_swap_request"
+	RoomCode   string `json:"room_code"`
+	FromUserID int64  `json:"from_user_id"`
+	FromSeatNo int    `json:"from_seat_no"`
+}
+
+// サーバー→クライアント：席操作の失敗通知（申請者本人にだけ返す）
+type SeatErrorResponse struct {
+	Type    string        `json:"type"` // "seat_error"
+	Code    response.Code `json:"code"`
+	Message string        `json:"message"` // 接続した人の言語の文言
+}
+
+// 空席への移動
+func selectSeat(repo store.Store, room *models.Room, userID int64, seatNo int) error {
+	if room.Status != "waiting" {
+		return errSeatChangeNotWaiting
+	}
+	if seatNo < 1 || seatNo > room.MaxPlayers {
+		return errInvalidSeat
+	}
+	if err := repo.RoomUsers().MoveToSeat(room.ID, userID, seatNo); err != nil {
+		return err
+	}
+	// 座席が変わったので、この人が出していた入れ替え申請は無効
+	clearSeatSwapRequests(room.RoomCode, userID)
+	return nil
+}
+
+// 席の入れ替え申請。相手からも自分宛ての申請が出ていれば入れ替えを実行して true を返す。
+// まだなら申請を記録し、相手に seat_swap_request を送って false を返す。
+func requestSeatSwap(ctx context.Context, repo store.Store, room *models.Room, userID, targetUserID int64) (bool, error) {
+	if room.Status != "waiting" {
+		return false, errSeatChangeNotWaiting
+	}
+	if targetUserID == 0 || targetUserID == userID {
+		return false, errSwapTarget
+	}
+	inRoom, err := repo.RoomUsers().IsMember(room.ID, targetUserID)
+	if err != nil {
+		return false, err
+	}
+	if !inRoom {
+		return false, errSwapTarget
+	}
+
+	seatSwapMu.Lock()
+	reqs := seatSwapRequests[room.RoomCode]
+	if reqs == nil {
+		reqs = make(map[int64]int64)
+		seatSwapRequests[room.RoomCode] = reqs
+	}
+	mutual := reqs[targetUserID] == userID
+	if mutual {
+		delete(reqs, targetUserID)
+		delete(reqs, userID)
+	} else {
+		reqs[userID] = targetUserID
+	}
+	seatSwapMu.Unlock()
+
+	if mutual {
+		if err := repo.RoomUsers().SwapSeats(room.ID, userID, targetUserID); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	seatNo, err := repo.RoomUsers().SeatNo(room.ID, userID)
+	if err != nil {
+		return false, err
+	}
+	notifyUserInRoom(ctx, room.RoomCode, targetUserID, SeatSwapRequestNotice{
+		Type:       "seat_swap_request",
+		RoomCode:   room.RoomCode,
+		FromUserID: userID,
+		FromSeatNo: seatNo,
+	})
+	return false, nil
+}
+
+// userID が出した／受けた入れ替え申請をすべて破棄
+func clearSeatSwapRequests(roomCode string, userID int64) {
+	seatSwapMu.Lock()
+	defer seatSwapMu.Unlock()
+
+	reqs := seatSwapRequests[roomCode]
+	for from, to := range reqs {
+		if from == userID || to == userID {
+			delete(reqs, from)
+		}
+	}
+	if len(reqs) == 0 {
+		delete(seatSwapRequests, roomCode)
+	}
+}
+
+// ルームWS上の特定ユーザーにだけ送信（他インスタンスにつないでいても届く）
+func notifyUserInRoom(ctx context.Context, roomCode string, userID int64, v interface{}) {
+	localNotifyUser(roomCode, userID, v)
+	if raw, err := json.Marshal(v); err == nil {
+		publishEvent(ctx, roomEvent{Kind: "notify_user", Room: roomCode, UserID: userID, Data: raw})
+	}
+}
+
+func localNotifyUser(roomCode string, userID int64, v interface{}) {
+	roomConnMu.Lock()
+	var targets []*roomConn
+	for _, rc := range roomConnections[roomCode] {
+		if rc.userID == userID {
+			targets = append(targets, rc)
+		}
+	}
+	roomConnMu.Unlock()
+
+	for _, c := range targets {
+		if err := writeRoomJSON(c, v); err != nil {
+			slog.Info("room notify failed", "room", roomCode, "user_id", userID, "err", err)
+		}
+	}
+}
+
+func sendSeatError(logger *slog.Logger, rc *roomConn, lang response.Lang, err error) {
+	logger.Info("seat command rejected", "err", err)
+	code := response.CodeInternal
+	switch {
+	case errors.Is(err, errSeatChangeNotWaiting):
+		code = response.CodeAlreadyStarted
+	case errors.Is(err, errInvalidSeat):
+		code = response.CodeInvalidSeat
+	case errors.Is(err, errSwapTarget):
+		code = response.CodeInvalidSwapTarget
+	case errors.Is(err, models.ErrSeatTaken):
+		code = response.CodeSeatTaken
+	}
+	notice := SeatErrorResponse{Type: "seat_error", Code: code, Message: response.MessageIn(lang, code)}
+	if werr := writeRoomJSON(rc, notice); werr != nil {
+		logger.Info("seat_error send failed", "err", werr)
+	}
+}
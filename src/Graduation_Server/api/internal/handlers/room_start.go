@@ -3,9 +3,11 @@ package handlers
 import (
 	"api/internal/middleware"
 	"api/internal/models"
-	"database/sql"
+	"api/internal/response"
+	"api/internal/store"
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
@@ -16,103 +18,112 @@ type StartRequest struct {
 
 // レスポンス
 type StartResponse struct {
-	Result string `json:"result"`
+	Result string `json:"result,omitempty"` // 旧ルートのみ（response.OK が "OK" を入れる）
 	GameID int64  `json:"game_id"`
 }
 
-func StartRoomHandler(db *sql.DB) http.HandlerFunc {
+func StartRoomHandler(repo store.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
 		// 認証 & リクエストチェック
 		userID := middleware.GetUserID(r)
-		log.Printf("[StartRoom] Request userID = %d\n", userID)
 		if userID == 0 {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
+			return
+		}
+		// 停止処理中は新しいゲームを始めない
+		if rejectDuringShutdown(w, r) {
 			return
 		}
 		var req StartRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RoomCode == "" {
-			http.Error(w, "Invalid request", http.StatusBadRequest)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.BadJSON(w, r)
+			return
+		}
+		if req.RoomCode == "" {
+			response.Invalid(w, r, response.Field("room_code", response.FieldRequired))
 			return
 		}
 		// ルーム取得
-		room, err := models.GetRoomByCode(db, req.RoomCode)
+		room, err := repo.Rooms().GetByCode(req.RoomCode)
 		if err != nil {
-			http.Error(w, "Room not found: "+err.Error(), http.StatusNotFound)
+			response.Fail(w, r, http.StatusNotFound, response.CodeRoomNotFound)
 			return
 		}
 
-		log.Printf("[StartRoom] room.ID=%d, room.OwnerID=%d, userID=%d\n",
-			room.ID, room.OwnerID, userID)
-
 		if room.OwnerID != userID {
-			log.Printf("[StartRoom] forbidden: not host (userID=%d, ownerID=%d)\n", userID, room.OwnerID)
-			http.Error(w, "Only host can start", http.StatusForbidden)
+			slog.InfoContext(r.Context(), "start room forbidden: not host", "room", room.RoomCode, "owner_id", room.OwnerID)
+			response.Fail(w, r, http.StatusForbidden, response.CodeNotHost)
 			return
 		}
 		// 「ホストかどうか」の判定
 		// isHost, err := models.IsUserHostInRoom(db, room.ID, userID)
 		// if err != nil {
-		// 	http.Error(w, "host check failed: "+err.Error(), http.StatusInternalServerError)
+		// 	response.Internal(w, r)
 		// 	return
 		// }
 		// if !isHost {
-		// 	http.Error(w, "Only host can start", http.StatusForbidden)
+		// 	response.Fail(w, r, http.StatusForbidden, response.CodeNotHost)
 		// 	return
 		// }
 		// 全員 Ready かチェック
-		userCount, err := models.CountUsersInRoom(db, room.ID)
+		userCount, err := repo.RoomUsers().Count(room.ID)
 		if err != nil {
-			http.Error(w, "count users failed: "+err.Error(), http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "start room: count users failed", "room", room.RoomCode, "err", err)
+			response.Internal(w, r)
 			return
 		}
-		readyCount, err := models.CountReadyUsers(db, room.ID)
+		readyCount, err := repo.RoomUsers().CountReady(room.ID)
 		if err != nil {
-			http.Error(w, "count ready failed: "+err.Error(), http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "start room: count ready failed", "room", room.RoomCode, "err", err)
+			response.Internal(w, r)
 			return
 		}
 		if userCount == 0 || userCount != readyCount {
-			http.Error(w, "Not all users are ready", http.StatusForbidden)
+			response.Fail(w, r, http.StatusForbidden, response.CodeNotAllReady)
 			return
 		}
 		// 状態チェック
 		if room.Status == "playing" {
-			http.Error(w, "Already playing", http.StatusConflict)
+			response.Fail(w, r, http.StatusConflict, response.CodeAlreadyStarted)
 			return
 		}
-		// トランザクションで Game 作成 & Room 状態更新
-		tx, err := db.Begin()
+		// Game 作成 & Room 状態更新（1トランザクション）
+		gameID, err := repo.Games().Start(room, models.ModeMulti)
 		if err != nil {
-			http.Error(w, "tx begin failed: "+err.Error(), http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "start room: start game failed", "room", room.RoomCode, "err", err)
+			response.Internal(w, r)
 			return
 		}
-		defer func() { _ = tx.Rollback() }()
+		roundsStarted.Inc()
 
-		res, err := tx.Exec(`
-			INSERT INTO games (mode_id, type_id, created_at, updated_at)
-			VALUES (?, ?, NOW(), NOW())`,
-			1, room.GameTypeID,
-		)
-		if err != nil {
-			http.Error(w, "insert games failed: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		gameID, err := res.LastInsertId()
-		if err != nil {
-			http.Error(w, "lastInsertId failed: "+err.Error(), http.StatusInternalServerError)
-			return
+		// 卓にゲームを結び付け、2回目以降の開始ならディーラーを席順で次の人へ回す（卓の担当インスタンスで行う）
+		if ownsTable(repo, req.RoomCode) {
+			startTable(repo, req.RoomCode, gameID, models.ModeMulti)
+		} else {
+			sendTableCommand(r.Context(), tableCommand{Kind: "start", Room: req.RoomCode, GameID: gameID, ModeID: models.ModeMulti})
 		}
 
-		if _, err := tx.Exec(`UPDATE rooms SET status='playing' WHERE id=?`, room.ID); err != nil {
-			http.Error(w, "update room status failed: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if err := tx.Commit(); err != nil {
-			http.Error(w, "tx commit failed: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
 		// WebSocket へ「ゲーム開始」を通知 & レスポンス返却
-		go broadcastStartGame(req.RoomCode, gameID)
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(StartResponse{Result: "OK", GameID: gameID})
+		// （レスポンス後も続くので、キャンセルは引き継がずトレースだけ引き継ぐ）
+		go broadcastStartGame(context.WithoutCancel(r.Context()), req.RoomCode, gameID)
+		response.OK(w, r, StartResponse{GameID: gameID})
+	}
+}
+
+// startTable は卓に開始したゲームを結び付けて保存する。
+// 卓が既にあれば（2回目以降の開始）ディーラーを次の席へ回し、無ければ空の卓を作って入室を待つ。
+func startTable(repo store.Store, roomCode string, gameID int64, modeID int) {
+	bjMu.Lock()
+	st, ok := bjRoomStates[roomCode]
+	if ok {
+		dealerID := RotateDealer(st)
+		slog.Info("dealer rotated", "room", roomCode, "dealer_id", dealerID)
+	} else {
+		st = &BJRoomState{Players: make(map[int64]*BJBetPlayerState)}
+		bjRoomStates[roomCode] = st
 	}
+	st.gameID, st.modeID, st.round, st.log = gameID, modeID, 0, nil
+	bjMu.Unlock()
+	persistBJState(repo, roomCode)
 }
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"api/internal/middleware"
+	"api/internal/models"
+	"api/internal/response"
+	"api/internal/store"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// テスト用のストアにユーザーを作って ID を返す
+func createTestUser(t *testing.T, repo *store.Memory, name string) int64 {
+	t.Helper()
+	id, err := repo.Users().Create(name, "", time.Now())
+	if err != nil {
+		t.Fatalf("create user %s: %v", name, err)
+	}
+	return id
+}
+
+// JWTミドルウェアを通った後と同じく、コンテキストに userID を入れてハンドラを呼ぶ
+// （応答は旧ルートの形。v1 のエンベロープは server の e2e テストで見る）
+func callAsUser(h http.HandlerFunc, userID int64, body interface{}) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(response.WithLegacy(req.Context()))
+	if userID != 0 {
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, userID))
+	}
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec
+}
+
+func decodeJSON(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("decode response: %v (body=%q)", err, rec.Body.String())
+	}
+}
+
+// ルームを作成してルームコードを返す
+func createTestRoom(t *testing.T, repo *store.Memory, hostID int64, maxPlayers int) string {
+	t.Helper()
+	rec := callAsUser(CreateRoomHandler(repo), hostID, CreateRoomRequest{GameTypeID: 1, MaxPlayers: maxPlayers})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create room: status %d body=%q", rec.Code, rec.Body.String())
+	}
+	var resp CreateRoomResponse
+	decodeJSON(t, rec, &resp)
+	return resp.RoomCode
+}
+
+func joinTestRoom(t *testing.T, repo *store.Memory, userID int64, roomCode string) {
+	t.Helper()
+	rec := callAsUser(JoinRoomHandler(repo), userID, JoinRoomRequest{RoomCode: roomCode})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("join room: status %d body=%q", rec.Code, rec.Body.String())
+	}
+}
+
+func roomUsers(t *testing.T, repo *store.Memory, roomCode string) (*models.Room, []models.RoomUser) {
+	t.Helper()
+	room, err := repo.Rooms().GetByCode(roomCode)
+	if err != nil {
+		t.Fatalf("get room: %v", err)
+	}
+	users, err := repo.RoomUsers().List(room.ID)
+	if err != nil {
+		t.Fatalf("list users: %v", err)
+	}
+	return room, users
+}
+
+func TestCreateRoomHandler(t *testing.T) {
+	repo := store.NewMemory()
+	host := createTestUser(t, repo, "host")
+
+	rec := callAsUser(CreateRoomHandler(repo), host, CreateRoomRequest{GameTypeID: 1})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body=%q", rec.Code, rec.Body.String())
+	}
+	var resp CreateRoomResponse
+	decodeJSON(t, rec, &resp)
+	if resp.Result != "OK" || resp.UserID != host || resp.GameName != "ブラックジャック" || len(resp.RoomCode) != 6 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	room, users := roomUsers(t, repo, resp.RoomCode)
+	if room.Status != "waiting" || room.OwnerID != host || room.MaxPlayers != 4 {
+		t.Fatalf("unexpected room: %+v", room)
+	}
+	if len(users) != 1 || users[0].UserID != host || !users[0].IsHost || users[0].SeatNo != models.HostSeatNo {
+		t.Fatalf("unexpected room users: %+v", users)
+	}
+}
+
+func TestCreateRoomHandlerErrors(t *testing.T) {
+	repo := store.NewMemory()
+	host := createTestUser(t, repo, "host")
+
+	if rec := callAsUser(CreateRoomHandler(repo), 0, CreateRoomRequest{GameTypeID: 1}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no user: status = %d, want 401", rec.Code)
+	}
+	if rec := callAsUser(CreateRoomHandler(repo), host, CreateRoomRequest{GameTypeID: 99}); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown game type: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestJoinRoomHandler(t *testing.T) {
+	repo := store.NewMemory()
+	host := createTestUser(t, repo, "host")
+	guest := createTestUser(t, repo, "guest")
+	third := createTestUser(t, repo, "third")
+	code := createTestRoom(t, repo, host, 2)
+
+	rec := callAsUser(JoinRoomHandler(repo), guest, JoinRoomRequest{RoomCode: code})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("join: status = %d, body=%q", rec.Code, rec.Body.String())
+	}
+	var resp JoinRoomResponse
+	decodeJSON(t, rec, &resp)
+	if resp.Result != "OK" || resp.RoomCode != code || resp.UserID != guest || resp.GameName != "ブラックジャック" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	// 参加済みなら重複登録せず OK
+	if rec := callAsUser(JoinRoomHandler(repo), guest, JoinRoomRequest{RoomCode: code}); rec.Code != http.StatusOK {
+		t.Fatalf("rejoin: status = %d", rec.Code)
+	}
+	_, users := roomUsers(t, repo, code)
+	if len(users) != 2 || users[1].UserID != guest || users[1].SeatNo != 2 {
+		t.Fatalf("unexpected room users: %+v", users)
+	}
+
+	// 満席
+	if rec := callAsUser(JoinRoomHandler(repo), third, JoinRoomRequest{RoomCode: code}); rec.Code != http.StatusForbidden {
+		t.Errorf("full room: status = %d, want 403", rec.Code)
+	}
+	// 存在しないルーム
+	if rec := callAsUser(JoinRoomHandler(repo), third, JoinRoomRequest{RoomCode: "000000x"}); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown room: status = %d, want 404", rec.Code)
+	}
+	// 待機中以外
+	room, _ := roomUsers(t, repo, code)
+	if err := repo.Rooms().UpdateStatus(room.ID, "playing"); err != nil {
+		t.Fatal(err)
+	}
+	if rec := callAsUser(JoinRoomHandler(repo), third, JoinRoomRequest{RoomCode: code}); rec.Code != http.StatusForbidden {
+		t.Errorf("playing room: status = %d, want 403", rec.Code)
+	}
+}
+
+func TestLeaveRoomHandler(t *testing.T) {
+	repo := store.NewMemory()
+	host := createTestUser(t, repo, "host")
+	guest := createTestUser(t, repo, "guest")
+	outsider := createTestUser(t, repo, "outsider")
+	code := createTestRoom(t, repo, host, 4)
+	joinTestRoom(t, repo, guest, code)
+
+	if rec := callAsUser(LeaveRoomHandler(repo), outsider, LeaveRoomRequest{RoomCode: code}); rec.Code != http.StatusForbidden {
+		t.Errorf("not in room: status = %d, want 403", rec.Code)
+	}
+
+	// ホストが抜けたら次の席の人がホスト
+	rec := callAsUser(LeaveRoomHandler(repo), host, LeaveRoomRequest{RoomCode: code})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("host leave: status = %d, body=%q", rec.Code, rec.Body.String())
+	}
+	var resp LeaveRoomResponse
+	decodeJSON(t, rec, &resp)
+	if !resp.HostChanged || resp.NewHostUserID != guest || resp.RoomBecameZero {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	room, users := roomUsers(t, repo, code)
+	if room.OwnerID != guest || len(users) != 1 || !users[0].IsHost {
+		t.Fatalf("unexpected room after host leave: %+v %+v", room, users)
+	}
+
+	// 最後の1人が抜けたらクローズ
+	rec = callAsUser(LeaveRoomHandler(repo), guest, LeaveRoomRequest{RoomCode: code})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("last leave: status = %d", rec.Code)
+	}
+	resp = LeaveRoomResponse{}
+	decodeJSON(t, rec, &resp)
+	if !resp.RoomBecameZero || resp.HostChanged {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if room, _ := roomUsers(t, repo, code); room.Status != "closed" {
+		t.Fatalf("room status = %q, want closed", room.Status)
+	}
+}
+
+func TestStartRoomHandler(t *testing.T) {
+	repo := store.NewMemory()
+	host := createTestUser(t, repo, "host")
+	guest := createTestUser(t, repo, "guest")
+	code := createTestRoom(t, repo, host, 4)
+	joinTestRoom(t, repo, guest, code)
+	room, _ := roomUsers(t, repo, code)
+
+	if rec := callAsUser(StartRoomHandler(repo), guest, StartRequest{RoomCode: code}); rec.Code != http.StatusForbidden {
+		t.Errorf("non-host start: status = %d, want 403", rec.Code)
+	}
+	if rec := callAsUser(StartRoomHandler(repo), host, StartRequest{RoomCode: code}); rec.Code != http.StatusForbidden {
+		t.Errorf("not ready: status = %d, want 403", rec.Code)
+	}
+
+	for _, id := range []int64{host, guest} {
+		if err := repo.RoomUsers().SetReady(room.ID, id, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rec := callAsUser(StartRoomHandler(repo), host, StartRequest{RoomCode: code})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("start: status = %d, body=%q", rec.Code, rec.Body.String())
+	}
+	var resp StartResponse
+	decodeJSON(t, rec, &resp)
+	if resp.Result != "OK" || resp.GameID == 0 || repo.GameCount() != 1 {
+		t.Fatalf("unexpected response: %+v (games=%d)", resp, repo.GameCount())
+	}
+	if room, _ := roomUsers(t, repo, code); room.Status != "playing" {
+		t.Fatalf("room status = %q, want playing", room.Status)
+	}
+	if rec := callAsUser(StartRoomHandler(repo), host, StartRequest{RoomCode: code}); rec.Code != http.StatusConflict {
+		t.Errorf("second start: status = %d, want 409", rec.Code)
+	}
+}
@@ -1,34 +1,72 @@
 package handlers
 
 import (
+	"api/internal/logging"
 	"api/internal/middleware"
-	"api/internal/models"
-	"database/sql"
+	"api/internal/response"
+	"api/internal/store"
+	"api/internal/tracing"
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var roomConnections = make(map[string]map[*websocket.Conn]int64)
+var roomConnections = make(map[string]map[*websocket.Conn]*roomConn)
 var roomConnMu sync.Mutex
 
+// ルームWSの送信の締切（詰まった接続で送信側をいつまでも止めない）
+const roomWriteWait = 5 * time.Second
+
+// roomConn はルームWSの1接続。
+// 書き込みの排他は接続ごとに持つ（ブロードキャストと個別返信が同じ接続へ同時に書かないように。遅い接続が他の接続への送信を止めないように）
+type roomConn struct {
+	conn    *websocket.Conn
+	userID  int64
+	writeMu sync.Mutex
+}
+
+// writeRoomJSON は締切付きで1メッセージ送る（締切の設定も書き込みなので writeMu の中で行う）
+func writeRoomJSON(c *roomConn, v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(roomWriteWait))
+	return c.conn.WriteJSON(v)
+}
+
 // WebSocket メッセージ構造
 type ReadyRequest struct {
 	RoomCode string `json:"room_code"`
 	IsReady  bool   `json:"is_ready"`
 }
 
+// クライアント→サーバー：ルームWSのコマンド
+// type 省略時は従来どおり Ready 更新として扱う。
+//   - "ready"       : is_ready を更新
+//   - "seat_select" : seat_no の空席へ移動
+//   - "seat_swap"   : target_user_id と席の入れ替えを申請（相手も申請したら成立）
+type RoomCommand struct {
+	Type         string `json:"type"`
+	RoomCode     string `json:"room_code"`
+	IsReady      bool   `json:"is_ready"`
+	SeatNo       int    `json:"seat_no"`
+	TargetUserID int64  `json:"target_user_id"`
+}
+
 type PlayerInfo struct {
 	UserID   int64  `json:"user_id"`
 	Name     string `json:"name"`
 	IsReady  bool   `json:"is_ready"`
 	IsHost   bool   `json:"is_host"`
 	IsDealer bool   `json:"is_dealer"`
+	SeatNo   int    `json:"seat_no"`
 }
 
 type RoomStatusResponse struct {
@@ -49,51 +87,56 @@ type StartGameBroadcast struct {
 	GameID   int64  `json:"game_id"`
 }
 
-func GameRoomWebSocketHandler(db *sql.DB) http.HandlerFunc {
+func GameRoomWebSocketHandler(repo store.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		roomCode := mux.Vars(r)["room_code"]
+		// 以降のログには request_id / user_id / room が付く
+		logger := logging.FromContext(logging.With(r.Context(), "room", roomCode))
+
 		// オリジン許可（必要に応じてmiddleware.Upgraderで設定）
 		conn, err := middleware.Upgrader.Upgrade(w, r, nil)
 		if err != nil {
-			log.Println("WebSocket Upgrade Error:", err)
+			logger.Warn("room ws upgrade failed", "err", err)
 			return
 		}
-		log.Println("WS connected")
-		defer func() {
-			log.Println("WS closing")
-			conn.Close()
-		}()
+		defer conn.Close()
 
-		roomCode := mux.Vars(r)["room_code"]
 		userID := middleware.GetUserID(r)
 		if userID == 0 {
-			log.Println("Unauthorized WebSocket access (userID = 0)")
+			logger.Warn("room ws unauthorized")
 			return
 		}
 
+		// 接続時・切断時の処理は接続（アップグレードした HTTP リクエスト）のスパンにぶら下げる
+		repo := repo.WithContext(r.Context())
+
 		// ルーム存在チェック
-		room, err := models.GetRoomByCode(db, roomCode)
+		room, err := repo.Rooms().GetByCode(roomCode)
 		if err != nil {
-			log.Println("Room not found:", roomCode, err)
+			logger.Warn("room ws room not found", "err", err)
 			return
 		}
 		// 所属チェック（未所属なら弾く）
-		inRoom, err := models.IsUserInRoom(db, room.ID, userID)
+		inRoom, err := repo.RoomUsers().IsMember(room.ID, userID)
 		if err != nil || !inRoom {
-			log.Printf("user %d is not in room %s\n", userID, roomCode)
+			logger.Warn("room ws rejected: not a member", "err", err)
 			return
 		}
+		logger.Info("room ws connected")
 
 		// 受信制限 & 死活監視
-		conn.SetReadLimit(50 << 20) // 20MB
-		_ = conn.SetReadDeadline(time.Now().Add(180 * time.Second))
+		limiter := newWSMessageLimiter(conn, response.Language(r))
+		_ = conn.SetReadDeadline(time.Now().Add(cfg.Timers.WSReadTimeout))
 		conn.SetPongHandler(func(string) error {
-			return conn.SetReadDeadline(time.Now().Add(180 * time.Second))
+			return conn.SetReadDeadline(time.Now().Add(cfg.Timers.WSReadTimeout))
 		})
 
-		// Ping送信用ゴルーチン
+		// Ping送信用ゴルーチン（ハンドラはこれが終わってから返る）
 		stopPing := make(chan struct{})
+		pingDone := make(chan struct{})
 		go func() {
-			ticker := time.NewTicker(30 * time.Second)
+			defer close(pingDone)
+			ticker := time.NewTicker(cfg.Timers.WSPingInterval)
 			defer ticker.Stop()
 			for {
 				select {
@@ -108,92 +151,153 @@ func GameRoomWebSocketHandler(db *sql.DB) http.HandlerFunc {
 		}()
 
 		// 接続登録
+		rc := &roomConn{conn: conn, userID: userID}
 		roomConnMu.Lock()
 		if roomConnections[roomCode] == nil {
-			roomConnections[roomCode] = make(map[*websocket.Conn]int64)
+			roomConnections[roomCode] = make(map[*websocket.Conn]*roomConn)
 		}
-		roomConnections[roomCode][conn] = userID
+		roomConnections[roomCode][conn] = rc
 		roomConnMu.Unlock()
 
 		defer func() {
 			close(stopPing)
+			<-pingDone
 			// 切断 → Readyをfalseに（任意）
-			_ = models.UpdateUserReady(db, room.ID, userID, false)
+			_ = repo.RoomUsers().SetReady(room.ID, userID, false)
 
 			roomConnMu.Lock()
 			delete(roomConnections[roomCode], conn)
 			roomConnMu.Unlock()
+			clearSeatSwapRequests(roomCode, userID)
 
-			log.Printf("WebSocket disconnected: roomCode=%s, userID=%d\n", roomCode, userID)
+			logger.Info("room ws disconnected")
 			// 切断後に最新状態を通知
-			broadcastRoomStatus(roomCode, db)
+			broadcastRoomStatus(r.Context(), roomCode, repo)
 		}()
 
 		// 接続直後の同期
-		broadcastRoomStatus(roomCode, db)
+		broadcastRoomStatus(r.Context(), roomCode, repo)
 
 		for {
 			msgType, raw, err := conn.ReadMessage()
 			if err != nil {
-				log.Println("WebSocket Read Error:", err)
+				logger.Info("room ws closed", "err", err)
 				break
 			}
 			if msgType != websocket.TextMessage && msgType != websocket.BinaryMessage {
 				continue
 			}
-
-			var readyReq ReadyRequest
-			if err := json.Unmarshal(raw, &readyReq); err != nil {
-				log.Println("Invalid message format:", err)
+			if !limiter.allow(logger, func(v interface{}) error { return writeRoomJSON(rc, v) }) {
 				continue
 			}
-			// room_codeの偽装防止：URLのroomCode固定で進めたいならここで上書きする
-			if readyReq.RoomCode != roomCode {
-				readyReq.RoomCode = roomCode
-			}
 
-			room, err := models.GetRoomByCode(db, readyReq.RoomCode)
-			if err != nil {
-				log.Println("GetRoomByCode error:", err)
+			var cmd RoomCommand
+			if err := json.Unmarshal(raw, &cmd); err != nil {
+				logger.Warn("invalid room ws message", "err", err)
 				continue
 			}
+			// room_codeの偽装防止：URLのroomCode固定で進める
+			cmd.RoomCode = roomCode
 
-			if err := models.UpdateUserReady(db, room.ID, userID, readyReq.IsReady); err != nil {
-				log.Println("UpdateUserReady error:", err)
-				continue
-			}
+			handleRoomCommand(r, repo, logger, rc, cmd)
+		}
+	}
+}
+
+// handleRoomCommand はルームWSで受けた1メッセージを処理する（メッセージごとにスパンを切る）
+func handleRoomCommand(r *http.Request, repo store.Store, logger *slog.Logger, rc *roomConn, cmd RoomCommand) {
+	userID := rc.userID
+	msgType := cmd.Type
+	if msgType == "" {
+		msgType = "ready"
+	}
+	ctx, span := startMessageSpan(r, "WS room "+msgType, cmd.RoomCode)
+	defer span.End()
+	repo = repo.WithContext(ctx)
+
+	room, err := repo.Rooms().GetByCode(cmd.RoomCode)
+	if err != nil {
+		logger.Error("room lookup failed", "err", err)
+		span.RecordError(err)
+		return
+	}
 
-			broadcastRoomStatus(readyReq.RoomCode, db)
+	switch cmd.Type {
+	case "", "ready":
+		if err := repo.RoomUsers().SetReady(room.ID, userID, cmd.IsReady); err != nil {
+			logger.Error("ready update failed", "err", err)
+			span.RecordError(err)
+			return
+		}
+	case "seat_select":
+		if err := selectSeat(repo, room, userID, cmd.SeatNo); err != nil {
+			sendSeatError(logger, rc, response.Language(r), err)
+			return
 		}
+	case "seat_swap":
+		swapped, err := requestSeatSwap(ctx, repo, room, userID, cmd.TargetUserID)
+		if err != nil {
+			sendSeatError(logger, rc, response.Language(r), err)
+			return
+		}
+		if !swapped {
+			// 相手の承諾待ち：状態は変わらないのでブロードキャスト不要
+			return
+		}
+	default:
+		logger.Warn("unknown room ws message type", "type", cmd.Type)
+		return
 	}
+
+	broadcastRoomStatus(ctx, cmd.RoomCode, repo)
+}
+
+// startMessageSpan は WS で受けたメッセージのスパンを始める。
+// 接続（アップグレードした HTTP リクエスト）のスパンは切断まで終わらないので、
+// メッセージごとに別トレースにして接続のスパンへリンクを張る。
+func startMessageSpan(r *http.Request, name, roomCode string) (context.Context, trace.Span) {
+	return tracing.Start(r.Context(), name,
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(r.Context())),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("room", roomCode),
+			attribute.Int64("user_id", middleware.GetUserID(r))))
 }
 
-func broadcastRoomStatus(roomCode string, db *sql.DB) {
+// broadcastRoomStatus はルームの最新状態を全員へ送る（他インスタンスの接続にも）
+func broadcastRoomStatus(ctx context.Context, roomCode string, repo store.Store) {
+	ctx, span := tracing.Start(ctx, "broadcast room_status",
+		trace.WithSpanKind(trace.SpanKindProducer), trace.WithAttributes(attribute.String("room", roomCode)))
+	defer span.End()
+
+	localRoomStatus(ctx, roomCode, repo)
+	publishEvent(ctx, roomEvent{Kind: "room_status", Room: roomCode})
+}
+
+func localRoomStatus(ctx context.Context, roomCode string, repo store.Store) {
+	repo = repo.WithContext(ctx)
 	roomConnMu.Lock()
 	connMap := roomConnections[roomCode]
 	if len(connMap) == 0 {
 		roomConnMu.Unlock()
 		return
 	}
-	type item struct {
-		c   *websocket.Conn
-		uid int64
-	}
-	snapshot := make([]item, 0, len(connMap))
-	for c, uid := range connMap {
-		snapshot = append(snapshot, item{c: c, uid: uid})
+	snapshot := make([]*roomConn, 0, len(connMap))
+	for _, rc := range connMap {
+		snapshot = append(snapshot, rc)
 	}
 	roomConnMu.Unlock()
 
-	room, err := models.GetRoomByCode(db, roomCode)
+	room, err := repo.Rooms().GetByCode(roomCode)
 	if err != nil {
-		log.Println("GetRoomByCode failed:", err)
+		slog.Error("room status: room lookup failed", "room", roomCode, "err", err)
 		return
 	}
 
-	users, err := models.GetUsersInRoom(db, room.ID)
+	users, err := repo.RoomUsers().List(room.ID)
 	if err != nil {
-		log.Println("GetUsersInRoom failed:", err)
+		slog.Error("room status: room users lookup failed", "room", roomCode, "err", err)
 		return
 	}
 
@@ -207,6 +311,7 @@ func broadcastRoomStatus(roomCode string, db *sql.DB) {
 			Name:    u.UserName,
 			IsReady: u.IsReady,
 			IsHost:  u.IsHost,
+			SeatNo:  u.SeatNo,
 		})
 		if !u.IsReady {
 			allReady = false
@@ -224,22 +329,22 @@ func broadcastRoomStatus(roomCode string, db *sql.DB) {
 	}
 
 	var toDelete []*websocket.Conn
-	for _, it := range snapshot {
-		if err := it.c.WriteJSON(statusMsg); err != nil {
-			log.Println("Broadcast error:", err)
-			toDelete = append(toDelete, it.c)
+	for _, rc := range snapshot {
+		if err := writeRoomJSON(rc, statusMsg); err != nil {
+			slog.Info("room status send failed", "room", roomCode, "user_id", rc.userID, "err", err)
+			toDelete = append(toDelete, rc.conn)
 			continue
 		}
 		// 全員Readyならホストにだけall_readyを送る
-		if allReady && it.uid == hostUserID {
+		if allReady && rc.userID == hostUserID {
 			readyMsg := AllReadyResponse{
 				Type:     "all_ready",
 				RoomCode: roomCode,
 				AllReady: true,
 			}
-			if err := it.c.WriteJSON(readyMsg); err != nil {
-				log.Println("AllReady broadcast error:", err)
-				toDelete = append(toDelete, it.c)
+			if err := writeRoomJSON(rc, readyMsg); err != nil {
+				slog.Info("all_ready send failed", "room", roomCode, "user_id", rc.userID, "err", err)
+				toDelete = append(toDelete, rc.conn)
 			}
 		}
 	}
@@ -254,8 +359,13 @@ func broadcastRoomStatus(roomCode string, db *sql.DB) {
 	}
 }
 
-// 退出/キック時に、該当ユーザーのWSコネクションを閉じる
-func closeUserConnections(roomCode string, userID int64) {
+// 退出/キック時に、該当ユーザーのWSコネクションを閉じる（他インスタンスの接続も）
+func closeUserConnections(ctx context.Context, roomCode string, userID int64) {
+	localCloseUser(roomCode, userID)
+	publishEvent(ctx, roomEvent{Kind: "close_user", Room: roomCode, UserID: userID})
+}
+
+func localCloseUser(roomCode string, userID int64) {
 	roomConnMu.Lock()
 	defer roomConnMu.Unlock()
 
@@ -264,8 +374,8 @@ func closeUserConnections(roomCode string, userID int64) {
 		return
 	}
 
-	for c, uid := range connMap {
-		if uid == userID {
+	for c, rc := range connMap {
+		if rc.userID == userID {
 			delete(connMap, c)
 			_ = c.Close()
 		}
@@ -275,20 +385,104 @@ func closeUserConnections(roomCode string, userID int64) {
 		delete(roomConnections, roomCode)
 	}
 }
-func broadcastStartGame(roomCode string, gameID int64) {
+
+// 運営がルームを閉じたとき、ルームWS・ブラックジャックWSの全員に notice を送って切断する（他インスタンスの接続も）
+func closeRoomConnections(ctx context.Context, roomCode string, notice interface{}) {
+	raw, err := json.Marshal(notice)
+	if err != nil {
+		slog.ErrorContext(ctx, "room close notice encode failed", "room", roomCode, "err", err)
+		return
+	}
+	localCloseRoom(roomCode, raw)
+	publishEvent(ctx, roomEvent{Kind: "close_room", Room: roomCode, Data: raw})
+}
+
+func localCloseRoom(roomCode string, raw json.RawMessage) {
+	roomConnMu.Lock()
+	var roomConns []*roomConn
+	for _, rc := range roomConnections[roomCode] {
+		roomConns = append(roomConns, rc)
+	}
+	roomConnMu.Unlock()
+
+	bjMu.Lock()
+	var tableConns []*websocket.Conn
+	for c := range bjRoomConns[roomCode] {
+		tableConns = append(tableConns, c)
+	}
+	bjMu.Unlock()
+
+	seatSwapMu.Lock()
+	delete(seatSwapRequests, roomCode)
+	seatSwapMu.Unlock()
+
+	// 登録の解除は各ハンドラの切断処理に任せる
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "room closed")
+	for _, rc := range roomConns {
+		_ = writeRoomJSON(rc, raw)
+		_ = rc.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		_ = rc.conn.Close()
+	}
+	for _, c := range tableConns {
+		_ = writeJSONSafe(c, raw)
+		_ = c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		_ = c.Close()
+	}
+}
+
+// BAN したユーザーの WS 接続をすべてのルームから切断する（他インスタンスの接続も）
+func disconnectUser(ctx context.Context, userID int64) {
+	localDisconnectUser(userID)
+	publishEvent(ctx, roomEvent{Kind: "disconnect_user", UserID: userID})
+}
+
+func localDisconnectUser(userID int64) {
+	var conns []*websocket.Conn
+	roomConnMu.Lock()
+	for _, m := range roomConnections {
+		for c, rc := range m {
+			if rc.userID == userID {
+				conns = append(conns, c)
+			}
+		}
+	}
+	roomConnMu.Unlock()
+
+	bjMu.Lock()
+	for _, m := range bjRoomConns {
+		for c, uid := range m {
+			if uid == userID {
+				conns = append(conns, c)
+			}
+		}
+	}
+	bjMu.Unlock()
+
+	msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "account banned")
+	for _, c := range conns {
+		_ = c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		_ = c.Close()
+	}
+}
+func broadcastStartGame(ctx context.Context, roomCode string, gameID int64) {
+	ctx, span := tracing.Start(ctx, "broadcast start_game", trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("room", roomCode), attribute.Int64("game_id", gameID)))
+	defer span.End()
+
+	localStartGame(roomCode, gameID)
+	publishEvent(ctx, roomEvent{Kind: "start_game", Room: roomCode, GameID: gameID})
+}
+
+func localStartGame(roomCode string, gameID int64) {
 	roomConnMu.Lock()
 	connMap := roomConnections[roomCode]
 	if len(connMap) == 0 {
 		roomConnMu.Unlock()
 		return
 	}
-	type item struct {
-		c   *websocket.Conn
-		uid int64
-	}
-	snapshot := make([]item, 0, len(connMap))
-	for c, uid := range connMap {
-		snapshot = append(snapshot, item{c: c, uid: uid})
+	snapshot := make([]*roomConn, 0, len(connMap))
+	for _, rc := range connMap {
+		snapshot = append(snapshot, rc)
 	}
 	roomConnMu.Unlock()
 
@@ -299,15 +493,11 @@ func broadcastStartGame(roomCode string, gameID int64) {
 	}
 
 	var toDelete []*websocket.Conn
-	for _, it := range snapshot {
-		// （任意）送信詰まり防止の締切
-		_ = it.c.SetWriteDeadline(time.Now().Add(5 * time.Second))
-		if err := it.c.WriteJSON(msg); err != nil {
+	for _, rc := range snapshot {
+		if err := writeRoomJSON(rc, msg); err != nil {
 			// 送れない接続は掃除
-			toDelete = append(toDelete, it.c)
+			toDelete = append(toDelete, rc.conn)
 		}
-		// 締切は都度リセットしておくと無難
-		_ = it.c.SetWriteDeadline(time.Time{})
 	}
 
 	if len(toDelete) > 0 {
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"api/internal/middleware"
+	"api/internal/store"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+// ルームWSだけを載せたテスト用サーバー。
+// JWT の代わりに ?uid= の値をそのまま userID としてコンテキストに入れる。
+func newRoomWSServer(t *testing.T, repo store.Store) *httptest.Server {
+	t.Helper()
+	r := mux.NewRouter()
+	r.Handle("/api/ws/room/{room_code}", testUserMiddleware(GameRoomWebSocketHandler(repo)))
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testUserMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uid, _ := strconv.ParseInt(r.URL.Query().Get("uid"), 10, 64)
+		ctx := context.WithValue(r.Context(), middleware.UserIDKey, uid)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func dialRoomWS(t *testing.T, srv *httptest.Server, roomCode string, userID int64) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/ws/room/" + roomCode + "?uid=" + strconv.FormatInt(userID, 10)
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// type が一致し、match が true を返すメッセージが来るまで読み進める
+func readRoomMessage(t *testing.T, conn *websocket.Conn, typ string, match func(raw []byte) bool) []byte {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		_ = conn.SetReadDeadline(deadline)
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for %s: %v", typ, err)
+		}
+		var head struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(raw, &head) != nil || head.Type != typ {
+			continue
+		}
+		if match == nil || match(raw) {
+			return raw
+		}
+	}
+}
+
+// room_status のうち、cond を満たすものが来るまで待つ
+func waitRoomStatus(t *testing.T, conn *websocket.Conn, cond func(RoomStatusResponse) bool) RoomStatusResponse {
+	t.Helper()
+	var status RoomStatusResponse
+	readRoomMessage(t, conn, "room_status", func(raw []byte) bool {
+		status = RoomStatusResponse{}
+		return json.Unmarshal(raw, &status) == nil && cond(status)
+	})
+	return status
+}
+
+func seatOf(status RoomStatusResponse, userID int64) int {
+	for _, p := range status.Players {
+		if p.UserID == userID {
+			return p.SeatNo
+		}
+	}
+	return 0
+}
+
+func TestRoomWebSocketReadyFlow(t *testing.T) {
+	repo := store.NewMemory()
+	host := createTestUser(t, repo, "host")
+	guest := createTestUser(t, repo, "guest")
+	code := createTestRoom(t, repo, host, 4)
+	joinTestRoom(t, repo, guest, code)
+	srv := newRoomWSServer(t, repo)
+
+	hostConn := dialRoomWS(t, srv, code, host)
+	waitRoomStatus(t, hostConn, func(s RoomStatusResponse) bool { return len(s.Players) == 2 })
+	guestConn := dialRoomWS(t, srv, code, guest)
+	waitRoomStatus(t, guestConn, func(s RoomStatusResponse) bool { return len(s.Players) == 2 })
+
+	// 両者 Ready → ホストにだけ all_ready が届く
+	for _, c := range []*websocket.Conn{hostConn, guestConn} {
+		if err := c.WriteJSON(RoomCommand{Type: "ready", IsReady: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	readRoomMessage(t, hostConn, "all_ready", nil)
+	waitRoomStatus(t, guestConn, func(s RoomStatusResponse) bool {
+		for _, p := range s.Players {
+			if !p.IsReady {
+				return false
+			}
+		}
+		return true
+	})
+
+	// ゲスト切断で Ready が外れ、ホスト側に通知される
+	guestConn.Close()
+	waitRoomStatus(t, hostConn, func(s RoomStatusResponse) bool {
+		for _, p := range s.Players {
+			if p.UserID == guest {
+				return !p.IsReady
+			}
+		}
+		return false
+	})
+}
+
+func TestRoomWebSocketSeatCommands(t *testing.T) {
+	repo := store.NewMemory()
+	host := createTestUser(t, repo, "host")
+	guest := createTestUser(t, repo, "guest")
+	code := createTestRoom(t, repo, host, 4)
+	joinTestRoom(t, repo, guest, code)
+	srv := newRoomWSServer(t, repo)
+
+	hostConn := dialRoomWS(t, srv, code, host)
+	guestConn := dialRoomWS(t, srv, code, guest)
+	waitRoomStatus(t, hostConn, func(s RoomStatusResponse) bool { return len(s.Players) == 2 })
+
+	// 空席へ移動
+	if err := guestConn.WriteJSON(RoomCommand{Type: "seat_select", SeatNo: 3}); err != nil {
+		t.Fatal(err)
+	}
+	waitRoomStatus(t, hostConn, func(s RoomStatusResponse) bool { return seatOf(s, guest) == 3 })
+
+	// 埋まっている席は seat_error
+	if err := guestConn.WriteJSON(RoomCommand{Type: "seat_select", SeatNo: 1}); err != nil {
+		t.Fatal(err)
+	}
+	readRoomMessage(t, guestConn, "seat_error", nil)
+
+	// 入れ替え申請 → 相手に通知 → 相手も申請したら成立
+	if err := hostConn.WriteJSON(RoomCommand{Type: "seat_swap", TargetUserID: guest}); err != nil {
+		t.Fatal(err)
+	}
+	raw := readRoomMessage(t, guestConn, "seat_swap_request", nil)
+	var notice SeatSwapRequestNotice
+	if err := json.Unmarshal(raw, &notice); err != nil || notice.FromUserID != host || notice.FromSeatNo != 1 {
+		t.Fatalf("unexpected notice: %s", raw)
+	}
+	if err := guestConn.WriteJSON(RoomCommand{Type: "seat_swap", TargetUserID: host}); err != nil {
+		t.Fatal(err)
+	}
+	waitRoomStatus(t, hostConn, func(s RoomStatusResponse) bool {
+		return seatOf(s, host) == 3 && seatOf(s, guest) == 1
+	})
+}
+
+func TestRoomWebSocketRejectsNonMember(t *testing.T) {
+	repo := store.NewMemory()
+	host := createTestUser(t, repo, "host")
+	outsider := createTestUser(t, repo, "outsider")
+	code := createTestRoom(t, repo, host, 4)
+	srv := newRoomWSServer(t, repo)
+
+	conn := dialRoomWS(t, srv, code, outsider)
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("non-member connection should be closed")
+	}
+}
+
+// 書き込みの排他は接続ごと：1つの接続への送信が詰まっていても他の接続には送れる
+func TestRoomWebSocketWriteLockIsPerConnection(t *testing.T) {
+	repo := store.NewMemory()
+	host := createTestUser(t, repo, "host")
+	guest := createTestUser(t, repo, "guest")
+	code := createTestRoom(t, repo, host, 4)
+	joinTestRoom(t, repo, guest, code)
+	srv := newRoomWSServer(t, repo)
+
+	hostConn := dialRoomWS(t, srv, code, host)
+	guestConn := dialRoomWS(t, srv, code, guest)
+	waitRoomStatus(t, hostConn, func(s RoomStatusResponse) bool { return len(s.Players) == 2 })
+	waitRoomStatus(t, guestConn, func(s RoomStatusResponse) bool { return len(s.Players) == 2 })
+
+	var stuck *roomConn
+	roomConnMu.Lock()
+	for _, rc := range roomConnections[code] {
+		if rc.userID == host {
+			stuck = rc
+		}
+	}
+	roomConnMu.Unlock()
+	if stuck == nil {
+		t.Fatal("host connection not registered")
+	}
+
+	// ホストへの送信が書き込み中のまま止まっている状態
+	stuck.writeMu.Lock()
+	defer stuck.writeMu.Unlock()
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		localNotifyUser(code, guest, map[string]string{"type": "ping_test"})
+	}()
+	select {
+	case <-sent:
+	case <-time.After(2 * time.Second):
+		t.Fatal("send to guest blocked by the host's write lock")
+	}
+	readRoomMessage(t, guestConn, "ping_test", nil)
+}
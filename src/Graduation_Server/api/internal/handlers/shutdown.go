@@ -0,0 +1,200 @@
+// サーバー停止（デプロイ）時の後片付け
+//
+// main から次の順で呼ぶ：
+//
+//	BeginShutdown → WaitForTables → RefundOpenBets → CloseAllConnections → http.Server.Shutdown
+
+package handlers
+
+import (
+	"api/internal/response"
+	"api/internal/store"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 停止処理中かどうか（新しいルームの作成・参加・開始と、賭け金の追加・確定を断る）
+var shuttingDown atomic.Bool
+
+// 停止処理中のお知らせ（ルームWS・ブラックジャックWSの全接続へ送る）
+type ShutdownNotice struct {
+	Type     string `json:"type"` // "server_shutdown"
+	Message  string `json:"message"`
+	Deadline string `json:"deadline"` // この時刻までに進行中の卓を終える（RFC3339）
+}
+
+// IsShuttingDown は停止処理中なら true
+func IsShuttingDown() bool {
+	return shuttingDown.Load()
+}
+
+// rejectDuringShutdown は停止処理中なら 503 を返して true を返す
+func rejectDuringShutdown(w http.ResponseWriter, r *http.Request) bool {
+	if !IsShuttingDown() {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(60))
+	response.Fail(w, r, http.StatusServiceUnavailable, response.CodeShuttingDown)
+	return true
+}
+
+// BeginShutdown は新しいルームの受け付けを止め、接続中の全クライアントに再起動を予告する
+func BeginShutdown(deadline time.Time) {
+	shuttingDown.Store(true)
+
+	notice := ShutdownNotice{
+		Type:     "server_shutdown",
+		Message:  "メンテナンスのためサーバーを再起動します",
+		Deadline: deadline.Format(time.RFC3339),
+	}
+	roomConns, bjConns := allConnections()
+	for _, c := range roomConns {
+		if err := writeRoomJSON(c, notice); err != nil {
+			slog.Debug("shutdown notice send failed", "err", err)
+		}
+	}
+	for _, c := range bjConns {
+		if err := writeJSONSafe(c, notice); err != nil {
+			slog.Debug("shutdown notice send failed", "err", err)
+		}
+	}
+	slog.Info("shutdown: notified connections", "room_conns", len(roomConns), "blackjack_conns", len(bjConns))
+}
+
+// WaitForTables は進行中のラウンドが全部精算されるまで待つ。
+// 停止処理中は新しいベットを受け付けないので、精算が済めば卓は空く。
+// ctx が先に終わった場合は ctx.Err() を返す（残りは RefundOpenBets で返す）。
+func WaitForTables(ctx context.Context) error {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		n := busyTables()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			slog.Info("shutdown: waiting for tables with open bets", "tables", n)
+			return ctx.Err()
+		}
+	}
+}
+
+// ラウンドが進行中のルームの数。
+// 誰かがベットを確定していて、精算を送るディーラーがつながっている卓だけを数える
+// （確定前のベットやディーラーのいない卓は、待っても精算されないので RefundOpenBets で返す）。
+func busyTables() int {
+	bjMu.Lock()
+	defer bjMu.Unlock()
+	n := 0
+	for code, st := range bjRoomStates {
+		if len(roundBettors(st)) > 0 && dealerConnected(code, st) {
+			n++
+		}
+	}
+	return n
+}
+
+func dealerConnected(roomCode string, st *BJRoomState) bool {
+	for _, uid := range bjRoomConns[roomCode] {
+		if uid == st.DealerID {
+			return true
+		}
+	}
+	return false
+}
+
+// RefundOpenBets は卓に残っているベットを手持ちチップに戻し、各卓に bet_state を送る。
+// 戻した卓はその場で保存し（プロセスが終わっても返したチップが残るように）、
+// 戻したルーム数とチップの合計、保存に失敗した卓のエラーを返す。
+func RefundOpenBets(repo store.Store) (rooms, chips int, err error) {
+	bjMu.Lock()
+	var codes []string
+	for code, st := range bjRoomStates {
+		refunded := false
+		for _, p := range st.Players {
+			if p.Bet <= 0 {
+				continue
+			}
+			slog.Info("shutdown: refund", "room", code, "user_id", p.UserID, "bet", p.Bet)
+			chips += p.Bet
+			recordChips("refund", p.Bet)
+			p.TotalChips += p.Bet
+			p.Bet = 0
+			p.Confirmed = false
+			refunded = true
+		}
+		if refunded {
+			codes = append(codes, code)
+		}
+	}
+	bjMu.Unlock()
+
+	var errs []error
+	for _, code := range codes {
+		broadcastBetState(context.Background(), code)
+		if err := saveBJState(repo, code); err != nil {
+			errs = append(errs, fmt.Errorf("room %s: %w", code, err))
+		}
+	}
+	return len(codes), chips, errors.Join(errs...)
+}
+
+// CloseAllConnections は全WSを 1012 (Service Restart) で閉じ、
+// 各ハンドラの切断処理が終わるまで（最大 ctx まで）待つ
+func CloseAllConnections(ctx context.Context, reason string) {
+	roomConns, bjConns := allConnections()
+	msg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, reason)
+	conns := bjConns
+	for _, rc := range roomConns {
+		conns = append(conns, rc.conn)
+	}
+	for _, c := range conns {
+		_ = c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		_ = c.Close()
+	}
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		room, bj := allConnections()
+		if len(room)+len(bj) == 0 {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			slog.Warn("shutdown: connections did not finish closing", "conns", len(room)+len(bj))
+			return
+		}
+	}
+}
+
+// 登録中のルームWS・ブラックジャックWSの接続一覧
+func allConnections() (room []*roomConn, bj []*websocket.Conn) {
+	roomConnMu.Lock()
+	for _, m := range roomConnections {
+		for _, rc := range m {
+			room = append(room, rc)
+		}
+	}
+	roomConnMu.Unlock()
+
+	bjMu.Lock()
+	for _, m := range bjRoomConns {
+		for c := range m {
+			bj = append(bj, c)
+		}
+	}
+	bjMu.Unlock()
+	return room, bj
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"api/internal/models"
+	"api/internal/store"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+func dialBlackjackWS(t *testing.T, srv *httptest.Server, roomCode string, userID int64) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/ws/blackjackwebsocket/" + roomCode + "?uid=" + strconv.FormatInt(userID, 10)
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func betOf(raw []byte, userID int64) (bet, chips int, ok bool) {
+	var s BetStateBroadcast
+	if json.Unmarshal(raw, &s) != nil {
+		return 0, 0, false
+	}
+	for _, p := range s.Players {
+		if p.UserID == userID {
+			return p.Bet, p.TotalChips, true
+		}
+	}
+	return 0, 0, false
+}
+
+// 閉じられるまで読み進め、close code を確認する
+func expectClosedWith(t *testing.T, conn *websocket.Conn, code int) {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, code) {
+				t.Fatalf("closed with %v, want close code %d", err, code)
+			}
+			return
+		}
+	}
+}
+
+func TestGracefulShutdownSequence(t *testing.T) {
+	repo := store.NewMemory()
+	host := createTestUser(t, repo, "host")
+	guest := createTestUser(t, repo, "guest")
+	late := createTestUser(t, repo, "late")
+	code := createTestRoom(t, repo, host, 4)
+	joinTestRoom(t, repo, guest, code)
+	joinTestRoom(t, repo, late, code)
+	// ゲームを始めておく（ディーラーは席1のホスト）
+	startTable(repo, code, 1, models.ModeMulti)
+
+	r := mux.NewRouter()
+	r.Handle("/api/ws/room/{room_code}", testUserMiddleware(GameRoomWebSocketHandler(repo)))
+	r.Handle("/api/ws/blackjackwebsocket/{room_code}", testUserMiddleware(BlackjackWebSocketHandle(repo)))
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { shuttingDown.Store(false) })
+
+	roomConn := dialRoomWS(t, srv, code, host)
+	waitRoomStatus(t, roomConn, func(s RoomStatusResponse) bool { return len(s.Players) == 3 })
+	bjHost := dialBlackjackWS(t, srv, code, host)
+	readRoomMessage(t, bjHost, "player_order", nil)
+	bjGuest := dialBlackjackWS(t, srv, code, guest)
+	readRoomMessage(t, bjGuest, "player_order", nil)
+	bjLate := dialBlackjackWS(t, srv, code, late)
+	readRoomMessage(t, bjLate, "player_order", nil)
+
+	// ゲストは 100 を確定して勝負中、late は 50 を置いただけ（未確定）の状態で停止処理に入る
+	bet := func(conn *websocket.Conn, userID int64, amount int, confirm bool) {
+		t.Helper()
+		if err := conn.WriteJSON(BetCommand{Type: "bet_update", Bet: amount, Confirm: confirm}); err != nil {
+			t.Fatal(err)
+		}
+		readRoomMessage(t, bjHost, "bet_state", func(raw []byte) bool {
+			b, _, ok := betOf(raw, userID)
+			return ok && b == amount
+		})
+	}
+	bet(bjGuest, guest, 100, true)
+	bet(bjLate, late, 50, false)
+
+	BeginShutdown(time.Now().Add(time.Minute))
+	for _, c := range []*websocket.Conn{roomConn, bjHost, bjGuest, bjLate} {
+		readRoomMessage(t, c, "server_shutdown", nil)
+	}
+
+	// 新しいルームの作成・参加は 503
+	if rec := callAsUser(CreateRoomHandler(repo), host, CreateRoomRequest{GameTypeID: 1}); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("create room during shutdown: status = %d, want 503", rec.Code)
+	}
+	if rec := callAsUser(JoinRoomHandler(repo), guest, JoinRoomRequest{RoomCode: code}); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("join room during shutdown: status = %d, want 503", rec.Code)
+	}
+
+	// 停止処理中は賭け金を増やせない
+	if err := bjLate.WriteJSON(BetCommand{Type: "bet_update", Bet: 80, Confirm: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 確定したベットの勝負が終わっていないので待たされる
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := WaitForTables(ctx); err == nil {
+		t.Fatal("WaitForTables returned before the round was settled")
+	}
+	bjMu.Lock()
+	lateBet, lateConfirmed := bjRoomStates[code].Players[late].Bet, bjRoomStates[code].Players[late].Confirmed
+	bjMu.Unlock()
+	if lateBet != 50 || lateConfirmed {
+		t.Fatalf("bet during shutdown was accepted: bet=%d confirmed=%v", lateBet, lateConfirmed)
+	}
+
+	// 精算されれば期限を待たずに終わる（確定前のベットは待たない）
+	if err := bjHost.WriteJSON(RoundResultCommand{Type: "round_result", Results: []PlayerRoundResult{{UserID: guest, Result: "lose"}}}); err != nil {
+		t.Fatal(err)
+	}
+	readRoomMessage(t, bjGuest, "round_settled", nil)
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer waitCancel()
+	if err := WaitForTables(waitCtx); err != nil {
+		t.Fatalf("WaitForTables after the round was settled: %v", err)
+	}
+
+	// 残ったベットは手持ちに戻って卓に通知され、保存される
+	rooms, chips, err := RefundOpenBets(repo)
+	if rooms != 1 || chips != 50 || err != nil {
+		t.Fatalf("refund = (%d rooms, %d chips, %v), want (1, 50, nil)", rooms, chips, err)
+	}
+	readRoomMessage(t, bjLate, "bet_state", func(raw []byte) bool {
+		bet, total, ok := betOf(raw, late)
+		return ok && bet == 0 && total == cfg.Chips.TableStart
+	})
+	snap, err := repo.Snapshots().Get(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved bjSnapshot
+	if err := json.Unmarshal(snap.State, &saved); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range saved.Players {
+		if p.UserID == late && (p.Bet != 0 || p.TotalChips != cfg.Chips.TableStart) {
+			t.Fatalf("saved after refund: %+v", p)
+		}
+	}
+
+	// 全WSが 1012 で閉じられる
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer closeCancel()
+	CloseAllConnections(closeCtx, "server restarting")
+	for _, c := range []*websocket.Conn{roomConn, bjHost, bjGuest, bjLate} {
+		expectClosedWith(t, c, websocket.CloseServiceRestart)
+	}
+	if room, bj := allConnections(); len(room)+len(bj) != 0 {
+		t.Fatalf("connections still registered: room=%d bj=%d", len(room), len(bj))
+	}
+}
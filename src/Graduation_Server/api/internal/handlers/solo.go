@@ -6,11 +6,24 @@
 package handlers
 
 import (
-	"encoding/json"
-	"log"
+	"api/internal/response"
+	"log/slog"
 	"net/http"
 )
 
+// ソロ・フレンド・マルチのゲーム一覧の応答
+type GameListResponse struct {
+	Games []GameListItem `json:"games"`
+}
+
+// types の1行（type は game_types.code）
+type GameListItem struct {
+	ID   int    `json:"id"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+	Rule string `json:"rule"`
+}
+
 func SoloGameListHandler(w http.ResponseWriter, r *http.Request) {
 	// ---- SQLクエリ ----
 	// ソロモード (modes.mode = 'ソロ') かつ is_can_play=TRUE の type を取得
@@ -23,48 +36,39 @@ func SoloGameListHandler(w http.ResponseWriter, r *http.Request) {
 	`
 
 	// ---- DB問い合わせ ----
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(r.Context(), query)
 	if err != nil {
-		log.Printf("[ERROR] solo select failed: %v", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "solo games select failed", "err", err)
+		response.Internal(w, r)
 		return
 	}
 	defer rows.Close()
 
 	// ---- 結果のスキャン ----
-	var games []map[string]interface{}
+	var games []GameListItem
 	for rows.Next() {
 		var id int
 		var typeCode, displayName, rule string
 
 		// 1行分のデータを変数に取り込み
 		if err := rows.Scan(&id, &typeCode, &displayName, &rule); err != nil {
-			log.Printf("[ERROR] solo scan failed: %v", err)
-			http.Error(w, "Scan error", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "solo games scan failed", "err", err)
+			response.Internal(w, r)
 			return
 		}
 
-		// map にして append
-		games = append(games, map[string]interface{}{
-			"id":   id,
-			"type": typeCode,
-			"name": displayName,
-			"rule": rule,
-		})
+		// 1行ずつ append
+		games = append(games, GameListItem{ID: id, Type: typeCode, Name: displayName, Rule: rule})
 	}
 
 	// ---- イテレーション中のエラー確認 ----
 	if err := rows.Err(); err != nil {
-		log.Printf("[ERROR] rows iteration failed: %v", err)
-		http.Error(w, "Data fetch error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "solo games rows iteration failed", "err", err)
+		response.Internal(w, r)
 		return
 	}
 
 	// ---- レスポンス作成 ----
-	resp := map[string]interface{}{
-		"result": "OK",
-		"games":  games,
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	resp := GameListResponse{Games: games}
+	response.OK(w, r, resp)
 }
@@ -0,0 +1,141 @@
+//戦績API（round_results の集計）
+
+package handlers
+
+import (
+	"api/internal/middleware"
+	"api/internal/models"
+	"api/internal/response"
+	"api/internal/store"
+	"log/slog"
+	"math"
+	"net/http"
+)
+
+// PlayerStats は戦績の集計（ディーラーとして参加したラウンドも含む）
+type PlayerStats struct {
+	Hands            int     `json:"hands"`
+	HandsAsDealer    int     `json:"hands_as_dealer"`
+	Wins             int     `json:"wins"`
+	Losses           int     `json:"losses"`
+	Pushes           int     `json:"pushes"`
+	WinRate          float64 `json:"win_rate"` // 0〜1（小数第3位まで）
+	LossRate         float64 `json:"loss_rate"`
+	PushRate         float64 `json:"push_rate"`
+	Blackjacks       int     `json:"blackjacks"`
+	Busts            int     `json:"busts"`
+	NetChips         int     `json:"net_chips"`          // 収支の合計
+	BiggestWin       int     `json:"biggest_win"`        // 1ラウンドの最大の収支
+	BiggestPot       int     `json:"biggest_pot"`        // 1ラウンドで戻ってきた最大のチップ
+	LongestWinStreak int     `json:"longest_win_streak"` // 引き分けでも途切れる
+}
+
+type ModeStats struct {
+	ModeID int    `json:"mode_id"`
+	Mode   string `json:"mode"`
+	PlayerStats
+}
+
+type PlayerStatsResponse struct {
+	Lifetime PlayerStats `json:"lifetime"`
+	Modes    []ModeStats `json:"modes"` // 遊んだモードだけ（mode_id 順）
+}
+
+// PlayerStatsHandler は自分の戦績を通算とモード別で返す
+func PlayerStatsHandler(repo store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
+		userID := middleware.GetUserID(r)
+		if userID == 0 {
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
+			return
+		}
+		totals, err := repo.Rounds().Totals(userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "sum round results failed", "user_id", userID, "err", err)
+			response.Internal(w, r)
+			return
+		}
+		outcomes, err := repo.Rounds().Outcomes(userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "list round outcomes failed", "user_id", userID, "err", err)
+			response.Internal(w, r)
+			return
+		}
+		modes, err := repo.Games().Modes()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "list modes failed", "err", err)
+			response.Internal(w, r)
+			return
+		}
+		response.OK(w, r, summarizeRounds(totals, outcomes, modes))
+	}
+}
+
+// summarizeRounds は SQL で集計したモード別の値をまとめ、連勝数だけは古い順の勝敗から数える
+func summarizeRounds(totals []models.RoundTotals, outcomes []models.RoundOutcome, modes []models.Mode) PlayerStatsResponse {
+	var lifetime statsCounter
+	byMode := make(map[int]*statsCounter, len(totals))
+	for _, t := range totals {
+		lifetime.addTotals(t)
+		c := &statsCounter{}
+		c.addTotals(t)
+		byMode[t.ModeID] = c
+	}
+	for _, o := range outcomes {
+		lifetime.addOutcome(o.Result)
+		if c, ok := byMode[o.ModeID]; ok {
+			c.addOutcome(o.Result)
+		}
+	}
+
+	out := PlayerStatsResponse{Lifetime: lifetime.stats(), Modes: []ModeStats{}}
+	for _, m := range modes {
+		if c, ok := byMode[m.ID]; ok {
+			out.Modes = append(out.Modes, ModeStats{ModeID: m.ID, Mode: m.Mode, PlayerStats: c.stats()})
+		}
+	}
+	return out
+}
+
+type statsCounter struct {
+	PlayerStats
+	streak int
+}
+
+func (c *statsCounter) addTotals(t models.RoundTotals) {
+	c.Hands += t.Hands
+	c.HandsAsDealer += t.HandsAsDealer
+	c.Wins += t.Wins
+	c.Losses += t.Losses
+	c.Pushes += t.Pushes
+	c.Blackjacks += t.Blackjacks
+	c.Busts += t.Busts
+	c.NetChips += t.Net
+	c.BiggestWin = max(c.BiggestWin, t.MaxNet)
+	c.BiggestPot = max(c.BiggestPot, t.MaxPayout)
+}
+
+// addOutcome は連勝数だけを数える（引き分けでも途切れる）
+func (c *statsCounter) addOutcome(result string) {
+	if result != models.RoundWin {
+		c.streak = 0
+		return
+	}
+	c.streak++
+	c.LongestWinStreak = max(c.LongestWinStreak, c.streak)
+}
+
+func (c *statsCounter) stats() PlayerStats {
+	s := c.PlayerStats
+	if s.Hands > 0 {
+		s.WinRate = rate(s.Wins, s.Hands)
+		s.LossRate = rate(s.Losses, s.Hands)
+		s.PushRate = rate(s.Pushes, s.Hands)
+	}
+	return s
+}
+
+func rate(n, total int) float64 {
+	return math.Round(float64(n)/float64(total)*1000) / 1000
+}
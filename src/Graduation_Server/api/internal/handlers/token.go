@@ -0,0 +1,118 @@
+// トークン発行まわり（アクセストークン＋リフレッシュトークン）
+
+package handlers
+
+import (
+	"api/internal/auth"
+	"api/internal/store"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// errAccountBanned は BAN 中のユーザーにトークンを発行しようとした場合のエラー
+var errAccountBanned = errors.New("account banned")
+
+// ログイン・アカウント作成・リフレッシュで返すトークン一式
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // アクセストークンの残り秒数
+}
+
+// トークンを返す API（アカウント作成・ログイン・リフレッシュ・パスワード再設定）の応答
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // アクセストークンの残り秒数
+}
+
+func (p *TokenPair) response() TokenResponse {
+	return TokenResponse{Token: p.AccessToken, RefreshToken: p.RefreshToken, ExpiresIn: p.ExpiresIn}
+}
+
+// 新しいログインセッション（family）としてトークン一式を発行
+func issueTokenPair(ctx context.Context, userID int64) (*TokenPair, error) {
+	familyID, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	return issueTokenPairInFamily(ctx, userID, familyID)
+}
+
+func issueTokenPairInFamily(ctx context.Context, userID int64, familyID string) (*TokenPair, error) {
+	refresh, refreshHash, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	// 先にアクセストークンを作る（BAN 中ならリフレッシュトークンを保存しない）
+	pair, err := signAccessToken(ctx, userID, refresh)
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.WithContext(ctx).Tokens().CreateRefresh(userID, refreshHash, familyID, time.Now().Add(cfg.JWT.RefreshTokenTTL)); err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// 発行済みのリフレッシュトークンと組にするアクセストークンを作る（BAN 中なら errAccountBanned）
+func signAccessToken(ctx context.Context, userID int64, refresh string) (*TokenPair, error) {
+	subject, err := repo.WithContext(ctx).Tokens().UserInfo(userID)
+	if err != nil {
+		return nil, err
+	}
+	if subject.Banned {
+		return nil, errAccountBanned
+	}
+	access, _, expiresAt, err := auth.Default().Issue(userID, subject.Name, subject.Version, subject.Role)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(time.Until(expiresAt).Round(time.Second) / time.Second),
+	}, nil
+}
+
+// 平文のリフレッシュトークンと、DB保存用のハッシュを作る
+func newRefreshToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// 期限切れのリフレッシュトークン・失効リストを定期的に掃除する
+func StartTokenCleanup(tokens store.TokenRepository, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := tokens.PurgeExpired(); err != nil {
+				slog.Error("token cleanup failed", "err", err)
+			}
+		}
+	}()
+}
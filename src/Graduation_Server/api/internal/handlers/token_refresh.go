@@ -0,0 +1,88 @@
+// トークン再発行API（リフレッシュトークンのローテーション）
+
+package handlers
+
+import (
+	"api/internal/response"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// refresh_token: ログイン時などに受け取ったリフレッシュトークン
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RefreshTokenHandler はリフレッシュトークンを新しいトークン一式と交換する。
+// 使ったリフレッシュトークンは失効し、以後は新しいものだけが有効になる。
+// 失効済みのトークンが再び使われた場合は盗用とみなし、同じ family をすべて失効させる。
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	repo := repo.WithContext(r.Context())
+	var req RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadJSON(w, r)
+		return
+	}
+	if req.RefreshToken == "" {
+		response.Invalid(w, r, response.Field("refresh_token", response.FieldRequired))
+		return
+	}
+
+	old, err := repo.Tokens().GetRefresh(hashToken(req.RefreshToken))
+	if err == sql.ErrNoRows {
+		response.Fail(w, r, http.StatusUnauthorized, response.CodeTokenInvalid)
+		return
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "refresh token lookup failed", "err", err)
+		response.Internal(w, r)
+		return
+	}
+
+	// 再利用検知：既に入れ替え済み（または失効済み）のトークン
+	if old.RevokedAt.Valid {
+		slog.WarnContext(r.Context(), "refresh token reuse detected", "user_id", old.UserID, "family_id", old.FamilyID)
+		if err := repo.Tokens().RevokeFamily(old.FamilyID); err != nil {
+			slog.ErrorContext(r.Context(), "revoke family failed", "user_id", old.UserID, "err", err)
+		}
+		response.Fail(w, r, http.StatusUnauthorized, response.CodeTokenInvalid)
+		return
+	}
+	if time.Now().After(old.ExpiresAt) {
+		response.Fail(w, r, http.StatusUnauthorized, response.CodeTokenInvalid)
+		return
+	}
+
+	refresh, refreshHash, err := newRefreshToken()
+	if err != nil {
+		response.Internal(w, r)
+		return
+	}
+	rotated, err := repo.Tokens().RotateRefresh(old, refreshHash, time.Now().Add(cfg.JWT.RefreshTokenTTL))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "refresh token rotation failed", "user_id", old.UserID, "err", err)
+		response.Internal(w, r)
+		return
+	}
+	if !rotated {
+		// 同じトークンで同時にリフレッシュされた（先に処理された方だけ有効）
+		response.Fail(w, r, http.StatusUnauthorized, response.CodeTokenInvalid)
+		return
+	}
+
+	pair, err := signAccessToken(r.Context(), old.UserID, refresh)
+	if errors.Is(err, errAccountBanned) {
+		response.Fail(w, r, http.StatusForbidden, response.CodeAccountBanned)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "token issue failed", "user_id", old.UserID, "err", err)
+		response.Internal(w, r)
+		return
+	}
+
+	response.OK(w, r, pair.response())
+}
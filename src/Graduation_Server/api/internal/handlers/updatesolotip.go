@@ -2,50 +2,240 @@ package handlers
 
 import (
 	"api/internal/middleware"
-	"database/sql"
+	"api/internal/models"
+	"api/internal/response"
+	"api/internal/store"
 	"encoding/json"
+	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 )
 
 // UpdateTipRequest はチップ増減リクエストの構造体
 // chip_diff: 増減させたいチップの差分値（+なら加算, -なら減算）
+// round: 1ラウンド分の結果（送れば戦績と経過に残る。chip_diff がそのラウンドの収支になる）
 type UpdateTipRequest struct {
-	NewChips int `json:"chip_diff"`
+	NewChips int              `json:"chip_diff"`
+	Round    *SoloRoundResult `json:"round,omitempty"`
+}
+
+type SoloRoundResult struct {
+	Bet       int    `json:"bet"`
+	Result    string `json:"result"` // "win" / "lose" / "push"
+	Blackjack bool   `json:"blackjack"`
+	Bust      bool   `json:"bust"`
+	// 経過（リプレイ用。どれも省略できる）
+	SeedCommitment string            `json:"seed_commitment,omitempty"` // 配る前に決めたシードの SHA-256（16進 64 文字）
+	Seed           string            `json:"seed,omitempty"`            // seed_commitment を送るなら必須
+	Actions        []SoloRoundAction `json:"actions,omitempty"`         // 配った札と操作（起きた順）
+}
+
+// ソロの操作。deal の to は 0 ならディーラー（親）、自分の user_id ならプレイヤー
+type SoloRoundAction struct {
+	models.RoundActionEvent
+	At time.Time `json:"at"` // 操作した時刻（端末の時計。省略時は受け取った時刻）
+}
+
+// v1 の応答（旧ルートは文字列だけを返す）
+type UpdateTipResponse struct {
+	ChipDiff int   `json:"chip_diff"`
+	RoundID  int64 `json:"round_id,omitempty"` // round を送ったときの経過の ID（/history/rounds/{round_id}）
 }
 
 // UpdateSoloTipHandler はソロ用チップ数を更新するハンドラ。
 // JWT 認証が必須で、リクエストで受け取った chip_diff を tips テーブルに反映する。
-func UpdateSoloTipHandler(db *sql.DB) http.Handler {
+func UpdateSoloTipHandler(repo store.Store) http.Handler {
 	return middleware.JWTMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		repo := repo.WithContext(r.Context())
 		// ---- ユーザーIDをJWTから取得 ----
 		userIDFloat, ok := r.Context().Value(middleware.UserIDKey).(int64)
 		if !ok {
-			http.Error(w, "ユーザーIDが無効です", http.StatusUnauthorized)
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
 			return
 		}
-		userID := int(userIDFloat)
+		userID := userIDFloat
 
 		// ---- リクエストデコード ----
 		// 例: {"chip_diff": -100}
 		var req UpdateTipRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "無効なリクエスト形式", http.StatusBadRequest)
+			response.BadJSON(w, r)
 			return
 		}
+		if req.Round != nil {
+			if fields := checkSoloRound(*req.Round, userID, req.NewChips); len(fields) > 0 {
+				response.Invalid(w, r, fields...)
+				return
+			}
+		}
 
 		// ---- DB更新処理 ----
 		// solo_tip_count を差分更新する
-		_, err := db.Exec(
-			"UPDATE tips SET solo_tip_count = solo_tip_count + ? WHERE user_id = ?",
-			req.NewChips, userID,
-		)
+		err := repo.Tips().AddSolo(userID, req.NewChips)
 		if err != nil {
-			http.Error(w, "チップ更新に失敗しました", http.StatusInternalServerError)
+			response.Internal(w, r)
 			return
 		}
 
+		if req.NewChips > 0 {
+			recordChips("solo_gain", req.NewChips)
+		} else {
+			recordChips("solo_loss", -req.NewChips)
+		}
+
+		// ---- ラウンドの記録 ----
+		// チップは更新済みなので、保存に失敗しても戦績と経過が欠けるだけにする
+		resp := UpdateTipResponse{ChipDiff: req.NewChips}
+		if rd := req.Round; rd != nil {
+			chips := 0
+			if tip, err := repo.Tips().Get(userID); err != nil {
+				slog.Warn("solo chips lookup failed", "user_id", userID, "err", err)
+			} else {
+				chips = tip.SoloTipCount
+			}
+			now := time.Now()
+			result := models.RoundResult{
+				UserID: userID, ModeID: models.ModeSolo, Role: models.RoundRolePlayer,
+				Result: rd.Result, Bet: rd.Bet, Payout: rd.Bet + req.NewChips, Net: req.NewChips,
+				Blackjack: rd.Blackjack, Bust: rd.Bust, CreatedAt: now,
+			}
+			id, err := repo.Rounds().Record(soloRoundLog(userID, *rd, result, chips, now), []models.RoundResult{result})
+			if err != nil {
+				slog.Error("solo round save failed", "user_id", userID, "err", err)
+			}
+			resp.RoundID = id
+		}
+
 		// ---- 成功レスポンス ----
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("チップを更新しました"))
+		// 旧ルートは文字列だけを返していた
+		response.Text(w, r, "チップを更新しました", resp)
 	}))
 }
+
+// checkSoloRound はソロのラウンド結果と収支 chipDiff が食い違っていないか、経過が正しいかを見る
+//
+// ソロの勝負は端末の中だけで行うので、サーバーは勝ち負けそのものを確かめられない。
+// 信用するのは端末が送った結果（勝ち・負け・引き分け、ブラックジャック）までで、
+// chip_diff はベットとその結果で動きうる範囲に収める：
+//   - ブラックジャックの勝ち：ベットの 1.5 倍ちょうど（端数切り捨て。ルームの払い戻しと同じ）
+//   - それ以外の勝ち・負け：賭けた額まで。賭けた額はベット ×（手の数 + ダブルした数）で、
+//     スプリット・ダブルは actions で送った分だけ数える（送らなければベットまで）
+//
+// round を付けない chip_diff だけの更新は旧クライアントとの互換のためにそのまま受け付ける（戦績には残らない）。
+func checkSoloRound(rd SoloRoundResult, userID int64, chipDiff int) []response.FieldError {
+	var fields []response.FieldError
+	if rd.Bet <= 0 {
+		fields = append(fields, response.Field("round.bet", response.FieldOutOfRange))
+	}
+	if !validOutcome(rd.Result, rd.Blackjack, rd.Bust) {
+		fields = append(fields, response.Field("round.result", response.FieldInvalid))
+	} else if rd.Bet > 0 {
+		// 勝ちなら賭けた額まで増え、負けなら賭けた額まで減り、引き分けなら変わらない
+		staked := soloStake(rd)
+		switch rd.Result {
+		case models.RoundWin:
+			if rd.Blackjack && chipDiff != roundPayout(rd.Bet, rd.Result, true)-rd.Bet ||
+				!rd.Blackjack && (chipDiff <= 0 || chipDiff > staked) {
+				fields = append(fields, response.Field("chip_diff", response.FieldOutOfRange))
+			}
+		case models.RoundLose:
+			if chipDiff >= 0 || staked+chipDiff < 0 {
+				fields = append(fields, response.Field("chip_diff", response.FieldOutOfRange))
+			}
+		case models.RoundPush:
+			if chipDiff != 0 {
+				fields = append(fields, response.Field("chip_diff", response.FieldOutOfRange))
+			}
+		}
+	}
+
+	if rd.SeedCommitment != "" && !models.IsSeedCommitment(rd.SeedCommitment) {
+		fields = append(fields, response.Field("round.seed_commitment", response.FieldInvalid))
+	}
+	switch {
+	case len(rd.Seed) > models.MaxSeedLength:
+		fields = append(fields, response.Field("round.seed", response.FieldTooLong))
+	case rd.Seed == "" && rd.SeedCommitment != "":
+		fields = append(fields, response.Field("round.seed", response.FieldRequired))
+	case rd.Seed != "" && models.IsSeedCommitment(rd.SeedCommitment) && !models.SeedMatches(rd.Seed, rd.SeedCommitment):
+		fields = append(fields, response.Field("round.seed", response.FieldInvalid))
+	}
+	// start・bet・commit・settle の分を空けておく
+	if len(rd.Actions) > models.MaxRoundEvents-4 {
+		fields = append(fields, response.Field("round.actions", response.FieldTooLong))
+	} else {
+		for _, a := range rd.Actions {
+			if !validSoloAction(a.RoundActionEvent, userID) {
+				fields = append(fields, response.Field("round.actions", response.FieldInvalid))
+				break
+			}
+		}
+	}
+	return fields
+}
+
+// soloStake はソロのラウンドで賭けた額（スプリットで手が増え、ダブルは1つの手につき1回までベットを足す）
+func soloStake(rd SoloRoundResult) int {
+	hands, doubles := 1, 0
+	for _, a := range rd.Actions {
+		switch a.Action {
+		case "split":
+			hands++
+		case "double":
+			doubles++
+		}
+	}
+	return rd.Bet * (hands + min(doubles, hands))
+}
+
+// validSoloAction はソロの操作としてあり得るか（deal の配り先はディーラー（0）か自分）
+func validSoloAction(a models.RoundActionEvent, userID int64) bool {
+	if !models.IsRoundAction(a.Action) || (a.Card != "" && !models.IsCard(a.Card)) {
+		return false
+	}
+	switch a.Action {
+	case "deal":
+		return a.To == 0 || a.To == userID
+	case "reveal":
+		return a.Card != "" && a.To == 0
+	}
+	return a.To == 0
+}
+
+// soloRoundLog はソロの1ラウンドの経過を作る（chipsAfter は精算後の手持ち）
+func soloRoundLog(userID int64, rd SoloRoundResult, res models.RoundResult, chipsAfter int, now time.Time) models.RoundLog {
+	startedAt := now
+	if len(rd.Actions) > 0 && !rd.Actions[0].At.IsZero() {
+		startedAt = rd.Actions[0].At
+	}
+	chipsBefore := chipsAfter - res.Net
+	log := models.RoundLog{
+		ModeID: models.ModeSolo, SeedCommitment: strings.ToLower(rd.SeedCommitment), Seed: rd.Seed,
+		StartedAt: startedAt, SettledAt: now,
+	}
+	log.Append(models.RoundEvent{At: startedAt, Kind: models.RoundEventStart,
+		Start: &models.RoundStartEvent{Players: []models.RoundSeat{{UserID: userID, Chips: chipsBefore}}}})
+	if log.SeedCommitment != "" {
+		log.Append(models.RoundEvent{At: startedAt, Kind: models.RoundEventCommit, UserID: userID,
+			Commit: &models.RoundCommitEvent{SeedCommitment: log.SeedCommitment}})
+	}
+	log.Append(models.RoundEvent{At: startedAt, Kind: models.RoundEventBet, UserID: userID,
+		Bet: &models.RoundBetEvent{Bet: rd.Bet, Confirmed: true, Chips: chipsBefore - rd.Bet}})
+	for _, a := range rd.Actions {
+		at := a.At
+		if at.IsZero() {
+			at = now
+		}
+		action := a.RoundActionEvent
+		log.Append(models.RoundEvent{At: at, Kind: models.RoundEventAction, UserID: userID, Action: &action})
+	}
+	log.Append(models.RoundEvent{At: now, Kind: models.RoundEventSettle, UserID: userID, Settle: &models.RoundSettleEvent{
+		DealerNet: -res.Net,
+		Results: []models.SettledResult{{
+			UserID: userID, Result: res.Result, Blackjack: res.Blackjack, Bust: res.Bust,
+			Bet: res.Bet, Payout: res.Payout, Net: res.Net, TotalChips: chipsAfter,
+		}},
+	}})
+	return log
+}
@@ -1,70 +1,136 @@
 package handlers
 
 import (
+	"api/internal/models"
+	"api/internal/response"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 
 	"api/internal/middleware"
+	"api/internal/store"
 )
 
-// UpdateUserSettingsRequest はユーザーの音量設定を更新するリクエスト
+// UpdateUserSettingsRequest はユーザーの設定を更新するリクエスト（省略した項目は変えない）
 // bgm_volume: BGM音量 (0.0〜1.0)
 // se_volume:  効果音(SE)音量 (0.0〜1.0)
+// icon:       アイコン（/profile/catalog の icons のどれか）
+// language:   文言の言語（"ja" / "en"。"" で未設定に戻し、端末の Accept-Language に従う）
+// vibration:  振動
+// card_back:  カードの裏面（/profile/catalog の card_backs のどれか）
 type UpdateUserSettingsRequest struct {
-	BgmVolume float64 `json:"bgm_volume"`
-	SeVolume  float64 `json:"se_volume"`
+	BgmVolume *float64 `json:"bgm_volume"`
+	SeVolume  *float64 `json:"se_volume"`
+	Icon      *string  `json:"icon"`
+	Language  *string  `json:"language"`
+	Vibration *bool    `json:"vibration"`
+	CardBack  *string  `json:"card_back"`
 }
 
-// UpdateUserSettingsResponse は更新結果のレスポンス
-// result: "OK" 固定
+// UpdateUserSettingsResponse は更新後の設定
 type UpdateUserSettingsResponse struct {
-	Result string `json:"result"`
+	Settings MainDataSettings `json:"settings"`
 }
 
-// UpdateUserSettingsHandler はユーザーの音量設定を更新するハンドラ。
-// JWT認証で userID を確認し、settings テーブルを更新する。
+// UpdateUserSettingsHandler はユーザーの設定（音量・アイコン・言語・振動・カードの裏面）を更新するハンドラ。
+// JWT認証で userID を確認し、指定された項目だけ settings テーブルに反映する。
 func UpdateUserSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	repo := repo.WithContext(r.Context())
 	// ---- 認証確認 ----
 	userID := middleware.GetUserID(r)
 	if userID == 0 {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
 		return
 	}
 
 	// ---- リクエストデコード ----
 	var req UpdateUserSettingsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		response.BadJSON(w, r)
 		return
 	}
 
-	// ---- トランザクション開始 ----
-	tx, err := db.Begin()
-	if err != nil {
-		http.Error(w, "DB transaction error", http.StatusInternalServerError)
+	// ---- 現在の設定に上書き ----
+	current, err := repo.Settings().Get(userID)
+	if err == store.ErrNotFound {
+		current = &models.Setting{}
+		*current = models.DefaultSetting
+	} else if err != nil {
+		slog.ErrorContext(r.Context(), "settings lookup failed", "user_id", userID, "err", err)
+		response.Internal(w, r)
 		return
 	}
-	// エラー時は自動でRollbackされる
-	defer tx.Rollback()
-
-	// ---- settings 更新処理 ----
-	_, err = tx.Exec(`
-		UPDATE settings
-		SET bgm_volume = ?, se_volume = ?
-		WHERE user_id = ?`,
-		req.BgmVolume, req.SeVolume, userID)
-	if err != nil {
-		http.Error(w, "Failed to update volume settings", http.StatusInternalServerError)
+	s, invalid := applySettings(*current, req)
+	if len(invalid) > 0 {
+		response.Invalid(w, r, invalid...)
 		return
 	}
 
-	// ---- コミット ----
-	if err := tx.Commit(); err != nil {
-		http.Error(w, "Failed to commit DB transaction", http.StatusInternalServerError)
+	// ---- settings 更新処理 ----
+	if err := repo.Settings().Save(userID, s); err != nil {
+		slog.ErrorContext(r.Context(), "settings update failed", "user_id", userID, "err", err)
+		response.Internal(w, r)
 		return
 	}
 
 	// ---- 成功レスポンス返却 ----
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(UpdateUserSettingsResponse{Result: "OK"})
+	response.OK(w, r, UpdateUserSettingsResponse{Settings: settingsResponse(userID, &s)})
+}
+
+// applySettings は req で指定された項目を s に上書きする。範囲外・一覧に無い値は項目ごとのエラーで返す
+func applySettings(s models.Setting, req UpdateUserSettingsRequest) (models.Setting, []response.FieldError) {
+	var invalid []response.FieldError
+	volume := func(field string, v *float64, dst *float64) {
+		if v == nil {
+			return
+		}
+		if *v < 0 || *v > 1 {
+			invalid = append(invalid, response.Field(field, response.FieldOutOfRange))
+			return
+		}
+		*dst = *v
+	}
+	volume("bgm_volume", req.BgmVolume, &s.BgmVolume)
+	volume("se_volume", req.SeVolume, &s.SeVolume)
+
+	if req.Icon != nil {
+		if models.IsIcon(*req.Icon) {
+			s.Icon = *req.Icon
+		} else {
+			invalid = append(invalid, response.Field("icon", response.FieldInvalid))
+		}
+	}
+	if req.Language != nil {
+		if *req.Language == "" {
+			s.Language = ""
+		} else if lang, ok := response.ParseLang(*req.Language); ok {
+			s.Language = string(lang)
+		} else {
+			invalid = append(invalid, response.Field("language", response.FieldInvalid))
+		}
+	}
+	if req.Vibration != nil {
+		s.Vibration = *req.Vibration
+	}
+	if req.CardBack != nil {
+		if models.IsCardBack(*req.CardBack) {
+			s.CardBack = *req.CardBack
+		} else {
+			invalid = append(invalid, response.Field("card_back", response.FieldInvalid))
+		}
+	}
+	return s, invalid
+}
+
+// userLanguage はログイン中のユーザーが settings に設定した言語（未設定なら false）
+func userLanguage(r *http.Request) (response.Lang, bool) {
+	userID := middleware.GetUserID(r)
+	if userID == 0 || repo == nil {
+		return "", false
+	}
+	s, err := repo.WithContext(r.Context()).Settings().Get(userID)
+	if err != nil {
+		return "", false
+	}
+	return response.ParseLang(s.Language)
 }
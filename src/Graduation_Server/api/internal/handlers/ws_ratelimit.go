@@ -0,0 +1,69 @@
+// WebSocket の受信回数の制限（1接続ごと）
+//
+// 上限を超えたメッセージは処理せずに捨て、続けて超えている間は最初の1回だけ rate_limited を返す。
+// 1メッセージの大きさは SetReadLimit で制限する（超えると gorilla/websocket が切断する）。
+
+package handlers
+
+import (
+	"api/internal/middleware"
+	"api/internal/ratelimit"
+	"api/internal/response"
+	"log/slog"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 捨てたメッセージの通知
+type RateLimitedNotice struct {
+	Type         string        `json:"type"` // "rate_limited"
+	Code         response.Code `json:"code"` // 常に rate_limited
+	Message      string        `json:"message"`
+	RetryAfterMs int64         `json:"retry_after_ms"`
+}
+
+type wsMessageLimiter struct {
+	bucket  *ratelimit.Bucket // nil は無効
+	limited bool              // 直前のメッセージも捨てた
+	lang    response.Lang     // rate_limited の文言の言語
+}
+
+// newWSMessageLimiter は conn の受信サイズの上限を設定し、回数制限を作る
+func newWSMessageLimiter(conn *websocket.Conn, lang response.Lang) *wsMessageLimiter {
+	rate, burst, maxBytes := middleware.WSMessageLimits()
+	conn.SetReadLimit(maxBytes)
+	l := &wsMessageLimiter{lang: lang}
+	if rate > 0 {
+		l.bucket = ratelimit.NewBucket(rate, burst, time.Now())
+	}
+	return l
+}
+
+// allow は受けたメッセージを処理してよければ true。捨てるときは send で rate_limited を返す。
+func (l *wsMessageLimiter) allow(logger *slog.Logger, send func(v interface{}) error) bool {
+	if l.bucket == nil {
+		return true
+	}
+	ok, wait := l.bucket.Allow(time.Now())
+	if ok {
+		l.limited = false
+		return true
+	}
+	middleware.CountRateLimited("ws_message")
+	if l.limited {
+		return false
+	}
+	l.limited = true
+	logger.Warn("ws message rate limited")
+	notice := RateLimitedNotice{
+		Type:         "rate_limited",
+		Code:         response.CodeRateLimited,
+		Message:      response.MessageIn(l.lang, response.CodeRateLimited),
+		RetryAfterMs: wait.Milliseconds(),
+	}
+	if err := send(notice); err != nil {
+		logger.Debug("rate_limited send failed", "err", err)
+	}
+	return false
+}
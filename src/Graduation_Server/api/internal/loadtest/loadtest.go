@@ -0,0 +1,323 @@
+// Package loadtest は起動中のサーバーに bot を大量につないで負荷をかける。
+//
+// 1卓ごとに「ゲストアカウント作成 → ルーム作成・参加 → Ready → 開始 → ブラックジャックWS接続」
+// まで進め、以後はディーラー以外がベットを更新し続ける。
+// bet_update を送ってから同じ卓の各接続に bet_state が届くまでをブロードキャスト遅延として計る。
+package loadtest
+
+import (
+	"api/internal/bot"
+	"api/internal/handlers"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Options は負荷のかけ方
+type Options struct {
+	BaseURL         string        // 例: http://127.0.0.1:9090
+	Tables          int           // 同時に立てる卓の数
+	PlayersPerTable int           // 1卓の人数（ホスト込み、2〜）
+	Duration        time.Duration // 全体の実行時間（立ち上げ込み）
+	RampUp          time.Duration // 卓の立ち上げをこの時間に均等にばらす
+	BetInterval     time.Duration // 1人あたりのベット更新間隔
+	NamePrefix      string        // bot の名前の接頭辞（既存ユーザーと重ならないよう実行ごとに変える）
+}
+
+func (o *Options) validate() error {
+	switch {
+	case o.BaseURL == "":
+		return errors.New("loadtest: base URL is required")
+	case o.Tables < 1:
+		return errors.New("loadtest: tables must be at least 1")
+	case o.PlayersPerTable < 2 || o.PlayersPerTable > 64:
+		return errors.New("loadtest: players per table must be between 2 and 64")
+	case o.Duration <= 0:
+		return errors.New("loadtest: duration must be positive")
+	case o.RampUp < 0 || o.RampUp >= o.Duration:
+		return errors.New("loadtest: ramp-up must be shorter than the duration")
+	case o.BetInterval <= 0:
+		return errors.New("loadtest: bet interval must be positive")
+	}
+	return nil
+}
+
+// 卓の準備（Ready・開始・接続）で待つ上限
+const setupTimeout = 30 * time.Second
+
+// Run は Options どおりに負荷をかけ、Duration が過ぎるか ctx が終わるまで待つ。
+// 結果は stats に集計される（途中経過は呼び出し側で stats.Snapshot を見る）。
+func Run(ctx context.Context, opts Options, stats *Stats) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	// 卓の数だけ接続するので、アイドル接続を多めに残して使い回す
+	httpClient := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &timedTransport{
+			base:  &http.Transport{MaxIdleConns: 1000, MaxIdleConnsPerHost: 1000, IdleConnTimeout: 90 * time.Second},
+			stats: stats,
+		},
+	}
+
+	var step time.Duration
+	if opts.Tables > 1 {
+		step = opts.RampUp / time.Duration(opts.Tables-1)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Tables; i++ {
+		if i > 0 && step > 0 {
+			select {
+			case <-time.After(step):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		t := &table{
+			index:   i,
+			opts:    opts,
+			stats:   stats,
+			http:    httpClient,
+			pending: make(map[int64]*pendingBet),
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.run(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// HTTP API の応答時間を計る
+type timedTransport struct {
+	base  http.RoundTripper
+	stats *Stats
+}
+
+func (t *timedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	res, err := t.base.RoundTrip(req)
+	if err == nil {
+		t.stats.observeHTTP(time.Since(start))
+	}
+	return res, err
+}
+
+// 送ったベットが各接続に届いたかの追跡（1人につき最新の1件だけ）
+type pendingBet struct {
+	bet    int
+	sentAt time.Time
+	seenBy uint64 // 届いた接続のビット（卓内の番号）
+}
+
+type table struct {
+	index int
+	opts  Options
+	stats *Stats
+	http  *http.Client
+
+	bots     []*bot.Client
+	joined   []*bot.Client // ルームに入れた bot（終了時に退出させる）
+	roomCode string
+
+	mu       sync.Mutex
+	dealerID int64
+	pending  map[int64]*pendingBet // userID -> 最後に送ったベット
+}
+
+func (t *table) run(ctx context.Context) {
+	t.bots = make([]*bot.Client, t.opts.PlayersPerTable)
+	for i := range t.bots {
+		b := bot.New(t.opts.BaseURL, fmt.Sprintf("%s-%d-%d", t.opts.NamePrefix, t.index, i))
+		b.HTTP = t.http
+		t.bots[i] = b
+	}
+
+	conns, err := t.setup(ctx)
+	defer func() {
+		for _, c := range conns {
+			_ = c.Close()
+			t.stats.connClosed()
+		}
+		t.leave()
+	}()
+	if err != nil {
+		t.stats.tableFailed()
+		return
+	}
+	t.stats.tableReady()
+
+	// ディーラー以外がベットを更新し続ける
+	var wg sync.WaitGroup
+	for i, b := range t.bots {
+		wg.Add(1)
+		go func(i int, b *bot.Client) {
+			defer wg.Done()
+			t.bet(ctx, b, conns[len(t.bots)+i])
+		}(i, b)
+	}
+	wg.Wait()
+}
+
+// setup は卓を開始状態まで進め、開いた接続（ルームWS、ブラックジャックWSの順）を返す
+func (t *table) setup(ctx context.Context) ([]*bot.Conn, error) {
+	var conns []*bot.Conn
+	// 終了時刻を過ぎてからの失敗は数えない
+	step := func(stage string, err error) error {
+		if err != nil && ctx.Err() == nil {
+			t.stats.fail(stage)
+		}
+		return err
+	}
+
+	for _, b := range t.bots {
+		if err := step("account", b.CreateAccount("")); err != nil {
+			return conns, err
+		}
+	}
+	host := t.bots[0]
+	code, err := host.CreateRoom(1, t.opts.PlayersPerTable)
+	if err := step("create_room", err); err != nil {
+		return conns, err
+	}
+	t.roomCode = code
+	t.joined = append(t.joined, host)
+	for _, b := range t.bots[1:] {
+		if err := step("join", b.JoinRoom(code)); err != nil {
+			return conns, err
+		}
+		t.joined = append(t.joined, b)
+	}
+
+	// ルームWS に全員つないで Ready
+	for _, b := range t.bots {
+		c, err := b.DialRoom(code)
+		if err := step("room_ws", err); err != nil {
+			return conns, err
+		}
+		t.stats.connOpened()
+		conns = append(conns, c)
+		if err := step("send", c.Send(handlers.RoomCommand{Type: "ready", IsReady: true})); err != nil {
+			return conns, err
+		}
+		t.stats.messageSent()
+	}
+	if _, err := conns[0].Expect("all_ready", nil, setupTimeout); step("ready", err) != nil {
+		return conns, err
+	}
+	if _, err := host.StartRoom(code); step("start", err) != nil {
+		return conns, err
+	}
+	for _, c := range conns {
+		if _, err := c.Expect("start_game", nil, setupTimeout); step("start", err) != nil {
+			return conns, err
+		}
+	}
+
+	// ブラックジャックWS（受信は記録せず集計だけ）
+	for i, b := range t.bots {
+		i := i
+		c, err := b.DialBlackjackFunc(code, func(m bot.Message) { t.onMessage(i, m) })
+		if err := step("bj_ws", err); err != nil {
+			return conns, err
+		}
+		t.stats.connOpened()
+		conns = append(conns, c)
+	}
+	if ctx.Err() != nil {
+		return conns, ctx.Err()
+	}
+	return conns, nil
+}
+
+func (t *table) onMessage(connIndex int, m bot.Message) {
+	t.stats.messageReceived(m.Type)
+	switch m.Type {
+	case "player_order":
+		var order handlers.PlayerOrderMessage
+		if m.Decode(&order) != nil {
+			return
+		}
+		for _, p := range order.Players {
+			if p.IsDealer {
+				t.mu.Lock()
+				t.dealerID = p.UserID
+				t.mu.Unlock()
+			}
+		}
+	case "bet_state":
+		var state handlers.BetStateBroadcast
+		if m.Decode(&state) != nil {
+			return
+		}
+		bit := uint64(1) << uint(connIndex)
+		t.mu.Lock()
+		for _, p := range state.Players {
+			pb := t.pending[p.UserID]
+			if pb == nil || pb.bet != p.Bet || pb.seenBy&bit != 0 {
+				continue
+			}
+			pb.seenBy |= bit
+			t.stats.observeBroadcast(m.At.Sub(pb.sentAt))
+		}
+		t.mu.Unlock()
+	}
+}
+
+// bet はディーラーでない間、BetInterval ごとにベット額を変えて送り続ける
+func (t *table) bet(ctx context.Context, b *bot.Client, conn *bot.Conn) {
+	// 全員が同じ瞬間に送らないよう開始をずらす
+	select {
+	case <-time.After(time.Duration(rand.Int63n(int64(t.opts.BetInterval)))):
+	case <-ctx.Done():
+		return
+	}
+	ticker := time.NewTicker(t.opts.BetInterval)
+	defer ticker.Stop()
+
+	for seq := 0; ; seq++ {
+		t.mu.Lock()
+		isDealer := t.dealerID == b.UserID
+		t.mu.Unlock()
+		if !isDealer {
+			// 10〜500 の範囲で毎回違う額（手持ち 1000 を超えない）
+			amount := 10 * (1 + seq%50)
+			t.mu.Lock()
+			t.pending[b.UserID] = &pendingBet{bet: amount, sentAt: time.Now()}
+			t.mu.Unlock()
+			if err := conn.Send(handlers.BetCommand{Type: "bet_update", Bet: amount}); err != nil {
+				if ctx.Err() == nil {
+					t.stats.fail("send")
+				}
+				return
+			}
+			t.stats.messageSent()
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 終了時にルームから抜けてサーバー側の状態を片付ける
+func (t *table) leave() {
+	for _, b := range t.joined {
+		if _, err := b.LeaveRoom(t.roomCode); err != nil {
+			t.stats.fail("leave")
+		}
+	}
+}
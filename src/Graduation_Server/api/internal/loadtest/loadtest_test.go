@@ -0,0 +1,113 @@
+package loadtest
+
+import (
+	"api/internal/auth"
+	"api/internal/config"
+	"api/internal/handlers"
+	"api/internal/middleware"
+	"api/internal/server"
+	"api/internal/store"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// メモリストアのサーバーに小さな負荷をかけ、集計が埋まることを確認する
+func TestRunSmallLoad(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	issuer, err := auth.NewIssuer(map[string][]byte{"lt": []byte("loadtest-signing-key-0123456789abcdef")}, "lt", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.Init(issuer)
+	cfg := config.Default()
+	cfg.Timers.Action = 500 * time.Millisecond
+	handlers.InitConfig(cfg)
+	repo := store.NewMemory()
+	handlers.InitStore(repo)
+	middleware.InitTokenStore(repo.Tokens())
+	var running sync.WaitGroup
+	srv := httptest.NewServer(trackHandlers(server.NewRouter(repo), &running))
+	t.Cleanup(func() { closeServer(t, srv, &running) })
+
+	stats := NewStats()
+	err = Run(context.Background(), Options{
+		BaseURL:         srv.URL,
+		Tables:          3,
+		PlayersPerTable: 3,
+		Duration:        2 * time.Second,
+		RampUp:          200 * time.Millisecond,
+		BetInterval:     100 * time.Millisecond,
+		NamePrefix:      "lt",
+	}, stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := stats.Snapshot()
+	if r.TablesReady != 3 || r.TablesFailed != 0 {
+		t.Fatalf("tables ready=%d failed=%d, errors=%v", r.TablesReady, r.TablesFailed, r.Errors)
+	}
+	if r.ErrorCount() != 0 {
+		t.Fatalf("unexpected errors: %v", r.Errors)
+	}
+	if r.ConnsPeak != 3*3*2 || r.ConnsOpen != 0 {
+		t.Fatalf("conns peak=%d open=%d, want peak 18 and all closed", r.ConnsPeak, r.ConnsOpen)
+	}
+	if r.Broadcast.Count == 0 || r.ReceivedType["bet_state"] == 0 || r.Sent == 0 {
+		t.Fatalf("no traffic recorded: %+v", r)
+	}
+}
+
+// trackHandlers は WebSocket を含む全ハンドラの終了を running で待てるようにする
+// （httptest.Server.Close はアップグレードした接続のハンドラを待たない）
+func trackHandlers(h http.Handler, running *sync.WaitGroup) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		running.Add(1)
+		defer running.Done()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// closeServer は残っている WS を閉じ、ハンドラが全部終わるまで待つ
+// （次の実行が設定を入れ直す前に、前の実行の接続が設定を読み終えているように）
+func closeServer(t *testing.T, srv *httptest.Server, running *sync.WaitGroup) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	handlers.CloseAllConnections(ctx, "test finished")
+	srv.Close()
+
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Error("handlers still running after the server was closed")
+	}
+}
+
+func TestPercentiles(t *testing.T) {
+	var samples []time.Duration
+	for i := 100; i >= 1; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	p := percentiles(samples)
+	if p.Count != 100 || p.P50 != 50*time.Millisecond || p.P90 != 90*time.Millisecond ||
+		p.P99 != 99*time.Millisecond || p.Max != 100*time.Millisecond {
+		t.Fatalf("unexpected percentiles: %+v", p)
+	}
+	if (percentiles(nil) != Percentiles{}) {
+		t.Fatal("empty samples should give zero value")
+	}
+}
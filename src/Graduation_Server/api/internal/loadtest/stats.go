@@ -0,0 +1,247 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stats は負荷試験中の集計（複数ゴルーチンから同時に書き込まれる）
+type Stats struct {
+	mu      sync.Mutex
+	started time.Time
+
+	tablesReady  int
+	tablesFailed int
+
+	connsOpen  int
+	connsPeak  int
+	connsTotal int
+
+	received map[string]int64 // type -> 件数
+	sent     int64
+	errors   map[string]int64 // 段階 -> 件数
+
+	broadcast []time.Duration // bet_update 送信から各接続に bet_state が届くまで
+	http      []time.Duration // HTTP API の応答時間
+}
+
+func NewStats() *Stats {
+	return &Stats{
+		started:  time.Now(),
+		received: make(map[string]int64),
+		errors:   make(map[string]int64),
+	}
+}
+
+func (s *Stats) tableReady() {
+	s.mu.Lock()
+	s.tablesReady++
+	s.mu.Unlock()
+}
+
+func (s *Stats) tableFailed() {
+	s.mu.Lock()
+	s.tablesFailed++
+	s.mu.Unlock()
+}
+
+func (s *Stats) connOpened() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connsOpen++
+	s.connsTotal++
+	if s.connsOpen > s.connsPeak {
+		s.connsPeak = s.connsOpen
+	}
+}
+
+func (s *Stats) connClosed() {
+	s.mu.Lock()
+	s.connsOpen--
+	s.mu.Unlock()
+}
+
+func (s *Stats) messageReceived(typ string) {
+	s.mu.Lock()
+	s.received[typ]++
+	s.mu.Unlock()
+}
+
+func (s *Stats) messageSent() {
+	s.mu.Lock()
+	s.sent++
+	s.mu.Unlock()
+}
+
+// fail は stage（account / join / bj_ws など）ごとにエラーを数える
+func (s *Stats) fail(stage string) {
+	s.mu.Lock()
+	s.errors[stage]++
+	s.mu.Unlock()
+}
+
+func (s *Stats) observeBroadcast(d time.Duration) {
+	s.mu.Lock()
+	s.broadcast = append(s.broadcast, d)
+	s.mu.Unlock()
+}
+
+func (s *Stats) observeHTTP(d time.Duration) {
+	s.mu.Lock()
+	s.http = append(s.http, d)
+	s.mu.Unlock()
+}
+
+// Report はある時点の集計結果
+type Report struct {
+	Elapsed time.Duration
+
+	TablesReady  int
+	TablesFailed int
+
+	ConnsOpen  int
+	ConnsPeak  int
+	ConnsTotal int
+
+	Received     int64
+	ReceivedType map[string]int64
+	Sent         int64
+	Errors       map[string]int64
+
+	Broadcast Percentiles
+	HTTP      Percentiles
+}
+
+// Percentiles は遅延の分布
+type Percentiles struct {
+	Count int
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+func (s *Stats) Snapshot() Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := Report{
+		Elapsed:      time.Since(s.started),
+		TablesReady:  s.tablesReady,
+		TablesFailed: s.tablesFailed,
+		ConnsOpen:    s.connsOpen,
+		ConnsPeak:    s.connsPeak,
+		ConnsTotal:   s.connsTotal,
+		ReceivedType: make(map[string]int64, len(s.received)),
+		Sent:         s.sent,
+		Errors:       make(map[string]int64, len(s.errors)),
+		Broadcast:    percentiles(s.broadcast),
+		HTTP:         percentiles(s.http),
+	}
+	for k, v := range s.received {
+		r.ReceivedType[k] = v
+		r.Received += v
+	}
+	for k, v := range s.errors {
+		r.Errors[k] = v
+	}
+	return r
+}
+
+func percentiles(samples []time.Duration) Percentiles {
+	if len(samples) == 0 {
+		return Percentiles{}
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	at := func(q float64) time.Duration {
+		return sorted[int(q*float64(len(sorted)-1))]
+	}
+	return Percentiles{
+		Count: len(sorted),
+		P50:   at(0.50),
+		P90:   at(0.90),
+		P99:   at(0.99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+func (p Percentiles) String() string {
+	if p.Count == 0 {
+		return "n=0"
+	}
+	return fmt.Sprintf("n=%d p50=%s p90=%s p99=%s max=%s", p.Count,
+		round(p.P50), round(p.P90), round(p.P99), round(p.Max))
+}
+
+func round(d time.Duration) time.Duration {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(100 * time.Microsecond)
+}
+
+// ErrorCount はエラーの合計
+func (r Report) ErrorCount() int64 {
+	var n int64
+	for _, v := range r.Errors {
+		n += v
+	}
+	return n
+}
+
+// ErrorRate は送受信・HTTP の件数に対するエラーの割合
+func (r Report) ErrorRate() float64 {
+	total := r.Sent + int64(r.HTTP.Count) + int64(r.ConnsTotal) + r.ErrorCount()
+	if total == 0 {
+		return 0
+	}
+	return float64(r.ErrorCount()) / float64(total)
+}
+
+// ProgressLine は途中経過の1行表示。prev は前回のスナップショット（受信レートの計算用）。
+func (r Report) ProgressLine(prev Report) string {
+	rate := 0.0
+	if dt := (r.Elapsed - prev.Elapsed).Seconds(); dt > 0 {
+		rate = float64(r.Received-prev.Received) / dt
+	}
+	return fmt.Sprintf("[%6s] tables=%d(failed %d) conns=%d(peak %d) recv=%.0f/s sent=%d errors=%d bet_state p99=%s",
+		r.Elapsed.Round(time.Second), r.TablesReady, r.TablesFailed, r.ConnsOpen, r.ConnsPeak,
+		rate, r.Sent, r.ErrorCount(), round(r.Broadcast.P99))
+}
+
+// Print は最終結果を出力する
+func (r Report) Print(w io.Writer) {
+	secs := r.Elapsed.Seconds()
+	if secs <= 0 {
+		secs = 1
+	}
+	fmt.Fprintf(w, "---- loadtest result (%s) ----\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "tables       : ready=%d failed=%d\n", r.TablesReady, r.TablesFailed)
+	fmt.Fprintf(w, "connections  : open=%d peak=%d total=%d\n", r.ConnsOpen, r.ConnsPeak, r.ConnsTotal)
+	fmt.Fprintf(w, "messages     : received=%d (%.1f/s) sent=%d (%.1f/s)\n",
+		r.Received, float64(r.Received)/secs, r.Sent, float64(r.Sent)/secs)
+	types := make([]string, 0, len(r.ReceivedType))
+	for t := range r.ReceivedType {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		fmt.Fprintf(w, "  %-12s %d (%.1f/s)\n", t, r.ReceivedType[t], float64(r.ReceivedType[t])/secs)
+	}
+	fmt.Fprintf(w, "bet_state    : %s\n", r.Broadcast)
+	fmt.Fprintf(w, "http         : %s\n", r.HTTP)
+	fmt.Fprintf(w, "errors       : %d (rate %.3f%%)\n", r.ErrorCount(), r.ErrorRate()*100)
+	stages := make([]string, 0, len(r.Errors))
+	for st := range r.Errors {
+		stages = append(stages, st)
+	}
+	sort.Strings(stages)
+	for _, st := range stages {
+		fmt.Fprintf(w, "  %-12s %d\n", st, r.Errors[st])
+	}
+	fmt.Fprintln(w, strings.Repeat("-", 32))
+}
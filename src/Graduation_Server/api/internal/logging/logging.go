@@ -0,0 +1,118 @@
+// Package logging は log/slog の設定をまとめる。
+//
+//   - 出力形式（text / json）とレベルの切り替え
+//   - context に載せた属性（request_id / user_id / room など）を各レコードに付ける
+//   - パスワード・トークンなどの秘密情報を伏せる
+//
+// ハンドラからは slog.InfoContext(r.Context(), ...) のように context 付きで書くと、
+// ミドルウェアが載せた request_id などが自動で付く。
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// 出力形式（config.LogFormatText / LogFormatJSON と同じ値）
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ParseLevel は "debug" / "info" / "warn" / "error" をレベルに変換する。
+func ParseLevel(s string) (slog.Level, error) {
+	var lv slog.Level
+	if err := lv.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("logging: unknown level %q", s)
+	}
+	return lv, nil
+}
+
+// New は形式とレベルを指定してロガーを作る。
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	lv, err := ParseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: lv, ReplaceAttr: redactAttr}
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case FormatText, "":
+		h = slog.NewTextHandler(w, opts)
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("logging: unknown format %q", format)
+	}
+	return slog.New(contextHandler{h}), nil
+}
+
+// Setup は New で作ったロガーを slog のデフォルトにする。
+// 標準の log パッケージ（依存ライブラリの出力）も同じ出力先・形式になる。
+func Setup(w io.Writer, format, level string) error {
+	l, err := New(w, format, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(l)
+	return nil
+}
+
+// ---- context の属性 ----
+
+type ctxKey struct{}
+
+// With は ctx に属性を足す。引数は slog.Info と同じ（key, value の並びか slog.Attr）。
+func With(ctx context.Context, args ...any) context.Context {
+	var r slog.Record
+	r.Add(args...)
+	attrs := append([]slog.Attr(nil), Attrs(ctx)...)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, ctxKey{}, attrs)
+}
+
+// Attrs は With で載せた属性を返す。
+func Attrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	return attrs
+}
+
+// FromContext は ctx の属性を付けたデフォルトロガーを返す。
+// WebSocket の読み込みループなど、同じ属性で何度も書く場所で使う。
+func FromContext(ctx context.Context) *slog.Logger {
+	l := slog.Default()
+	for _, a := range Attrs(ctx) {
+		l = l.With(a)
+	}
+	return l
+}
+
+// contextHandler は *Context 系の呼び出しで渡された ctx の属性をレコードに足す。
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs := Attrs(ctx); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
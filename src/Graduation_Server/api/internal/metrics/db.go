@@ -0,0 +1,12 @@
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// RegisterDBStats は database/sql の接続プールの状態（go_sql_*{db_name="<name>"}）を Default に登録する
+func RegisterDBStats(db *sql.DB, name string) {
+	Default.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
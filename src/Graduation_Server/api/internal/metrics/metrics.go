@@ -0,0 +1,64 @@
+// Package metrics は /metrics に出す Prometheus のレジストリ（client_golang）。
+//
+// カウンタ・ゲージ・ヒストグラムは Factory（promauto）で作り、Default に登録する。
+// 接続数や部屋数のようにラベル付きで取得時に数えるものは NewGaugeFunc で登録する。
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Default は本番で /metrics に出すレジストリ（Go ランタイムとプロセスの値も含む）
+var Default = prometheus.NewRegistry()
+
+// Factory は Default に登録するメトリクスを作る
+var Factory = promauto.With(Default)
+
+func init() {
+	Default.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler は /metrics 用のハンドラ
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Default, promhttp.HandlerOpts{})
+}
+
+// ---- 取得時に数える値 ----
+
+// Emit は取得時の値を1系列ずつ渡す
+type Emit func(value float64, labelValues ...string)
+
+// funcCollector は取得のたびに fn で系列を集める（client_golang の GaugeFunc はラベルを持てないため）
+type funcCollector struct {
+	desc *prometheus.Desc
+	fn   func(emit Emit)
+}
+
+func (f *funcCollector) Describe(ch chan<- *prometheus.Desc) { ch <- f.desc }
+
+func (f *funcCollector) Collect(ch chan<- prometheus.Metric) {
+	f.fn(func(v float64, values ...string) {
+		m, err := prometheus.NewConstMetric(f.desc, prometheus.GaugeValue, v, values...)
+		if err != nil {
+			// ラベル数の誤りなどは取得のエラーとして返す
+			m = prometheus.NewInvalidMetric(f.desc, err)
+		}
+		ch <- m
+	})
+}
+
+// NewGaugeFunc は取得のたびに fn で値を集めるゲージを r に登録する
+func NewGaugeFunc(r prometheus.Registerer, name, help string, labels []string, fn func(emit Emit)) {
+	r.MustRegister(&funcCollector{
+		desc: prometheus.NewDesc(name, help, labels, nil),
+		fn:   fn,
+	})
+}
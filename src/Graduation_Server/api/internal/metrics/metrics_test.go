@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGaugeFunc(t *testing.T) {
+	r := prometheus.NewRegistry()
+	rooms := map[string]int{"waiting": 3, "playing": 1}
+	NewGaugeFunc(r, "rooms", "Rooms by status.", []string{"status"}, func(emit Emit) {
+		for status, n := range rooms {
+			emit(float64(n), status)
+		}
+	})
+
+	want := `# HELP rooms Rooms by status.
+# TYPE rooms gauge
+rooms{status="playing"} 1
+rooms{status="waiting"} 3
+`
+	if err := testutil.GatherAndCompare(r, strings.NewReader(want), "rooms"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 取得のたびに数え直す
+	rooms["playing"] = 2
+	delete(rooms, "waiting")
+	want = `# HELP rooms Rooms by status.
+# TYPE rooms gauge
+rooms{status="playing"} 2
+`
+	if err := testutil.GatherAndCompare(r, strings.NewReader(want), "rooms"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGaugeFuncLabelCountFails(t *testing.T) {
+	r := prometheus.NewRegistry()
+	NewGaugeFunc(r, "bad", "x", []string{"a"}, func(emit Emit) { emit(1, "1", "2") })
+	if _, err := r.Gather(); err == nil {
+		t.Error("expected an error on label count mismatch")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "# TYPE go_goroutines gauge") {
+		t.Errorf("runtime metrics missing:\n%s", body)
+	}
+}
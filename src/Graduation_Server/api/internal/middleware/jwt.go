@@ -1,11 +1,16 @@
 package middleware
 
 import (
+	"api/internal/auth"
+	"api/internal/logging"
+	"api/internal/models"
+	"api/internal/response"
+	"api/internal/store"
 	"context"
+	"log/slog"
 	"net/http"
 	"strings"
-
-	"github.com/golang-jwt/jwt/v4"
+	"time"
 )
 
 // コンテキストに格納するキーの型（衝突防止）
@@ -13,57 +18,100 @@ type contextKey string
 
 const UserIDKey = contextKey("userID")
 
-// JWTの署名鍵（環境変数などで管理推奨）
-var jwtSecret = []byte("your_secret_key")
+// トークンの role クレーム（運営APIの権限チェックに使う）
+const RoleKey = contextKey("role")
+
+// 認証に使ったトークンの jti / 有効期限（ログアウト時の失効登録に使う）
+const (
+	TokenIDKey     = contextKey("tokenID")
+	TokenExpiryKey = contextKey("tokenExpiry")
+)
+
+// 失効チェック用のトークンストア（InitTokenStore で設定。未設定なら失効チェックはしない）
+var tokens store.TokenRepository
+
+func InitTokenStore(t store.TokenRepository) {
+	tokens = t
+}
+
+// requestToken は Authorization: Bearer か、無ければクエリパラメータ token（WebSocket 用）のトークン
+func requestToken(r *http.Request) string {
+	if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	return r.URL.Query().Get("token")
+}
 
 // JWTミドルウェア
 func JWTMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var tokenStr string
-
-		authHeader := r.Header.Get("Authorization")
-		if strings.HasPrefix(authHeader, "Bearer ") {
-			tokenStr = strings.TrimPrefix(authHeader, "Bearer ")
-		} else {
-			// Authorizationヘッダーなければクエリパラメータtokenを取得
-			tokenStr = r.URL.Query().Get("token")
-		}
-
+		tokenStr := requestToken(r)
 		if tokenStr == "" {
-			http.Error(w, "Missing token", http.StatusUnauthorized)
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeUnauthorized)
 			return
 		}
 
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return jwtSecret, nil
-		})
-
-		if err != nil || !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+		// 署名・有効期限の検証（鍵は auth.Issuer が kid で選ぶ）
+		claims, err := auth.Default().Parse(tokenStr)
+		if err != nil {
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeTokenInvalid)
 			return
 		}
+		userID := claims.UserID
+		jti := claims.ID
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			http.Error(w, "Invalid claims", http.StatusUnauthorized)
+		// 失効チェック（ログアウト済み / 全端末ログアウト後のトークンを弾く）
+		revoked, err := IsTokenRevoked(userID, jti, claims.TokenVersion)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "token revocation check failed", "user_id", userID, "err", err)
+			response.Internal(w, r)
 			return
 		}
-
-		userIDFloat, ok := claims["user_id"].(float64)
-		if !ok {
-			http.Error(w, "Missing user_id in token", http.StatusUnauthorized)
+		if revoked {
+			response.Fail(w, r, http.StatusUnauthorized, response.CodeTokenRevoked)
 			return
 		}
-		userID := int64(userIDFloat)
 
-		ctx := context.WithValue(r.Context(), UserIDKey, userID)
+		// 以降のログには user_id が付く
+		ctx := logging.With(r.Context(), "user_id", userID)
+		ctx = context.WithValue(ctx, UserIDKey, userID)
+		ctx = context.WithValue(ctx, RoleKey, claims.Role)
+		ctx = context.WithValue(ctx, TokenIDKey, jti)
+		if claims.ExpiresAt != nil {
+			ctx = context.WithValue(ctx, TokenExpiryKey, claims.ExpiresAt.Time)
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// AdminMiddleware は JWT の検証に加えて、role クレームが admin のトークンだけを通す
+func AdminMiddleware(next http.Handler) http.Handler {
+	return JWTMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetRole(r) != models.RoleAdmin {
+			slog.WarnContext(r.Context(), "admin api rejected", "role", GetRole(r), "path", r.URL.Path)
+			response.Fail(w, r, http.StatusForbidden, response.CodeForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// IsTokenRevoked は jti が失効リストにあるか、token_version が古い場合に true を返す。
+// ユーザーが消えている場合も失効扱い。
+func IsTokenRevoked(userID int64, jti string, version int) (bool, error) {
+	if tokens == nil {
+		return false, nil
+	}
+	current, revoked, err := tokens.State(userID, jti)
+	if err == store.ErrNotFound {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return revoked || current != version, nil
+}
+
 // ハンドラー内で userID を取得する関数
 func GetUserID(r *http.Request) int64 {
 	if val, ok := r.Context().Value(UserIDKey).(int64); ok {
@@ -71,3 +119,16 @@ func GetUserID(r *http.Request) int64 {
 	}
 	return 0 // 存在しない・不正な場合は 0（認証失敗などで弾くのが良い）
 }
+
+// トークンの role クレーム（無ければ空文字）
+func GetRole(r *http.Request) string {
+	role, _ := r.Context().Value(RoleKey).(string)
+	return role
+}
+
+// 認証に使ったトークンの jti と有効期限を取得する関数
+func GetTokenID(r *http.Request) (string, time.Time) {
+	jti, _ := r.Context().Value(TokenIDKey).(string)
+	exp, _ := r.Context().Value(TokenExpiryKey).(time.Time)
+	return jti, exp
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"api/internal/logging"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// RequestIDHeader はリクエストIDを受け渡すヘッダー（前段のロードバランサーが付けたものはそのまま使う）
+const RequestIDHeader = "X-Request-ID"
+
+// 監視の定期アクセスはアクセスログを debug に落とす
+var quietRoutes = map[string]bool{"/metrics": true, "/healthz": true, "/readyz": true}
+
+// RequestLog はリクエストIDを決めて context とレスポンスヘッダーに載せ、終わったらアクセスログを出す。
+// WebSocket は切断時に1行出る。router.Use で登録する。
+func RequestLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := logging.With(r.Context(), "request_id", id)
+
+		route := "unknown"
+		if cur := mux.CurrentRoute(r); cur != nil {
+			if tpl, err := cur.GetPathTemplate(); err == nil {
+				route = tpl
+			}
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		status := rec.status
+		if rec.hijacked {
+			status = http.StatusSwitchingProtocols
+		}
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case quietRoutes[route]:
+			level = slog.LevelDebug
+		}
+		slog.Log(ctx, level, "http request",
+			"method", r.Method,
+			"route", route,
+			"path", r.URL.Path,
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"remote", r.RemoteAddr,
+		)
+	})
+}
+
+func newRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// 外から来たIDはログを汚さないよう英数字と "-_." の64文字までに限る
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
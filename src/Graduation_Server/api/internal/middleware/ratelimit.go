@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"api/internal/auth"
+	"api/internal/config"
+	"api/internal/metrics"
+	"api/internal/ratelimit"
+	"api/internal/response"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ---- 呼び出し回数の制限（HTTP）----
+// 1つのクライアントが API を連打して MySQL を詰まらせないよう、トークンバケットで回数を制限する。
+// InitRateLimits を呼ぶまでは何も制限しない（e2e テスト・負荷試験用のサーバーなど）。
+
+// 制限の種類（RateLimit に渡す）
+const (
+	LimitAPI           = "api"            // /api/ 全体（ユーザーID、未ログインはIPごと）
+	LimitCreateRoom    = "create_room"    // ユーザーごと
+	LimitCreateAccount = "create_account" // IPごと
+)
+
+type rateLimitRule struct {
+	limiter *ratelimit.Limiter
+	byIP    bool // トークンがあってもIPで数える
+}
+
+// InitRateLimits で作る設定一式（差し替えは丸ごと行う）
+type rateLimitState struct {
+	rules map[string]rateLimitRule
+	ws    config.RateLimitConfig // WebSocket の項目だけ使う
+}
+
+var (
+	rateLimits atomic.Pointer[rateLimitState]
+
+	rateLimited = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "rate_limited_total",
+		Help: "Requests and WebSocket messages rejected by rate limits, by limit.",
+	}, []string{"limit"})
+)
+
+// InitRateLimits は設定の rate / burst で制限を作り直す（rate が 0 の制限は無効）
+func InitRateLimits(c config.RateLimitConfig) {
+	st := &rateLimitState{rules: make(map[string]rateLimitRule), ws: c}
+	add := func(name string, rate float64, burst int, byIP bool) {
+		if rate > 0 {
+			st.rules[name] = rateLimitRule{limiter: ratelimit.New(rate, burst), byIP: byIP}
+		}
+	}
+	add(LimitAPI, c.APIRate, c.APIBurst, false)
+	add(LimitCreateRoom, c.CreateRoomRate, c.CreateRoomBurst, false)
+	add(LimitCreateAccount, c.CreateAccountRate, c.CreateAccountBurst, true)
+	rateLimits.Store(st)
+}
+
+// ResetRateLimits は InitRateLimits 前の状態（制限無し）に戻す
+func ResetRateLimits() {
+	rateLimits.Store(nil)
+}
+
+// WSMessageLimits は WebSocket の1接続あたりの受信制限（rate が 0 なら回数は無制限）。
+// InitRateLimits 前は回数の制限無し・サイズはデフォルト値。
+func WSMessageLimits() (rate float64, burst int, maxBytes int64) {
+	c := config.Default().RateLimit
+	if st := rateLimits.Load(); st != nil {
+		c = st.ws
+	} else {
+		c.WSMessageRate = 0
+	}
+	return c.WSMessageRate, c.WSMessageBurst, int64(c.WSMaxMessageBytes)
+}
+
+// RateLimit は name の制限をかけるミドルウェア。超えたら 429 と Retry-After を返す。
+// LimitAPI は /api/ 以外（/metrics・/healthz など）には掛けない。
+func RateLimit(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			st := rateLimits.Load()
+			if st == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rule, ok := st.rules[name]
+			if !ok || (name == LimitAPI && !strings.HasPrefix(r.URL.Path, "/api/")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			key := rateLimitKey(r, rule.byIP)
+			if allowed, wait := rule.limiter.Allow(key, time.Now()); !allowed {
+				rateLimited.WithLabelValues(name).Inc()
+				slog.WarnContext(r.Context(), "rate limited", "limit", name, "key", key, "path", r.URL.Path)
+				WriteTooManyRequests(w, r, wait)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CountRateLimited は WebSocket など HTTP 以外で制限したときに件数を数える
+func CountRateLimited(name string) {
+	rateLimited.WithLabelValues(name).Inc()
+}
+
+// WriteTooManyRequests は 429 と Retry-After（秒、切り上げ）を返す
+func WriteTooManyRequests(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	secs := int((wait + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	response.Fail(w, r, http.StatusTooManyRequests, response.CodeRateLimited)
+}
+
+// rateLimitKey は署名の通るトークンがあればユーザーID、無ければ接続元IP。
+// 失効の確認（DB）はここではしない（JWTMiddleware が後で弾く）。
+func rateLimitKey(r *http.Request, byIP bool) string {
+	if !byIP {
+		if tok := requestToken(r); tok != "" && auth.Default() != nil {
+			if claims, err := auth.Default().Parse(tok); err == nil {
+				return "user:" + strconv.FormatInt(claims.UserID, 10)
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
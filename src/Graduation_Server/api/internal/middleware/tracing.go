@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"api/internal/logging"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing はリクエストごとにサーバースパンを作る（otelmux。呼び出し元の traceparent があれば続きにする）。
+// スパン名は "HTTP <メソッド> <ルート>"。trace_id はログにも載せる。WebSocket は接続中ずっと1つのスパンになる。
+// tracing.Init の後に作り、router.Use で RequestLog より前に登録する。
+func Tracing() mux.MiddlewareFunc {
+	traced := otelmux.Middleware("api", otelmux.WithSpanNameFormatter(func(route string, r *http.Request) string {
+		return "HTTP " + r.Method + " " + route
+	}))
+	return func(next http.Handler) http.Handler {
+		return traced(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsSampled() {
+				r = r.WithContext(logging.With(r.Context(), "trace_id", sc.TraceID().String()))
+			}
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
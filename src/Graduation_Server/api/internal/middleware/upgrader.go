@@ -3,12 +3,51 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/websocket"
 )
 
-var Upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
+// 許可する Origin（"*" で全許可）。SetAllowedOrigins で設定から差し替える。
+var allowedOrigins = []string{"*"}
+
+func SetAllowedOrigins(origins []string) {
+	allowedOrigins = origins
+}
+
+// CheckOrigin は Origin ヘッダーが許可リストにあるかを返す。
+// Unity などのネイティブクライアントは Origin を送らないので、無い場合は許可する。
+func CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
 		return true
-	},
+	}
+	for _, o := range allowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+var Upgrader = websocket.Upgrader{
+	CheckOrigin: CheckOrigin,
+}
+
+// CORS はブラウザからのアクセス用に CORS ヘッダーを付け、プリフライト(OPTIONS)に応答する。
+func CORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" && CheckOrigin(r) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
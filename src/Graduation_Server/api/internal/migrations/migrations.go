@@ -0,0 +1,377 @@
+// Package migrations はバイナリに埋め込んだ SQL でスキーマを管理する。
+//
+// sql/ 配下に「4桁の番号_名前.up.sql」と「同じ番号_名前.down.sql」を1組で置く。
+// 適用済みの番号は schema_version テーブルに記録し、未適用のものだけを番号順に流す。
+// MySQL の DDL はトランザクションで巻き戻せないため、1ファイル内の文は順に実行し、
+// 途中で失敗したらそのバージョンは記録しない（手で直してから再実行する）。
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// 複数プロセスから同時に migrate しないための名前付きロック
+const lockName = "graduation_schema_migrate"
+
+const createVersionTable = `
+CREATE TABLE IF NOT EXISTS schema_version (
+  version     INT          NOT NULL PRIMARY KEY,
+  name        VARCHAR(128) NOT NULL,
+  applied_at  DATETIME     NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
+
+var (
+	ErrUnknownVersion = errors.New("migrations: unknown version")
+	ErrLocked         = errors.New("migrations: another migration is running")
+)
+
+// Migration は1バージョン分の up/down スクリプト
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status は1バージョン分の適用状況
+type Status struct {
+	Migration
+	AppliedAt *time.Time // 未適用なら nil
+}
+
+// All は埋め込まれたマイグレーションを番号順に返す。
+func All() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		fname := e.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fname, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fname, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migrations: unexpected file %s", fname)
+		}
+		base := strings.TrimSuffix(fname, "."+direction+".sql")
+		num, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migrations: bad file name %s", fname)
+		}
+		version, err := strconv.Atoi(num)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrations: bad version in %s", fname)
+		}
+		body, err := files.ReadFile(path.Join("sql", fname))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migrations: version %d has two names (%s, %s)", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrations: version %d needs both up and down scripts", m.Version)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Latest は埋め込まれている最新のバージョン番号
+func Latest() (int, error) {
+	all, err := All()
+	if err != nil {
+		return 0, err
+	}
+	if len(all) == 0 {
+		return 0, nil
+	}
+	return all[len(all)-1].Version, nil
+}
+
+// CurrentVersion は適用済みの最大バージョン（schema_version が無ければ 0）
+func CurrentVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var exists bool
+	if err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) > 0 FROM information_schema.tables
+		 WHERE table_schema = DATABASE() AND table_name = 'schema_version'`).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, nil
+	}
+	var v sql.NullInt64
+	if err := db.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_version`).Scan(&v); err != nil {
+		return 0, err
+	}
+	return int(v.Int64), nil
+}
+
+// StatusList は全マイグレーションの適用状況を返す。
+func StatusList(ctx context.Context, db *sql.DB) ([]Status, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.ExecContext(ctx, createVersionTable); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Status, 0, len(all))
+	for _, m := range all {
+		s := Status{Migration: m}
+		if at, ok := applied[m.Version]; ok {
+			at := at
+			s.AppliedAt = &at
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// Up は target 以下の未適用マイグレーションを番号順に流す（target <= 0 なら最新まで）。
+// 適用したバージョンを返す。
+func Up(ctx context.Context, db *sql.DB, target int) ([]Migration, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	if target <= 0 && len(all) > 0 {
+		target = all[len(all)-1].Version
+	}
+	if target > 0 && !hasVersion(all, target) {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, target)
+	}
+
+	var done []Migration
+	err = withLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range all {
+			if m.Version > target {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := execScript(ctx, conn, m.Up); err != nil {
+				return fmt.Errorf("migrations: %04d_%s up: %w", m.Version, m.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx,
+				`INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`,
+				m.Version, m.Name, time.Now()); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down は適用済みのものを新しい順に steps 個だけ戻す。戻したバージョンを返す。
+func Down(ctx context.Context, db *sql.DB, steps int) ([]Migration, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = withLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(all) - 1; i >= 0 && len(done) < steps; i-- {
+			m := all[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if err := execScript(ctx, conn, m.Down); err != nil {
+				return fmt.Errorf("migrations: %04d_%s down: %w", m.Version, m.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, `DELETE FROM schema_version WHERE version = ?`, m.Version); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Baseline は手作業でスキーマを作った既存DB向けに、version 以下を「適用済み」として記録する。
+// SQL は流さない。
+func Baseline(ctx context.Context, db *sql.DB, version int) error {
+	all, err := All()
+	if err != nil {
+		return err
+	}
+	if !hasVersion(all, version) {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return withLock(ctx, db, func(conn *sql.Conn) error {
+		now := time.Now()
+		for _, m := range all {
+			if m.Version > version {
+				break
+			}
+			if _, err := conn.ExecContext(ctx,
+				`INSERT IGNORE INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`,
+				m.Version, m.Name, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func hasVersion(all []Migration, version int) bool {
+	for _, m := range all {
+		if m.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// *sql.DB と *sql.Conn の共通部分
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func appliedVersions(ctx context.Context, q queryer) (map[int]time.Time, error) {
+	rows, err := q.QueryContext(ctx, `SELECT version, applied_at FROM schema_version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var v int
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		applied[v] = at
+	}
+	return applied, rows.Err()
+}
+
+// GET_LOCK は接続単位なので、1本の接続を握ったまま fn を実行する
+func withLock(ctx context.Context, db *sql.DB, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, 10)`, lockName).Scan(&got); err != nil {
+		return err
+	}
+	if got.Int64 != 1 {
+		return ErrLocked
+	}
+	defer conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, lockName)
+
+	if _, err := conn.ExecContext(ctx, createVersionTable); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func execScript(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w\n%s", err, stmt)
+		}
+	}
+	return nil
+}
+
+// splitStatements は ; 区切りで文に分ける。
+// 文字列リテラル内の ; と、-- / # で始まる行コメントは区切りとして扱わない。
+func splitStatements(script string) []string {
+	var stmts []string
+	var cur strings.Builder
+	var quote rune
+	comment := false
+
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		cur.Reset()
+	}
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case comment:
+			if c == '\n' {
+				comment = false
+				cur.WriteRune(c)
+			}
+		case quote != 0:
+			cur.WriteRune(c)
+			if c == '\\' && i+1 < len(runes) {
+				i++
+				cur.WriteRune(runes[i])
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			cur.WriteRune(c)
+		case c == '#' || (c == '-' && i+1 < len(runes) && runes[i+1] == '-'):
+			comment = true
+		case c == ';':
+			flush()
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	flush()
+	return stmts
+}
@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	cases := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "two statements",
+			script: "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			want:   []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"},
+		},
+		{
+			name:   "trailing statement without terminator",
+			script: "INSERT INTO a VALUES (1);\nINSERT INTO a VALUES (2)",
+			want:   []string{"INSERT INTO a VALUES (1)", "INSERT INTO a VALUES (2)"},
+		},
+		{
+			name:   "empty statements are dropped",
+			script: ";;\n  ;\nSELECT 1;;",
+			want:   []string{"SELECT 1"},
+		},
+		{
+			name:   "semicolon in single-quoted string",
+			script: "INSERT INTO m (body) VALUES ('a;b');SELECT 1;",
+			want:   []string{"INSERT INTO m (body) VALUES ('a;b')", "SELECT 1"},
+		},
+		{
+			name:   "semicolon in double-quoted string and backticks",
+			script: "INSERT INTO `t;1` (`c;2`) VALUES (\"x;y\");",
+			want:   []string{"INSERT INTO `t;1` (`c;2`) VALUES (\"x;y\")"},
+		},
+		{
+			name:   "backslash-escaped quote",
+			script: `INSERT INTO m VALUES ('it\'s; fine');SELECT 2;`,
+			want:   []string{`INSERT INTO m VALUES ('it\'s; fine')`, "SELECT 2"},
+		},
+		{
+			name:   "doubled quote",
+			script: "INSERT INTO m VALUES ('it''s; fine');SELECT 2;",
+			want:   []string{"INSERT INTO m VALUES ('it''s; fine')", "SELECT 2"},
+		},
+		{
+			name:   "escaped backslash before the closing quote",
+			script: `INSERT INTO m VALUES ('c:\\');SELECT 3;`,
+			want:   []string{`INSERT INTO m VALUES ('c:\\')`, "SELECT 3"},
+		},
+		{
+			name:   "semicolon in -- comment",
+			script: "-- 初期データ; 2件\nINSERT INTO a VALUES (1); -- 1件目; 終わり\nINSERT INTO a VALUES (2);",
+			want:   []string{"INSERT INTO a VALUES (1)", "INSERT INTO a VALUES (2)"},
+		},
+		{
+			name:   "semicolon in # comment",
+			script: "# a; b\nSELECT 1;",
+			want:   []string{"SELECT 1"},
+		},
+		{
+			name:   "comment markers inside a string are kept",
+			script: "INSERT INTO m VALUES ('a -- b # c');",
+			want:   []string{"INSERT INTO m VALUES ('a -- b # c')"},
+		},
+		{
+			name:   "comment without a trailing newline",
+			script: "SELECT 1; -- done",
+			want:   []string{"SELECT 1"},
+		},
+		{
+			name:   "comments only",
+			script: "-- nothing here;\n# nor here;\n",
+			want:   nil,
+		},
+	}
+	for _, c := range cases {
+		if got := splitStatements(c.script); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: splitStatements(%q) = %q, want %q", c.name, c.script, got, c.want)
+		}
+	}
+}
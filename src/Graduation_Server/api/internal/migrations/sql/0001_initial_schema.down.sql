@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS players;
+DROP TABLE IF EXISTS games;
+DROP TABLE IF EXISTS room_users;
+DROP TABLE IF EXISTS rooms;
+DROP TABLE IF EXISTS types;
+DROP TABLE IF EXISTS game_types;
+DROP TABLE IF EXISTS modes;
+DROP TABLE IF EXISTS tips;
+DROP TABLE IF EXISTS settings;
+DROP TABLE IF EXISTS users;
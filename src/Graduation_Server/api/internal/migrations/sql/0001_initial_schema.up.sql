@@ -0,0 +1,93 @@
+-- 初期スキーマ（ユーザー・設定・チップ・モード/ゲーム種別・ルーム・ゲーム）
+
+CREATE TABLE users (
+  id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+  name        VARCHAR(64)  NOT NULL,
+  password    VARCHAR(255) NOT NULL DEFAULT '',   -- bcrypt。ゲストは空文字
+  created_at  DATETIME     NOT NULL,
+  last_login  DATETIME     NULL,
+  UNIQUE KEY uq_users_name (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE settings (
+  user_id     BIGINT       NOT NULL PRIMARY KEY,
+  bgm_volume  DOUBLE       NOT NULL DEFAULT 1.0,
+  se_volume   DOUBLE       NOT NULL DEFAULT 1.0,
+  icon        VARCHAR(64)  NOT NULL DEFAULT 'default_icon'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE tips (
+  user_id          BIGINT   NOT NULL PRIMARY KEY,
+  solo_tip_count   BIGINT   NOT NULL DEFAULT 0,
+  multi_tip_count  BIGINT   NOT NULL DEFAULT 0,
+  updated_at       DATETIME NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 遊び方（ソロ / マルチ / ランク / フレンド）
+CREATE TABLE modes (
+  id           INT AUTO_INCREMENT PRIMARY KEY,
+  mode         VARCHAR(32) NOT NULL,
+  is_can_play  BOOLEAN     NOT NULL DEFAULT TRUE,
+  UNIQUE KEY uq_modes_mode (mode)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- ゲームの種類（ブラックジャックなど）
+CREATE TABLE game_types (
+  id    INT AUTO_INCREMENT PRIMARY KEY,
+  code  VARCHAR(32) NOT NULL,
+  name  VARCHAR(64) NOT NULL,
+  UNIQUE KEY uq_game_types_code (code)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- モードごとに遊べるゲーム（表示名・ルール説明付き）
+CREATE TABLE types (
+  id            INT AUTO_INCREMENT PRIMARY KEY,
+  mode_id       INT          NOT NULL,
+  game_type_id  INT          NOT NULL,
+  name          VARCHAR(64)  NOT NULL,
+  rule          TEXT         NOT NULL,
+  is_can_play   BOOLEAN      NOT NULL DEFAULT TRUE,
+  UNIQUE KEY uq_types_mode_game (mode_id, game_type_id),
+  KEY idx_types_game_type (game_type_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE rooms (
+  id            BIGINT AUTO_INCREMENT PRIMARY KEY,
+  room_code     VARCHAR(16) NOT NULL,
+  game_type_id  INT         NOT NULL,
+  max_players   INT         NOT NULL DEFAULT 4,
+  owner_id      BIGINT      NOT NULL DEFAULT 0,
+  status        VARCHAR(16) NOT NULL DEFAULT 'waiting',   -- waiting / playing / closed
+  created_at    DATETIME    NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  KEY idx_rooms_code (room_code)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- seat_no は 1 始まり。席替えの入れ替え中だけ一時的に NULL を使う
+CREATE TABLE room_users (
+  id        BIGINT AUTO_INCREMENT PRIMARY KEY,
+  room_id   BIGINT  NOT NULL,
+  user_id   BIGINT  NOT NULL,
+  is_ready  BOOLEAN NOT NULL DEFAULT FALSE,
+  seat_no   INT     NULL,
+  UNIQUE KEY uq_room_users_room_user (room_id, user_id),
+  UNIQUE KEY uq_room_users_room_seat (room_id, seat_no),
+  KEY idx_room_users_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE games (
+  id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+  mode_id     INT      NOT NULL,
+  type_id     INT      NOT NULL,
+  created_at  DATETIME NOT NULL,
+  updated_at  DATETIME NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE players (
+  id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+  room_id    BIGINT  NOT NULL,
+  user_id    BIGINT  NOT NULL,
+  chip       BIGINT  NOT NULL DEFAULT 0,
+  bet        BIGINT  NOT NULL DEFAULT 0,
+  is_dealer  BOOLEAN NOT NULL DEFAULT FALSE,
+  UNIQUE KEY uq_players_room_user (room_id, user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
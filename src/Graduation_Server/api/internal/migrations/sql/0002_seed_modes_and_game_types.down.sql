@@ -0,0 +1,3 @@
+DELETE FROM types WHERE game_type_id = 1 AND mode_id IN (1, 2, 4);
+DELETE FROM game_types WHERE id = 1;
+DELETE FROM modes WHERE id IN (1, 2, 3, 4);
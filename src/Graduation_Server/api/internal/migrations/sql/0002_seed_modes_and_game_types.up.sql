@@ -0,0 +1,16 @@
+-- モード・ゲーム種別の初期データ
+-- modes.id はルーム開始時の games.mode_id などで固定値として参照されるので明示する
+
+INSERT INTO modes (id, mode, is_can_play) VALUES
+  (1, 'ソロ',     TRUE),
+  (2, 'マルチ',   TRUE),
+  (3, 'ランク',   FALSE),
+  (4, 'フレンド', TRUE);
+
+INSERT INTO game_types (id, code, name) VALUES
+  (1, 'blackjack', 'ブラックジャック');
+
+INSERT INTO types (mode_id, game_type_id, name, rule, is_can_play) VALUES
+  (1, 1, 'ブラックジャック', 'ディーラーより21に近い手を目指す。21を超えたら負け。', TRUE),
+  (2, 1, 'ブラックジャック', '最大4人で同じディーラーと勝負する。親は席順に交代。', TRUE),
+  (4, 1, 'ブラックジャック', 'ルームコードを共有して友達と遊ぶ。', TRUE);
@@ -0,0 +1,23 @@
+-- リフレッシュトークン・失効管理
+-- token_version は「全端末からログアウト」で上がり、古いアクセストークンを無効にする
+
+ALTER TABLE users ADD COLUMN token_version INT NOT NULL DEFAULT 0;
+
+CREATE TABLE refresh_tokens (
+  id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id     BIGINT      NOT NULL,
+  token_hash  CHAR(64)    NOT NULL,
+  family_id   CHAR(32)    NOT NULL,
+  expires_at  DATETIME    NOT NULL,
+  created_at  DATETIME    NOT NULL,
+  revoked_at  DATETIME    NULL,
+  UNIQUE KEY uq_refresh_tokens_hash (token_hash),
+  KEY idx_refresh_tokens_user (user_id),
+  KEY idx_refresh_tokens_family (family_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE revoked_tokens (
+  jti         CHAR(32)    NOT NULL PRIMARY KEY,
+  user_id     BIGINT      NOT NULL,
+  expires_at  DATETIME    NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS password_reset_codes;
+DROP TABLE IF EXISTS transfer_codes;
@@ -0,0 +1,24 @@
+-- 引き継ぎコード・パスワード再設定コード（どちらもハッシュだけを保存）
+
+CREATE TABLE transfer_codes (
+  id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id     BIGINT      NOT NULL,
+  code_hash   CHAR(64)    NOT NULL,
+  expires_at  DATETIME    NOT NULL,
+  created_at  DATETIME    NOT NULL,
+  used_at     DATETIME    NULL,
+  UNIQUE KEY uq_transfer_codes_hash (code_hash),
+  KEY idx_transfer_codes_user (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- kind: 'recovery'（本人が事前発行、expires_at NULL で無期限） / 'admin'（運営が発行）
+CREATE TABLE password_reset_codes (
+  id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id     BIGINT      NOT NULL,
+  code_hash   CHAR(64)    NOT NULL,
+  kind        VARCHAR(16) NOT NULL,
+  expires_at  DATETIME    NULL,
+  created_at  DATETIME    NOT NULL,
+  used_at     DATETIME    NULL,
+  KEY idx_password_reset_codes_user (user_id, code_hash)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS bj_room_snapshots;
@@ -0,0 +1,9 @@
+-- ブラックジャック卓の状態スナップショット（クラッシュ後の復元用）
+-- state は handlers の bjSnapshot を JSON にしたもの。seq が大きいものだけで上書きする。
+
+CREATE TABLE bj_room_snapshots (
+  room_code   VARCHAR(16) NOT NULL PRIMARY KEY,
+  seq         BIGINT      NOT NULL,
+  state       TEXT        NOT NULL,
+  updated_at  DATETIME    NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
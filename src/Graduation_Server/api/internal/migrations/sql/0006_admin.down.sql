@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS admin_audit_log;
+DROP TABLE IF EXISTS chip_ledger;
+ALTER TABLE users
+  DROP COLUMN ban_reason,
+  DROP COLUMN banned_at,
+  DROP COLUMN role;
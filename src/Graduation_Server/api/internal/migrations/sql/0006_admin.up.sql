@@ -0,0 +1,32 @@
+-- 運営用：ロール・BAN・チップ台帳・操作ログ
+-- role は JWT の role クレームにそのまま入る（'player' / 'admin'）
+
+ALTER TABLE users
+  ADD COLUMN role        VARCHAR(16)  NOT NULL DEFAULT 'player',
+  ADD COLUMN banned_at   DATETIME     NULL,
+  ADD COLUMN ban_reason  VARCHAR(255) NOT NULL DEFAULT '';
+
+-- 所持チップの増減履歴（運営の手動調整など）。wallet: 'solo' / 'multi'
+-- admin_id は操作した運営ユーザー（コマンドラインからの操作は 0）
+CREATE TABLE chip_ledger (
+  id             BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id        BIGINT       NOT NULL,
+  wallet         VARCHAR(16)  NOT NULL,
+  delta          BIGINT       NOT NULL,
+  balance_after  BIGINT       NOT NULL,
+  reason         VARCHAR(255) NOT NULL,
+  admin_id       BIGINT       NOT NULL DEFAULT 0,
+  created_at     DATETIME     NOT NULL,
+  KEY idx_chip_ledger_user (user_id, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 運営操作の記録。detail は操作内容の JSON
+CREATE TABLE admin_audit_log (
+  id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+  admin_id    BIGINT       NOT NULL,
+  action      VARCHAR(64)  NOT NULL,
+  target      VARCHAR(64)  NOT NULL DEFAULT '',
+  detail      TEXT         NOT NULL,
+  created_at  DATETIME     NOT NULL,
+  KEY idx_admin_audit_log_created (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
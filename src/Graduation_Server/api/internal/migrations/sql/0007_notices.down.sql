@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS announcements;
+DROP TABLE IF EXISTS service_status;
@@ -0,0 +1,25 @@
+-- メンテナンス状態と全体へのお知らせ
+
+-- サービス全体の状態（1行だけ）。maintenance 中は新しいルームの作成・参加を断る
+CREATE TABLE service_status (
+  id                   TINYINT      PRIMARY KEY,
+  maintenance          BOOLEAN      NOT NULL DEFAULT FALSE,
+  maintenance_message  VARCHAR(255) NOT NULL DEFAULT '',
+  updated_by           BIGINT       NOT NULL DEFAULT 0,
+  updated_at           DATETIME     NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT INTO service_status (id, maintenance, maintenance_message, updated_by, updated_at)
+VALUES (1, FALSE, '', 0, NOW());
+
+-- お知らせ。starts_at <= 現在 < ends_at の間だけ表示する。severity: 'info' / 'warning' / 'critical'
+CREATE TABLE announcements (
+  id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+  message     VARCHAR(500) NOT NULL,
+  severity    VARCHAR(16)  NOT NULL DEFAULT 'info',
+  starts_at   DATETIME     NOT NULL,
+  ends_at     DATETIME     NOT NULL,
+  created_by  BIGINT       NOT NULL DEFAULT 0,
+  created_at  DATETIME     NOT NULL,
+  KEY idx_announcements_window (ends_at, starts_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,3 @@
+-- 文言の言語（'ja' / 'en'）。空は未設定で、端末の Accept-Language に従う
+ALTER TABLE settings
+  ADD COLUMN language VARCHAR(8) NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN name_changed_at;
+
+ALTER TABLE settings
+  DROP COLUMN card_back,
+  DROP COLUMN vibration;
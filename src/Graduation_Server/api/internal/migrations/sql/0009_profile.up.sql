@@ -0,0 +1,8 @@
+-- 振動・カードの裏面の設定
+ALTER TABLE settings
+  ADD COLUMN vibration BOOLEAN     NOT NULL DEFAULT TRUE,
+  ADD COLUMN card_back VARCHAR(64) NOT NULL DEFAULT 'default_back';
+
+-- 最後に名前を変えた日時（変更の間隔を空けるため。NULL は未変更）
+ALTER TABLE users
+  ADD COLUMN name_changed_at DATETIME NULL;
@@ -0,0 +1,18 @@
+-- ラウンドごと・参加者ごとの結果（戦績の集計に使う）
+CREATE TABLE round_results (
+  id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+  user_id     BIGINT     NOT NULL,
+  mode_id     INT        NOT NULL,
+  game_id     BIGINT     NULL,                -- ルームのゲーム（games.id）。ソロは NULL
+  round_no    INT        NOT NULL DEFAULT 0,  -- ゲームの中で何ラウンド目か（1〜）。ソロは 0
+  role        VARCHAR(8) NOT NULL,            -- 'player' / 'dealer'
+  result      VARCHAR(8) NOT NULL,            -- 'win' / 'lose' / 'push'
+  bet         BIGINT     NOT NULL,
+  payout      BIGINT     NOT NULL,            -- 戻ってきたチップ（ベット分を含む）
+  net         BIGINT     NOT NULL,            -- 収支（ディーラーは卓全体の精算の合計）
+  blackjack   BOOLEAN    NOT NULL DEFAULT FALSE,
+  bust        BOOLEAN    NOT NULL DEFAULT FALSE,
+  created_at  DATETIME   NOT NULL,
+  KEY idx_round_results_user (user_id, id),
+  KEY idx_round_results_game (game_id, round_no)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
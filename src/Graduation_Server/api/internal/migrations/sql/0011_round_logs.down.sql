@@ -0,0 +1,5 @@
+ALTER TABLE round_results
+  DROP KEY idx_round_results_log,
+  DROP COLUMN round_log_id;
+
+DROP TABLE IF EXISTS round_logs;
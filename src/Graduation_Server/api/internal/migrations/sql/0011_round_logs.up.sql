@@ -0,0 +1,19 @@
+-- ラウンドの経過（リプレイ・問い合わせ対応用）
+-- events は models.RoundEvent の配列を JSON にしたもの。参加者は round_results.round_log_id から引く。
+CREATE TABLE round_logs (
+  id               BIGINT AUTO_INCREMENT PRIMARY KEY,
+  mode_id          INT          NOT NULL,
+  game_id          BIGINT       NULL,                -- ルームのゲーム（games.id）。ソロは NULL
+  round_no         INT          NOT NULL DEFAULT 0,
+  room_code        VARCHAR(16)  NOT NULL DEFAULT '',
+  seed_commitment  VARCHAR(64)  NOT NULL DEFAULT '', -- 配る前に送られたシードの SHA-256（16進）
+  seed             VARCHAR(128) NOT NULL DEFAULT '', -- 精算時に明かされたシード
+  events           MEDIUMTEXT   NOT NULL,
+  started_at       DATETIME(3)  NOT NULL,
+  settled_at       DATETIME(3)  NOT NULL,
+  KEY idx_round_logs_game (game_id, round_no)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE round_results
+  ADD COLUMN round_log_id BIGINT NULL,
+  ADD KEY idx_round_results_log (round_log_id);
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 引き継ぎコードのテーブル定義は migrations/sql/0004_account_codes.up.sql
+//
+// コードも平文ではなく SHA-256 のハッシュだけを保存する。
+
+// ゲストアカウントを通常アカウントにする（パスワード設定＋必要なら名前変更）。
+// 既にパスワードが設定されていた場合は false を返す（同時に2回呼ばれても1回だけ成功）。
+// 名前が他のユーザーに使われていれば ErrNameTaken。
+func UpgradeGuestAccount(db DB, userID int64, name, hashedPassword string) (bool, error) {
+	res, err := db.Exec(`
+		UPDATE users
+		   SET name = ?, password = ?
+		 WHERE id = ? AND password = ''`,
+		name, hashedPassword, userID)
+	var me *mysql.MySQLError
+	if errors.As(err, &me) && me.Number == 1062 { // ER_DUP_ENTRY（uq_users_name）
+		return false, ErrNameTaken
+	}
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// 引き継ぎコードを登録する。未使用の古いコードは無効にして、有効なのは常に最新の1つだけ。
+func CreateTransferCode(db DB, userID int64, codeHash string, expiresAt time.Time) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	if _, err := tx.Exec(`UPDATE transfer_codes SET used_at = ? WHERE user_id = ? AND used_at IS NULL`, now, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO transfer_codes (user_id, code_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?)`,
+		userID, codeHash, expiresAt, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// 引き継ぎコードを使用済みにして、紐づく userID を返す。
+// 存在しない・期限切れ・使用済みの場合は sql.ErrNoRows。
+func RedeemTransferCode(db DB, codeHash string) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	var id, userID int64
+	err = tx.QueryRow(`
+		SELECT id, user_id
+		  FROM transfer_codes
+		 WHERE code_hash = ? AND used_at IS NULL AND expires_at > ?
+		 FOR UPDATE`,
+		codeHash, now,
+	).Scan(&id, &userID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE transfer_codes SET used_at = ? WHERE id = ?`, now, id); err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
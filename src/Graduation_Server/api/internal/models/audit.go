@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// テーブル定義は migrations/sql/0006_admin.up.sql
+
+// admin_audit_log の1行。AdminID が 0 ならコマンドラインからの操作
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	AdminID   int64           `json:"admin_id"`
+	Action    string          `json:"action"` // 例: room.close / user.ban / chips.adjust
+	Target    string          `json:"target"` // 対象（ルームコード・ユーザーIDなど）
+	Detail    json.RawMessage `json:"detail"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func InsertAuditEntry(db DB, e AuditEntry) error {
+	detail := e.Detail
+	if len(detail) == 0 {
+		detail = json.RawMessage("{}")
+	}
+	_, err := db.Exec(`
+		INSERT INTO admin_audit_log (admin_id, action, target, detail, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		e.AdminID, e.Action, e.Target, string(detail), e.CreatedAt)
+	return err
+}
+
+// 新しい順に limit 件（beforeID > 0 ならそれより古いもの）
+func ListAuditEntries(db DB, beforeID int64, limit int) ([]AuditEntry, error) {
+	rows, err := db.Query(`
+		SELECT id, admin_id, action, target, detail, created_at
+		  FROM admin_audit_log
+		 WHERE ? = 0 OR id < ?
+		 ORDER BY id DESC
+		 LIMIT ?`, beforeID, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var detail string
+		if err := rows.Scan(&e.ID, &e.AdminID, &e.Action, &e.Target, &detail, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		e.Detail = json.RawMessage(detail)
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
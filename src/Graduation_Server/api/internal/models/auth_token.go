@@ -0,0 +1,147 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// リフレッシュトークン・失効管理のテーブル定義は migrations/sql/0003_auth_tokens.up.sql
+//
+// refresh_tokens には平文ではなく SHA-256 のハッシュだけを保存する。
+// family_id はログイン1回ごとに振られ、ローテーションしても引き継がれる
+// （使用済みトークンが再利用されたら family ごと失効させる）。
+
+// refresh_tokens の1行
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	FamilyID  string
+	ExpiresAt time.Time
+	RevokedAt sql.NullTime
+}
+
+// リフレッシュトークンを保存
+func CreateRefreshToken(db DB, userID int64, tokenHash, familyID string, expiresAt time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		userID, tokenHash, familyID, expiresAt, time.Now())
+	return err
+}
+
+// ハッシュからリフレッシュトークンを取得（無ければ sql.ErrNoRows）
+func GetRefreshToken(db DB, tokenHash string) (*RefreshToken, error) {
+	var t RefreshToken
+	err := db.QueryRow(`
+		SELECT id, user_id, family_id, expires_at, revoked_at
+		  FROM refresh_tokens
+		 WHERE token_hash = ?`,
+		tokenHash,
+	).Scan(&t.ID, &t.UserID, &t.FamilyID, &t.ExpiresAt, &t.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// 古いトークンを失効させ、同じ family で新しいトークンを登録する。
+// 同じトークンで同時にリフレッシュされた場合は片方だけ成功し、もう片方は false を返す。
+func RotateRefreshToken(db DB, old *RefreshToken, newHash string, expiresAt time.Time) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	res, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, now, old.ID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		old.UserID, newHash, old.FamilyID, expiresAt, now); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// family（1回のログインから派生したトークン全部）を失効
+func RevokeRefreshTokenFamily(db DB, familyID string) error {
+	_, err := db.Exec(`UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL`, time.Now(), familyID)
+	return err
+}
+
+// ユーザーの全リフレッシュトークンを失効させ、token_version を上げる。
+// token_version が変わると、発行済みのアクセストークンもミドルウェアで弾かれる。
+func RevokeAllUserTokens(db DB, userID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL`, time.Now(), userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE users SET token_version = token_version + 1 WHERE id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// アクセストークン（jti）を有効期限まで失効リストに載せる
+func RevokeAccessToken(db DB, jti string, userID int64, expiresAt time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO revoked_tokens (jti, user_id, expires_at)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE expires_at = VALUES(expires_at)`,
+		jti, userID, expiresAt)
+	return err
+}
+
+// ユーザーの現在の token_version と、jti が失効済みかどうかを1回で取得
+func GetTokenState(db DB, userID int64, jti string) (version int, revoked bool, err error) {
+	err = db.QueryRow(`
+		SELECT u.token_version,
+		       EXISTS(SELECT 1 FROM revoked_tokens rt WHERE rt.jti = ?)
+		  FROM users u
+		 WHERE u.id = ?`,
+		jti, userID,
+	).Scan(&version, &revoked)
+	return version, revoked, err
+}
+
+// トークン発行時に見るユーザーの情報
+type TokenSubject struct {
+	Name    string
+	Version int    // token_version
+	Role    string // role クレームに入れる
+	Banned  bool   // BAN 中は発行しない
+}
+
+// 発行時に埋め込む名前・token_version・ロールを取得
+func GetUserTokenInfo(db DB, userID int64) (*TokenSubject, error) {
+	var s TokenSubject
+	err := db.QueryRow(`SELECT name, token_version, role, banned_at IS NOT NULL FROM users WHERE id = ?`, userID).
+		Scan(&s.Name, &s.Version, &s.Role, &s.Banned)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// 期限切れのトークン行を掃除
+func PurgeExpiredTokens(db DB) error {
+	now := time.Now()
+	if _, err := db.Exec(`DELETE FROM refresh_tokens WHERE expires_at < ?`, now); err != nil {
+		return err
+	}
+	_, err := db.Exec(`DELETE FROM revoked_tokens WHERE expires_at < ?`, now)
+	return err
+}
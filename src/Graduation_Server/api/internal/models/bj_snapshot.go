@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// テーブル定義は migrations/sql/0005_bj_room_snapshots.up.sql
+
+// bj_room_snapshots の1行（State は JSON のまま扱う）
+type BJSnapshot struct {
+	RoomCode  string
+	Seq       int64 // 卓ごとの通し番号。古い書き込みが新しい状態を上書きしないように使う
+	State     []byte
+	UpdatedAt time.Time
+}
+
+// スナップショットを保存（既存行より seq が古ければ何もしない）
+func SaveBJSnapshot(db DB, s BJSnapshot) error {
+	_, err := db.Exec(`
+		INSERT INTO bj_room_snapshots (room_code, seq, state, updated_at)
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		  state      = IF(VALUES(seq) > seq, VALUES(state), state),
+		  updated_at = IF(VALUES(seq) > seq, VALUES(updated_at), updated_at),
+		  seq        = GREATEST(seq, VALUES(seq))`,
+		s.RoomCode, s.Seq, s.State, s.UpdatedAt)
+	return err
+}
+
+func DeleteBJSnapshot(db DB, roomCode string) error {
+	_, err := db.Exec(`DELETE FROM bj_room_snapshots WHERE room_code = ?`, roomCode)
+	return err
+}
+
+// 1ルーム分（無ければ sql.ErrNoRows）
+func GetBJSnapshot(db DB, roomCode string) (*BJSnapshot, error) {
+	var s BJSnapshot
+	err := db.QueryRow(`SELECT room_code, seq, state, updated_at FROM bj_room_snapshots WHERE room_code = ?`, roomCode).
+		Scan(&s.RoomCode, &s.Seq, &s.State, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// 全スナップショット（起動時の復元用）
+func ListBJSnapshots(db DB) ([]BJSnapshot, error) {
+	rows, err := db.Query(`SELECT room_code, seq, state, updated_at FROM bj_room_snapshots ORDER BY room_code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []BJSnapshot
+	for rows.Next() {
+		var s BJSnapshot
+		if err := rows.Scan(&s.RoomCode, &s.Seq, &s.State, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
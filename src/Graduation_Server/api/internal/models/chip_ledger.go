@@ -0,0 +1,107 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// テーブル定義は migrations/sql/0006_admin.up.sql
+//
+// 運営によるチップの手動調整は tips を直接書き換えず、必ず chip_ledger に1行残す。
+
+// chip_ledger.wallet の値（tips のどちらの列か）
+const (
+	WalletSolo  = "solo"
+	WalletMulti = "multi"
+)
+
+// ErrInsufficientChips は調整後の残高がマイナスになる場合のエラー
+var ErrInsufficientChips = errors.New("chip balance would become negative")
+
+// chip_ledger の1行
+type ChipLedgerEntry struct {
+	ID           int64     `json:"id"`
+	UserID       int64     `json:"user_id"`
+	Wallet       string    `json:"wallet"`
+	Delta        int       `json:"delta"`
+	BalanceAfter int       `json:"balance_after"`
+	Reason       string    `json:"reason"`
+	AdminID      int64     `json:"admin_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// tips の列名（wallet はハンドラで検証済みだが、SQL に埋め込むので念のためここでも絞る）
+func walletColumn(wallet string) (string, bool) {
+	switch wallet {
+	case WalletSolo:
+		return "solo_tip_count", true
+	case WalletMulti:
+		return "multi_tip_count", true
+	}
+	return "", false
+}
+
+// AdjustChips は e.Wallet の残高を e.Delta だけ増減し、台帳に記録した行を返す（1トランザクション）。
+// tips の行が無ければ sql.ErrNoRows、残高が足りなければ ErrInsufficientChips。
+func AdjustChips(db DB, e ChipLedgerEntry) (*ChipLedgerEntry, error) {
+	col, ok := walletColumn(e.Wallet)
+	if !ok {
+		return nil, errors.New("unknown wallet: " + e.Wallet)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var balance int
+	if err := tx.QueryRow(`SELECT `+col+` FROM tips WHERE user_id = ? FOR UPDATE`, e.UserID).Scan(&balance); err != nil {
+		return nil, err
+	}
+	balance += e.Delta
+	if balance < 0 {
+		return nil, ErrInsufficientChips
+	}
+	if _, err := tx.Exec(`UPDATE tips SET `+col+` = ?, updated_at = ? WHERE user_id = ?`, balance, e.CreatedAt, e.UserID); err != nil {
+		return nil, err
+	}
+	res, err := tx.Exec(`
+		INSERT INTO chip_ledger (user_id, wallet, delta, balance_after, reason, admin_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		e.UserID, e.Wallet, e.Delta, balance, e.Reason, e.AdminID, e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if e.ID, err = res.LastInsertId(); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	e.BalanceAfter = balance
+	return &e, nil
+}
+
+// ユーザーの台帳（新しい順に limit 件）
+func ListChipLedger(db DB, userID int64, limit int) ([]ChipLedgerEntry, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, wallet, delta, balance_after, reason, admin_id, created_at
+		  FROM chip_ledger
+		 WHERE user_id = ?
+		 ORDER BY id DESC
+		 LIMIT ?`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []ChipLedgerEntry
+	for rows.Next() {
+		var e ChipLedgerEntry
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Wallet, &e.Delta, &e.BalanceAfter, &e.Reason, &e.AdminID, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DB は各関数が使う *sql.DB のメソッド。
+// WithContext で包むとリクエストの context（キャンセル・トレースのスパン）が SQL まで届く。
+type DB interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	Begin() (*Tx, error)
+}
+
+// WithContext は db の呼び出しをすべて ctx 付き（ExecContext など）にする
+func WithContext(db *sql.DB, ctx context.Context) DB {
+	return ctxDB{db: db, ctx: ctx}
+}
+
+type ctxDB struct {
+	db  *sql.DB
+	ctx context.Context
+}
+
+func (c ctxDB) Exec(query string, args ...any) (sql.Result, error) {
+	return c.db.ExecContext(c.ctx, query, args...)
+}
+
+func (c ctxDB) Query(query string, args ...any) (*sql.Rows, error) {
+	return c.db.QueryContext(c.ctx, query, args...)
+}
+
+func (c ctxDB) QueryRow(query string, args ...any) *sql.Row {
+	return c.db.QueryRowContext(c.ctx, query, args...)
+}
+
+func (c ctxDB) Begin() (*Tx, error) {
+	tx, err := c.db.BeginTx(c.ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, ctx: c.ctx}, nil
+}
+
+// Tx は Begin が返すトランザクション。
+// *sql.Tx の Exec などは context を取らないので、Begin に渡した context で実行するようにする
+// （トランザクション中の文もキャンセルされ、リクエストのスパンの下に SQL スパンが付く）。
+type Tx struct {
+	*sql.Tx
+	ctx context.Context
+}
+
+func (t *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return t.Tx.ExecContext(t.ctx, query, args...)
+}
+
+func (t *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return t.Tx.QueryContext(t.ctx, query, args...)
+}
+
+func (t *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return t.Tx.QueryRowContext(t.ctx, query, args...)
+}
@@ -0,0 +1,34 @@
+package models
+
+// game_types.name を取得（無ければ sql.ErrNoRows）
+func GetGameTypeName(db DB, gameTypeID int) (string, error) {
+	var name string
+	err := db.QueryRow(`SELECT name FROM game_types WHERE id = ?`, gameTypeID).Scan(&name)
+	return name, err
+}
+
+// ゲームを作成し、ルームを playing にする（1トランザクション）。games.id を返す。
+func StartGame(db DB, room *Room, modeID int) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.Exec(`
+		INSERT INTO games (mode_id, type_id, created_at, updated_at)
+		VALUES (?, ?, NOW(), NOW())`,
+		modeID, room.GameTypeID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	gameID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`UPDATE rooms SET status='playing' WHERE id=?`, room.ID); err != nil {
+		return 0, err
+	}
+	return gameID, tx.Commit()
+}
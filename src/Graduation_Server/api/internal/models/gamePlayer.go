@@ -1,9 +1,5 @@
 package models
 
-import (
-	"database/sql"
-)
-
 type PlayerGameInfo struct {
 	UserID   int64  `json:"user_id"`
 	UserName string `json:"user_name"`
@@ -13,7 +9,7 @@ type PlayerGameInfo struct {
 }
 
 // room_idに紐づくゲーム用プレイヤー情報を取得
-func GetPlayersForGame(db *sql.DB, roomID int64) ([]PlayerGameInfo, error) {
+func GetPlayersForGame(db DB, roomID int64) ([]PlayerGameInfo, error) {
 	rows, err := db.Query(`
         SELECT u.id, u.name, p.chip, p.bet, p.is_dealer
         FROM players p
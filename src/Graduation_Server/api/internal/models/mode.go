@@ -0,0 +1,87 @@
+package models
+
+import "database/sql"
+
+// modes / types（テーブル定義は migrations/sql/0001_initial_schema.up.sql、初期データは 0002）
+
+// modes.id（0002 の初期データと同じ）
+const (
+	ModeSolo   = 1
+	ModeMulti  = 2
+	ModeRank   = 3
+	ModeFriend = 4
+)
+
+// modes の1行
+type Mode struct {
+	ID      int    `json:"id"`
+	Mode    string `json:"mode"`
+	CanPlay bool   `json:"is_can_play"`
+}
+
+// types の1行（モードごとに遊べるゲーム）
+type ModeGameType struct {
+	ID         int    `json:"id"`
+	ModeID     int    `json:"mode_id"`
+	GameTypeID int    `json:"game_type_id"`
+	Name       string `json:"name"`
+	CanPlay    bool   `json:"is_can_play"`
+}
+
+func ListModes(db DB) ([]Mode, error) {
+	rows, err := db.Query(`SELECT id, mode, is_can_play FROM modes ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Mode
+	for rows.Next() {
+		var m Mode
+		if err := rows.Scan(&m.ID, &m.Mode, &m.CanPlay); err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+func ListModeGameTypes(db DB) ([]ModeGameType, error) {
+	rows, err := db.Query(`SELECT id, mode_id, game_type_id, name, is_can_play FROM types ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []ModeGameType
+	for rows.Next() {
+		var t ModeGameType
+		if err := rows.Scan(&t.ID, &t.ModeID, &t.GameTypeID, &t.Name, &t.CanPlay); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// modes.is_can_play を切り替える（無ければ sql.ErrNoRows）
+func SetModePlayable(db DB, modeID int, canPlay bool) error {
+	return setPlayable(db, `modes`, modeID, canPlay)
+}
+
+// types.is_can_play を切り替える（無ければ sql.ErrNoRows）
+func SetModeGameTypePlayable(db DB, typeID int, canPlay bool) error {
+	return setPlayable(db, `types`, typeID, canPlay)
+}
+
+func setPlayable(db DB, table string, id int, canPlay bool) error {
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM `+table+` WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	_, err := db.Exec(`UPDATE `+table+` SET is_can_play = ? WHERE id = ?`, canPlay, id)
+	return err
+}
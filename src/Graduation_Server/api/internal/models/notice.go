@@ -0,0 +1,125 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// service_status / announcements（テーブル定義は migrations/sql/0007_notices.up.sql）
+
+// お知らせの重要度
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// メンテナンス状態（service_status の1行）
+type Maintenance struct {
+	Enabled   bool      `json:"enabled"`
+	Message   string    `json:"message"`
+	UpdatedBy int64     `json:"updated_by"` // 0 はコマンドライン・初期値
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// announcements の1行
+type Announcement struct {
+	ID        int64     `json:"id"`
+	Message   string    `json:"message"`
+	Severity  string    `json:"severity"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	CreatedBy int64     `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 行が無い（マイグレーション直後に消された等）場合は無効として扱う
+func GetMaintenance(db DB) (*Maintenance, error) {
+	var m Maintenance
+	err := db.QueryRow(`
+		SELECT maintenance, maintenance_message, updated_by, updated_at
+		  FROM service_status WHERE id = 1`).Scan(&m.Enabled, &m.Message, &m.UpdatedBy, &m.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return &Maintenance{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func SetMaintenance(db DB, m Maintenance) error {
+	_, err := db.Exec(`
+		INSERT INTO service_status (id, maintenance, maintenance_message, updated_by, updated_at)
+		VALUES (1, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		  maintenance = VALUES(maintenance),
+		  maintenance_message = VALUES(maintenance_message),
+		  updated_by = VALUES(updated_by),
+		  updated_at = VALUES(updated_at)`,
+		m.Enabled, m.Message, m.UpdatedBy, m.UpdatedAt)
+	return err
+}
+
+func CreateAnnouncement(db DB, a Announcement) (int64, error) {
+	res, err := db.Exec(`
+		INSERT INTO announcements (message, severity, starts_at, ends_at, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		a.Message, a.Severity, a.StartsAt, a.EndsAt, a.CreatedBy, a.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// now の時点で表示期間中のもの（開始が新しい順）
+func ListActiveAnnouncements(db DB, now time.Time) ([]Announcement, error) {
+	return queryAnnouncements(db, `
+		SELECT id, message, severity, starts_at, ends_at, created_by, created_at
+		  FROM announcements
+		 WHERE starts_at <= ? AND ends_at > ?
+		 ORDER BY starts_at DESC, id DESC`, now, now)
+}
+
+// まだ終わっていないもの（開始前の予約も含む。開始が早い順）
+func ListPendingAnnouncements(db DB, now time.Time) ([]Announcement, error) {
+	return queryAnnouncements(db, `
+		SELECT id, message, severity, starts_at, ends_at, created_by, created_at
+		  FROM announcements
+		 WHERE ends_at > ?
+		 ORDER BY starts_at, id`, now)
+}
+
+// 無ければ sql.ErrNoRows
+func DeleteAnnouncement(db DB, id int64) error {
+	res, err := db.Exec(`DELETE FROM announcements WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func queryAnnouncements(db DB, query string, args ...any) ([]Announcement, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Announcement
+	for rows.Next() {
+		var a Announcement
+		if err := rows.Scan(&a.ID, &a.Message, &a.Severity, &a.StartsAt, &a.EndsAt, &a.CreatedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+)
+
+// パスワード再設定コードのテーブル定義は migrations/sql/0004_account_codes.up.sql
+
+// 再設定コードの種類
+const (
+	ResetCodeRecovery = "recovery"
+	ResetCodeAdmin    = "admin"
+)
+
+// ログイン照合用：ユーザーIDとパスワードハッシュ（ゲストは空文字）
+func GetUserCredentials(db DB, name string) (userID int64, hashed string, err error) {
+	err = db.QueryRow(`SELECT id, password FROM users WHERE name = ?`, name).Scan(&userID, &hashed)
+	return userID, hashed, err
+}
+
+// パスワードを更新
+func UpdatePassword(db DB, userID int64, hashedPassword string) error {
+	_, err := db.Exec(`UPDATE users SET password = ? WHERE id = ?`, hashedPassword, userID)
+	return err
+}
+
+// リカバリーコードを作り直す（未使用の古いリカバリーコードは破棄）
+func ReplaceRecoveryCodes(db DB, userID int64, codeHashes []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM password_reset_codes WHERE user_id = ? AND kind = ? AND used_at IS NULL`, userID, ResetCodeRecovery); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, h := range codeHashes {
+		if _, err := tx.Exec(`
+			INSERT INTO password_reset_codes (user_id, code_hash, kind, expires_at, created_at)
+			VALUES (?, ?, ?, NULL, ?)`,
+			userID, h, ResetCodeRecovery, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// 運営が発行する一回限りの再設定コード
+func CreateAdminResetCode(db DB, userID int64, codeHash string, expiresAt time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO password_reset_codes (user_id, code_hash, kind, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		userID, codeHash, ResetCodeAdmin, expiresAt, time.Now())
+	return err
+}
+
+// 再設定コードを使ってパスワードを更新する（コード消費とパスワード更新は同じTx）。
+// コードが無い・使用済み・期限切れなら false。
+func ResetPasswordWithCode(db DB, userID int64, codeHash, hashedPassword string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	res, err := tx.Exec(`
+		UPDATE password_reset_codes
+		   SET used_at = ?
+		 WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+		   AND (expires_at IS NULL OR expires_at > ?)
+		 LIMIT 1`,
+		now, userID, codeHash, now)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if _, err := tx.Exec(`UPDATE users SET password = ? WHERE id = ?`, hashedPassword, userID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
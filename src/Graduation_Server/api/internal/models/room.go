@@ -1,6 +1,8 @@
 package models
 
-import "database/sql"
+import (
+	"time"
+)
 
 type Room struct {
 	ID         int64
@@ -19,9 +21,10 @@ type RoomUser struct {
 	UserName string `json:"user_name"`
 	IsReady  bool   `json:"is_ready"`
 	IsHost   bool   `json:"is_host"`
+	SeatNo   int    `json:"seat_no"` // room_users.seat_no（1始まり。席順＝手番・ディーラー順）
 }
 
-func CreateRoom(db *sql.DB, roomCode string, gameTypeID, maxPlayers int) (int64, error) {
+func CreateRoom(db DB, roomCode string, gameTypeID, maxPlayers int) (int64, error) {
 	res, err := db.Exec(`INSERT INTO rooms (room_code, game_type_id, status, max_players) VALUES (?, ?, 'waiting', ?)`,
 		roomCode, gameTypeID, maxPlayers)
 	if err != nil {
@@ -30,7 +33,7 @@ func CreateRoom(db *sql.DB, roomCode string, gameTypeID, maxPlayers int) (int64,
 	return res.LastInsertId()
 }
 
-func GetRoomByCode(db *sql.DB, roomCode string) (*Room, error) {
+func GetRoomByCode(db DB, roomCode string) (*Room, error) {
 	var r Room
 	err := db.QueryRow(`
 		SELECT id, room_code, game_type_id, status, max_players, created_at, owner_id
@@ -44,17 +47,37 @@ func GetRoomByCode(db *sql.DB, roomCode string) (*Room, error) {
 	return &r, nil
 }
 
-func UpdateRoomStatus(db *sql.DB, roomID int64, status string) error {
+func UpdateRoomStatus(db DB, roomID int64, status string) error {
 	_, err := db.Exec(`UPDATE rooms SET status = ? WHERE id = ?`, status, roomID)
 	return err
 }
 
+// 状態ごとのルーム数（メトリクス用）
+func CountRoomsByStatus(db DB) (map[string]int, error) {
+	rows, err := db.Query(`SELECT status, COUNT(*) FROM rooms GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
 type CreateRoomRequest struct {
 	GameTypeID int `json:"game_type_id"`
 	MaxPlayers int `json:"max_players"`
 }
 
-func GetUsersInRoom(db *sql.DB, roomID int64) ([]RoomUser, error) {
+func GetUsersInRoom(db DB, roomID int64) ([]RoomUser, error) {
 	rows, err := db.Query(`
 SELECT 
     ru.id,                  -- room_users.id（内部ID）
@@ -62,12 +85,13 @@ SELECT
     u.id AS user_id,        -- ← ここを追加（users.id）
     u.name AS user_name,
     ru.is_ready,
+    COALESCE(ru.seat_no, 0) AS seat_no,
     CASE WHEN ru.user_id = r.owner_id THEN 1 ELSE 0 END AS is_host
 FROM room_users ru
 INNER JOIN users u ON ru.user_id = u.id
 INNER JOIN rooms r ON ru.room_id = r.id
 WHERE ru.room_id = ?
-ORDER BY is_host DESC, ru.id ASC
+ORDER BY ru.seat_no IS NULL, ru.seat_no ASC, ru.id ASC
 
     `, roomID)
 	if err != nil {
@@ -79,7 +103,7 @@ ORDER BY is_host DESC, ru.id ASC
 	for rows.Next() {
 		var u RoomUser
 		var isHostInt int
-		if err := rows.Scan(&u.ID, &u.RoomID, &u.UserID, &u.UserName, &u.IsReady, &isHostInt); err != nil {
+		if err := rows.Scan(&u.ID, &u.RoomID, &u.UserID, &u.UserName, &u.IsReady, &u.SeatNo, &isHostInt); err != nil {
 			return nil, err
 		}
 		u.IsHost = isHostInt == 1
@@ -87,3 +111,74 @@ ORDER BY is_host DESC, ru.id ASC
 	}
 	return users, nil
 }
+
+// ルームを作成し、作成者をホスト（席 HostSeatNo・未準備）として登録する（1トランザクション）。
+func CreateRoomWithHost(db DB, roomCode string, gameTypeID, maxPlayers int, ownerID int64, now time.Time) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.Exec(
+		"INSERT INTO rooms (room_code, game_type_id, max_players, owner_id, status, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		roomCode, gameTypeID, maxPlayers, ownerID, "waiting", now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	roomID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := AddUserToRoomAsHost(tx, roomID, ownerID, false /*is_ready*/); err != nil {
+		return 0, err
+	}
+	return roomID, tx.Commit()
+}
+
+// 終わっていないルーム（waiting / playing）と参加人数
+type OpenRoom struct {
+	Room
+	Players int
+}
+
+func ListOpenRooms(db DB) ([]OpenRoom, error) {
+	rows, err := db.Query(`
+		SELECT r.id, r.room_code, r.game_type_id, r.status, r.max_players, r.created_at, r.owner_id,
+		       (SELECT COUNT(*) FROM room_users ru WHERE ru.room_id = r.id)
+		  FROM rooms r
+		 WHERE r.status <> 'closed'
+		 ORDER BY r.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []OpenRoom
+	for rows.Next() {
+		var o OpenRoom
+		if err := rows.Scan(&o.ID, &o.RoomCode, &o.GameTypeID, &o.Status, &o.MaxPlayers, &o.CreatedAt, &o.OwnerID, &o.Players); err != nil {
+			return nil, err
+		}
+		list = append(list, o)
+	}
+	return list, rows.Err()
+}
+
+// 運営がルームを閉じる：参加者を全員外して status='closed' にする
+func CloseRoom(db DB, roomID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM room_users WHERE room_id = ?`, roomID); err != nil {
+		return err
+	}
+	if err := UpdateRoomStatusTx(tx, roomID, "closed"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
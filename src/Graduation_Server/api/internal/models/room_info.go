@@ -12,14 +12,15 @@ type PlayerInfo struct {
 	IsHost  bool   `json:"is_host"`
 }
 
-func GetPlayersByRoomID(db *sql.DB, roomID int64) ([]PlayerInfo, error) {
+func GetPlayersByRoomID(db DB, roomID int64) ([]PlayerInfo, error) {
 	rows, err := db.Query(`
         SELECT u.name, ru.is_ready, ru.user_id, 
                CASE WHEN ru.user_id = r.owner_id THEN 1 ELSE 0 END AS is_host
         FROM room_users ru
         INNER JOIN users u ON ru.user_id = u.id
         INNER JOIN rooms r ON ru.room_id = r.id
-        WHERE ru.room_id = ?`, roomID)
+        WHERE ru.room_id = ?
+        ORDER BY ru.seat_no IS NULL, ru.seat_no ASC, ru.id ASC`, roomID)
 	if err != nil {
 		return nil, err
 	}
@@ -41,7 +42,7 @@ func GetPlayersByRoomID(db *sql.DB, roomID int64) ([]PlayerInfo, error) {
 	return players, nil
 }
 
-func GetGameNameByTypeID(db *sql.DB, gameTypeID int) (string, error) {
+func GetGameNameByTypeID(db DB, gameTypeID int) (string, error) {
 	var name string
 	err := db.QueryRow("SELECT name FROM game_types WHERE id = ?", gameTypeID).Scan(&name)
 	if err != nil {
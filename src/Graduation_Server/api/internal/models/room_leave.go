@@ -1,27 +1,30 @@
 package models
 
-import "database/sql"
+import (
+	"database/sql"
+	"errors"
+)
 
 // room_users から room_id ごとの人数を数える
-func CountUsersInRoomTx(tx *sql.Tx, roomID int64) (int, error) {
+func CountUsersInRoomTx(tx *Tx, roomID int64) (int, error) {
 	var cnt int
 	err := tx.QueryRow(`SELECT COUNT(*) FROM room_users WHERE room_id = ?`, roomID).Scan(&cnt)
 	return cnt, err
 }
 
 // LeaveRoomHandler で、残り 0 人になったとき status='closed' にするのに使用。
-func UpdateRoomStatusTx(tx *sql.Tx, roomID int64, status string) error {
+func UpdateRoomStatusTx(tx *Tx, roomID int64, status string) error {
 	_, err := tx.Exec(`UPDATE rooms SET status = ? WHERE id = ?`, status, roomID)
 	return err
 }
 
-// MIN(ru.id) のユーザーを次のオーナーに採用（別基準が良ければ変更してOK）
-func PickNextOwnerTx(tx *sql.Tx, roomID int64) (userID int64, found bool, err error) {
+// 席番号が一番小さいユーザーを次のオーナーに採用（席未割当は後回し）
+func PickNextOwnerTx(tx *Tx, roomID int64) (userID int64, found bool, err error) {
 	err = tx.QueryRow(`
 		SELECT ru.user_id
 		  FROM room_users ru
 		 WHERE ru.room_id = ?
-		 ORDER BY ru.id ASC
+		 ORDER BY ru.seat_no IS NULL, ru.seat_no ASC, ru.id ASC
 		 LIMIT 1
 	`, roomID).Scan(&userID)
 	if err == sql.ErrNoRows {
@@ -34,16 +37,70 @@ func PickNextOwnerTx(tx *sql.Tx, roomID int64) (userID int64, found bool, err er
 }
 
 // rooms.owner_id = newOwnerID に更新するだけの関数。
-func SetRoomOwnerTx(tx *sql.Tx, roomID, newOwnerID int64) error {
+func SetRoomOwnerTx(tx *Tx, roomID, newOwnerID int64) error {
 	_, err := tx.Exec(`UPDATE rooms SET owner_id = ? WHERE id = ?`, newOwnerID, roomID)
 	return err
 }
 
 // 退出（行削除）。戻り値は削除件数。
-func RemoveUserFromRoomTx(tx *sql.Tx, roomID, userID int64) (int64, error) {
+func RemoveUserFromRoomTx(tx *Tx, roomID, userID int64) (int64, error) {
 	res, err := tx.Exec(`DELETE FROM room_users WHERE room_id = ? AND user_id = ?`, roomID, userID)
 	if err != nil {
 		return 0, err
 	}
 	return res.RowsAffected()
 }
+
+// ErrNotInRoom はルームに参加していないユーザーを退出させようとした場合のエラー
+var ErrNotInRoom = errors.New("user is not in room")
+
+// 退出処理の結果
+type LeaveResult struct {
+	HostChanged    bool
+	NewHostUserID  int64
+	RoomBecameZero bool
+}
+
+// 退出・ホスト交代・クローズ判定を1トランザクションで行う。
+// 残り 0 人なら status='closed'、ホストが抜けたら席順で次の人をホストにする。
+func LeaveRoom(db DB, room *Room, userID int64) (LeaveResult, error) {
+	var result LeaveResult
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	affected, err := RemoveUserFromRoomTx(tx, room.ID, userID)
+	if err != nil {
+		return result, err
+	}
+	if affected == 0 {
+		return result, ErrNotInRoom
+	}
+
+	leftCount, err := CountUsersInRoomTx(tx, room.ID)
+	if err != nil {
+		return result, err
+	}
+	if leftCount == 0 {
+		if err := UpdateRoomStatusTx(tx, room.ID, "closed"); err != nil {
+			return result, err
+		}
+		result.RoomBecameZero = true
+	} else if userID == room.OwnerID {
+		newOwnerID, found, err := PickNextOwnerTx(tx, room.ID)
+		if err != nil {
+			return result, err
+		}
+		if found {
+			if err := SetRoomOwnerTx(tx, room.ID, newOwnerID); err != nil {
+				return result, err
+			}
+			result.HostChanged = true
+			result.NewHostUserID = newOwnerID
+		}
+	}
+	return result, tx.Commit()
+}
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 席番号は room_users.seat_no（テーブル定義は migrations/sql/0001_initial_schema.up.sql）
+//
+// seat_no は 1 始まり。席替えの入れ替え中だけ一時的に NULL を使う
+// （MySQL の UNIQUE は NULL の重複を許すため）。
+
+// ルーム作成者が最初に座る席
+const HostSeatNo = 1
+
+var (
+	// ErrSeatTaken は指定した席に既に誰かが座っている場合のエラー
+	ErrSeatTaken = errors.New("seat already taken")
+	// ErrNoFreeSeat は空席が1つもない場合のエラー
+	ErrNoFreeSeat = errors.New("no free seat")
+)
+
+// DB と *Tx の共通部分
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// ルーム内で使用中の席番号を取得（席番号 -> userID）
+func occupiedSeats(q queryer, roomID int64) (map[int]int64, error) {
+	return scanSeats(q.Query(`
+		SELECT seat_no, user_id
+		  FROM room_users
+		 WHERE room_id = ? AND seat_no IS NOT NULL
+	`, roomID))
+}
+
+// occupiedSeats と同じだが、トランザクションの中でルームの全員の行をロックする
+// （空いている席には行が無いので、全員の行をロックして同じ席へ動く人と順番にする）
+func lockSeats(tx *Tx, roomID int64) (map[int]int64, error) {
+	return scanSeats(tx.Query(`
+		SELECT seat_no, user_id
+		  FROM room_users
+		 WHERE room_id = ? AND seat_no IS NOT NULL
+		   FOR UPDATE
+	`, roomID))
+}
+
+func scanSeats(rows *sql.Rows, err error) (map[int]int64, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seats := make(map[int]int64)
+	for rows.Next() {
+		var seatNo int
+		var userID int64
+		if err := rows.Scan(&seatNo, &userID); err != nil {
+			return nil, err
+		}
+		seats[seatNo] = userID
+	}
+	return seats, rows.Err()
+}
+
+// 1..maxPlayers のうち一番小さい空席番号を返す
+func NextFreeSeat(db DB, roomID int64, maxPlayers int) (int, error) {
+	seats, err := occupiedSeats(db, roomID)
+	if err != nil {
+		return 0, err
+	}
+	for n := 1; n <= maxPlayers; n++ {
+		if _, used := seats[n]; !used {
+			return n, nil
+		}
+	}
+	return 0, ErrNoFreeSeat
+}
+
+// ユーザーの現在の席番号（未割当なら 0）
+func GetSeatNo(db DB, roomID, userID int64) (int, error) {
+	var seatNo sql.NullInt64
+	err := db.QueryRow(`
+		SELECT seat_no FROM room_users WHERE room_id = ? AND user_id = ?
+	`, roomID, userID).Scan(&seatNo)
+	if err != nil {
+		return 0, err
+	}
+	return int(seatNo.Int64), nil
+}
+
+// 空席へ移動する。移動先が埋まっていれば ErrSeatTaken。
+func MoveUserToSeat(db DB, roomID, userID int64, seatNo int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	seats, err := lockSeats(tx, roomID)
+	if err != nil {
+		return err
+	}
+	if owner, used := seats[seatNo]; used {
+		if owner == userID {
+			return nil // 既にその席
+		}
+		return ErrSeatTaken
+	}
+
+	res, err := tx.Exec(`UPDATE room_users SET seat_no = ? WHERE room_id = ? AND user_id = ?`, seatNo, roomID, userID)
+	var me *mysql.MySQLError
+	if errors.As(err, &me) && me.Number == 1062 { // ER_DUP_ENTRY（UNIQUE(room_id, seat_no)。入室と同時に埋まった）
+		return ErrSeatTaken
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// 2人の席を入れ替える。UNIQUE(room_id, seat_no) に引っかからないよう
+// 一度 NULL に退避してから入れ替える。
+func SwapSeats(db DB, roomID, userA, userB int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var seatA, seatB sql.NullInt64
+	if err := tx.QueryRow(`SELECT seat_no FROM room_users WHERE room_id = ? AND user_id = ? FOR UPDATE`, roomID, userA).Scan(&seatA); err != nil {
+		return err
+	}
+	if err := tx.QueryRow(`SELECT seat_no FROM room_users WHERE room_id = ? AND user_id = ? FOR UPDATE`, roomID, userB).Scan(&seatB); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE room_users SET seat_no = NULL WHERE room_id = ? AND user_id = ?`, roomID, userA); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE room_users SET seat_no = ? WHERE room_id = ? AND user_id = ?`, seatA, roomID, userB); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE room_users SET seat_no = ? WHERE room_id = ? AND user_id = ?`, seatB, roomID, userA); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
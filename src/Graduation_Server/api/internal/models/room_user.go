@@ -2,46 +2,46 @@ package models
 
 import "database/sql"
 
-// ルームにユーザーを追加
-func AddUserToRoom(db *sql.DB, roomID, userID int64) error {
-	_, err := db.Exec(`INSERT INTO room_users (room_id, user_id, is_ready) VALUES (?, ?, false)`, roomID, userID)
+// ルームにユーザーを追加（seatNo は NextFreeSeat で決めた席番号）
+func AddUserToRoom(db DB, roomID, userID int64, seatNo int) error {
+	_, err := db.Exec(`INSERT INTO room_users (room_id, user_id, is_ready, seat_no) VALUES (?, ?, false, ?)`, roomID, userID, seatNo)
 	return err
 }
 
 // ルーム内の人数カウント
-func CountUsersInRoom(db *sql.DB, roomID int64) (int, error) {
+func CountUsersInRoom(db DB, roomID int64) (int, error) {
 	var count int
 	err := db.QueryRow(`SELECT COUNT(*) FROM room_users WHERE room_id = ?`, roomID).Scan(&count)
 	return count, err
 }
 
 // Ready 状態の更新
-func UpdateUserReady(db *sql.DB, roomID, userID int64, isReady bool) error {
+func UpdateUserReady(db DB, roomID, userID int64, isReady bool) error {
 	_, err := db.Exec(`UPDATE room_users SET is_ready = ? WHERE room_id = ? AND user_id = ?`, isReady, roomID, userID)
 	return err
 }
 
 // Ready な人数を数える
-func CountReadyUsers(db *sql.DB, roomID int64) (int, error) {
+func CountReadyUsers(db DB, roomID int64) (int, error) {
 	var count int
 	err := db.QueryRow(`SELECT COUNT(*) FROM room_users WHERE room_id = ? AND is_ready = true`, roomID).Scan(&count)
 	return count, err
 }
 
-// ホストとして部屋に追加
-func AddUserToRoomAsHost(tx *sql.Tx, roomID, userID int64, isReady bool) error {
-	// (room_id, user_id) に UNIQUE がある前提（無い場合は下のDDLを参照）
+// ホストとして部屋に追加（作成直後のルームなので席は HostSeatNo 固定）
+func AddUserToRoomAsHost(tx *Tx, roomID, userID int64, isReady bool) error {
+	// (room_id, user_id) に UNIQUE がある前提（migrations/sql/0001_initial_schema.up.sql の uq_room_users_room_user）
 	_, err := tx.Exec(`
-		INSERT INTO room_users (room_id, user_id, is_ready)
-		VALUES (?, ?, ?)
+		INSERT INTO room_users (room_id, user_id, is_ready, seat_no)
+		VALUES (?, ?, ?, ?)
 		ON DUPLICATE KEY UPDATE
 			is_ready = VALUES(is_ready)
-	`, roomID, userID, isReady)
+	`, roomID, userID, isReady, HostSeatNo)
 	return err
 }
 
 // ユーザーがそのルームに参加済みかどうか（room_users の存在チェック）
-func IsUserInRoom(db *sql.DB, roomID, userID int64) (bool, error) {
+func IsUserInRoom(db DB, roomID, userID int64) (bool, error) {
 	var dummy int
 	err := db.QueryRow(`
 		SELECT 1 FROM room_users WHERE room_id = ? AND user_id = ? LIMIT 1
@@ -56,7 +56,7 @@ func IsUserInRoom(db *sql.DB, roomID, userID int64) (bool, error) {
 }
 
 // 対象ユーザーがホストかどうか（rooms.owner_id で判定）
-func IsUserHostInRoom(db *sql.DB, roomID, userID int64) (bool, error) {
+func IsUserHostInRoom(db DB, roomID, userID int64) (bool, error) {
 	var dummy int
 	err := db.QueryRow(`
 		SELECT 1 FROM rooms WHERE id = ? AND owner_id = ? LIMIT 1
@@ -69,3 +69,20 @@ func IsUserHostInRoom(db *sql.DB, roomID, userID int64) (bool, error) {
 	}
 	return true, nil
 }
+
+// 一番小さい空席に座らせて参加登録し、席番号を返す。
+// 同時参加で同じ席を取り合った場合は UNIQUE 違反になるので数回やり直す。
+// 満席なら ErrNoFreeSeat。
+func JoinRoom(db DB, roomID, userID int64, maxPlayers int) (int, error) {
+	var joinErr error
+	for attempt := 0; attempt < 3; attempt++ {
+		seatNo, err := NextFreeSeat(db, roomID, maxPlayers)
+		if err != nil {
+			return 0, err
+		}
+		if joinErr = AddUserToRoom(db, roomID, userID, seatNo); joinErr == nil {
+			return seatNo, nil
+		}
+	}
+	return 0, joinErr
+}
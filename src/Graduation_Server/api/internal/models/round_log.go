@@ -0,0 +1,203 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// テーブル定義は migrations/sql/0011_round_logs.up.sql
+//
+// 1ラウンドの経過（誰がいくら賭け、どのカードがどの順で配られ、誰が何をしたか）を
+// 起きた順のイベントとして残す。カードを配るのはクライアントなので、配った札と操作は
+// クライアントが送ったものをそのまま記録し、サーバーが見たもの（ベット・精算）と並べる。
+//
+// シードは配る前に SHA-256 だけを送り（commit）、精算で本体を明かす。
+// 後から seed を照らし合わせれば、配り始めた後にシードを差し替えていないことが分かる。
+
+// RoundEvent.Kind の値
+const (
+	RoundEventStart  = "start"  // ラウンドの始まり（席と手持ち）
+	RoundEventBet    = "bet"    // ベットの変更・確定
+	RoundEventCommit = "commit" // シードの SHA-256
+	RoundEventAction = "action" // 配った札・プレイヤーの操作
+	RoundEventSettle = "settle" // 精算
+)
+
+// RoundActionEvent.Action の値
+var RoundActions = []string{"deal", "hit", "stand", "double", "split", "surrender", "reveal"}
+
+// 1ラウンドに残すイベントの上限（超えた分は捨てる）
+const MaxRoundEvents = 512
+
+// round_logs の1行
+type RoundLog struct {
+	ID             int64        `json:"round_id"`
+	ModeID         int          `json:"mode_id"`
+	GameID         int64        `json:"game_id,omitempty"` // ソロは無し
+	RoundNo        int          `json:"round_no"`
+	RoomCode       string       `json:"room_code,omitempty"`
+	SeedCommitment string       `json:"seed_commitment,omitempty"`
+	Seed           string       `json:"seed,omitempty"`
+	StartedAt      time.Time    `json:"started_at"`
+	SettledAt      time.Time    `json:"settled_at"`
+	Events         []RoundEvent `json:"events"` // 起きた順
+}
+
+// 経過の1件（Kind に対応するものだけが入る）
+type RoundEvent struct {
+	Seq    int               `json:"seq"` // 1〜
+	At     time.Time         `json:"at"`
+	Kind   string            `json:"kind"`
+	UserID int64             `json:"user_id,omitempty"` // 操作した人
+	Start  *RoundStartEvent  `json:"start,omitempty"`
+	Bet    *RoundBetEvent    `json:"bet,omitempty"`
+	Commit *RoundCommitEvent `json:"commit,omitempty"`
+	Action *RoundActionEvent `json:"action,omitempty"`
+	Settle *RoundSettleEvent `json:"settle,omitempty"`
+}
+
+type RoundStartEvent struct {
+	DealerID int64       `json:"dealer_id,omitempty"` // ソロは無し
+	Players  []RoundSeat `json:"players"`             // 席順
+}
+
+type RoundSeat struct {
+	UserID int64 `json:"user_id"`
+	SeatNo int   `json:"seat_no"`
+	Chips  int   `json:"chips"` // 手持ち
+	Bet    int   `json:"bet"`   // 前のラウンドから持ち越したベット
+}
+
+type RoundBetEvent struct {
+	Bet       int  `json:"bet"`
+	Confirmed bool `json:"confirmed"`
+	Chips     int  `json:"chips"` // 変更後の手持ち
+}
+
+type RoundCommitEvent struct {
+	SeedCommitment string `json:"seed_commitment"`
+}
+
+type RoundActionEvent struct {
+	Action string `json:"action"`         // deal / hit / stand / double / split / surrender / reveal
+	Card   string `json:"card,omitempty"` // 例 "AS" "10H"（ランク + S/H/D/C）
+	To     int64  `json:"to,omitempty"`   // deal の配り先（ディーラー自身なら dealer_id）
+}
+
+type RoundSettleEvent struct {
+	DealerNet       int             `json:"dealer_net"`
+	DealerBlackjack bool            `json:"dealer_blackjack"`
+	DealerBust      bool            `json:"dealer_bust"`
+	Results         []SettledResult `json:"results"` // 席順
+}
+
+// 精算の1人分
+type SettledResult struct {
+	UserID     int64  `json:"user_id"`
+	Result     string `json:"result"`
+	Blackjack  bool   `json:"blackjack"`
+	Bust       bool   `json:"bust"`
+	Bet        int    `json:"bet"`
+	Payout     int    `json:"payout"` // 戻ってきたチップ（ベット分を含む）
+	Net        int    `json:"net"`
+	TotalChips int    `json:"total_chips"` // 精算後の手持ち
+}
+
+// Append は Seq を振ってイベントを足す（上限を超えたら足さずに false）
+func (l *RoundLog) Append(ev RoundEvent) bool {
+	if len(l.Events) >= MaxRoundEvents {
+		return false
+	}
+	ev.Seq = len(l.Events) + 1
+	l.Events = append(l.Events, ev)
+	return true
+}
+
+// SeedVerified はシードを明かしていて、それが配る前に送った SHA-256 と一致するか
+func (l *RoundLog) SeedVerified() bool {
+	return l.SeedCommitment != "" && l.Seed != "" && SeedMatches(l.Seed, l.SeedCommitment)
+}
+
+// SeedMatches は seed の SHA-256（16進）が commitment と一致するか
+func SeedMatches(seed, commitment string) bool {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:]) == strings.ToLower(commitment)
+}
+
+var (
+	seedCommitmentPattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+	cardPattern           = regexp.MustCompile(`^(A|[2-9]|10|J|Q|K)[SHDC]$`)
+)
+
+// 明かすシードの最大長（round_logs.seed）
+const MaxSeedLength = 128
+
+func IsSeedCommitment(s string) bool { return seedCommitmentPattern.MatchString(s) }
+func IsCard(s string) bool           { return cardPattern.MatchString(s) }
+func IsRoundAction(s string) bool    { return contains(RoundActions, s) }
+
+// RecordRound はラウンドの経過と参加者ごとの結果をまとめて保存し、経過の ID を返す（1トランザクション）
+func RecordRound(db DB, log RoundLog, results []RoundResult) (int64, error) {
+	events, err := json.Marshal(log.Events)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.Exec(`
+		INSERT INTO round_logs
+		  (mode_id, game_id, round_no, room_code, seed_commitment, seed, events, started_at, settled_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		log.ModeID, nullID(log.GameID), log.RoundNo, log.RoomCode, log.SeedCommitment, log.Seed, events, log.StartedAt, log.SettledAt)
+	if err != nil {
+		return 0, err
+	}
+	logID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	for _, r := range results {
+		if _, err := tx.Exec(`
+			INSERT INTO round_results
+			  (user_id, mode_id, game_id, round_no, round_log_id, role, result, bet, payout, net, blackjack, bust, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			r.UserID, r.ModeID, nullID(r.GameID), r.RoundNo, logID, r.Role, r.Result, r.Bet, r.Payout, r.Net, r.Blackjack, r.Bust, r.CreatedAt,
+		); err != nil {
+			return 0, err
+		}
+	}
+	return logID, tx.Commit()
+}
+
+// GetRoundLog は経過を1件返す（無ければ sql.ErrNoRows）
+func GetRoundLog(db DB, id int64) (*RoundLog, error) {
+	var l RoundLog
+	var gameID sql.NullInt64
+	var events []byte
+	err := db.QueryRow(`
+		SELECT id, mode_id, game_id, round_no, room_code, seed_commitment, seed, events, started_at, settled_at
+		  FROM round_logs WHERE id = ?`, id).
+		Scan(&l.ID, &l.ModeID, &gameID, &l.RoundNo, &l.RoomCode, &l.SeedCommitment, &l.Seed, &events, &l.StartedAt, &l.SettledAt)
+	if err != nil {
+		return nil, err
+	}
+	l.GameID = gameID.Int64
+	if err := json.Unmarshal(events, &l.Events); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// 0 は NULL として保存する
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// テーブル定義は migrations/sql/0010_round_results.up.sql（round_log_id は 0011）
+//
+// 1ラウンドの精算ごとに、参加者（ベットしたプレイヤーとディーラー）1人につき1行残す。
+// 保存はラウンドの経過と一緒に RecordRound（round_log.go）で行う。
+
+// round_results.result の値
+const (
+	RoundWin  = "win"
+	RoundLose = "lose"
+	RoundPush = "push"
+)
+
+// round_results.role の値
+const (
+	RoundRolePlayer = "player"
+	RoundRoleDealer = "dealer"
+)
+
+// round_results の1行
+type RoundResult struct {
+	ID        int64
+	UserID    int64
+	ModeID    int
+	GameID    int64 // ソロは 0（NULL）
+	RoundNo   int
+	LogID     int64 // round_logs.id（経過）
+	Role      string
+	Result    string
+	Bet       int
+	Payout    int
+	Net       int
+	Blackjack bool
+	Bust      bool
+	CreatedAt time.Time
+}
+
+// RoundTotals はユーザーの結果をモードごとに集計したもの
+type RoundTotals struct {
+	ModeID        int
+	Hands         int
+	HandsAsDealer int
+	Wins          int
+	Losses        int
+	Pushes        int
+	Blackjacks    int
+	Busts         int
+	Net           int // 収支の合計
+	MaxNet        int // 1ラウンドの最大の収支
+	MaxPayout     int // 1ラウンドで戻ってきた最大のチップ
+}
+
+// SumRoundResults はユーザーの結果をモードごとに SQL で集計する（mode_id 順）
+func SumRoundResults(db DB, userID int64) ([]RoundTotals, error) {
+	rows, err := db.Query(`
+		SELECT mode_id, COUNT(*), SUM(role = ?), SUM(result = ?), SUM(result = ?), SUM(result = ?),
+		       SUM(blackjack), SUM(bust), SUM(net), MAX(net), MAX(payout)
+		  FROM round_results
+		 WHERE user_id = ?
+		 GROUP BY mode_id
+		 ORDER BY mode_id`, RoundRoleDealer, RoundWin, RoundLose, RoundPush, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []RoundTotals
+	for rows.Next() {
+		var t RoundTotals
+		if err := rows.Scan(&t.ModeID, &t.Hands, &t.HandsAsDealer, &t.Wins, &t.Losses, &t.Pushes,
+			&t.Blackjacks, &t.Busts, &t.Net, &t.MaxNet, &t.MaxPayout); err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+// RoundOutcome は連勝数の集計に使う1ラウンドの勝敗だけ
+type RoundOutcome struct {
+	ModeID int
+	Result string
+}
+
+// ListRoundOutcomes はユーザーの勝敗だけを古い順に返す（連勝数の集計に順番が要る）
+func ListRoundOutcomes(db DB, userID int64) ([]RoundOutcome, error) {
+	rows, err := db.Query(`SELECT mode_id, result FROM round_results WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []RoundOutcome
+	for rows.Next() {
+		var o RoundOutcome
+		if err := rows.Scan(&o.ModeID, &o.Result); err != nil {
+			return nil, err
+		}
+		list = append(list, o)
+	}
+	return list, rows.Err()
+}
+
+// ListRecentGameResults は最後に遊んだのが新しい順に limit ゲーム分の結果を古い順に返す
+// （ソロは1ラウンドを1ゲームとする。beforeID > 0 なら最後の結果の ID がそれより小さいゲームだけ）
+func ListRecentGameResults(db DB, userID, beforeID int64, limit int) ([]RoundResult, error) {
+	return queryRoundResults(db, `r
+		  JOIN (SELECT COALESCE(game_id, -id) AS game_key, MAX(id) AS last_id
+		          FROM round_results
+		         WHERE user_id = ?
+		         GROUP BY game_key
+		        HAVING ? = 0 OR last_id < ?
+		         ORDER BY last_id DESC
+		         LIMIT ?) g ON COALESCE(r.game_id, -r.id) = g.game_key
+		 WHERE r.user_id = ?
+		 ORDER BY r.id`, userID, beforeID, beforeID, limit, userID)
+}
+
+// ListRoundResultsByLog は1ラウンドの参加者全員の結果を返す
+func ListRoundResultsByLog(db DB, logID int64) ([]RoundResult, error) {
+	return queryRoundResults(db, `WHERE round_log_id = ? ORDER BY id`, logID)
+}
+
+func queryRoundResults(db DB, where string, args ...any) ([]RoundResult, error) {
+	rows, err := db.Query(`
+		SELECT id, user_id, mode_id, game_id, round_no, round_log_id, role, result, bet, payout, net, blackjack, bust, created_at
+		  FROM round_results `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []RoundResult
+	for rows.Next() {
+		var r RoundResult
+		var gameID, logID sql.NullInt64
+		if err := rows.Scan(&r.ID, &r.UserID, &r.ModeID, &gameID, &r.RoundNo, &logID, &r.Role, &r.Result,
+			&r.Bet, &r.Payout, &r.Net, &r.Blackjack, &r.Bust, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.GameID, r.LogID = gameID.Int64, logID.Int64
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
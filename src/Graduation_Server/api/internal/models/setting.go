@@ -0,0 +1,86 @@
+package models
+
+// settings の1行（音量は 0.0〜1.0）
+type Setting struct {
+	BgmVolume float64
+	SeVolume  float64
+	Icon      string // Icons のどれか
+	Language  string // 'ja' / 'en'。空は未設定（Accept-Language に従う）
+	Vibration bool   // 振動（対応端末のみ）
+	CardBack  string // カードの裏面。CardBacks のどれか
+}
+
+// アカウント作成時の初期設定
+var DefaultSetting = Setting{BgmVolume: 1.0, SeVolume: 1.0, Icon: "default_icon", Vibration: true, CardBack: "default_back"}
+
+// ---- 選べるアイコン・カードの裏面 ----
+// 名前はクライアントの素材名と同じ。足すときは先にクライアントへ素材を入れること
+
+var Icons = []string{
+	"default_icon",
+	"icon_spade",
+	"icon_heart",
+	"icon_diamond",
+	"icon_club",
+	"icon_chip",
+	"icon_crown",
+	"icon_dealer",
+}
+
+var CardBacks = []string{
+	"default_back",
+	"red_back",
+	"blue_back",
+	"black_back",
+	"gold_back",
+}
+
+func IsIcon(name string) bool     { return contains(Icons, name) }
+func IsCardBack(name string) bool { return contains(CardBacks, name) }
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func CreateSetting(db DB, userID int64, s Setting) error {
+	_, err := db.Exec(`
+		INSERT INTO settings (user_id, bgm_volume, se_volume, icon, language, vibration, card_back)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID, s.BgmVolume, s.SeVolume, s.Icon, s.Language, s.Vibration, s.CardBack)
+	return err
+}
+
+// 設定を取得（無ければ sql.ErrNoRows）
+func GetSetting(db DB, userID int64) (*Setting, error) {
+	var s Setting
+	err := db.QueryRow(`
+		SELECT bgm_volume, se_volume, icon, language, vibration, card_back
+		  FROM settings
+		 WHERE user_id = ?`, userID).
+		Scan(&s.BgmVolume, &s.SeVolume, &s.Icon, &s.Language, &s.Vibration, &s.CardBack)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// 設定を丸ごと保存（行が無ければ作る）
+func SaveSetting(db DB, userID int64, s Setting) error {
+	_, err := db.Exec(`
+		INSERT INTO settings (user_id, bgm_volume, se_volume, icon, language, vibration, card_back)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		  bgm_volume = VALUES(bgm_volume),
+		  se_volume  = VALUES(se_volume),
+		  icon       = VALUES(icon),
+		  language   = VALUES(language),
+		  vibration  = VALUES(vibration),
+		  card_back  = VALUES(card_back)`,
+		userID, s.BgmVolume, s.SeVolume, s.Icon, s.Language, s.Vibration, s.CardBack)
+	return err
+}
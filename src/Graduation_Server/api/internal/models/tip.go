@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// tips の1行（ソロ用・マルチ用の所持チップ）
+type Tip struct {
+	SoloTipCount  int
+	MultiTipCount int
+}
+
+func CreateTip(db DB, userID int64, t Tip, now time.Time) error {
+	_, err := db.Exec(`
+		INSERT INTO tips (user_id, solo_tip_count, multi_tip_count, updated_at)
+		VALUES (?, ?, ?, ?)`,
+		userID, t.SoloTipCount, t.MultiTipCount, now)
+	return err
+}
+
+// チップを取得（無ければ sql.ErrNoRows）
+func GetTip(db DB, userID int64) (*Tip, error) {
+	var t Tip
+	err := db.QueryRow(`SELECT solo_tip_count, multi_tip_count FROM tips WHERE user_id = ?`, userID).
+		Scan(&t.SoloTipCount, &t.MultiTipCount)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ソロ用チップを差分で増減
+func AddSoloTip(db DB, userID int64, diff int) error {
+	_, err := db.Exec(
+		"UPDATE tips SET solo_tip_count = solo_tip_count + ?, updated_at = ? WHERE user_id = ?",
+		diff, time.Now(), userID,
+	)
+	return err
+}
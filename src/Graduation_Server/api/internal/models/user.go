@@ -1,7 +1,127 @@
 package models
 
-import "net/http"
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// users.role の値（JWT の role クレームにも入る）
+const (
+	RolePlayer = "player"
+	RoleAdmin  = "admin"
+)
 
 func GetUserData(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("User data placeholder"))
 }
+
+// users の1行（パスワードはハッシュ。ゲストは空文字）
+type User struct {
+	ID           int64
+	Name         string
+	PasswordHash string
+	CreatedAt    time.Time
+	Role         string
+	BannedAt     sql.NullTime // BAN 中なら Valid
+	BanReason    string
+	NameChanged  sql.NullTime // 最後に名前を変えた日時（未変更なら無効）
+}
+
+// ErrNameTaken は変更先の名前が他のユーザーに使われている場合のエラー
+var ErrNameTaken = errors.New("user name already taken")
+
+// ErrNameChangeTooSoon は前回の名前の変更から間隔が空いていない場合のエラー
+var ErrNameChangeTooSoon = errors.New("user name changed too recently")
+
+// ユーザーを作成して users.id を返す
+func CreateUser(db DB, name, hashedPassword string, now time.Time) (int64, error) {
+	res, err := db.Exec(`
+		INSERT INTO users (name, password, created_at, last_login)
+		VALUES (?, ?, ?, ?)`,
+		name, hashedPassword, now, now)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ID でユーザーを取得（無ければ sql.ErrNoRows）
+func GetUserByID(db DB, userID int64) (*User, error) {
+	var u User
+	err := db.QueryRow(`
+		SELECT id, name, password, created_at, role, banned_at, ban_reason, name_changed_at
+		  FROM users
+		 WHERE id = ?`, userID).
+		Scan(&u.ID, &u.Name, &u.PasswordHash, &u.CreatedAt, &u.Role, &u.BannedAt, &u.BanReason, &u.NameChanged)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// 名前が使われているか
+func UserNameExists(db DB, name string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE name = ?)`, name).Scan(&exists)
+	return exists, err
+}
+
+// 名前を変えて変更日時を記録する。
+// 前回の変更が changedBefore より後なら変えずに ErrNameChangeTooSoon を返す（同時に来た変更も UPDATE の条件で1つに絞る）。
+// 使われている名前なら ErrNameTaken、ユーザーが居なければ sql.ErrNoRows。
+func RenameUser(db DB, userID int64, name string, now, changedBefore time.Time) error {
+	res, err := db.Exec(`
+		UPDATE users SET name = ?, name_changed_at = ?
+		 WHERE id = ? AND (name_changed_at IS NULL OR name_changed_at <= ?)`, name, now, userID, changedBefore)
+	var me *mysql.MySQLError
+	if errors.As(err, &me) && me.Number == 1062 { // ER_DUP_ENTRY（uq_users_name）
+		return ErrNameTaken
+	}
+	if err != nil {
+		return err
+	}
+	// name_changed_at は必ず変わるので、0 件なら条件に合わなかった（居ないか間隔が空いていない）
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)`, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return ErrNameChangeTooSoon
+}
+
+// ロールを変更（ユーザーが居なければ sql.ErrNoRows）
+func SetUserRole(db DB, userID int64, role string) error {
+	return updateUser(db, userID, `UPDATE users SET role = ? WHERE id = ?`, role, userID)
+}
+
+// BAN する（ログイン・トークン発行を止める。発行済みトークンの失効は呼び出し側で行う）
+func BanUser(db DB, userID int64, reason string, now time.Time) error {
+	return updateUser(db, userID, `UPDATE users SET banned_at = ?, ban_reason = ? WHERE id = ?`, now, reason, userID)
+}
+
+func UnbanUser(db DB, userID int64) error {
+	return updateUser(db, userID, `UPDATE users SET banned_at = NULL, ban_reason = '' WHERE id = ?`, userID)
+}
+
+// 存在確認をしてから1行更新する
+// （MySQL の RowsAffected は値が変わらないと 0 になるので、0 件かどうかでは判定できない）
+func updateUser(db DB, userID int64, query string, args ...any) error {
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM users WHERE id = ?)`, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	_, err := db.Exec(query, args...)
+	return err
+}
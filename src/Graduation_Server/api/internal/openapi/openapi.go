@@ -0,0 +1,176 @@
+// Package openapi は API の仕様書（OpenAPI 3）を作って配る。
+//
+// ルート・要求・応答の型は routes.go の表に書き、スキーマは handlers の型から
+// リフレクションで作る（json タグがそのままプロパティ名になる）。
+// 型を変えれば仕様書も変わる。表と実際のルーター・応答のずれは server のテストで検出する。
+//
+// 仕様書に載せるのは /api/v1 だけ。旧ルート（/api/...）は以前の形のまま残している互換用。
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Path は仕様書を配る場所
+const Path = "/api/openapi.json"
+
+// ---- 仕様書の形（使う分だけ） ----
+
+// Document は OpenAPI 3.0 の仕様書
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Tags       []Tag               `json:"tags"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// PathItem はメソッド（小文字）ごとの操作
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	AdminOnly   bool                  `json:"x-admin-only,omitempty"` // role=admin のトークンが必要
+	WebSocket   *WebSocket            `json:"x-websocket,omitempty"`  // GET でアップグレードした後のメッセージ
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path / query
+	Required    bool    `json:"required"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// WebSocket は接続後にやり取りするメッセージ（どれも type で種類を見分ける JSON）
+type WebSocket struct {
+	Client []Message `json:"client"` // クライアント → サーバー
+	Server []Message `json:"server"` // サーバー → クライアント
+}
+
+type Message struct {
+	Type    string  `json:"type"`
+	Summary string  `json:"summary"`
+	Schema  *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// ---- 配布 ----
+
+var (
+	once    sync.Once
+	doc     *Document
+	docJSON []byte
+)
+
+// Spec は仕様書を返す（初回に作って使い回す）。
+// 表や型の誤り（スキーマ名の重複など）はプログラムの誤りなので panic する。
+func Spec() *Document {
+	once.Do(func() {
+		d, err := Build()
+		if err != nil {
+			panic("openapi: " + err.Error())
+		}
+		raw, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			panic("openapi: " + err.Error())
+		}
+		doc, docJSON = d, raw
+	})
+	return doc
+}
+
+// JSON は仕様書の JSON
+func JSON() []byte {
+	Spec()
+	return docJSON
+}
+
+// Handler は仕様書を返す（認証不要。クライアントのコード生成やドキュメント表示に使う）
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write(JSON())
+	})
+}
+
+// Operation は method・path（/api/v1/... のテンプレート）の操作。無ければ nil
+func (d *Document) Operation(method, path string) *Operation {
+	item, ok := d.Paths[path]
+	if !ok {
+		return nil
+	}
+	return item[lowerMethod(method)]
+}
+
+// SuccessSchema は成功時（200）の本文のスキーマ
+func (op *Operation) SuccessSchema() *Schema {
+	res, ok := op.Responses["200"]
+	if !ok {
+		return nil
+	}
+	for _, mt := range res.Content {
+		return mt.Schema
+	}
+	return nil
+}
+
+// ServerMessage は WebSocket でサーバーが送る typ のメッセージのスキーマ。無ければ nil
+func (op *Operation) ServerMessage(typ string) *Schema {
+	if op.WebSocket == nil {
+		return nil
+	}
+	for _, m := range op.WebSocket.Server {
+		if m.Type == typ {
+			return m.Schema
+		}
+	}
+	return nil
+}
@@ -0,0 +1,414 @@
+package openapi
+
+import (
+	"api/internal/handlers"
+	"api/internal/response"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ---- ルートの表 ----
+//
+// server/router.go に API を足したらここにも足す（足し忘れは server のテストで落ちる）。
+// request は要求の本文の型、response は成功時に data に入る型。
+
+// 認証
+type access int
+
+const (
+	public access = iota
+	user          // JWT（Authorization: Bearer。WebSocket はクエリの token でもよい）
+	admin         // role=admin の JWT
+)
+
+type route struct {
+	method   string
+	path     string // /api/v1 より後ろ。{name} はパスパラメータ
+	tag      string
+	summary  string
+	access   access
+	request  interface{} // nil は本文なし
+	optional bool        // 本文を省略できる
+	response interface{}
+	query    []param
+	socket   *socket // GET でアップグレードする WebSocket
+}
+
+type param struct {
+	name string
+	typ  string // integer / string
+	desc string
+}
+
+type socket struct {
+	client []message
+	server []message
+}
+
+type message struct {
+	typ     string
+	summary string
+	body    interface{}
+}
+
+// どちらの WebSocket にも届く全体通知など
+var commonServerMessages = []message{
+	{"maintenance", "メンテナンスの開始・終了", handlers.MaintenanceNotice{}},
+	{"announcement", "お知らせの表示開始", handlers.AnnouncementNotice{}},
+	{"announcement_removed", "お知らせの取り下げ", handlers.AnnouncementRemovedNotice{}},
+	{"room_closed", "運営によるルームの強制クローズ（この後切断される）", handlers.RoomClosedNotice{}},
+	{"server_shutdown", "サーバーの停止予告（deadline までに卓を終える）", handlers.ShutdownNotice{}},
+	{"rate_limited", "メッセージの送りすぎで捨てた", handlers.RateLimitedNotice{}},
+}
+
+var adminLimit = param{"limit", "integer", "件数（省略時 50、最大 500）"}
+
+var routes = []route{
+	// ---- アカウント ----
+	{method: "POST", path: "/create_account", tag: "account", summary: "アカウント作成（auto_flg でゲスト）",
+		request: handlers.CreateAccountRequest{}, response: handlers.TokenResponse{}},
+	{method: "POST", path: "/login", tag: "account", summary: "名前とパスワードでログイン",
+		request: handlers.LoginRequest{}, response: handlers.LoginResponse{}},
+	{method: "POST", path: "/token/refresh", tag: "account", summary: "リフレッシュトークンでトークンを再発行",
+		request: handlers.RefreshTokenRequest{}, response: handlers.TokenResponse{}},
+	{method: "POST", path: "/account/transfer", tag: "account", summary: "引き継ぎコードで新しい端末にログイン",
+		request: handlers.RedeemTransferCodeRequest{}, response: handlers.TransferLoginResponse{}},
+	{method: "POST", path: "/account/reset_password", tag: "account", summary: "リカバリーコード・再設定コードでパスワードを再設定",
+		request: handlers.ResetPasswordRequest{}, response: handlers.TokenResponse{}},
+	{method: "POST", path: "/logout", tag: "account", summary: "この端末からログアウト", access: user,
+		request: handlers.LogoutRequest{}, optional: true, response: struct{}{}},
+	{method: "POST", path: "/logout_all", tag: "account", summary: "全端末からログアウト", access: user,
+		response: struct{}{}},
+	{method: "POST", path: "/account/upgrade", tag: "account", summary: "ゲストアカウントの本登録", access: user,
+		request: handlers.UpgradeGuestRequest{}, response: handlers.UpgradeGuestResponse{}},
+	{method: "POST", path: "/account/transfer_code", tag: "account", summary: "機種変更用の引き継ぎコードを発行", access: user,
+		response: handlers.TransferCodeResponse{}},
+	{method: "POST", path: "/account/password", tag: "account", summary: "パスワード変更（他の端末はログアウトされる）", access: user,
+		request: handlers.ChangePasswordRequest{}, response: handlers.TokenResponse{}},
+	{method: "POST", path: "/account/recovery_codes", tag: "account", summary: "リカバリーコードを発行（平文は今回だけ）", access: user,
+		response: handlers.RecoveryCodesResponse{}},
+
+	// ---- ホーム画面 ----
+	{method: "GET", path: "/get_main_data", tag: "home", summary: "設定・チップ・お知らせなどホーム画面の表示一式", access: user,
+		response: handlers.MainDataResponse{}},
+	{method: "GET", path: "/rank_mode", tag: "home", summary: "ソロ・マルチが遊べるか",
+		response: handlers.RankModeResponse{}},
+	{method: "GET", path: "/game_mode", tag: "home", summary: "ランク・フレンドが遊べるか",
+		response: handlers.GameModeResponse{}},
+	{method: "GET", path: "/solo_games", tag: "home", summary: "ソロで遊べるゲーム一覧",
+		response: handlers.GameListResponse{}},
+	{method: "GET", path: "/friend_games", tag: "home", summary: "フレンドで遊べるゲーム一覧",
+		response: handlers.GameListResponse{}},
+	{method: "POST", path: "/update_settings", tag: "home", summary: "音量・アイコン・言語・振動・カードの裏面の設定を更新", access: user,
+		request: handlers.UpdateUserSettingsRequest{}, response: handlers.UpdateUserSettingsResponse{}},
+	{method: "GET", path: "/profile/catalog", tag: "home", summary: "設定で選べるアイコン・カードの裏面・言語の一覧",
+		response: handlers.ProfileCatalogResponse{}},
+	{method: "POST", path: "/profile/name", tag: "home", summary: "名前の変更（一度変えたら一定期間は変えられない）", access: user,
+		request: handlers.ChangeNameRequest{}, response: handlers.ChangeNameResponse{}},
+	{method: "POST", path: "/updatesolotip", tag: "home", summary: "ソロのチップを増減（round を付ければ戦績に残る）", access: user,
+		request: handlers.UpdateTipRequest{}, response: handlers.UpdateTipResponse{}},
+	{method: "GET", path: "/stats", tag: "home", summary: "自分の戦績（通算とモード別）", access: user,
+		response: handlers.PlayerStatsResponse{}},
+	{method: "GET", path: "/history", tag: "home", summary: "遊んだゲームの履歴（新しい順）", access: user,
+		response: handlers.HistoryResponse{}, query: []param{
+			{"before", "integer", "前の応答の next_before（ページ送り）"},
+			{"limit", "integer", "ゲーム数（省略時 20、最大 100）"},
+		}},
+	{method: "GET", path: "/history/rounds/{round_id}", tag: "home", summary: "ラウンドの経過（リプレイ。参加したラウンドのみ）", access: user,
+		response: handlers.RoundReplayResponse{}},
+	{method: "GET", path: "/get_chip_data", tag: "home", summary: "所持チップ", access: user,
+		response: handlers.ChipResponse{}},
+
+	// ---- ルーム ----
+	{method: "POST", path: "/create_room", tag: "room", summary: "ルーム作成（作った人がホスト）", access: user,
+		request: handlers.CreateRoomRequest{}, response: handlers.CreateRoomResponse{}},
+	{method: "POST", path: "/join_room", tag: "room", summary: "ルームコードで参加", access: user,
+		request: handlers.JoinRoomRequest{}, response: handlers.JoinRoomResponse{}},
+	{method: "POST", path: "/rooms/leave", tag: "room", summary: "ルームから退出（ホストなら次の人に交代）", access: user,
+		request: handlers.LeaveRoomRequest{}, response: handlers.LeaveRoomResponse{}},
+	{method: "POST", path: "/rooms/start", tag: "room", summary: "ゲーム開始（ホストのみ・全員準備完了後）", access: user,
+		request: handlers.StartRequest{}, response: handlers.StartResponse{}},
+	{method: "GET", path: "/rooms/{room_id}/players", tag: "room", summary: "ゲーム中のプレイヤー一覧",
+		response: handlers.PlayersForGameResponse{}},
+	{method: "GET", path: "/ws/room/{room_code}", tag: "room", summary: "ルーム待機画面の WebSocket", access: user,
+		socket: &socket{
+			client: []message{
+				{"ready", "準備完了の切り替え（type 省略時も ready）", handlers.RoomCommand{}},
+				{"seat_select", "空いている席に移る", handlers.RoomCommand{}},
+				{"seat_swap", "他の人に席の交換を頼む・受ける", handlers.RoomCommand{}},
+			},
+			server: append([]message{
+				{"room_status", "参加者と席の一覧（変わるたびに全員へ）", handlers.RoomStatusResponse{}},
+				{"all_ready", "全員の準備完了", handlers.AllReadyResponse{}},
+				{"start_game", "ゲーム開始", handlers.StartGameBroadcast{}},
+				{"seat_swap_request", "席の交換の依頼", handlers.SeatSwapRequestNotice{}},
+				{"seat_error", "席の操作の失敗", handlers.SeatErrorResponse{}},
+			}, commonServerMessages...),
+		}},
+	{method: "GET", path: "/ws/blackjackwebsocket/{room_code}", tag: "game", summary: "ブラックジャック卓の WebSocket", access: user,
+		socket: &socket{
+			client: []message{
+				{"bet_update", "賭け金の変更・決定", handlers.BetCommand{}},
+				{"round_commit", "配る前にシードの SHA-256 を送る（ディーラーのみ）", handlers.RoundCommitCommand{}},
+				{"round_action", "配った札・プレイヤーの操作を経過に残す", handlers.RoundActionCommand{}},
+				{"round_result", "ラウンドの結果（ディーラーのみ。seed でシードを明かす）", handlers.RoundResultCommand{}},
+			},
+			server: append([]message{
+				{"player_order", "席順（手番・ディーラーの順）", handlers.PlayerOrderMessage{}},
+				{"bet_state", "全員の賭け金と決定状況", handlers.BetStateBroadcast{}},
+				{"timer", "賭けの残り時間", handlers.TimerMessage{}},
+				{"round_settled", "ラウンドの精算（払い戻しと手持ち）", handlers.RoundSettledBroadcast{}},
+				{"table_error", "round_commit・round_action・round_result を受け付けなかった（送った人にだけ）", handlers.TableErrorResponse{}},
+			}, commonServerMessages...),
+		}},
+
+	// ---- 運営 ----
+	{method: "GET", path: "/admin/rooms", tag: "admin", summary: "ルーム一覧", access: admin,
+		response: handlers.AdminRoomsResponse{}},
+	{method: "GET", path: "/admin/rooms/{room_code}", tag: "admin", summary: "ルームの詳細（卓の状態）", access: admin,
+		response: handlers.AdminRoomDetailResponse{}},
+	{method: "POST", path: "/admin/rooms/{room_code}/close", tag: "admin", summary: "ルームの強制クローズ", access: admin,
+		request: handlers.AdminCloseRoomRequest{}, response: handlers.AdminCloseRoomResponse{}},
+	{method: "GET", path: "/admin/rounds/{round_id}", tag: "admin", summary: "ラウンドの経過（問い合わせ対応）", access: admin,
+		response: handlers.RoundReplayResponse{}},
+	{method: "GET", path: "/admin/users/{user_id}/chips", tag: "admin", summary: "チップの台帳", access: admin,
+		response: handlers.AdminChipLedgerResponse{}, query: []param{adminLimit}},
+	{method: "POST", path: "/admin/users/{user_id}/chips", tag: "admin", summary: "チップの調整", access: admin,
+		request: handlers.AdminAdjustChipsRequest{}, response: handlers.AdminAdjustChipsResponse{}},
+	{method: "POST", path: "/admin/users/{user_id}/ban", tag: "admin", summary: "BAN（発行済みのトークンも無効）", access: admin,
+		request: handlers.AdminBanRequest{}, response: handlers.AdminUserResponse{}},
+	{method: "POST", path: "/admin/users/{user_id}/unban", tag: "admin", summary: "BAN 解除", access: admin,
+		response: handlers.AdminUserResponse{}},
+	{method: "GET", path: "/admin/modes", tag: "admin", summary: "モード・ゲーム種別の公開状態", access: admin,
+		response: handlers.AdminModesResponse{}},
+	{method: "POST", path: "/admin/modes/{id}", tag: "admin", summary: "モードの公開切り替え", access: admin,
+		request: handlers.AdminPlayableRequest{}, response: handlers.AdminPlayableResponse{}},
+	{method: "POST", path: "/admin/types/{id}", tag: "admin", summary: "ゲーム種別の公開切り替え", access: admin,
+		request: handlers.AdminPlayableRequest{}, response: handlers.AdminPlayableResponse{}},
+	{method: "GET", path: "/admin/maintenance", tag: "admin", summary: "メンテナンスの状態", access: admin,
+		response: handlers.AdminMaintenanceResponse{}},
+	{method: "POST", path: "/admin/maintenance", tag: "admin", summary: "メンテナンスの開始・終了", access: admin,
+		request: handlers.AdminMaintenanceRequest{}, response: handlers.AdminMaintenanceResponse{}},
+	{method: "GET", path: "/admin/announcements", tag: "admin", summary: "終わっていないお知らせ（予約を含む）", access: admin,
+		response: handlers.AdminAnnouncementsResponse{}},
+	{method: "POST", path: "/admin/announcements", tag: "admin", summary: "お知らせの登録", access: admin,
+		request: handlers.AdminAnnouncementRequest{}, response: handlers.AdminAnnouncementResponse{}},
+	{method: "DELETE", path: "/admin/announcements/{id}", tag: "admin", summary: "お知らせの取り下げ", access: admin,
+		response: handlers.AdminDeleteAnnouncementResponse{}},
+	{method: "GET", path: "/admin/audit", tag: "admin", summary: "運営の操作ログ（新しい順）", access: admin,
+		response: handlers.AdminAuditResponse{},
+		query:    []param{{"before", "integer", "この ID より前（ページ送り）"}, adminLimit}},
+}
+
+// 運用（監視・ヘルスチェック）。エンベロープに包まず、そのまま返す
+type opsRoute struct {
+	path        string
+	summary     string
+	contentType string
+	body        interface{} // nil は JSON 以外
+}
+
+var opsRoutes = []opsRoute{
+	{"/healthz", "プロセスが応答できるか（依存先の異常は status=degraded）", "application/json", handlers.HealthResponse{}},
+	{"/readyz", "トラフィックを受けてよいか（依存先の異常・停止処理中は 503）", "application/json", handlers.HealthResponse{}},
+	{"/metrics", "メトリクス（Prometheus のテキスト形式）", "text/plain", nil},
+	{Path, "この仕様書", "application/json", nil},
+}
+
+var tags = []Tag{
+	{"account", "アカウント・ログイン"},
+	{"home", "ホーム画面・設定・チップ"},
+	{"room", "ルーム（待機画面）"},
+	{"game", "ゲーム卓"},
+	{"admin", "運営API（role=admin のみ。操作は監査ログに残る）"},
+	{"ops", "運用（監視・ヘルスチェック。応答はエンベロープに包まない）"},
+}
+
+const description = `応答はすべて同じ形（エンベロープ）で返す。
+
+    成功: {"ok": true,  "data": {...}}
+    失敗: {"ok": false, "error": {"code": "room_full", "message": "...", "fields": [...]}}
+
+error.code は変わらない識別子（分岐に使う）、message は表示用の文言。
+入力項目のエラーは code=validation_failed で、fields に項目ごとの code が入る。
+要求の本文で省略した項目はゼロ値として扱う（必須かどうかはサーバーが検査して fields で返す）。
+ただし設定の更新（update_settings）は部分更新で、省略した項目は変えない。
+
+message は日本語（ja）か英語（en）。ユーザー設定の language（update_settings で指定）、
+無ければ Accept-Language、どちらも無ければ日本語で返し、使った言語を Content-Language で示す。
+WebSocket のエラー（seat_error・table_error・rate_limited）も code と、接続時に決めた言語の message を持つ。
+
+WebSocket は GET でアップグレードし、トークンはクエリの token で渡す。
+やり取りするメッセージは各操作の x-websocket に type ごとに載せている。
+ブラックジャックの勝負はクライアントで行い、ディーラーが round_result で結果を送ると
+サーバーが払い戻しを計算して round_settled を全員へ送る（結果は /stats の戦績に残る）。
+配る前に round_commit でシードの SHA-256 を送り、配った札と操作を round_action で送ると、
+ベット・精算と合わせてラウンドの経過として残り、/history から再生できる。
+round_commit を送ったラウンドは、round_result の seed が先に送った SHA-256 と一致しないと精算しない（seed_mismatch）。
+一致したラウンドは経過の seed_verified が true になる。
+
+/api/... （v1 以外）は以前の形の応答を返す互換用のルートで、この仕様書には載せない。`
+
+// ---- 組み立て ----
+
+var pathParam = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// Build は表から仕様書を作る
+func Build() (*Document, error) {
+	g := newGenerator()
+	g.enums[reflect.TypeOf(response.Code(""))] = codeStrings(response.Codes())
+	g.enums[reflect.TypeOf(response.FieldCode(""))] = codeStrings(response.FieldCodes())
+	errorEnvelope := g.of(ErrorResponse{}, output)
+
+	d := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "Graduation Server API",
+			Version:     "v1",
+			Description: description,
+		},
+		Tags:  tags,
+		Paths: map[string]PathItem{},
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				"token": {Type: "apiKey", In: "query", Name: "token",
+					Description: "WebSocket 用（ブラウザ・Unity の WebSocket はヘッダーを付けられないため）"},
+			},
+		},
+	}
+
+	for _, rt := range routes {
+		path := response.V1Prefix + rt.path
+		item, ok := d.Paths[path]
+		if !ok {
+			item = PathItem{}
+			d.Paths[path] = item
+		}
+		method := lowerMethod(rt.method)
+		if _, dup := item[method]; dup {
+			g.fail("duplicate route %s %s", rt.method, path)
+		}
+
+		op := &Operation{
+			OperationID: operationID(rt.method, rt.path),
+			Summary:     rt.summary,
+			Tags:        []string{rt.tag},
+			Responses: map[string]*Response{
+				"default": {
+					Description: "失敗（error.code で種類を見分ける）",
+					Content:     map[string]MediaType{"application/json": {Schema: errorEnvelope}},
+				},
+			},
+			AdminOnly: rt.access == admin,
+		}
+		if rt.access != public {
+			op.Security = []map[string][]string{{"bearer": {}}}
+			if rt.socket != nil {
+				op.Security = append(op.Security, map[string][]string{"token": {}})
+			}
+		}
+		for _, m := range pathParam.FindAllStringSubmatch(rt.path, -1) {
+			typ := "integer"
+			if strings.HasSuffix(m[1], "_code") {
+				typ = "string"
+			}
+			op.Parameters = append(op.Parameters, Parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: typ}})
+		}
+		for _, q := range rt.query {
+			op.Parameters = append(op.Parameters, Parameter{Name: q.name, In: "query", Description: q.desc, Schema: &Schema{Type: q.typ}})
+		}
+		if rt.request != nil {
+			op.RequestBody = &RequestBody{
+				Required: !rt.optional,
+				Content:  map[string]MediaType{"application/json": {Schema: g.of(rt.request, input)}},
+			}
+		}
+
+		if rt.socket != nil {
+			op.Responses["101"] = &Response{Description: "WebSocket に切り替え"}
+			ws := &WebSocket{}
+			for _, m := range rt.socket.client {
+				ws.Client = append(ws.Client, Message{Type: m.typ, Summary: m.summary, Schema: g.of(m.body, input)})
+			}
+			for _, m := range rt.socket.server {
+				ws.Server = append(ws.Server, Message{Type: m.typ, Summary: m.summary, Schema: g.of(m.body, output)})
+			}
+			op.WebSocket = ws
+		} else {
+			op.Responses["200"] = &Response{
+				Description: "成功",
+				Content:     map[string]MediaType{"application/json": {Schema: successEnvelope(g.of(rt.response, output))}},
+			}
+		}
+		item[method] = op
+	}
+
+	for _, rt := range opsRoutes {
+		mt := MediaType{Schema: &Schema{Type: "string"}}
+		if rt.body != nil {
+			mt.Schema = g.of(rt.body, output)
+		} else if rt.contentType == "application/json" {
+			mt.Schema = &Schema{Type: "object"}
+		}
+		d.Paths[rt.path] = PathItem{"get": {
+			OperationID: operationID("GET", rt.path),
+			Summary:     rt.summary,
+			Tags:        []string{"ops"},
+			Responses: map[string]*Response{
+				"200": {Description: "成功", Content: map[string]MediaType{rt.contentType: mt}},
+			},
+		}}
+	}
+
+	if g.err != nil {
+		return nil, g.err
+	}
+	d.Components.Schemas = g.schemas
+	return d, nil
+}
+
+// ErrorResponse は失敗の応答（ok は常に false）。response.Envelope の失敗側だけの形
+type ErrorResponse struct {
+	OK    bool           `json:"ok"`
+	Error response.Error `json:"error"`
+}
+
+func successEnvelope(data *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"ok":   {Type: "boolean", Enum: []interface{}{true}},
+			"data": data,
+		},
+		Required:             []string{"ok", "data"},
+		AdditionalProperties: false,
+	}
+}
+
+func codeStrings[T ~string](codes []T) []string {
+	out := make([]string, len(codes))
+	for i, c := range codes {
+		out[i] = string(c)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// operationID は "POST /rooms/{room_id}/players" → "postRoomsRoomIdPlayers"
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(lowerMethod(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '_' || r == '.' || r == '{' || r == '}'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+func lowerMethod(method string) string {
+	return strings.ToLower(method)
+}
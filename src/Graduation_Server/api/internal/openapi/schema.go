@@ -0,0 +1,224 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Schema は JSON Schema（OpenAPI 3.0 の部分集合）
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"` // *Schema か false
+}
+
+const refPrefix = "#/components/schemas/"
+
+// Ref は components の name への参照
+func Ref(name string) *Schema {
+	return &Schema{Ref: refPrefix + name}
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// 要求（クライアントが送る）か応答（サーバーが返す）か。
+// 応答は omitempty の無い項目を必須にする。要求は省略するとゼロ値として扱うので必須は付けない
+// （値の検査はハンドラがして、エラーは fields で返す）。
+type direction int
+
+const (
+	output direction = iota
+	input
+)
+
+func (d direction) String() string {
+	if d == input {
+		return "request"
+	}
+	return "response"
+}
+
+// generator は Go の型から components のスキーマを作る。
+// 名前付きの構造体は型名で components に登録して $ref で参照する。
+type generator struct {
+	schemas map[string]*Schema
+	types   map[string]reflect.Type
+	dirs    map[string]direction
+	enums   map[reflect.Type][]string // 値が決まっている文字列型（エラー code など）
+	err     error
+}
+
+func newGenerator() *generator {
+	return &generator{
+		schemas: map[string]*Schema{},
+		types:   map[string]reflect.Type{},
+		dirs:    map[string]direction{},
+		enums:   map[reflect.Type][]string{},
+	}
+}
+
+// of は v の型のスキーマ
+func (g *generator) of(v interface{}, dir direction) *Schema {
+	return g.schema(reflect.TypeOf(v), dir)
+}
+
+func (g *generator) fail(format string, args ...interface{}) {
+	if g.err == nil {
+		g.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (g *generator) schema(t reflect.Type, dir direction) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawType:
+		return &Schema{Description: "任意の JSON"}
+	}
+	if values, ok := g.enums[t]; ok {
+		return g.component(t, dir, func() *Schema {
+			s := &Schema{Type: "string"}
+			for _, v := range values {
+				s.Enum = append(s.Enum, v)
+			}
+			return s
+		})
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(g.schema(t.Elem(), dir))
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Interface:
+		return &Schema{Description: "任意の JSON"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		// nil のスライスは null になる
+		return &Schema{Type: "array", Items: g.schema(t.Elem(), dir), Nullable: true}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem(), dir)}
+	case reflect.Map:
+		// キーは JSON では文字列（数値のキーも文字列になる）
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem(), dir), Nullable: true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t, dir)
+		}
+		return g.component(t, dir, func() *Schema { return g.object(t, dir) })
+	}
+	g.fail("%s: unsupported kind %s", t, t.Kind())
+	return &Schema{}
+}
+
+// component は名前付きの型を components に登録して参照を返す
+func (g *generator) component(t reflect.Type, dir direction, build func() *Schema) *Schema {
+	name := t.Name()
+	if prev, ok := g.types[name]; ok {
+		if prev != t {
+			g.fail("schema name %q is used by both %s and %s", name, prev, t)
+		} else if g.dirs[name] != dir {
+			g.fail("%s is used as both a request and a response", t)
+		}
+		return Ref(name)
+	}
+	g.types[name] = t
+	g.dirs[name] = dir
+	g.schemas[name] = nil // 自分自身を参照する型でも止まるように先に登録する
+	g.schemas[name] = build()
+	return Ref(name)
+}
+
+// object は構造体のスキーマ（encoding/json と同じ規則でプロパティを並べる）
+func (g *generator) object(t reflect.Type, dir direction) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+	g.fields(s, t, dir)
+	return s
+}
+
+func (g *generator) fields(s *Schema, t reflect.Type, dir direction) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		// タグの無い埋め込み構造体は項目を展開する
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(s, ft, dir)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, dup := s.Properties[name]; dup {
+			g.fail("%s: duplicate json name %q", t, name)
+			continue
+		}
+		var prop *Schema
+		if hasOption(opts, "string") {
+			prop = &Schema{Type: "string"}
+		} else {
+			prop = g.schema(ft, dir)
+		}
+		s.Properties[name] = prop
+		if dir == output && !hasOption(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+func hasOption(opts, name string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+// nullable は s に null も許したもの（OpenAPI 3.0 は $ref に nullable を並べられないので allOf で包む）
+func nullable(s *Schema) *Schema {
+	if s.Ref != "" {
+		return &Schema{AllOf: []*Schema{s}, Nullable: true}
+	}
+	c := *s
+	c.Nullable = true
+	return &c
+}
@@ -0,0 +1,123 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Validate は v（JSON を interface{} にデコードしたもの）が s に合うか調べ、最初に見つけたずれを返す。
+// 必須の項目・仕様書に無い項目・型・列挙値を見る（テストで実際の応答と仕様書を突き合わせる用）。
+func (d *Document) Validate(s *Schema, v interface{}) error {
+	return d.validate("$", s, v)
+}
+
+func (d *Document) validate(at string, s *Schema, v interface{}) error {
+	if s == nil {
+		return fmt.Errorf("%s: no schema", at)
+	}
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, refPrefix)
+		target, ok := d.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("%s: unknown schema %s", at, s.Ref)
+		}
+		return d.validate(at, target, v)
+	}
+	if v == nil {
+		if s.Nullable || (s.Type == "" && len(s.AllOf) == 0) {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", at)
+	}
+	for _, sub := range s.AllOf {
+		if err := d.validate(at, sub, v); err != nil {
+			return err
+		}
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		return fmt.Errorf("%s: %v is not one of %v", at, v, s.Enum)
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return typeError(at, s.Type, v)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", at, name)
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if prop, ok := s.Properties[k]; ok {
+				if err := d.validate(at+"."+k, prop, obj[k]); err != nil {
+					return err
+				}
+				continue
+			}
+			switch extra := s.AdditionalProperties.(type) {
+			case *Schema:
+				if err := d.validate(at+"."+k, extra, obj[k]); err != nil {
+					return err
+				}
+			case bool:
+				if !extra {
+					return fmt.Errorf("%s: property %q is not in the spec", at, k)
+				}
+			}
+		}
+	case "array":
+		list, ok := v.([]interface{})
+		if !ok {
+			return typeError(at, s.Type, v)
+		}
+		for i, item := range list {
+			if err := d.validate(fmt.Sprintf("%s[%d]", at, i), s.Items, item); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return typeError(at, s.Type, v)
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) {
+			return typeError(at, s.Type, v)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return typeError(at, s.Type, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return typeError(at, s.Type, v)
+		}
+	default:
+		return fmt.Errorf("%s: unknown type %q", at, s.Type)
+	}
+	return nil
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func typeError(at, want string, v interface{}) error {
+	return fmt.Errorf("%s: want %s, got %T (%v)", at, want, v, v)
+}
@@ -0,0 +1,95 @@
+// Package ratelimit はトークンバケットによる呼び出し回数の制限を行う。
+//
+// Bucket は1つの呼び出し元（WebSocket の1接続など）用、
+// Limiter はキー（ユーザーID・IP）ごとに Bucket を持つ HTTP 用。
+// どちらも rate（1秒あたりの補充数）と burst（溜められる上限）で決める。
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket は1つのトークンバケット（ゴルーチン安全ではない。Limiter の中か、1つのゴルーチンから使う）
+type Bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket は満タンのバケットを作る
+func NewBucket(rate float64, burst int, now time.Time) *Bucket {
+	return &Bucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// Allow は1つ消費できれば true。できなければ次の1つが溜まるまでの時間を返す。
+func (b *Bucket) Allow(now time.Time) (bool, time.Duration) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if b.rate <= 0 {
+		return false, time.Hour
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+// full は満タンまで戻っているか（掃除用）
+func (b *Bucket) full(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// Limiter はキーごとのトークンバケット
+type Limiter struct {
+	rate  float64
+	burst int
+
+	mu      sync.Mutex
+	buckets map[string]*Bucket
+	ops     int
+}
+
+// New は rate / burst の Limiter を作る
+func New(rate float64, burst int) *Limiter {
+	return &Limiter{rate: rate, burst: burst, buckets: make(map[string]*Bucket)}
+}
+
+// Allow は key の呼び出しを1回数える。制限中なら false と待ち時間を返す。
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = NewBucket(l.rate, l.burst, now)
+		l.buckets[key] = b
+	}
+	allowed, wait := b.Allow(now)
+
+	// たまに満タンに戻ったバケットを捨てる（map が増え続けないように）
+	l.ops++
+	if l.ops%1000 == 0 {
+		for k, v := range l.buckets {
+			if v.full(now) {
+				delete(l.buckets, k)
+			}
+		}
+	}
+	return allowed, wait
+}
+
+// Len は保持しているバケット数（テスト・監視用）
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewBucket(2, 3, now) // 毎秒2つ、最大3つ
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := b.Allow(now); !ok {
+			t.Fatalf("call %d within burst was limited", i+1)
+		}
+	}
+	ok, wait := b.Allow(now)
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("over burst: ok=%v wait=%v, want false 500ms", ok, wait)
+	}
+
+	// 0.5秒で1つ溜まる
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := b.Allow(now); !ok {
+		t.Fatal("refilled token was not available")
+	}
+	if ok, _ := b.Allow(now); ok {
+		t.Fatal("second call after refill should be limited")
+	}
+
+	// 長く空いても burst までしか溜まらない
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if ok, _ := b.Allow(now); !ok {
+			t.Fatalf("call %d after idle was limited", i+1)
+		}
+	}
+	if ok, _ := b.Allow(now); ok {
+		t.Fatal("bucket refilled beyond burst")
+	}
+}
+
+func TestLimiterKeys(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(1, 1)
+	if ok, _ := l.Allow("user:1", now); !ok {
+		t.Fatal("first call limited")
+	}
+	if ok, _ := l.Allow("user:1", now); ok {
+		t.Fatal("second call for same key should be limited")
+	}
+	// 別のキーは別のバケット
+	if ok, _ := l.Allow("user:2", now); !ok {
+		t.Fatal("other key limited")
+	}
+}
+
+func TestLimiterCleanup(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(1, 1)
+	for i := 0; i < 999; i++ {
+		l.Allow(fmt.Sprintf("ip:%d", i), now)
+	}
+	// 1000回目の呼び出しで、満タンに戻ったバケットが捨てられる
+	l.Allow("ip:last", now.Add(time.Minute))
+	if n := l.Len(); n != 1 {
+		t.Fatalf("buckets after cleanup = %d, want 1", n)
+	}
+}
@@ -0,0 +1,239 @@
+package response
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Code はエラーの種類（クライアントが分岐に使うので一度決めたら変えない）
+type Code string
+
+const (
+	// リクエストの形
+	CodeBadRequest       Code = "bad_request"
+	CodeInvalidJSON      Code = "invalid_json"
+	CodeValidationFailed Code = "validation_failed"
+	CodeNotFound         Code = "not_found"
+	CodeMethodNotAllowed Code = "method_not_allowed"
+	CodeUnsupportedMedia Code = "unsupported_media_type"
+
+	// 認証・権限
+	CodeUnauthorized       Code = "unauthorized"
+	CodeTokenInvalid       Code = "token_invalid"
+	CodeTokenRevoked       Code = "token_revoked"
+	CodeInvalidCredentials Code = "invalid_credentials"
+	CodeLoginLocked        Code = "login_locked"
+	CodeAccountBanned      Code = "account_banned"
+	CodeForbidden          Code = "forbidden"
+
+	// アカウント
+	CodeUserNotFound      Code = "user_not_found"
+	CodeNameTaken         Code = "name_taken"
+	CodeAlreadyRegistered Code = "already_registered"
+	CodeGuestAccount      Code = "guest_account"
+	CodeInvalidCode       Code = "invalid_code"
+	CodeNameChangeTooSoon Code = "name_change_too_soon"
+
+	// ルーム・ゲーム
+	CodeRoomNotFound       Code = "room_not_found"
+	CodeRoomFull           Code = "room_full"
+	CodeRoomNotWaiting     Code = "room_not_waiting"
+	CodeNotInRoom          Code = "not_in_room"
+	CodeNotHost            Code = "not_host"
+	CodeNotAllReady        Code = "not_all_ready"
+	CodeAlreadyStarted     Code = "already_started"
+	CodeRoomClosed         Code = "room_closed"
+	CodeSeatTaken          Code = "seat_taken"
+	CodeInvalidSeat        Code = "invalid_seat"
+	CodeInvalidSwapTarget  Code = "invalid_swap_target"
+	CodeGameNotFound       Code = "game_not_found"
+	CodeInsufficientChips  Code = "insufficient_chips"
+	CodeNotDealer          Code = "not_dealer"
+	CodeNoBets             Code = "no_bets"
+	CodeInvalidRoundResult Code = "invalid_round_result"
+	CodeInvalidRoundAction Code = "invalid_round_action"
+	CodeSeedMismatch       Code = "seed_mismatch"
+	CodeConflict           Code = "conflict"
+
+	// サーバーの状態
+	CodeRateLimited  Code = "rate_limited"
+	CodeMaintenance  Code = "maintenance"
+	CodeShuttingDown Code = "shutting_down"
+	CodeInternal     Code = "internal_error"
+)
+
+// FieldCode は入力項目のエラーの種類
+type FieldCode string
+
+const (
+	FieldRequired   FieldCode = "required"
+	FieldInvalid    FieldCode = "invalid"
+	FieldTooShort   FieldCode = "too_short"
+	FieldTooLong    FieldCode = "too_long"
+	FieldOutOfRange FieldCode = "out_of_range"
+)
+
+// ---- 文言 ----
+//
+// 表示用の文言を code ごとに言語別に持つ（クライアントは code で分岐し、文言はそのまま出してよい）。
+// code を足したら全言語に足すこと（足りない言語は日本語で返る）。
+
+var messages = map[Lang]map[Code]string{
+	LangJa: {
+		CodeBadRequest:         "リクエストが正しくありません",
+		CodeInvalidJSON:        "リクエストの形式が正しくありません",
+		CodeValidationFailed:   "入力内容に誤りがあります",
+		CodeNotFound:           "見つかりません",
+		CodeMethodNotAllowed:   "このメソッドには対応していません",
+		CodeUnsupportedMedia:   "対応していない Content-Type です",
+		CodeUnauthorized:       "ログインが必要です",
+		CodeTokenInvalid:       "ログインの有効期限が切れました。もう一度ログインしてください",
+		CodeTokenRevoked:       "ログアウト済みです。もう一度ログインしてください",
+		CodeInvalidCredentials: "名前またはパスワードが違います",
+		CodeLoginLocked:        "ログインの失敗が続いたため、しばらくログインできません",
+		CodeAccountBanned:      "このアカウントは利用停止中です",
+		CodeForbidden:          "この操作は許可されていません",
+		CodeUserNotFound:       "ユーザーが見つかりません",
+		CodeNameTaken:          "この名前は既に使用されています",
+		CodeAlreadyRegistered:  "このアカウントは登録済みです",
+		CodeGuestAccount:       "ゲストアカウントではこの操作はできません",
+		CodeInvalidCode:        "コードが正しくないか、有効期限が切れています",
+		CodeNameChangeTooSoon:  "名前はまだ変更できません。しばらくしてから変更してください",
+		CodeRoomNotFound:       "ルームが見つかりません",
+		CodeRoomFull:           "ルームが満員です",
+		CodeRoomNotWaiting:     "このルームには参加できません",
+		CodeNotInRoom:          "ルームに参加していません",
+		CodeNotHost:            "ホストだけが操作できます",
+		CodeNotAllReady:        "全員の準備ができていません",
+		CodeAlreadyStarted:     "ゲームは既に始まっています",
+		CodeRoomClosed:         "ルームは既に終了しています",
+		CodeSeatTaken:          "その席は埋まっています",
+		CodeInvalidSeat:        "その席は選べません",
+		CodeInvalidSwapTarget:  "席を交換できる相手ではありません",
+		CodeGameNotFound:       "ゲームが見つかりません",
+		CodeInsufficientChips:  "チップが足りません",
+		CodeNotDealer:          "この操作はディーラーだけができます",
+		CodeNoBets:             "精算するベットがありません",
+		CodeInvalidRoundResult: "ラウンドの結果が正しくありません",
+		CodeInvalidRoundAction: "その操作は今はできません",
+		CodeSeedMismatch:       "シードが配る前に送られたものと一致しません",
+		CodeConflict:           "他の操作と重なりました。もう一度お試しください",
+		CodeRateLimited:        "しばらく時間をおいてから再度お試しください",
+		CodeMaintenance:        "メンテナンス中のため受け付けていません",
+		CodeShuttingDown:       "サーバーの再起動中です。しばらくしてから再度お試しください",
+		CodeInternal:           "サーバーでエラーが発生しました",
+	},
+	LangEn: {
+		CodeBadRequest:         "The request is invalid.",
+		CodeInvalidJSON:        "The request body is not valid JSON.",
+		CodeValidationFailed:   "Some fields are invalid.",
+		CodeNotFound:           "Not found.",
+		CodeMethodNotAllowed:   "This method is not supported.",
+		CodeUnsupportedMedia:   "This Content-Type is not supported.",
+		CodeUnauthorized:       "Please log in.",
+		CodeTokenInvalid:       "Your session has expired. Please log in again.",
+		CodeTokenRevoked:       "You have been logged out. Please log in again.",
+		CodeInvalidCredentials: "The name or password is incorrect.",
+		CodeLoginLocked:        "Too many failed attempts. Please try again later.",
+		CodeAccountBanned:      "This account has been suspended.",
+		CodeForbidden:          "You are not allowed to do this.",
+		CodeUserNotFound:       "User not found.",
+		CodeNameTaken:          "This name is already taken.",
+		CodeAlreadyRegistered:  "This account is already registered.",
+		CodeGuestAccount:       "Guest accounts cannot do this.",
+		CodeInvalidCode:        "The code is invalid or has expired.",
+		CodeNameChangeTooSoon:  "You changed your name recently. Please try again later.",
+		CodeRoomNotFound:       "Room not found.",
+		CodeRoomFull:           "The room is full.",
+		CodeRoomNotWaiting:     "You cannot join this room.",
+		CodeNotInRoom:          "You are not in this room.",
+		CodeNotHost:            "Only the host can do this.",
+		CodeNotAllReady:        "Not everyone is ready.",
+		CodeAlreadyStarted:     "The game has already started.",
+		CodeRoomClosed:         "The room has already closed.",
+		CodeSeatTaken:          "That seat is taken.",
+		CodeInvalidSeat:        "You cannot choose that seat.",
+		CodeInvalidSwapTarget:  "You cannot swap seats with that player.",
+		CodeGameNotFound:       "Game not found.",
+		CodeInsufficientChips:  "You do not have enough chips.",
+		CodeNotDealer:          "Only the dealer can do this.",
+		CodeNoBets:             "There are no confirmed bets to settle.",
+		CodeInvalidRoundResult: "The round result is invalid.",
+		CodeInvalidRoundAction: "That action is not allowed right now.",
+		CodeSeedMismatch:       "The seed does not match the commitment sent before the deal.",
+		CodeConflict:           "Another operation got in the way. Please try again.",
+		CodeRateLimited:        "Too many requests. Please wait a moment and try again.",
+		CodeMaintenance:        "The server is under maintenance.",
+		CodeShuttingDown:       "The server is restarting. Please try again shortly.",
+		CodeInternal:           "Something went wrong on the server.",
+	},
+}
+
+var fieldMessages = map[Lang]map[FieldCode]string{
+	LangJa: {
+		FieldRequired:   "入力してください",
+		FieldInvalid:    "正しくありません",
+		FieldTooShort:   "短すぎます",
+		FieldTooLong:    "長すぎます",
+		FieldOutOfRange: "範囲外です",
+	},
+	LangEn: {
+		FieldRequired:   "This field is required.",
+		FieldInvalid:    "This value is invalid.",
+		FieldTooShort:   "This value is too short.",
+		FieldTooLong:    "This value is too long.",
+		FieldOutOfRange: "This value is out of range.",
+	},
+}
+
+// Message は r への応答に使う言語での code の文言
+func Message(r *http.Request, code Code) string {
+	return MessageIn(Language(r), code)
+}
+
+// MessageIn は lang での code の文言（WebSocket など、リクエストの外で文言を作るとき用）
+func MessageIn(lang Lang, code Code) string {
+	if m, ok := messages[lang][code]; ok {
+		return m
+	}
+	if m, ok := messages[DefaultLang][code]; ok {
+		return m
+	}
+	return MessageIn(lang, CodeInternal)
+}
+
+// FieldMessage は r への応答に使う言語での入力項目のエラー code の文言
+func FieldMessage(r *http.Request, code FieldCode) string {
+	return FieldMessageIn(Language(r), code)
+}
+
+// FieldMessageIn は lang での入力項目のエラー code の文言
+func FieldMessageIn(lang Lang, code FieldCode) string {
+	if m, ok := fieldMessages[lang][code]; ok {
+		return m
+	}
+	if m, ok := fieldMessages[DefaultLang][code]; ok {
+		return m
+	}
+	return FieldMessageIn(lang, FieldInvalid)
+}
+
+// Codes は定義済みのエラー code の一覧（API 仕様書の列挙に使う）
+func Codes() []Code {
+	codes := make([]Code, 0, len(messages[DefaultLang]))
+	for c := range messages[DefaultLang] {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// FieldCodes は定義済みの入力項目のエラー code の一覧
+func FieldCodes() []FieldCode {
+	codes := make([]FieldCode, 0, len(fieldMessages[DefaultLang]))
+	for c := range fieldMessages[DefaultLang] {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
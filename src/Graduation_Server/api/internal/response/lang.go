@@ -0,0 +1,125 @@
+package response
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ---- 文言の言語 ----
+//
+// エラーの message などサーバーが作る文言は、次の順で決めた言語で返す。
+//   1. ユーザー設定（settings.language。ログイン済みで設定してある場合）
+//   2. Accept-Language
+//   3. 日本語
+// 旧ルートの失敗の文字列も同じ文言を使う。
+
+// Lang は文言の言語
+type Lang string
+
+const (
+	LangJa Lang = "ja"
+	LangEn Lang = "en"
+)
+
+// DefaultLang は決められないときの言語
+const DefaultLang = LangJa
+
+// Langs は対応している言語
+func Langs() []Lang {
+	return []Lang{LangJa, LangEn}
+}
+
+// ParseLang は "en" / "en-US" / "ja_JP" などを対応している言語にする
+func ParseLang(s string) (Lang, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if i := strings.IndexAny(s, "-_"); i >= 0 {
+		s = s[:i]
+	}
+	for _, l := range Langs() {
+		if s == string(l) {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// userLanguage はログイン中のユーザーが設定した言語を返す（handlers が登録する）
+var userLanguage func(r *http.Request) (Lang, bool)
+
+// InitUserLanguage はユーザー設定の言語の引き方を登録する（起動時に1回）
+func InitUserLanguage(f func(r *http.Request) (Lang, bool)) {
+	userLanguage = f
+}
+
+// 1リクエストで何度も文言を作っても、ユーザー設定は1回だけ引く
+type langCache struct {
+	lang Lang
+	done bool
+}
+
+type langKey struct{}
+
+// Language は r への応答に使う言語
+func Language(r *http.Request) Lang {
+	cache, _ := r.Context().Value(langKey{}).(*langCache)
+	if cache != nil && cache.done {
+		return cache.lang
+	}
+	lang := resolveLanguage(r)
+	if cache != nil {
+		cache.lang, cache.done = lang, true
+	}
+	return lang
+}
+
+func withLangCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, langKey{}, &langCache{})
+}
+
+func resolveLanguage(r *http.Request) Lang {
+	if userLanguage != nil {
+		if l, ok := userLanguage(r); ok {
+			return l
+		}
+	}
+	if l, ok := AcceptLanguage(r.Header.Get("Accept-Language")); ok {
+		return l
+	}
+	return DefaultLang
+}
+
+// AcceptLanguage は Accept-Language のうち q の大きい順で最初に対応している言語
+func AcceptLanguage(header string) (Lang, bool) {
+	type tag struct {
+		lang string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = f
+		}
+		if q > 0 {
+			tags = append(tags, tag{lang, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	for _, t := range tags {
+		if l, ok := ParseLang(t.lang); ok {
+			return l, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,196 @@
+// Package response は API の応答の形をそろえる。
+//
+// /api/v1 の応答は成功・失敗とも同じ形（エンベロープ）で返す。
+//
+//	{"ok": true,  "data": {...}}
+//	{"ok": false, "error": {"code": "room_full", "message": "ルームが満員です"}}
+//	{"ok": false, "error": {"code": "validation_failed", "message": "...",
+//	                        "fields": [{"field": "name", "code": "required", "message": "..."}]}}
+//
+// code は変わらない識別子（クライアントはこれで分岐する）、message は表示用の文言
+// （言語はユーザー設定か Accept-Language で決める。lang.go）。
+//
+// 旧ルート（/api/... の v1 以外）は互換のため以前と同じ形で返す。
+// 成功は "result": "OK" を足した JSON（配列・文字列はそのまま）、失敗は http.Error の文字列
+// （文字列は v1 の message と同じ文言）。
+// どちらで返すかは Versioning ミドルウェアが決めたものをリクエストの context から読む。
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// V1Prefix は現行版の API のパス
+const V1Prefix = "/api/v1"
+
+// Envelope は /api/v1 の応答
+type Envelope struct {
+	OK    bool        `json:"ok"`
+	Data  interface{} `json:"data,omitempty"`
+	Error *Error      `json:"error,omitempty"`
+}
+
+// Error は失敗の中身
+type Error struct {
+	Code    Code         `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError は入力項目ごとのエラー
+type FieldError struct {
+	Field   string    `json:"field"`
+	Code    FieldCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// ---- 版の判定 ----
+
+type legacyKey struct{}
+
+// Versioning は /api/v1 以外の /api/... を旧形式の応答にする（ルーター全体に掛ける）。
+// 文言の言語もここでリクエストごとに1回だけ決めるようにしておく。
+func Versioning(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := withLangCache(r.Context())
+		if strings.HasPrefix(r.URL.Path, "/api/") && !isV1Path(r.URL.Path) {
+			ctx = WithLegacy(ctx)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func isV1Path(path string) bool {
+	return path == V1Prefix || strings.HasPrefix(path, V1Prefix+"/")
+}
+
+// WithLegacy は旧形式で返す印を付けた ctx を返す（ハンドラ単体のテストなど）
+func WithLegacy(ctx context.Context) context.Context {
+	return context.WithValue(ctx, legacyKey{}, true)
+}
+
+// IsLegacy は旧ルートへのリクエストかどうか
+func IsLegacy(r *http.Request) bool {
+	v, _ := r.Context().Value(legacyKey{}).(bool)
+	return v
+}
+
+// ---- 成功 ----
+
+// OK は data を 200 で返す。
+// 旧ルートでは data が JSON オブジェクトなら "result": "OK" を足し、それ以外はそのまま返す。
+func OK(w http.ResponseWriter, r *http.Request, data interface{}) {
+	if !IsLegacy(r) {
+		writeJSON(w, r, http.StatusOK, Envelope{OK: true, Data: data})
+		return
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "response encode failed", "err", err)
+		http.Error(w, Message(r, CodeInternal), http.StatusInternalServerError)
+		return
+	}
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(raw, &obj) == nil && obj != nil {
+		obj["result"] = json.RawMessage(`"OK"`)
+		writeJSON(w, r, http.StatusOK, obj)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, json.RawMessage(raw))
+}
+
+// Text は旧ルートでは text を本文そのままで返し、v1 では data を返す
+// （以前から JSON でなく文字列を返していた API 用）
+func Text(w http.ResponseWriter, r *http.Request, text string, data interface{}) {
+	if IsLegacy(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte(text))
+		return
+	}
+	OK(w, r, data)
+}
+
+// ---- 失敗 ----
+
+// Fail は code の失敗を status で返す。
+// 文言は code の既定のもの（v1 は message、旧ルートは http.Error の文字列）。
+func Fail(w http.ResponseWriter, r *http.Request, status int, code Code) {
+	FailMessage(w, r, status, code, Message(r, code))
+}
+
+// FailMessage は既定の文言の代わりに message を返す（運営が決めたメンテナンス文言など）
+func FailMessage(w http.ResponseWriter, r *http.Request, status int, code Code, message string) {
+	w.Header().Set("Content-Language", string(Language(r)))
+	if IsLegacy(r) {
+		http.Error(w, message, status)
+		return
+	}
+	writeJSON(w, r, status, Envelope{Error: &Error{Code: code, Message: message}})
+}
+
+// NG は旧ルートでは 200 の {"result": "NG", "message": ...} を返していた失敗。
+// v1 では他の失敗と同じく status で返す。
+func NG(w http.ResponseWriter, r *http.Request, status int, code Code) {
+	if IsLegacy(r) {
+		w.Header().Set("Content-Language", string(Language(r)))
+		writeJSON(w, r, http.StatusOK, map[string]interface{}{"result": "NG", "message": Message(r, code)})
+		return
+	}
+	Fail(w, r, status, code)
+}
+
+// Invalid は入力項目のエラーを 400 validation_failed で返す。
+// 旧ルートは文字列に項目ごとの文言を並べる（例: "入力内容に誤りがあります (name: 入力してください)"）。
+func Invalid(w http.ResponseWriter, r *http.Request, fields ...FieldError) {
+	w.Header().Set("Content-Language", string(Language(r)))
+	for i := range fields {
+		if fields[i].Message == "" {
+			fields[i].Message = FieldMessage(r, fields[i].Code)
+		}
+	}
+	message := Message(r, CodeValidationFailed)
+	if IsLegacy(r) {
+		if len(fields) > 0 {
+			parts := make([]string, len(fields))
+			for i, f := range fields {
+				parts[i] = f.Field + ": " + f.Message
+			}
+			message += " (" + strings.Join(parts, ", ") + ")"
+		}
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, r, http.StatusBadRequest, Envelope{Error: &Error{
+		Code:    CodeValidationFailed,
+		Message: message,
+		Fields:  fields,
+	}})
+}
+
+// Field は項目 field のエラー（文言は code の既定のもの）
+func Field(field string, code FieldCode) FieldError {
+	return FieldError{Field: field, Code: code}
+}
+
+// BadJSON はボディが JSON として読めなかったときの 400
+func BadJSON(w http.ResponseWriter, r *http.Request) {
+	Fail(w, r, http.StatusBadRequest, CodeInvalidJSON)
+}
+
+// Internal は DB エラーなどサーバー側の失敗の 500（原因は呼び出し側でログに出す）
+func Internal(w http.ResponseWriter, r *http.Request) {
+	Fail(w, r, http.StatusInternalServerError, CodeInternal)
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.DebugContext(r.Context(), "response write failed", "err", err)
+	}
+}
@@ -0,0 +1,262 @@
+package server_test
+
+import (
+	"api/internal/bot"
+	"api/internal/handlers"
+	"api/internal/response"
+	"api/internal/store"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isCode は err が status と error.code の応答かどうか
+func isCode(err error, status int, code response.Code) bool {
+	var se *bot.StatusError
+	return errors.As(err, &se) && se.Status == status && se.Code == code
+}
+
+// ゲストは名前とパスワードを決めて本登録でき、同じ ID のまま名前＋パスワードでログインできる
+func TestAccountUpgrade(t *testing.T) {
+	srv, _ := startServer(t)
+	const password = "upgrade-password-123"
+	guest := bot.New(srv.URL, "acct-guest")
+	if err := guest.CreateAccount(""); err != nil {
+		t.Fatal(err)
+	}
+	member := bot.New(srv.URL, "acct-taken")
+	if err := member.CreateAccount(password); err != nil {
+		t.Fatal(err)
+	}
+	upgrade := func(c *bot.Client, name, password string) (handlers.UpgradeGuestResponse, error) {
+		var resp handlers.UpgradeGuestResponse
+		err := c.Call("POST", "/account/upgrade", handlers.UpgradeGuestRequest{Name: name, Password: password}, &resp)
+		return resp, err
+	}
+
+	if _, err := upgrade(guest, "", "  "); !isCode(err, http.StatusBadRequest, response.CodeValidationFailed) {
+		t.Fatalf("upgrade without a password: err = %v", err)
+	}
+	if _, err := upgrade(guest, "acct-taken", password); !isCode(err, http.StatusConflict, response.CodeNameTaken) {
+		t.Fatalf("upgrade to a taken name: err = %v", err)
+	}
+	if _, err := upgrade(member, "", password); !isCode(err, http.StatusConflict, response.CodeAlreadyRegistered) {
+		t.Fatalf("upgrade of a registered account: err = %v", err)
+	}
+
+	resp, err := upgrade(guest, " acct-member ", password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Name != "acct-member" || resp.Token == "" {
+		t.Fatalf("upgrade = %+v", resp)
+	}
+	if _, err := upgrade(guest, "", password); !isCode(err, http.StatusConflict, response.CodeAlreadyRegistered) {
+		t.Fatalf("second upgrade: err = %v", err)
+	}
+
+	again := bot.New(srv.URL, "acct-member")
+	if err := again.Login(password); err != nil {
+		t.Fatal(err)
+	}
+	if again.UserID != guest.UserID {
+		t.Fatalf("user id after upgrade = %d, want %d", again.UserID, guest.UserID)
+	}
+
+	// 名前を省略すれば今の名前のまま
+	keep := bot.New(srv.URL, "acct-keep")
+	if err := keep.CreateAccount(""); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := upgrade(keep, "", password); err != nil || resp.Name != "acct-keep" {
+		t.Fatalf("upgrade keeping the name = %+v, %v", resp, err)
+	}
+}
+
+// 引き継ぎコードは最新の1つだけが一度だけ使え、使うと旧端末のセッションは失効する
+func TestAccountTransferCode(t *testing.T) {
+	srv, _ := startServer(t)
+	old := bot.New(srv.URL, "acct-transfer")
+	if err := old.CreateAccount(""); err != nil {
+		t.Fatal(err)
+	}
+	issue := func() handlers.TransferCodeResponse {
+		t.Helper()
+		var resp handlers.TransferCodeResponse
+		if err := old.Call("POST", "/account/transfer_code", nil, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.TransferCode) != 14 || resp.ExpiresAt <= time.Now().Unix() {
+			t.Fatalf("transfer code = %+v", resp)
+		}
+		return resp
+	}
+	redeem := func(code string) (handlers.TransferLoginResponse, error) {
+		var resp handlers.TransferLoginResponse
+		err := bot.New(srv.URL, "").Call("POST", "/account/transfer", handlers.RedeemTransferCodeRequest{TransferCode: code}, &resp)
+		return resp, err
+	}
+
+	if err := bot.New(srv.URL, "").Call("POST", "/account/transfer_code", nil, nil); !isStatus(err, http.StatusUnauthorized) {
+		t.Fatalf("issue without a token: err = %v", err)
+	}
+	first := issue()
+	second := issue()
+
+	if _, err := redeem("ABCD-EFGH"); !isCode(err, http.StatusBadRequest, response.CodeValidationFailed) {
+		t.Fatalf("redeem a malformed code: err = %v", err)
+	}
+	// 新しいコードを発行すると前のコードは使えない
+	if _, err := redeem(first.TransferCode); !isCode(err, http.StatusUnauthorized, response.CodeInvalidCode) {
+		t.Fatalf("redeem a replaced code: err = %v", err)
+	}
+	// ハイフン・小文字の入力ゆれは吸収する
+	typed := strings.ToLower(strings.ReplaceAll(second.TransferCode, "-", " "))
+	resp, err := redeem(typed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.UserID != old.UserID || resp.Name != "acct-transfer" || resp.Token == "" {
+		t.Fatalf("redeem = %+v", resp)
+	}
+	if _, err := redeem(second.TransferCode); !isCode(err, http.StatusUnauthorized, response.CodeInvalidCode) {
+		t.Fatalf("redeem a used code: err = %v", err)
+	}
+
+	// 旧端末のトークンは失効し、新端末のトークンで続きから使える
+	if err := old.Call("GET", "/get_main_data", nil, nil); !isStatus(err, http.StatusUnauthorized) {
+		t.Fatalf("old device after transfer: err = %v", err)
+	}
+	moved := bot.New(srv.URL, "acct-transfer")
+	moved.Token = resp.Token
+	if err := moved.Call("GET", "/get_main_data", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// パスワード変更は現在のパスワードを確かめ、変更後は他の端末のセッションを失効させる
+func TestPasswordChange(t *testing.T) {
+	srv, _ := startServer(t)
+	const password, next = "change-password-123", "change-password-456"
+	user := bot.New(srv.URL, "pw-change")
+	if err := user.CreateAccount(password); err != nil {
+		t.Fatal(err)
+	}
+	other := bot.New(srv.URL, "pw-change")
+	if err := other.Login(password); err != nil {
+		t.Fatal(err)
+	}
+	change := func(c *bot.Client, current, next string) (handlers.TokenResponse, error) {
+		var resp handlers.TokenResponse
+		err := c.Call("POST", "/account/password", handlers.ChangePasswordRequest{CurrentPassword: current, NewPassword: next}, &resp)
+		return resp, err
+	}
+
+	_, err := change(user, password, " ")
+	var se *bot.StatusError
+	if !errors.As(err, &se) || se.Code != response.CodeValidationFailed {
+		t.Fatalf("change without a new password: err = %v", err)
+	}
+	if f := decodeEnvelope(t, se.Body).Error.Fields; len(f) != 1 || f[0].Field != "new_password" {
+		t.Fatalf("fields = %+v, want new_password", f)
+	}
+	if _, err := change(user, "wrong-password", next); !isCode(err, http.StatusUnauthorized, response.CodeInvalidCredentials) {
+		t.Fatalf("change with a wrong password: err = %v", err)
+	}
+	guest := bot.New(srv.URL, "pw-guest")
+	if err := guest.CreateAccount(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := change(guest, "", next); !isCode(err, http.StatusBadRequest, response.CodeGuestAccount) {
+		t.Fatalf("change on a guest: err = %v", err)
+	}
+
+	resp, err := change(user, password, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Call("GET", "/get_main_data", nil, nil); !isStatus(err, http.StatusUnauthorized) {
+		t.Fatalf("other device after change: err = %v", err)
+	}
+	user.Token = resp.Token
+	if err := user.Call("GET", "/get_main_data", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.New(srv.URL, "pw-change").Login(password); !isStatus(err, http.StatusUnauthorized) {
+		t.Fatalf("login with the old password: err = %v", err)
+	}
+	if err := bot.New(srv.URL, "pw-change").Login(next); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// リカバリーコード・運営発行コードはどちらも一度だけ使え、使うと全端末のセッションを失効させる
+func TestPasswordReset(t *testing.T) {
+	srv, repo := startServer(t)
+	const password = "reset-password-123"
+	user := bot.New(srv.URL, "pw-reset")
+	if err := user.CreateAccount(password); err != nil {
+		t.Fatal(err)
+	}
+	issue := func() []string {
+		t.Helper()
+		var resp handlers.RecoveryCodesResponse
+		if err := user.Call("POST", "/account/recovery_codes", nil, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.RecoveryCodes) != 8 {
+			t.Fatalf("recovery codes = %v", resp.RecoveryCodes)
+		}
+		return resp.RecoveryCodes
+	}
+	reset := func(name, code, next string) (handlers.TokenResponse, error) {
+		var resp handlers.TokenResponse
+		err := bot.New(srv.URL, "").Call("POST", "/account/reset_password",
+			handlers.ResetPasswordRequest{Name: name, Code: code, NewPassword: next}, &resp)
+		return resp, err
+	}
+
+	old := issue()
+	codes := issue()
+	if _, err := reset("", "", ""); !isCode(err, http.StatusBadRequest, response.CodeValidationFailed) {
+		t.Fatalf("reset without fields: err = %v", err)
+	}
+	// 作り直す前のコード・他人の名前は同じ 401 にする
+	if _, err := reset("pw-reset", old[0], "after-old-code"); !isCode(err, http.StatusUnauthorized, response.CodeInvalidCredentials) {
+		t.Fatalf("reset with a replaced code: err = %v", err)
+	}
+	if _, err := reset("pw-nobody", codes[0], "after-other-name"); !isCode(err, http.StatusUnauthorized, response.CodeInvalidCredentials) {
+		t.Fatalf("reset for an unknown name: err = %v", err)
+	}
+
+	resp, err := reset("pw-reset", strings.ToLower(codes[0]), "after-recovery-code")
+	if err != nil || resp.Token == "" {
+		t.Fatalf("reset with a recovery code = %+v, %v", resp, err)
+	}
+	if err := user.Call("GET", "/get_main_data", nil, nil); !isStatus(err, http.StatusUnauthorized) {
+		t.Fatalf("session after reset: err = %v", err)
+	}
+	if _, err := reset("pw-reset", codes[0], "again"); !isCode(err, http.StatusUnauthorized, response.CodeInvalidCredentials) {
+		t.Fatalf("reset with a used code: err = %v", err)
+	}
+	if err := bot.New(srv.URL, "pw-reset").Login("after-recovery-code"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 運営発行のコード（api reset-code）
+	code, expiresAt, err := handlers.IssueAdminResetCode(repo, "pw-reset")
+	if err != nil || !expiresAt.After(time.Now()) {
+		t.Fatalf("IssueAdminResetCode = %q, %v, %v", code, expiresAt, err)
+	}
+	if _, err := reset("pw-reset", code, "after-admin-code"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.New(srv.URL, "pw-reset").Login("after-admin-code"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := handlers.IssueAdminResetCode(repo, "pw-nobody"); err != store.ErrNotFound {
+		t.Fatalf("IssueAdminResetCode for an unknown name: err = %v", err)
+	}
+}
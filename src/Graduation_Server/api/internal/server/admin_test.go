@@ -0,0 +1,144 @@
+package server_test
+
+import (
+	"api/internal/bot"
+	"api/internal/handlers"
+	"api/internal/models"
+	"api/internal/store"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// 運営API：player は弾かれ、admin になって再ログインすると使える。操作はすべて操作ログに残る。
+func TestAdminAPI(t *testing.T) {
+	srv, repo := startServer(t)
+	admin := newAdmin(t, srv.URL, repo)
+
+	host := bot.New(srv.URL, "admin-host")
+	if err := host.CreateAccount(""); err != nil {
+		t.Fatal(err)
+	}
+	code, err := host.CreateRoom(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := host.DialRoom(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Expect("room_status", nil, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var rooms struct {
+		Rooms []handlers.AdminRoomSummary `json:"rooms"`
+	}
+	if err := admin.Call("GET", "/admin/rooms", nil, &rooms); err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms.Rooms) != 1 || rooms.Rooms[0].RoomCode != code || rooms.Rooms[0].Players != 1 {
+		t.Fatalf("rooms = %+v", rooms.Rooms)
+	}
+
+	// チップの調整：残高がマイナスになる減額は 409
+	chipsPath := fmt.Sprintf("/admin/users/%d/chips", host.UserID)
+	var adjusted struct {
+		Entry models.ChipLedgerEntry `json:"entry"`
+	}
+	if err := admin.Call("POST", chipsPath, handlers.AdminAdjustChipsRequest{Wallet: "multi", Delta: 500, Reason: "障害の補填"}, &adjusted); err != nil {
+		t.Fatal(err)
+	}
+	if adjusted.Entry.Delta != 500 || adjusted.Entry.AdminID != admin.UserID {
+		t.Fatalf("entry = %+v", adjusted.Entry)
+	}
+	over := handlers.AdminAdjustChipsRequest{Wallet: "multi", Delta: -(adjusted.Entry.BalanceAfter + 1), Reason: "回収"}
+	if err := admin.Call("POST", chipsPath, over, nil); !isStatus(err, http.StatusConflict) {
+		t.Fatalf("overdraw: err = %v, want 409", err)
+	}
+	var ledger struct {
+		MultiTip int                      `json:"multi_tip"`
+		Entries  []models.ChipLedgerEntry `json:"ledger"`
+	}
+	if err := admin.Call("GET", chipsPath, nil, &ledger); err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger.Entries) != 1 || ledger.Entries[0].ID != adjusted.Entry.ID || ledger.MultiTip != adjusted.Entry.BalanceAfter {
+		t.Fatalf("ledger = %+v", ledger.Entries)
+	}
+
+	// ルームを閉じると接続中の参加者に room_closed が届いて切断される
+	if err := admin.Call("POST", "/admin/rooms/"+code+"/close", handlers.AdminCloseRoomRequest{Reason: "不正の調査"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Expect("room_closed", nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Closed(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.Call("GET", "/admin/rooms", nil, &rooms); err != nil {
+		t.Fatal(err)
+	}
+	if len(rooms.Rooms) != 0 {
+		t.Fatalf("rooms after close = %+v", rooms.Rooms)
+	}
+
+	// BAN すると発行済みのトークンはすぐ使えなくなる
+	if err := admin.Call("POST", fmt.Sprintf("/admin/users/%d/ban", admin.UserID), handlers.AdminBanRequest{Reason: "self"}, nil); !isStatus(err, http.StatusBadRequest) {
+		t.Fatalf("self ban: err = %v, want 400", err)
+	}
+	if err := admin.Call("POST", fmt.Sprintf("/admin/users/%d/ban", host.UserID), handlers.AdminBanRequest{Reason: "チート"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := host.CreateRoom(1, 2); !isStatus(err, http.StatusUnauthorized) {
+		t.Fatalf("banned user: err = %v, want 401", err)
+	}
+
+	// モードの切り替え：存在しない ID は 404
+	enable := true
+	if err := admin.Call("POST", "/admin/modes/1", handlers.AdminPlayableRequest{CanPlay: &enable}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.Call("POST", "/admin/modes/999", handlers.AdminPlayableRequest{CanPlay: &enable}, nil); !isStatus(err, http.StatusNotFound) {
+		t.Fatalf("unknown mode: err = %v, want 404", err)
+	}
+
+	var audit struct {
+		Entries []models.AuditEntry `json:"entries"`
+	}
+	if err := admin.Call("GET", "/admin/audit", nil, &audit); err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range audit.Entries {
+		if e.AdminID != admin.UserID {
+			t.Errorf("audit %s admin_id = %d", e.Action, e.AdminID)
+		}
+		actions = append(actions, e.Action)
+	}
+	if fmt.Sprint(actions) != "[mode.toggle user.ban room.close chips.adjust]" {
+		t.Errorf("audit actions = %v", actions)
+	}
+}
+
+// newAdmin はアカウントを作り、player では運営APIが 403 になることを確かめてから admin にして再ログインする
+func newAdmin(t *testing.T, baseURL string, repo *store.Memory) *bot.Client {
+	t.Helper()
+	const password = "ops-password-123"
+	admin := bot.New(baseURL, "ops")
+	if err := admin.CreateAccount(password); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.Call("GET", "/admin/rooms", nil, nil); !isStatus(err, http.StatusForbidden) {
+		t.Fatalf("player token: err = %v, want 403", err)
+	}
+	if err := repo.Users().SetRole(admin.UserID, models.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.Login(password); err != nil {
+		t.Fatal(err)
+	}
+	return admin
+}
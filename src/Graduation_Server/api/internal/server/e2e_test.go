@@ -0,0 +1,455 @@
+package server_test
+
+import (
+	"api/internal/auth"
+	"api/internal/bot"
+	"api/internal/config"
+	"api/internal/handlers"
+	"api/internal/middleware"
+	"api/internal/server"
+	"api/internal/store"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// e2e テスト：本番と同じルーターをメモリストアで起動し、bot から HTTP / WebSocket で操作する。
+// 届いたメッセージの順番も確認するので、-race 付きで回すこと（go test -race ./internal/server）。
+
+func TestMain(m *testing.M) {
+	// ハンドラのログ（タイマーなど）が多いので、-v のときだけ出す
+	flag.Parse()
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+
+	// 鍵・設定はパッケージ変数なので、前のテストの接続が残っていても競合しないよう最初に1回だけ設定する
+	issuer, err := auth.NewIssuer(map[string][]byte{"e2e": []byte("e2e-test-signing-key-0123456789abcdef")}, "e2e", 15*time.Minute)
+	if err != nil {
+		log.Fatal(err)
+	}
+	auth.Init(issuer)
+	handlers.InitConfig(testCfg)
+
+	os.Exit(m.Run())
+}
+
+var testCfg = func() *config.Config {
+	cfg := config.Default()
+	cfg.Timers.Action = time.Second
+	return cfg
+}()
+
+// 全ハンドラを載せたテスト用サーバーを起動する
+func startServer(t *testing.T) (*httptest.Server, *store.Memory) {
+	t.Helper()
+	repo := store.NewMemory()
+	handlers.InitStore(repo)
+	middleware.InitTokenStore(repo.Tokens())
+
+	var running sync.WaitGroup
+	srv := httptest.NewServer(trackHandlers(middleware.CORS(server.NewRouter(repo)), &running))
+	t.Cleanup(func() { closeServer(t, srv, &running) })
+	return srv, repo
+}
+
+// trackHandlers は WebSocket を含む全ハンドラの終了を running で待てるようにする
+// （httptest.Server.Close はアップグレードした接続のハンドラを待たない）
+func trackHandlers(h http.Handler, running *sync.WaitGroup) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		running.Add(1)
+		defer running.Done()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// closeServer は残っている WS を閉じ、切断時のログや通知まで含めてハンドラが全部終わるまで待つ
+// （次のテストに前のテストの接続が残らないように）
+func closeServer(t *testing.T, srv *httptest.Server, running *sync.WaitGroup) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	handlers.CloseAllConnections(ctx, "test finished")
+	srv.Close()
+
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Error("handlers still running after the server was closed")
+	}
+}
+
+// 各 bot で並行に f を実行し、最初のエラーを返す
+func each(bots []*bot.Client, f func(i int, b *bot.Client) error) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(bots))
+	for i, b := range bots {
+		wg.Add(1)
+		go func(i int, b *bot.Client) {
+			defer wg.Done()
+			errs[i] = f(i, b)
+		}(i, b)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// テスト終了時に接続を閉じ、失敗していたら受信履歴を出す
+func track(t *testing.T, conns *[]*bot.Conn, mu *sync.Mutex) func(*bot.Conn) *bot.Conn {
+	t.Cleanup(func() {
+		for _, c := range *conns {
+			if t.Failed() {
+				t.Log("\n" + c.Transcript("timer"))
+			}
+			_ = c.Close()
+		}
+	})
+	return func(c *bot.Conn) *bot.Conn {
+		mu.Lock()
+		defer mu.Unlock()
+		*conns = append(*conns, c)
+		return c
+	}
+}
+
+func allReady(s handlers.RoomStatusResponse) bool {
+	for _, p := range s.Players {
+		if !p.IsReady {
+			return false
+		}
+	}
+	return len(s.Players) > 0
+}
+
+// ログイン → ルーム作成・参加 → Ready → 開始 → ベット確定までを N 人で通す
+func TestE2EBlackjackBettingFlow(t *testing.T) {
+	const players = 4
+	const bet = 100
+	srv, _ := startServer(t)
+
+	var (
+		conns []*bot.Conn
+		mu    sync.Mutex
+	)
+	keep := track(t, &conns, &mu)
+
+	bots := make([]*bot.Client, players)
+	for i := range bots {
+		bots[i] = bot.New(srv.URL, fmt.Sprintf("bot%d", i+1))
+	}
+
+	// アカウント作成後、改めてパスワードでログインし直す
+	if err := each(bots, func(i int, b *bot.Client) error {
+		password := "password-" + b.Name
+		if err := b.CreateAccount(password); err != nil {
+			return err
+		}
+		return b.Login(password)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	host := bots[0]
+	code, err := host.CreateRoom(1, players)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := each(bots[1:], func(i int, b *bot.Client) error { return b.JoinRoom(code) }); err != nil {
+		t.Fatal(err)
+	}
+
+	// ルームWS：全員が揃った room_status が届く
+	roomConns := make([]*bot.Conn, players)
+	if err := each(bots, func(i int, b *bot.Client) error {
+		c, err := b.DialRoom(code)
+		if err != nil {
+			return err
+		}
+		roomConns[i] = keep(c)
+		_, err = bot.ExpectDecoded(c, "room_status", func(s handlers.RoomStatusResponse) bool {
+			return len(s.Players) == players
+		}, 0)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 全員 Ready → 全員 Ready の room_status → （ホストだけ）all_ready の順で届く
+	if err := each(bots, func(i int, b *bot.Client) error {
+		return roomConns[i].Send(handlers.RoomCommand{Type: "ready", IsReady: true})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := each(bots, func(i int, b *bot.Client) error {
+		c := roomConns[i]
+		if _, err := bot.ExpectDecoded(c, "room_status", allReady, 0); err != nil {
+			return err
+		}
+		if b == host {
+			_, err := c.Expect("all_ready", nil, 0)
+			return err
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range roomConns[1:] {
+		for _, m := range c.Messages() {
+			if m.Type == "all_ready" {
+				t.Fatalf("%s: all_ready must only be sent to the host", c.Label)
+			}
+		}
+	}
+
+	gameID, err := host.StartRoom(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := host.StartRoom(code); !isStatus(err, http.StatusConflict) {
+		t.Fatalf("second start: err = %v, want 409", err)
+	}
+	if err := each(bots, func(i int, b *bot.Client) error {
+		_, err := bot.ExpectDecoded(roomConns[i], "start_game", func(m handlers.StartGameBroadcast) bool {
+			return m.GameID == gameID && m.RoomCode == code
+		}, 0)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// ブラックジャックWS：最初のメッセージは必ず player_order（ディーラーは席1のホスト）
+	bjConns := make([]*bot.Conn, players)
+	if err := each(bots, func(i int, b *bot.Client) error {
+		c, err := b.DialBlackjack(code)
+		if err != nil {
+			return err
+		}
+		bjConns[i] = keep(c)
+		order, err := bot.ExpectDecoded[handlers.PlayerOrderMessage](c, "player_order", nil, 0)
+		if err != nil {
+			return err
+		}
+		if first := c.Messages()[0]; first.Type != "player_order" {
+			return fmt.Errorf("%s: first message is %q, want player_order", c.Label, first.Type)
+		}
+		if len(order.Players) != players {
+			return fmt.Errorf("%s: player_order has %d players", c.Label, len(order.Players))
+		}
+		for _, p := range order.Players {
+			if p.IsDealer != (p.UserID == host.UserID) {
+				return fmt.Errorf("%s: unexpected dealer flag %+v", c.Label, p)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// ディーラー以外がベットを確定 → 全員に all_confirmed の bet_state が届く
+	if err := each(bots[1:], func(i int, b *bot.Client) error {
+		return bjConns[i+1].Send(handlers.BetCommand{Type: "bet_update", Bet: bet, Confirm: true})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := each(bots, func(i int, b *bot.Client) error {
+		c := bjConns[i]
+		final, err := bot.ExpectDecoded(c, "bet_state", func(s handlers.BetStateBroadcast) bool {
+			return s.AllConfirmed
+		}, 0)
+		if err != nil {
+			return err
+		}
+		for _, p := range final.Players {
+			wantBet := bet
+			if p.UserID == host.UserID {
+				wantBet = 0
+			}
+			if p.Bet != wantBet || p.TotalChips != testCfg.Chips.TableStart-wantBet {
+				return fmt.Errorf("%s: unexpected player state %+v", c.Label, p)
+			}
+		}
+		return checkBetStateOrder(c)
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// bet_state は確定済みの人数が減らない順で届くはず（古い状態が後から届いたら順序の問題）
+func checkBetStateOrder(c *bot.Conn) error {
+	last := -1
+	for _, m := range c.Messages() {
+		if m.Type != "bet_state" {
+			continue
+		}
+		var s handlers.BetStateBroadcast
+		if err := m.Decode(&s); err != nil {
+			return fmt.Errorf("%s: #%d: %v", c.Label, m.Seq, err)
+		}
+		confirmed := 0
+		for _, p := range s.Players {
+			if p.Confirmed {
+				confirmed++
+			}
+		}
+		if confirmed < last {
+			return fmt.Errorf("%s: #%d: bet_state went back from %d to %d confirmed players", c.Label, m.Seq, last, confirmed)
+		}
+		last = confirmed
+	}
+	return nil
+}
+
+// 満席を超える同時参加と、同時退出でも席・ホストの整合性が崩れない
+func TestE2EConcurrentJoinAndLeave(t *testing.T) {
+	const maxPlayers = 4
+	const guests = 7
+	srv, repo := startServer(t)
+
+	var (
+		conns []*bot.Conn
+		mu    sync.Mutex
+	)
+	keep := track(t, &conns, &mu)
+
+	bots := make([]*bot.Client, guests+1)
+	for i := range bots {
+		bots[i] = bot.New(srv.URL, fmt.Sprintf("guest%d", i+1))
+	}
+	if err := each(bots, func(i int, b *bot.Client) error { return b.CreateAccount("") }); err != nil {
+		t.Fatal(err)
+	}
+	host := bots[0]
+	code, err := host.CreateRoom(1, maxPlayers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostConn, err := host.DialRoom(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep(hostConn)
+
+	// 7人が同時に参加 → 3人だけ成功し、残りは 403（満席）
+	var joined []*bot.Client
+	var joinMu sync.Mutex
+	if err := each(bots[1:], func(i int, b *bot.Client) error {
+		err := b.JoinRoom(code)
+		if isStatus(err, http.StatusForbidden) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		joinMu.Lock()
+		joined = append(joined, b)
+		joinMu.Unlock()
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(joined) != maxPlayers-1 {
+		t.Fatalf("joined = %d, want %d", len(joined), maxPlayers-1)
+	}
+
+	members := append([]*bot.Client{host}, joined...)
+	memberConns := make([]*bot.Conn, len(members))
+	memberConns[0] = hostConn
+	if err := each(joined, func(i int, b *bot.Client) error {
+		c, err := b.DialRoom(code)
+		memberConns[i+1] = c
+		if err == nil {
+			keep(c)
+		}
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	status, err := bot.ExpectDecoded(hostConn, "room_status", func(s handlers.RoomStatusResponse) bool {
+		return len(s.Players) == maxPlayers
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seats := map[int]bool{}
+	for _, p := range status.Players {
+		if p.SeatNo < 1 || p.SeatNo > maxPlayers || seats[p.SeatNo] {
+			t.Fatalf("invalid seat assignment: %+v", status.Players)
+		}
+		seats[p.SeatNo] = true
+	}
+
+	// 全員が同時に退出 → ちょうど1人だけ「最後の1人」になり、ルームはクローズ
+	var zeroCount int
+	var leaveMu sync.Mutex
+	if err := each(members, func(i int, b *bot.Client) error {
+		resp, err := b.LeaveRoom(code)
+		if err != nil {
+			return err
+		}
+		if resp.RoomBecameZero {
+			leaveMu.Lock()
+			zeroCount++
+			leaveMu.Unlock()
+		}
+		// 退出したユーザーのルームWSはサーバー側から切られる
+		return memberConns[i].Closed(0)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if zeroCount != 1 {
+		t.Fatalf("room_became_zero reported %d times, want 1", zeroCount)
+	}
+	room, err := repo.Rooms().GetByCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if room.Status != "closed" {
+		t.Fatalf("room status = %q, want closed", room.Status)
+	}
+}
+
+// ログアウトしたアクセストークンでは WebSocket にも入れない
+func TestE2ELogoutRevokesWebSocketAccess(t *testing.T) {
+	srv, _ := startServer(t)
+	b := bot.New(srv.URL, "logout-bot")
+	if err := b.CreateAccount(""); err != nil {
+		t.Fatal(err)
+	}
+	code, err := b.CreateRoom(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := b.DialRoom(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Expect("room_status", nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Close()
+
+	if err := b.Logout(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.DialRoom(code); err == nil {
+		t.Fatal("dial with a revoked token should fail")
+	}
+}
+
+func isStatus(err error, status int) bool {
+	var se *bot.StatusError
+	return errors.As(err, &se) && se.Status == status
+}
@@ -0,0 +1,272 @@
+package server_test
+
+import (
+	"api/internal/bot"
+	"api/internal/handlers"
+	"api/internal/models"
+	"api/internal/response"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// 配る前に送ったシードと配った札・操作がベット・精算と並んで経過に残り、履歴から再生できる
+func TestRoundHistoryAndReplay(t *testing.T) {
+	srv, repo := startServer(t)
+	host := bot.New(srv.URL, "history-host")
+	guest := bot.New(srv.URL, "history-guest")
+	other := bot.New(srv.URL, "history-other")
+	for _, b := range []*bot.Client{host, guest, other} {
+		if err := b.CreateAccount(""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// ---- 卓に着く（ディーラーは席1のホスト）----
+	code, err := host.CreateRoom(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := guest.JoinRoom(code); err != nil {
+		t.Fatal(err)
+	}
+	var roomConns []*bot.Conn
+	for _, b := range []*bot.Client{host, guest} {
+		c, err := b.DialRoom(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		roomConns = append(roomConns, c)
+	}
+	for _, c := range roomConns {
+		if err := c.Send(handlers.RoomCommand{Type: "ready", RoomCode: code, IsReady: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect(t, roomConns[0], "room_status", allReady)
+	gameID, err := host.StartRoom(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostTable, err := host.DialBlackjack(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hostTable.Close()
+	guestTable, err := guest.DialBlackjack(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer guestTable.Close()
+	expect[handlers.PlayerOrderMessage](t, guestTable, "player_order", nil)
+
+	send := func(conn *bot.Conn, v interface{}) {
+		t.Helper()
+		if err := conn.Send(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tableError := func(conn *bot.Conn, want response.Code) {
+		t.Helper()
+		expect(t, conn, "table_error", func(e handlers.TableErrorResponse) bool { return e.Code == want })
+	}
+	action := func(name, card string, to int64) handlers.RoundActionCommand {
+		return handlers.RoundActionCommand{Type: "round_action", RoundActionEvent: models.RoundActionEvent{Action: name, Card: card, To: to}}
+	}
+
+	send(guestTable, handlers.BetCommand{Type: "bet_update", Bet: 100, Confirm: true})
+	expect(t, hostTable, "bet_state", func(s handlers.BetStateBroadcast) bool { return s.AllConfirmed })
+
+	// ---- シードの SHA-256 を先に送る ----
+	const seed = "table-seed-1"
+	commit := handlers.RoundCommitCommand{Type: "round_commit", SeedCommitment: sha256Hex(seed)}
+	send(guestTable, commit)
+	tableError(guestTable, response.CodeNotDealer)
+	send(hostTable, handlers.RoundCommitCommand{Type: "round_commit", SeedCommitment: "not-a-hash"})
+	tableError(hostTable, response.CodeInvalidRoundAction)
+	send(hostTable, commit)
+	send(hostTable, commit) // 1ラウンドに1回だけ
+	tableError(hostTable, response.CodeInvalidRoundAction)
+
+	// ---- 配った札と操作 ----
+	send(guestTable, action("deal", "AS", guest.UserID))
+	tableError(guestTable, response.CodeNotDealer)
+	for _, bad := range []handlers.RoundActionCommand{
+		action("peek", "", 0),
+		action("deal", "1X", guest.UserID),
+		action("deal", "AS", other.UserID),
+	} {
+		send(hostTable, bad)
+		tableError(hostTable, response.CodeInvalidRoundAction)
+	}
+	send(hostTable, action("deal", "AS", guest.UserID))
+	send(hostTable, action("deal", "10H", host.UserID))
+	// 同じ接続のエラーを待てば、その前の操作は記録済み
+	send(hostTable, action("reveal", "", 0))
+	tableError(hostTable, response.CodeInvalidRoundAction)
+	send(guestTable, action("stand", "", 0))
+	send(guestTable, action("stand", "", host.UserID))
+	tableError(guestTable, response.CodeInvalidRoundAction)
+
+	// ---- 精算でシードを明かす ----
+	result := handlers.RoundResultCommand{Type: "round_result", Results: []handlers.PlayerRoundResult{{UserID: guest.UserID, Result: "win", Blackjack: true}}}
+	// commit したラウンドはシードを省いても、違うシードでも精算しない
+	send(hostTable, result)
+	tableError(hostTable, response.CodeSeedMismatch)
+	result.Seed = "another-seed"
+	send(hostTable, result)
+	tableError(hostTable, response.CodeSeedMismatch)
+	result.Seed = seed
+	send(hostTable, result)
+	settled, err := bot.ExpectDecoded(guestTable, "round_settled", func(s handlers.RoundSettledBroadcast) bool { return s.Round == 1 }, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settled.RoundID == 0 || settled.GameID != gameID {
+		t.Fatalf("round_settled = %+v", settled)
+	}
+
+	// ---- リプレイ（参加した人と運営だけ）----
+	replay := func(c *bot.Client, path string, id int64) handlers.RoundReplayResponse {
+		t.Helper()
+		var resp handlers.RoundReplayResponse
+		if err := c.Call("GET", fmt.Sprintf("%s/%d", path, id), nil, &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	rp := replay(guest, "/history/rounds", settled.RoundID)
+	if got := eventKinds(rp.Events); got != "[start bet commit action action action settle]" {
+		t.Fatalf("events = %s", got)
+	}
+	if !rp.SeedVerified || rp.Seed != seed || rp.GameID != gameID || rp.RoundNo != 1 || rp.RoomCode != code || rp.ModeID != models.ModeMulti {
+		t.Fatalf("replay = %+v", rp)
+	}
+	start, deal, settle := rp.Events[0].Start, rp.Events[3], rp.Events[6].Settle
+	if start == nil || start.DealerID != host.UserID || len(start.Players) != 2 || start.Players[1].UserID != guest.UserID {
+		t.Fatalf("start = %+v", start)
+	}
+	if deal.UserID != host.UserID || deal.Action == nil || *deal.Action != (models.RoundActionEvent{Action: "deal", Card: "AS", To: guest.UserID}) {
+		t.Fatalf("deal = %+v", deal)
+	}
+	if settle == nil || settle.DealerNet != -150 || len(settle.Results) != 1 || settle.Results[0].Payout != 250 {
+		t.Fatalf("settle = %+v", settle)
+	}
+	for i, ev := range rp.Events {
+		if ev.Seq != i+1 {
+			t.Fatalf("events[%d].seq = %d", i, ev.Seq)
+		}
+	}
+	if replay(host, "/history/rounds", settled.RoundID).ID != settled.RoundID {
+		t.Fatal("dealer cannot replay the round")
+	}
+	if err := other.Call("GET", fmt.Sprintf("/history/rounds/%d", settled.RoundID), nil, nil); !isStatus(err, http.StatusNotFound) {
+		t.Fatalf("other user's replay: err = %v, want 404", err)
+	}
+	admin := newAdmin(t, srv.URL, repo)
+	if replay(admin, "/admin/rounds", settled.RoundID).SeedCommitment != sha256Hex(seed) {
+		t.Fatal("admin replay lost the seed commitment")
+	}
+
+	// ---- ソロ（経過を付けて送る）----
+	soloSeed := "solo-seed"
+	at := time.Now().Add(-time.Minute).UTC().Truncate(time.Millisecond)
+	solo := handlers.SoloRoundResult{
+		Bet: 20, Result: "lose", SeedCommitment: sha256Hex(soloSeed), Seed: soloSeed,
+		Actions: []handlers.SoloRoundAction{
+			{RoundActionEvent: models.RoundActionEvent{Action: "deal", Card: "9C", To: guest.UserID}, At: at},
+			{RoundActionEvent: models.RoundActionEvent{Action: "deal", Card: "KD"}, At: at},
+			{RoundActionEvent: models.RoundActionEvent{Action: "stand"}},
+		},
+	}
+	bad := solo
+	bad.Actions = []handlers.SoloRoundAction{{RoundActionEvent: models.RoundActionEvent{Action: "deal", Card: "9C", To: other.UserID}}}
+	if err := guest.Call("POST", "/updatesolotip", handlers.UpdateTipRequest{NewChips: -20, Round: &bad}, nil); !isStatus(err, http.StatusBadRequest) {
+		t.Fatalf("solo deal to another user: err = %v, want 400", err)
+	}
+	for _, seed := range []string{"", "other"} {
+		bad = solo
+		bad.Seed = seed
+		if err := guest.Call("POST", "/updatesolotip", handlers.UpdateTipRequest{NewChips: -20, Round: &bad}, nil); !isStatus(err, http.StatusBadRequest) {
+			t.Fatalf("solo seed %q: err = %v, want 400", seed, err)
+		}
+	}
+	var tip handlers.UpdateTipResponse
+	if err := guest.Call("POST", "/updatesolotip", handlers.UpdateTipRequest{NewChips: -20, Round: &solo}, &tip); err != nil {
+		t.Fatal(err)
+	}
+	if tip.RoundID == 0 {
+		t.Fatalf("updatesolotip = %+v", tip)
+	}
+	sp := replay(guest, "/history/rounds", tip.RoundID)
+	if got := eventKinds(sp.Events); got != "[start commit bet action action action settle]" {
+		t.Fatalf("solo events = %s", got)
+	}
+	if !sp.SeedVerified || sp.GameID != 0 || sp.ModeID != models.ModeSolo || !sp.Events[3].At.Equal(at) {
+		t.Fatalf("solo replay = %+v", sp)
+	}
+
+	// ---- 履歴（ゲームごと、新しい順）----
+	historyPage := func(c *bot.Client, query string) handlers.HistoryResponse {
+		t.Helper()
+		var resp handlers.HistoryResponse
+		if err := c.Call("GET", "/history"+query, nil, &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	history := func(c *bot.Client, query string) []handlers.HistoryGame {
+		t.Helper()
+		return historyPage(c, query).Games
+	}
+	games := history(guest, "")
+	if len(games) != 2 || games[0].ModeID != models.ModeSolo || games[0].Net != -20 || games[0].Mode == "" ||
+		games[1].GameID != gameID || games[1].Net != 150 || len(games[1].Rounds) != 1 || games[1].Rounds[0].RoundID != settled.RoundID {
+		t.Fatalf("guest history = %+v", games)
+	}
+	if r := games[0].Rounds; len(r) != 1 || r[0].RoundID != tip.RoundID || r[0].Result != models.RoundLose {
+		t.Fatalf("solo history rounds = %+v", r)
+	}
+	// 1ゲームずつページ送りする
+	page := historyPage(guest, "?limit=1")
+	if len(page.Games) != 1 || page.Games[0].ModeID != models.ModeSolo || page.NextBefore == 0 {
+		t.Fatalf("limit=1: %+v", page)
+	}
+	page = historyPage(guest, fmt.Sprintf("?limit=1&before=%d", page.NextBefore))
+	if len(page.Games) != 1 || page.Games[0].GameID != gameID || len(page.Games[0].Rounds) != 1 || page.NextBefore == 0 {
+		t.Fatalf("second page: %+v", page)
+	}
+	page = historyPage(guest, fmt.Sprintf("?limit=1&before=%d", page.NextBefore))
+	if len(page.Games) != 0 || page.NextBefore != 0 {
+		t.Fatalf("third page: %+v", page)
+	}
+	if page := historyPage(guest, ""); page.NextBefore != 0 {
+		t.Fatalf("next_before on the last page: %+v", page)
+	}
+	if games := history(host, ""); len(games) != 1 || games[0].Rounds[0].Role != models.RoundRoleDealer || games[0].Net != -150 {
+		t.Fatalf("host history = %+v", games)
+	}
+	if games := history(other, ""); len(games) != 0 {
+		t.Fatalf("other history = %+v", games)
+	}
+	if err := guest.Call("GET", "/history?limit=0", nil, nil); !isStatus(err, http.StatusBadRequest) {
+		t.Fatalf("limit=0: err = %v, want 400", err)
+	}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func eventKinds(events []models.RoundEvent) string {
+	kinds := make([]string, len(events))
+	for i, ev := range events {
+		kinds[i] = ev.Kind
+	}
+	return fmt.Sprint(kinds)
+}
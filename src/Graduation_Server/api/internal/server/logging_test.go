@@ -0,0 +1,92 @@
+package server_test
+
+import (
+	"api/internal/logging"
+	"api/internal/middleware"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// lockedBuffer はハンドラの goroutine からも書かれるログを受ける
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) lines() []map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		var m map[string]any
+		if json.Unmarshal([]byte(line), &m) == nil {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func TestRequestIDAndAccessLog(t *testing.T) {
+	srv, _ := startServer(t)
+
+	var buf lockedBuffer
+	l, err := logging.New(&buf, logging.FormatJSON, "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := slog.Default()
+	slog.SetDefault(l)
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	// 前段で付いたIDはそのまま使う
+	req, _ := http.NewRequest("POST", srv.URL+"/api/login", strings.NewReader(`{"user_name":"nobody","password":"x"}`))
+	req.Header.Set(middleware.RequestIDHeader, "lb-trace-42")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if got := res.Header.Get(middleware.RequestIDHeader); got != "lb-trace-42" {
+		t.Errorf("X-Request-ID = %q", got)
+	}
+
+	// 不正なIDは作り直す
+	req, _ = http.NewRequest("GET", srv.URL+"/healthz", nil)
+	req.Header.Set(middleware.RequestIDHeader, "bad id with spaces")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	generated := res.Header.Get(middleware.RequestIDHeader)
+	if generated == "" || strings.Contains(generated, " ") {
+		t.Errorf("generated X-Request-ID = %q", generated)
+	}
+
+	var access []map[string]any
+	for _, m := range buf.lines() {
+		if m["msg"] == "http request" {
+			access = append(access, m)
+		}
+	}
+	if len(access) != 2 {
+		t.Fatalf("access log lines = %d: %v", len(access), buf.lines())
+	}
+	if a := access[0]; a["request_id"] != "lb-trace-42" || a["route"] != "/api/login" || a["level"] != "INFO" {
+		t.Errorf("access log = %v", a)
+	}
+	if a := access[1]; a["request_id"] != generated || a["level"] != "DEBUG" {
+		t.Errorf("health check access log = %v", a)
+	}
+}
@@ -0,0 +1,65 @@
+package server_test
+
+import (
+	"api/internal/handlers"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	srv, _ := startServer(t)
+
+	// ルートテンプレート単位で数えられること（存在しないユーザーでも 1 件として残る）
+	res, err := http.Post(srv.URL+"/api/login", "application/json", strings.NewReader(`{"user_name":"nobody","password":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	res, err = http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", res.StatusCode)
+	}
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("content-type = %q", ct)
+	}
+	body, _ := io.ReadAll(res.Body)
+	for _, want := range []string{
+		`http_requests_total{method="POST",route="/api/login",status="`,
+		`http_request_duration_seconds_bucket{method="POST",route="/api/login",le="+Inf"}`,
+		`websocket_connections{endpoint="room"}`,
+		`rooms{status="waiting"}`,
+		`# TYPE chip_flow_total counter`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics に %q がない:\n%s", want, body)
+		}
+	}
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	srv, _ := startServer(t)
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		res, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var h handlers.HealthResponse
+		err = json.NewDecoder(res.Body).Decode(&h)
+		res.Body.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if res.StatusCode != http.StatusOK || h.Status != "ok" {
+			t.Errorf("%s = %d %+v", path, res.StatusCode, h)
+		}
+	}
+}
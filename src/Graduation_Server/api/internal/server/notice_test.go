@@ -0,0 +1,167 @@
+package server_test
+
+import (
+	"api/internal/bot"
+	"api/internal/handlers"
+	"api/internal/models"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// メンテナンス中は新しいルームの作成・参加だけ断り、お知らせは接続中の全員と get_main_data に届く
+func TestMaintenanceAndAnnouncements(t *testing.T) {
+	srv, repo := startServer(t)
+	admin := newAdmin(t, srv.URL, repo)
+
+	host := bot.New(srv.URL, "notice-host")
+	guest := bot.New(srv.URL, "notice-guest")
+	for _, b := range []*bot.Client{host, guest} {
+		if err := b.CreateAccount(""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	code, err := host.CreateRoom(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := host.DialRoom(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Expect("room_status", nil, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	on, off := true, false
+	if err := admin.Call("POST", "/admin/maintenance", handlers.AdminMaintenanceRequest{Enabled: &on, Message: "22時まで"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	m, err := bot.ExpectDecoded(c, "maintenance", func(n handlers.MaintenanceNotice) bool { return n.Enabled }, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Message != "22時まで" {
+		t.Errorf("maintenance message = %q", m.Message)
+	}
+	if _, err := guest.CreateRoom(1, 2); !isStatus(err, http.StatusServiceUnavailable) {
+		t.Fatalf("create during maintenance: err = %v, want 503", err)
+	}
+	if err := guest.JoinRoom(code); !isStatus(err, http.StatusServiceUnavailable) {
+		t.Fatalf("join during maintenance: err = %v, want 503", err)
+	}
+	// 既にあるルームはそのまま遊べる
+	if err := c.Send(map[string]any{"type": "ready", "is_ready": true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bot.ExpectDecoded(c, "room_status", allReady, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// すぐ表示するものと、少し後に始まる予約
+	now := time.Now()
+	var created struct {
+		Announcement models.Announcement `json:"announcement"`
+	}
+	req := handlers.AdminAnnouncementRequest{Message: "臨時メンテナンスのお知らせ", Severity: models.SeverityWarning, EndsAt: now.Add(time.Hour)}
+	if err := admin.Call("POST", "/admin/announcements", req, &created); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bot.ExpectDecoded(c, "announcement", func(n handlers.AnnouncementNotice) bool {
+		return n.Announcement.ID == created.Announcement.ID && n.Announcement.Severity == models.SeverityWarning
+	}, 0); err != nil {
+		t.Fatal(err)
+	}
+	startsAt := now.Add(300 * time.Millisecond)
+	scheduled := handlers.AdminAnnouncementRequest{Message: "イベント開始", StartsAt: &startsAt, EndsAt: now.Add(time.Hour)}
+	if err := admin.Call("POST", "/admin/announcements", scheduled, nil); err != nil {
+		t.Fatal(err)
+	}
+	n, err := bot.ExpectDecoded(c, "announcement", func(n handlers.AnnouncementNotice) bool { return n.Announcement.Message == "イベント開始" }, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Now().Before(startsAt) {
+		t.Errorf("scheduled announcement sent before starts_at")
+	}
+	bad := handlers.AdminAnnouncementRequest{Message: "x", Severity: "loud", EndsAt: now.Add(time.Hour)}
+	if err := admin.Call("POST", "/admin/announcements", bad, nil); !isStatus(err, http.StatusBadRequest) {
+		t.Fatalf("bad severity: err = %v, want 400", err)
+	}
+
+	var main struct {
+		Maintenance struct {
+			Enabled bool   `json:"enabled"`
+			Message string `json:"message"`
+		} `json:"maintenance"`
+		Announcements []models.Announcement `json:"announcements"`
+	}
+	if err := guest.Call("GET", "/get_main_data", nil, &main); err != nil {
+		t.Fatal(err)
+	}
+	if !main.Maintenance.Enabled || len(main.Announcements) != 2 || main.Announcements[0].ID != n.Announcement.ID {
+		t.Fatalf("main data = %+v", main)
+	}
+
+	if err := admin.Call("DELETE", fmt.Sprintf("/admin/announcements/%d", created.Announcement.ID), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bot.ExpectDecoded(c, "announcement_removed", func(n handlers.AnnouncementRemovedNotice) bool {
+		return n.ID == created.Announcement.ID
+	}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := admin.Call("POST", "/admin/maintenance", handlers.AdminMaintenanceRequest{Enabled: &off}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := guest.JoinRoom(code); err != nil {
+		t.Fatalf("join after maintenance: %v", err)
+	}
+}
+
+// rank_mode / game_mode は store のモード設定とメンテナンス状態をそのまま返す
+func TestModesReportMaintenance(t *testing.T) {
+	srv, repo := startServer(t)
+	admin := newAdmin(t, srv.URL, repo)
+	user := bot.New(srv.URL, "modes-user")
+	if err := user.CreateAccount(""); err != nil {
+		t.Fatal(err)
+	}
+	modes := func() (handlers.RankModeResponse, handlers.GameModeResponse) {
+		t.Helper()
+		var rank handlers.RankModeResponse
+		var game handlers.GameModeResponse
+		if err := user.Call("GET", "/rank_mode", nil, &rank); err != nil {
+			t.Fatal(err)
+		}
+		if err := user.Call("GET", "/game_mode", nil, &game); err != nil {
+			t.Fatal(err)
+		}
+		return rank, game
+	}
+
+	rank, game := modes()
+	if rank != (handlers.RankModeResponse{CanSolo: true, CanMulti: true}) {
+		t.Fatalf("rank_mode = %+v", rank)
+	}
+	if game != (handlers.GameModeResponse{CanRank: false, CanFriend: true}) {
+		t.Fatalf("game_mode = %+v", game)
+	}
+
+	on, off := true, false
+	if err := admin.Call("POST", "/admin/maintenance", handlers.AdminMaintenanceRequest{Enabled: &on}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if rank, game := modes(); !rank.Maintenance || !game.Maintenance {
+		t.Fatalf("during maintenance: rank_mode = %+v, game_mode = %+v", rank, game)
+	}
+	if err := admin.Call("POST", "/admin/maintenance", handlers.AdminMaintenanceRequest{Enabled: &off}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if rank, game := modes(); rank.Maintenance || game.Maintenance {
+		t.Fatalf("after maintenance: rank_mode = %+v, game_mode = %+v", rank, game)
+	}
+}
@@ -0,0 +1,461 @@
+package server_test
+
+import (
+	"api/internal/bot"
+	"api/internal/handlers"
+	"api/internal/models"
+	"api/internal/openapi"
+	"api/internal/response"
+	"api/internal/server"
+	"api/internal/store"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// 仕様書とルーターの突き合わせ：ルーターにあって仕様書に無いルート、その逆をどちらも検出する
+func TestOpenAPIMatchesRouter(t *testing.T) {
+	spec := openapi.Spec()
+
+	// ルーターの「パス → メソッド」（メソッド制限の無いルートは空）
+	routes := map[string][]string{}
+	err := server.NewRouter(store.NewMemory()).Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil // PathPrefix のサブルーター
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		// 旧ルートは /api/v1 と同じものを登録している互換用なので仕様書には載せない
+		if strings.HasPrefix(path, "/api/") && !strings.HasPrefix(path, response.V1Prefix+"/") && path != openapi.Path {
+			return nil
+		}
+		methods, _ := route.GetMethods()
+		if _, ok := routes[path]; !ok {
+			routes[path] = []string{}
+		}
+		for _, m := range methods {
+			if m != http.MethodOptions {
+				routes[path] = append(routes[path], m)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, methods := range routes {
+		item, ok := spec.Paths[path]
+		if !ok {
+			t.Errorf("router has %s but the spec does not", path)
+			continue
+		}
+		for _, m := range methods {
+			if item[strings.ToLower(m)] == nil {
+				t.Errorf("router has %s %s but the spec does not", m, path)
+			}
+		}
+	}
+	for path, item := range spec.Paths {
+		methods, ok := routes[path]
+		if !ok {
+			t.Errorf("spec has %s but the router does not", path)
+			continue
+		}
+		for m := range item {
+			// メソッド制限の無いルートはどのメソッドでも通る
+			if len(methods) > 0 && !containsFold(methods, m) {
+				t.Errorf("spec has %s %s but the router does not", strings.ToUpper(m), path)
+			}
+		}
+	}
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// /api/openapi.json で配っているものが OpenAPI 3 の JSON で、$ref がすべて解決できる
+func TestOpenAPIServedSpec(t *testing.T) {
+	srv, _ := startServer(t)
+	res, err := http.Get(srv.URL + openapi.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK || !strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		t.Fatalf("status=%d content-type=%q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	var doc map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := doc["openapi"].(string); !strings.HasPrefix(v, "3.") {
+		t.Fatalf("openapi = %v", doc["openapi"])
+	}
+	schemas, _ := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	var refs []string
+	collectRefs(doc, &refs)
+	if len(refs) == 0 {
+		t.Fatal("no $ref in the spec")
+	}
+	for _, ref := range refs {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("unresolved $ref %s", ref)
+		}
+	}
+}
+
+func collectRefs(v interface{}, refs *[]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			if s, ok := child.(string); ok && k == "$ref" {
+				*refs = append(*refs, s)
+				continue
+			}
+			collectRefs(child, refs)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectRefs(child, refs)
+		}
+	}
+}
+
+// 実際の応答・WebSocket のメッセージを仕様書のスキーマで検査する。
+// 項目の追加・改名・型の変更を仕様書に反映し忘れると、ここで落ちる。
+func TestOpenAPIResponsesMatchSpec(t *testing.T) {
+	srv, repo := startServer(t)
+	c := &specChecker{t: t, spec: openapi.Spec(), base: srv.URL, seen: map[string]bool{}}
+	v1 := func(path string) string { return response.V1Prefix + path }
+
+	// ---- 運用 ----
+	c.call("GET", "/healthz", "", nil)
+	c.call("GET", "/readyz", "", nil)
+	c.call("GET", openapi.Path, "", nil)
+
+	// ---- アカウント ----
+	const password = "spec-password-123"
+	c.call("POST", v1("/create_account"), "", handlers.CreateAccountRequest{Name: "spec-host", Password: password})
+	if status, _ := c.call("POST", v1("/create_account"), "", handlers.CreateAccountRequest{Name: "spec-host", AutoFlg: true}); status != http.StatusConflict {
+		t.Fatalf("duplicate name: status = %d", status)
+	}
+	if status, _ := c.call("POST", v1("/create_account"), "", handlers.CreateAccountRequest{Name: " "}); status != http.StatusBadRequest {
+		t.Fatalf("empty name: status = %d", status)
+	}
+	_, login := c.call("POST", v1("/login"), "", handlers.LoginRequest{Name: "spec-host", Password: password})
+	_, refreshed := c.call("POST", v1("/token/refresh"), "", handlers.RefreshTokenRequest{RefreshToken: str(login["refresh_token"])})
+	host := bot.New(srv.URL, "spec-host")
+	host.Token, host.RefreshToken = str(refreshed["token"]), str(refreshed["refresh_token"])
+
+	_, created := c.call("POST", v1("/create_account"), "", handlers.CreateAccountRequest{Name: "spec-guest", AutoFlg: true})
+	guest := bot.New(srv.URL, "spec-guest")
+	guest.Token, guest.RefreshToken = str(created["token"]), str(created["refresh_token"])
+
+	// ---- ホーム画面 ----
+	_, main := c.call("GET", v1("/get_main_data"), host.Token, nil)
+	settings, _ := main["settings"].(map[string]interface{})
+	host.UserID = int64(settings["user_id"].(float64))
+	_, main = c.call("GET", v1("/get_main_data"), guest.Token, nil)
+	settings, _ = main["settings"].(map[string]interface{})
+	guest.UserID = int64(settings["user_id"].(float64))
+	c.call("POST", v1("/updatesolotip"), host.Token, handlers.UpdateTipRequest{NewChips: 10})
+	_, solo := c.call("POST", v1("/updatesolotip"), host.Token, handlers.UpdateTipRequest{NewChips: -50, Round: &handlers.SoloRoundResult{Bet: 50, Result: "lose", Bust: true}})
+	c.call("GET", v1("/history"), host.Token, nil)
+	c.call("GET", v1("/history/rounds/{round_id}"), host.Token, nil, solo["round_id"])
+	c.call("GET", v1("/get_chip_data"), host.Token, nil)
+	c.call("POST", v1("/update_settings"), host.Token, handlers.UpdateUserSettingsRequest{
+		BgmVolume: ptr(0.3), SeVolume: ptr(0.4), Icon: ptr("icon_spade"), Language: ptr("ja"), Vibration: ptr(false), CardBack: ptr("red_back"),
+	})
+	if status, _ := c.call("POST", v1("/update_settings"), host.Token, handlers.UpdateUserSettingsRequest{BgmVolume: ptr(1.5)}); status != http.StatusBadRequest {
+		t.Fatalf("volume out of range: status = %d", status)
+	}
+	c.call("GET", v1("/profile/catalog"), "", nil)
+	c.call("POST", v1("/profile/name"), guest.Token, handlers.ChangeNameRequest{Name: "spec-guest2"})
+	if status, _ := c.call("POST", v1("/profile/name"), guest.Token, handlers.ChangeNameRequest{Name: "spec-guest3"}); status != http.StatusTooManyRequests {
+		t.Fatalf("rename during cooldown: status = %d", status)
+	}
+
+	// ---- ルーム ----
+	_, room := c.call("POST", v1("/create_room"), host.Token, handlers.CreateRoomRequest{GameTypeID: 1, MaxPlayers: 3})
+	code := str(room["room_code"])
+	if status, _ := c.call("POST", v1("/join_room"), guest.Token, handlers.JoinRoomRequest{RoomCode: "000000"}); status != http.StatusNotFound {
+		t.Fatalf("join unknown room: status = %d", status)
+	}
+	_, joined := c.call("POST", v1("/join_room"), guest.Token, handlers.JoinRoomRequest{RoomCode: code})
+	c.call("GET", v1("/rooms/{room_id}/players"), "", nil, int64(joined["room_id"].(float64)))
+
+	roomWS := v1("/ws/room/{room_code}")
+	hostRoom, err := host.DialRoom(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.socket(roomWS, hostRoom)
+	guestRoom, err := guest.DialRoom(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.socket(roomWS, guestRoom)
+	expect(t, hostRoom, "room_status", func(s handlers.RoomStatusResponse) bool { return len(s.Players) == 2 })
+
+	// 席の操作（範囲外の席 → seat_error、入れ替えの申請 → 相手に seat_swap_request）
+	c.send(roomWS, guestRoom, handlers.RoomCommand{Type: "seat_select", SeatNo: 99})
+	expect[handlers.SeatErrorResponse](t, guestRoom, "seat_error", nil)
+	c.send(roomWS, guestRoom, handlers.RoomCommand{Type: "seat_swap", TargetUserID: host.UserID})
+	expect[handlers.SeatSwapRequestNotice](t, hostRoom, "seat_swap_request", nil)
+
+	for _, conn := range []*bot.Conn{hostRoom, guestRoom} {
+		c.send(roomWS, conn, handlers.RoomCommand{Type: "ready", RoomCode: code, IsReady: true})
+	}
+	expect[handlers.AllReadyResponse](t, hostRoom, "all_ready", nil)
+	c.call("POST", v1("/rooms/start"), host.Token, handlers.StartRequest{RoomCode: code})
+	expect[handlers.StartGameBroadcast](t, guestRoom, "start_game", nil)
+
+	bjWS := v1("/ws/blackjackwebsocket/{room_code}")
+	hostTable, err := host.DialBlackjack(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.socket(bjWS, hostTable)
+	guestTable, err := guest.DialBlackjack(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.socket(bjWS, guestTable)
+	expect[handlers.PlayerOrderMessage](t, guestTable, "player_order", nil)
+	expect[handlers.TimerMessage](t, guestTable, "timer", nil)
+	c.send(bjWS, guestTable, handlers.BetCommand{Type: "bet_update", Bet: 100, Confirm: true})
+	expect(t, hostTable, "bet_state", func(s handlers.BetStateBroadcast) bool { return s.AllConfirmed })
+	// 配る前のシードと配った札（経過に残る）
+	c.send(bjWS, hostTable, handlers.RoundCommitCommand{Type: "round_commit", SeedCommitment: sha256Hex("spec-seed")})
+	c.send(bjWS, hostTable, handlers.RoundActionCommand{Type: "round_action", RoundActionEvent: models.RoundActionEvent{Action: "deal", Card: "AS", To: guest.UserID}})
+	// 精算（ディーラーは最初の席のホスト。ディーラー以外が送ると table_error）
+	result := handlers.RoundResultCommand{Type: "round_result", Results: []handlers.PlayerRoundResult{{UserID: guest.UserID, Result: "win"}}, Seed: "spec-seed"}
+	c.send(bjWS, guestTable, result)
+	expect[handlers.TableErrorResponse](t, guestTable, "table_error", nil)
+	c.send(bjWS, hostTable, result)
+	expect[handlers.RoundSettledBroadcast](t, guestTable, "round_settled", nil)
+	c.call("GET", v1("/stats"), guest.Token, nil)
+
+	// ---- 運営 ----
+	admin := newAdmin(t, srv.URL, repo)
+	_, own := c.call("POST", v1("/create_room"), admin.Token, handlers.CreateRoomRequest{GameTypeID: 1, MaxPlayers: 2})
+	c.call("POST", v1("/rooms/leave"), admin.Token, handlers.LeaveRoomRequest{RoomCode: str(own["room_code"])})
+
+	c.call("GET", v1("/admin/rooms"), admin.Token, nil)
+	c.call("GET", v1("/admin/rooms/{room_code}"), admin.Token, nil, code)
+	c.call("GET", v1("/admin/rounds/{round_id}"), admin.Token, nil, solo["round_id"])
+	c.call("POST", v1("/admin/users/{user_id}/chips"), admin.Token, handlers.AdminAdjustChipsRequest{Wallet: "multi", Delta: 100, Reason: "補填"}, guest.UserID)
+	c.call("GET", v1("/admin/users/{user_id}/chips"), admin.Token, nil, guest.UserID)
+	on, off := true, false
+	c.call("GET", v1("/admin/modes"), admin.Token, nil)
+	c.call("POST", v1("/admin/modes/{id}"), admin.Token, handlers.AdminPlayableRequest{CanPlay: &on}, 1)
+	c.call("POST", v1("/admin/types/{id}"), admin.Token, handlers.AdminPlayableRequest{CanPlay: &on}, 1)
+
+	c.call("POST", v1("/admin/maintenance"), admin.Token, handlers.AdminMaintenanceRequest{Enabled: &on, Message: "点検中"})
+	expect(t, guestRoom, "maintenance", func(m handlers.MaintenanceNotice) bool { return m.Enabled })
+	c.call("GET", v1("/admin/maintenance"), admin.Token, nil)
+	c.call("POST", v1("/admin/maintenance"), admin.Token, handlers.AdminMaintenanceRequest{Enabled: &off})
+
+	_, ann := c.call("POST", v1("/admin/announcements"), admin.Token, handlers.AdminAnnouncementRequest{Message: "お知らせ", EndsAt: time.Now().Add(time.Hour)})
+	expect[handlers.AnnouncementNotice](t, guestTable, "announcement", nil)
+	c.call("GET", v1("/admin/announcements"), admin.Token, nil)
+	id := int64(ann["announcement"].(map[string]interface{})["id"].(float64))
+	c.call("DELETE", v1("/admin/announcements/{id}"), admin.Token, nil, id)
+	expect[handlers.AnnouncementRemovedNotice](t, guestTable, "announcement_removed", nil)
+
+	c.call("POST", v1("/admin/rooms/{room_code}/close"), admin.Token, handlers.AdminCloseRoomRequest{Reason: "点検"}, code)
+	expect[handlers.RoomClosedNotice](t, guestRoom, "room_closed", nil)
+	c.call("POST", v1("/admin/users/{user_id}/ban"), admin.Token, handlers.AdminBanRequest{Reason: "確認"}, guest.UserID)
+	c.call("POST", v1("/admin/users/{user_id}/unban"), admin.Token, nil, guest.UserID)
+	c.call("GET", v1("/admin/audit"), admin.Token, nil)
+
+	// ---- ログアウト ----
+	_, relogin := c.call("POST", v1("/login"), "", handlers.LoginRequest{Name: "spec-host", Password: password})
+	c.call("POST", v1("/logout"), str(relogin["token"]), handlers.LogoutRequest{RefreshToken: str(relogin["refresh_token"])})
+	c.call("POST", v1("/logout_all"), host.Token, nil)
+
+	// ---- 確かめていないもの ----
+	// 新しい API・メッセージを足したらここで実際の応答を検査すること（検査できないものは理由付きで下に足す）
+	notChecked := map[string]string{
+		"GET /metrics": "JSON ではない（metrics_test で確認）",
+		// ハンドラがグローバルの *sql.DB を直接使うので、メモリストアでは動かない
+		"GET /api/v1/rank_mode":                                        "sql",
+		"GET /api/v1/game_mode":                                        "sql",
+		"GET /api/v1/solo_games":                                       "sql",
+		"GET /api/v1/friend_games":                                     "sql",
+		"POST /api/v1/account/upgrade":                                 "sql",
+		"POST /api/v1/account/transfer_code":                           "sql",
+		"POST /api/v1/account/transfer":                                "sql",
+		"POST /api/v1/account/password":                                "sql",
+		"POST /api/v1/account/recovery_codes":                          "sql",
+		"POST /api/v1/account/reset_password":                          "sql",
+		"WS /api/v1/ws/room/{room_code} server_shutdown":               "サーバーの停止が必要",
+		"WS /api/v1/ws/blackjackwebsocket/{room_code} server_shutdown": "サーバーの停止が必要",
+		"WS /api/v1/ws/room/{room_code} rate_limited":                  "ratelimit_test で確認",
+		"WS /api/v1/ws/blackjackwebsocket/{room_code} rate_limited":    "ratelimit_test で確認",
+		"WS /api/v1/ws/room/{room_code} announcement":                  "卓の接続で確認",
+		"WS /api/v1/ws/room/{room_code} announcement_removed":          "卓の接続で確認",
+		"WS /api/v1/ws/blackjackwebsocket/{room_code} maintenance":     "ルームの接続で確認",
+		"WS /api/v1/ws/blackjackwebsocket/{room_code} room_closed":     "ルームの接続で確認",
+	}
+	t.Cleanup(func() {
+		var missing []string
+		for _, key := range c.operations() {
+			if !c.seen[key] && notChecked[key] == "" {
+				missing = append(missing, key)
+			}
+		}
+		sort.Strings(missing)
+		for _, key := range missing {
+			t.Errorf("%s: no live response was checked against the spec", key)
+		}
+	})
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// specChecker は API を呼んで応答を仕様書で検査し、検査した操作を記録する
+type specChecker struct {
+	t    *testing.T
+	spec *openapi.Document
+	base string
+	seen map[string]bool // "POST /api/v1/login" / "WS /api/v1/ws/room/{room_code} room_status"
+}
+
+// call は template の {name} を args で順に埋めて呼ぶ。成功なら data（JSON オブジェクト）を返す
+func (c *specChecker) call(method, template, token string, body interface{}, args ...interface{}) (int, map[string]interface{}) {
+	t := c.t
+	t.Helper()
+	op := c.spec.Operation(method, template)
+	if op == nil {
+		t.Fatalf("%s %s is not in the spec", method, template)
+	}
+	path := template
+	for _, a := range args {
+		i, j := strings.Index(path, "{"), strings.Index(path, "}")
+		path = path[:i] + url.PathEscape(fmt.Sprint(a)) + path[j+1:]
+	}
+	var raw string
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw = string(b)
+	}
+	status, text := rawCall(t, method, c.base+path, token, raw)
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		t.Fatalf("%s %s: not JSON (status=%d): %q", method, path, status, text)
+	}
+	schema := op.SuccessSchema()
+	if status != http.StatusOK {
+		schema = op.Responses["default"].Content["application/json"].Schema
+	} else {
+		c.seen[method+" "+template] = true
+	}
+	if err := c.spec.Validate(schema, v); err != nil {
+		t.Errorf("%s %s (status=%d) does not match the spec: %v\n%s", method, path, status, err, text)
+	}
+	obj, _ := v.(map[string]interface{})
+	data, _ := obj["data"].(map[string]interface{})
+	return status, data
+}
+
+// send はクライアントから送るメッセージが仕様書に載っているか確かめてから送る
+func (c *specChecker) send(template string, conn *bot.Conn, v interface{}) {
+	c.t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var msg map[string]interface{}
+	_ = json.Unmarshal(raw, &msg)
+	op := c.spec.Operation("GET", template)
+	found := false
+	for _, m := range op.WebSocket.Client {
+		if m.Type == msg["type"] {
+			found = true
+			if err := c.spec.Validate(m.Schema, msg); err != nil {
+				c.t.Errorf("%s: client message does not match the spec: %v", template, err)
+			}
+		}
+	}
+	if !found {
+		c.t.Errorf("%s: client message %q is not in the spec", template, msg["type"])
+	}
+	if err := conn.Send(v); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// expect は conn に typ のメッセージが届くのを待つ（cond が nil なら最初の1件）
+func expect[T any](t *testing.T, conn *bot.Conn, typ string, cond func(T) bool) {
+	t.Helper()
+	if _, err := bot.ExpectDecoded(conn, typ, cond, 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// socket は conn が受け取ったメッセージをすべて仕様書で検査して閉じる
+func (c *specChecker) socket(template string, conn *bot.Conn) {
+	t := c.t
+	op := c.spec.Operation("GET", template)
+	for _, m := range conn.Messages() {
+		schema := op.ServerMessage(m.Type)
+		if schema == nil {
+			t.Errorf("%s: message %q is not in the spec: %s", conn.Label, m.Type, m.Raw)
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(m.Raw, &v); err != nil {
+			t.Errorf("%s: %v", conn.Label, err)
+			continue
+		}
+		if err := c.spec.Validate(schema, v); err != nil {
+			t.Errorf("%s: %s does not match the spec: %v\n%s", conn.Label, m.Type, err, m.Raw)
+		}
+		c.seen["WS "+template+" "+m.Type] = true
+	}
+	_ = conn.Close()
+}
+
+// operations は仕様書の全操作（成功の応答があるもの）と WebSocket のサーバー側メッセージ
+func (c *specChecker) operations() []string {
+	var keys []string
+	for path, item := range c.spec.Paths {
+		for method, op := range item {
+			if op.WebSocket != nil {
+				for _, m := range op.WebSocket.Server {
+					keys = append(keys, "WS "+path+" "+m.Type)
+				}
+				continue
+			}
+			keys = append(keys, strings.ToUpper(method)+" "+path)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,183 @@
+package server_test
+
+import (
+	"api/internal/bot"
+	"api/internal/handlers"
+	"api/internal/models"
+	"api/internal/response"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 設定は指定した項目だけ変わり、範囲外・一覧に無い値は項目ごとに返す。名前の変更は重複と間隔を見る
+func TestProfileAndSettings(t *testing.T) {
+	srv, _ := startServer(t)
+	const password = "profile-password-123"
+	host := bot.New(srv.URL, "profile-host")
+	if err := host.CreateAccount(password); err != nil {
+		t.Fatal(err)
+	}
+	other := bot.New(srv.URL, "profile-other")
+	if err := other.CreateAccount(""); err != nil {
+		t.Fatal(err)
+	}
+	mainData := func() handlers.MainDataResponse {
+		t.Helper()
+		var main handlers.MainDataResponse
+		if err := host.Call("GET", "/get_main_data", nil, &main); err != nil {
+			t.Fatal(err)
+		}
+		return main
+	}
+	fields := func(err error) map[string]response.FieldCode {
+		t.Helper()
+		var se *bot.StatusError
+		if !errors.As(err, &se) || se.Status != http.StatusBadRequest || se.Code != response.CodeValidationFailed {
+			t.Fatalf("err = %v, want validation_failed", err)
+		}
+		got := map[string]response.FieldCode{}
+		for _, f := range decodeEnvelope(t, se.Body).Error.Fields {
+			got[f.Field] = f.Code
+		}
+		return got
+	}
+
+	// ---- 初期値と選べる値の一覧 ----
+	main := mainData()
+	if s := main.Settings; s.Icon != models.DefaultSetting.Icon || !s.Vibration || s.CardBack != models.DefaultSetting.CardBack {
+		t.Fatalf("initial settings = %+v", s)
+	}
+	if main.NameChangeableAt != nil {
+		t.Fatalf("name_changeable_at = %v before any change", main.NameChangeableAt)
+	}
+	var catalog handlers.ProfileCatalogResponse
+	if err := bot.New(srv.URL, "").Call("GET", "/profile/catalog", nil, &catalog); err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog.Icons) == 0 || len(catalog.CardBacks) == 0 || len(catalog.Languages) != len(response.Langs()) || catalog.NameMaxLength == 0 {
+		t.Fatalf("catalog = %+v", catalog)
+	}
+
+	// ---- 部分更新 ----
+	var updated handlers.UpdateUserSettingsResponse
+	if err := host.Call("POST", "/update_settings", handlers.UpdateUserSettingsRequest{
+		Icon: ptr(catalog.Icons[1]), Vibration: ptr(false),
+	}, &updated); err != nil {
+		t.Fatal(err)
+	}
+	want := handlers.MainDataSettings{
+		BgmVolume: models.DefaultSetting.BgmVolume, SeVolume: models.DefaultSetting.SeVolume,
+		Icon: catalog.Icons[1], Vibration: false, CardBack: models.DefaultSetting.CardBack, UserID: host.UserID,
+	}
+	if updated.Settings != want {
+		t.Fatalf("updated settings = %+v, want %+v", updated.Settings, want)
+	}
+	if err := host.Call("POST", "/update_settings", handlers.UpdateUserSettingsRequest{
+		SeVolume: ptr(0.0), CardBack: ptr(catalog.CardBacks[2]),
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	want.SeVolume, want.CardBack = 0, catalog.CardBacks[2]
+	if got := mainData().Settings; got != want {
+		t.Fatalf("main data settings = %+v, want %+v", got, want)
+	}
+
+	// 1つでも正しくなければ何も変えない
+	err := host.Call("POST", "/update_settings", handlers.UpdateUserSettingsRequest{
+		BgmVolume: ptr(-0.1), SeVolume: ptr(1.01), Icon: ptr("no_such_icon"), CardBack: ptr(""), Vibration: ptr(true),
+	}, nil)
+	got := fields(err)
+	if got["bgm_volume"] != response.FieldOutOfRange || got["se_volume"] != response.FieldOutOfRange ||
+		got["icon"] != response.FieldInvalid || got["card_back"] != response.FieldInvalid || len(got) != 4 {
+		t.Fatalf("invalid settings fields = %v", got)
+	}
+	if got := mainData().Settings; got != want {
+		t.Fatalf("settings changed by a rejected update: %+v", got)
+	}
+
+	// ---- 名前の変更 ----
+	rename := func(c *bot.Client, name string) (handlers.ChangeNameResponse, error) {
+		var resp handlers.ChangeNameResponse
+		err := c.Call("POST", "/profile/name", handlers.ChangeNameRequest{Name: name}, &resp)
+		return resp, err
+	}
+	for name, code := range map[string]response.FieldCode{
+		"   ":                   response.FieldRequired,
+		strings.Repeat("あ", 17): response.FieldTooLong,
+		"profile\x00host":       response.FieldInvalid,
+	} {
+		if _, err := rename(host, name); fields(err)["name"] != code {
+			t.Fatalf("rename to %q: err = %v, want %s", name, err, code)
+		}
+	}
+	var se *bot.StatusError
+	if _, err := rename(host, "profile-other"); !errors.As(err, &se) || se.Status != http.StatusConflict || se.Code != response.CodeNameTaken {
+		t.Fatalf("rename to a taken name: err = %v", err)
+	}
+	// 同じ名前は変更として数えない
+	if resp, err := rename(host, "profile-host"); err != nil || resp.NameChangeableAt != nil {
+		t.Fatalf("rename to the same name: %+v err=%v", resp, err)
+	}
+
+	resp, err := rename(host, "  profile-renamed ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Name != "profile-renamed" || resp.Token == "" || resp.NameChangeableAt == nil {
+		t.Fatalf("rename = %+v", resp)
+	}
+	host.Token, host.RefreshToken = resp.Token, resp.RefreshToken
+	if main := mainData(); main.Username != "profile-renamed" || main.NameChangeableAt == nil || !main.NameChangeableAt.Equal(*resp.NameChangeableAt) {
+		t.Fatalf("main data after rename: username=%q name_changeable_at=%v", main.Username, main.NameChangeableAt)
+	}
+	// 新しい名前でログインでき、古い名前は空く
+	renamed := bot.New(srv.URL, "profile-renamed")
+	if err := renamed.Login(password); err != nil {
+		t.Fatal(err)
+	}
+	if err := bot.New(srv.URL, "profile-host").CreateAccount(""); err != nil {
+		t.Fatalf("old name is still taken: %v", err)
+	}
+
+	// 間隔を空けずにもう一度は 429
+	status, body, header := rawCallHeader(t, "POST", srv.URL+response.V1Prefix+"/profile/name", host.Token, `{"name":"profile-again"}`, nil)
+	if env := decodeEnvelope(t, body); status != http.StatusTooManyRequests || env.Error == nil || env.Error.Code != response.CodeNameChangeTooSoon || header.Get("Retry-After") == "" {
+		t.Fatalf("rename during cooldown: status=%d Retry-After=%q body=%s", status, header.Get("Retry-After"), body)
+	}
+}
+
+// 同時に来た名前の変更は1つだけ通り、残りは間隔の制限で 429 になる
+func TestConcurrentRenamesHonorCooldown(t *testing.T) {
+	srv, _ := startServer(t)
+	user := bot.New(srv.URL, "rename-race")
+	if err := user.CreateAccount(""); err != nil {
+		t.Fatal(err)
+	}
+	const n = 8
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = user.Call("POST", "/profile/name", handlers.ChangeNameRequest{Name: fmt.Sprintf("rename-race-%d", i)}, nil)
+		}()
+	}
+	wg.Wait()
+	renamed := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			renamed++
+		case !isCode(err, http.StatusTooManyRequests, response.CodeNameChangeTooSoon):
+			t.Fatalf("rename %d: err = %v", i, err)
+		}
+	}
+	if renamed != 1 {
+		t.Fatalf("renamed %d times, want 1", renamed)
+	}
+}
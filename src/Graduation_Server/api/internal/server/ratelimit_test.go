@@ -0,0 +1,107 @@
+package server_test
+
+import (
+	"api/internal/bot"
+	"api/internal/config"
+	"api/internal/handlers"
+	"api/internal/middleware"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// 回数制限：HTTP は 429、WebSocket は rate_limited を返して捨てる
+func TestRateLimits(t *testing.T) {
+	limits := config.RateLimitConfig{
+		APIRate:            100,
+		APIBurst:           100,
+		CreateRoomRate:     0.001,
+		CreateRoomBurst:    1,
+		CreateAccountRate:  0.001,
+		CreateAccountBurst: 3,
+		WSMessageRate:      0.001,
+		WSMessageBurst:     2,
+		WSMaxMessageBytes:  1024,
+	}
+	middleware.InitRateLimits(limits)
+	t.Cleanup(middleware.ResetRateLimits)
+
+	srv, _ := startServer(t)
+
+	// アカウント作成は IP ごと
+	bots := []*bot.Client{bot.New(srv.URL, "rl-a"), bot.New(srv.URL, "rl-b"), bot.New(srv.URL, "rl-c")}
+	for _, b := range bots {
+		if err := b.CreateAccount(""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := bot.New(srv.URL, "rl-d").CreateAccount("")
+	var se *bot.StatusError
+	if !errors.As(err, &se) || se.Status != http.StatusTooManyRequests {
+		t.Fatalf("4th account: err = %v, want 429", err)
+	}
+
+	// ルーム作成はユーザーごと（他のユーザーは作れる）
+	code, err := bots[0].CreateRoom(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bots[0].CreateRoom(1, 2); !isStatus(err, http.StatusTooManyRequests) {
+		t.Fatalf("2nd room by same user: err = %v, want 429", err)
+	}
+	if _, err := bots[1].CreateRoom(1, 2); err != nil {
+		t.Fatalf("room by another user: %v", err)
+	}
+
+	// Retry-After が付く
+	req, _ := http.NewRequest("POST", srv.URL+"/api/create_room", strings.NewReader(`{"game_type_id":1}`))
+	req.Header.Set("Authorization", "Bearer "+bots[0].Token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests || res.Header.Get("Retry-After") == "" {
+		t.Fatalf("status = %d, Retry-After = %q", res.StatusCode, res.Header.Get("Retry-After"))
+	}
+
+	// WebSocket：burst を超えたメッセージは捨てられ、rate_limited が1回だけ届く
+	c, err := bots[0].DialRoom(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Expect("room_status", nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := c.Send(map[string]any{"type": "ready", "is_ready": i%2 == 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := bot.ExpectDecoded(c, "rate_limited", func(handlers.RateLimitedNotice) bool { return true }, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.RetryAfterMs <= 0 {
+		t.Errorf("retry_after_ms = %d", n.RetryAfterMs)
+	}
+
+	// 上限を超える大きさのメッセージは切断される
+	if err := c.Send(map[string]any{"type": "ready", "pad": strings.Repeat("x", 2048)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Closed(0); err != nil {
+		t.Fatal(err)
+	}
+	limited := 0
+	for _, m := range c.Messages() {
+		if m.Type == "rate_limited" {
+			limited++
+		}
+	}
+	if limited != 1 {
+		t.Errorf("rate_limited received %d times, want 1", limited)
+	}
+}
@@ -0,0 +1,276 @@
+package server_test
+
+import (
+	"api/internal/bot"
+	"api/internal/handlers"
+	"api/internal/response"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 生の応答（ステータスと本文）を見る
+func rawCall(t *testing.T, method, url, token, body string) (int, string) {
+	t.Helper()
+	status, raw, _ := rawCallHeader(t, method, url, token, body, nil)
+	return status, raw
+}
+
+// rawCall に要求ヘッダーを足し、応答ヘッダーも返す
+func rawCallHeader(t *testing.T, method, url, token, body string, header http.Header) (int, string, http.Header) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, string(raw), res.Header
+}
+
+func decodeEnvelope(t *testing.T, body string) response.Envelope {
+	t.Helper()
+	var env response.Envelope
+	if err := json.Unmarshal([]byte(body), &env); err != nil {
+		t.Fatalf("not an envelope: %v (body=%q)", err, body)
+	}
+	return env
+}
+
+// /api/v1 はエンベロープ、旧ルート（/api）は以前と同じ形
+func TestResponseEnvelopeAndLegacyRoutes(t *testing.T) {
+	srv, _ := startServer(t)
+	v1, legacy := srv.URL+response.V1Prefix, srv.URL+"/api"
+
+	// 入力項目のエラー
+	status, body := rawCall(t, "POST", v1+"/create_account", "", `{"name":" ","password":""}`)
+	env := decodeEnvelope(t, body)
+	if status != http.StatusBadRequest || env.OK || env.Error == nil || env.Error.Code != response.CodeValidationFailed {
+		t.Fatalf("v1 validation: status=%d body=%s", status, body)
+	}
+	if f := env.Error.Fields; len(f) != 1 || f[0].Field != "name" || f[0].Code != response.FieldRequired || f[0].Message == "" {
+		t.Fatalf("v1 validation fields = %+v", f)
+	}
+	status, body = rawCall(t, "POST", legacy+"/create_account", "", `{"name":" ","password":""}`)
+	wantInvalid := response.MessageIn(response.LangJa, response.CodeValidationFailed) + " (name: " + response.FieldMessageIn(response.LangJa, response.FieldRequired) + ")"
+	if status != http.StatusBadRequest || strings.TrimSpace(body) != wantInvalid {
+		t.Fatalf("legacy validation: status=%d body=%q", status, body)
+	}
+
+	// 名前の重複：v1 は 409 name_taken、旧ルートは 200 の result: NG
+	host := bot.New(srv.URL, "env-host")
+	if err := host.CreateAccount(""); err != nil {
+		t.Fatal(err)
+	}
+	err := bot.New(srv.URL, "env-host").CreateAccount("")
+	var se *bot.StatusError
+	if !errors.As(err, &se) || se.Status != http.StatusConflict || se.Code != response.CodeNameTaken {
+		t.Fatalf("v1 duplicate name: err = %v", err)
+	}
+	status, body = rawCall(t, "POST", legacy+"/create_account", "", `{"name":"env-host","auto_flg":true}`)
+	var ng map[string]string
+	if err := json.Unmarshal([]byte(body), &ng); err != nil || status != http.StatusOK || ng["result"] != "NG" ||
+		ng["message"] != response.MessageIn(response.LangJa, response.CodeNameTaken) {
+		t.Fatalf("legacy duplicate name: status=%d body=%s", status, body)
+	}
+
+	// ミドルウェアの失敗も同じ形
+	status, body = rawCall(t, "POST", v1+"/create_room", "", `{"game_type_id":1}`)
+	if env := decodeEnvelope(t, body); status != http.StatusUnauthorized || env.Error == nil || env.Error.Code != response.CodeUnauthorized {
+		t.Fatalf("v1 missing token: status=%d body=%s", status, body)
+	}
+	status, body = rawCall(t, "POST", legacy+"/create_room", "", `{"game_type_id":1}`)
+	if status != http.StatusUnauthorized || strings.TrimSpace(body) != response.MessageIn(response.LangJa, response.CodeUnauthorized) {
+		t.Fatalf("legacy missing token: status=%d body=%q", status, body)
+	}
+
+	// 成功：v1 は data に入り result は付かない、旧ルートは result: OK
+	status, body = rawCall(t, "POST", v1+"/create_room", host.Token, `{"game_type_id":1,"max_players":4}`)
+	var created struct {
+		OK   bool                   `json:"ok"`
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(body), &created); err != nil || status != http.StatusOK || !created.OK || created.Data["room_code"] == "" {
+		t.Fatalf("v1 create room: status=%d body=%s", status, body)
+	}
+	if _, ok := created.Data["result"]; ok {
+		t.Fatalf("v1 data has result: %s", body)
+	}
+	status, body = rawCall(t, "POST", legacy+"/create_room", host.Token, `{"game_type_id":1,"max_players":4}`)
+	if status != http.StatusOK || !strings.Contains(body, `"result":"OK"`) || !strings.Contains(body, `"room_code"`) {
+		t.Fatalf("legacy create room: status=%d body=%s", status, body)
+	}
+
+	// 配列を返していた API：v1 は data.players、旧ルートは配列のまま
+	status, body = rawCall(t, "GET", v1+"/rooms/1/players", "", "")
+	if env := decodeEnvelope(t, body); status != http.StatusOK || !env.OK || !strings.Contains(body, `"players"`) {
+		t.Fatalf("v1 players: status=%d body=%s", status, body)
+	}
+	status, body = rawCall(t, "GET", legacy+"/rooms/1/players", "", "")
+	if status != http.StatusOK || strings.HasPrefix(strings.TrimSpace(body), "{") {
+		t.Fatalf("legacy players: status=%d body=%s", status, body)
+	}
+
+	// 文字列を返していた API
+	status, body = rawCall(t, "POST", legacy+"/updatesolotip", host.Token, `{"chip_diff":10}`)
+	if status != http.StatusOK || body != "チップを更新しました" {
+		t.Fatalf("legacy updatesolotip: status=%d body=%q", status, body)
+	}
+	var tip struct {
+		ChipDiff int `json:"chip_diff"`
+	}
+	if err := host.Call("POST", "/updatesolotip", map[string]int{"chip_diff": 10}, &tip); err != nil || tip.ChipDiff != 10 {
+		t.Fatalf("v1 updatesolotip: %+v err=%v", tip, err)
+	}
+
+	// 存在しないルート・ルーム
+	status, body = rawCall(t, "GET", v1+"/no_such_api", "", "")
+	if env := decodeEnvelope(t, body); status != http.StatusNotFound || env.Error == nil || env.Error.Code != response.CodeNotFound {
+		t.Fatalf("v1 unknown route: status=%d body=%s", status, body)
+	}
+	err = host.JoinRoom("000000")
+	if !errors.As(err, &se) || se.Status != http.StatusNotFound || se.Code != response.CodeRoomNotFound {
+		t.Fatalf("v1 join unknown room: err = %v", err)
+	}
+	status, body = rawCall(t, "POST", legacy+"/join_room", host.Token, `{"room_code":"000000"}`)
+	if status != http.StatusNotFound || strings.TrimSpace(body) != response.MessageIn(response.LangJa, response.CodeRoomNotFound) {
+		t.Fatalf("legacy join unknown room: status=%d body=%q", status, body)
+	}
+}
+
+// 文言はユーザー設定 → Accept-Language → 日本語の順で決まる。旧ルートの文字列も同じ
+func TestLocalizedMessages(t *testing.T) {
+	srv, _ := startServer(t)
+	v1 := srv.URL + response.V1Prefix
+	lang := func(v string) http.Header { return http.Header{"Accept-Language": {v}} }
+
+	failure := func(token, acceptLanguage string) (response.Error, string) {
+		t.Helper()
+		var h http.Header
+		if acceptLanguage != "" {
+			h = lang(acceptLanguage)
+		}
+		status, body, res := rawCallHeader(t, "POST", v1+"/join_room", token, `{"room_code":"000000"}`, h)
+		env := decodeEnvelope(t, body)
+		if status != http.StatusNotFound || env.Error == nil || env.Error.Code != response.CodeRoomNotFound {
+			t.Fatalf("join unknown room: status=%d body=%s", status, body)
+		}
+		return *env.Error, res.Get("Content-Language")
+	}
+
+	host := bot.New(srv.URL, "lang-host")
+	if err := host.CreateAccount(""); err != nil {
+		t.Fatal(err)
+	}
+	ja, en := response.MessageIn(response.LangJa, response.CodeRoomNotFound), response.MessageIn(response.LangEn, response.CodeRoomNotFound)
+	if ja == en {
+		t.Fatalf("ja and en messages are the same: %q", ja)
+	}
+	for _, tc := range []struct {
+		acceptLanguage string
+		want           response.Lang
+	}{
+		{"", response.LangJa},
+		{"en-US,en;q=0.9", response.LangEn},
+		{"fr, ja;q=0.5, en;q=0.8", response.LangEn},
+		{"de", response.LangJa},
+	} {
+		e, cl := failure(host.Token, tc.acceptLanguage)
+		if want := response.MessageIn(tc.want, e.Code); e.Message != want || cl != string(tc.want) {
+			t.Fatalf("Accept-Language %q: message=%q Content-Language=%q, want %q (%s)", tc.acceptLanguage, e.Message, cl, want, tc.want)
+		}
+	}
+
+	// 入力項目のエラーは項目ごとの文言も同じ言語
+	status, body, _ := rawCallHeader(t, "POST", v1+"/create_account", "", `{"name":" "}`, lang("en"))
+	env := decodeEnvelope(t, body)
+	if status != http.StatusBadRequest || env.Error == nil || len(env.Error.Fields) != 1 ||
+		env.Error.Fields[0].Message != response.FieldMessageIn(response.LangEn, response.FieldRequired) {
+		t.Fatalf("en validation: status=%d body=%s", status, body)
+	}
+
+	// ユーザー設定は Accept-Language より優先。対応していない言語は入力エラー
+	var bad *bot.StatusError
+	err := host.Call("POST", "/update_settings", handlers.UpdateUserSettingsRequest{Language: ptr("fr")}, nil)
+	if !errors.As(err, &bad) || bad.Status != http.StatusBadRequest || bad.Code != response.CodeValidationFailed {
+		t.Fatalf("unsupported language: err = %v", err)
+	}
+	if err := host.Call("POST", "/update_settings", handlers.UpdateUserSettingsRequest{Language: ptr("en")}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if e, cl := failure(host.Token, "ja"); e.Message != en || cl != "en" {
+		t.Fatalf("user setting en: message=%q Content-Language=%q", e.Message, cl)
+	}
+	var main handlers.MainDataResponse
+	if err := host.Call("GET", "/get_main_data", nil, &main); err != nil || main.Settings.Language != "en" {
+		t.Fatalf("main data language = %q err=%v", main.Settings.Language, err)
+	}
+
+	// 言語だけ変えても音量は変わらない
+	if err := host.Call("POST", "/update_settings", handlers.UpdateUserSettingsRequest{BgmVolume: ptr(0.25)}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := host.Call("POST", "/update_settings", handlers.UpdateUserSettingsRequest{Language: ptr("")}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := host.Call("GET", "/get_main_data", nil, &main); err != nil || main.Settings.Language != "" || main.Settings.BgmVolume != 0.25 {
+		t.Fatalf("main data after partial update = %+v err=%v", main.Settings, err)
+	}
+	if e, _ := failure(host.Token, ""); e.Message != ja {
+		t.Fatalf("language reset: message=%q", e.Message)
+	}
+
+	// 旧ルートの文字列も Accept-Language の言語
+	status, body, res := rawCallHeader(t, "POST", srv.URL+"/api/join_room", host.Token, `{"room_code":"000000"}`, lang("en"))
+	if status != http.StatusNotFound || strings.TrimSpace(body) != en || res.Get("Content-Language") != "en" {
+		t.Fatalf("legacy join unknown room: status=%d body=%q", status, body)
+	}
+	status, body, _ = rawCallHeader(t, "POST", srv.URL+"/api/create_account", "", `{"name":" "}`, lang("en"))
+	if want := response.MessageIn(response.LangEn, response.CodeValidationFailed) + " (name: " + response.FieldMessageIn(response.LangEn, response.FieldRequired) + ")"; status != http.StatusBadRequest || strings.TrimSpace(body) != want {
+		t.Fatalf("legacy en validation: status=%d body=%q", status, body)
+	}
+
+	// WebSocket のエラーも code と設定した言語の文言を持つ
+	if err := host.Call("POST", "/update_settings", handlers.UpdateUserSettingsRequest{Language: ptr("en")}, nil); err != nil {
+		t.Fatal(err)
+	}
+	code, err := host.CreateRoom(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := host.DialRoom(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.Send(handlers.RoomCommand{Type: "seat_select", SeatNo: 99}); err != nil {
+		t.Fatal(err)
+	}
+	seatErr, err := bot.ExpectDecoded[handlers.SeatErrorResponse](conn, "seat_error", nil, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seatErr.Code != response.CodeInvalidSeat || seatErr.Message != response.MessageIn(response.LangEn, response.CodeInvalidSeat) {
+		t.Fatalf("seat_error = %+v", seatErr)
+	}
+}
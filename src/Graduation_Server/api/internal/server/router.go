@@ -0,0 +1,160 @@
+// Package server は API のルーティングをまとめる。
+// main（本番）と e2e テスト（メモリストア）で同じルーターを使う。
+package server
+
+import (
+	"api/internal/handlers"
+	"api/internal/metrics"
+	"api/internal/middleware"
+	"api/internal/openapi"
+	"api/internal/response"
+	"api/internal/store"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// NewRouter は全APIを登録したルーターを返す。
+// handlers.InitStore / InitConfig、auth.Init などの初期化は呼び出し側で済ませておくこと。
+func NewRouter(repo store.Store) *mux.Router {
+	r := mux.NewRouter()
+	// 応答の形（/api/v1 か旧ルートか）/ トレースのスパン / リクエストID とアクセスログ / ルートごとの件数・応答時間（/metrics）/ /api/ 全体の回数制限
+	r.Use(response.Versioning, middleware.Tracing(), middleware.RequestLog, middleware.Metrics, middleware.RateLimit(middleware.LimitAPI))
+
+	// ========== 運用（監視・ヘルスチェック） ==========
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+	r.HandleFunc("/healthz", handlers.HealthzHandler).Methods("GET")
+	r.HandleFunc("/readyz", handlers.ReadyzHandler).Methods("GET")
+	// API の仕様書（OpenAPI 3。/api/v1 の全ルートと WebSocket のメッセージ）
+	r.Handle(openapi.Path, openapi.Handler()).Methods("GET")
+
+	// ========== API ==========
+	// 現行版（/api/v1、応答はエンベロープ）と、互換のための旧ルート（/api、以前と同じ形の応答）に同じものを登録する。
+	// /api/v1 を先に登録する（/api の方に吸われないように）
+	registerAPI(r.PathPrefix(response.V1Prefix).Subrouter(), repo)
+	registerAPI(r.PathPrefix("/api").Subrouter(), repo)
+
+	// ルートが無い・メソッド違いも同じ形で返す（ミドルウェアを通らないので版の判定はここで掛ける）
+	r.NotFoundHandler = response.Versioning(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response.Fail(w, r, http.StatusNotFound, response.CodeNotFound)
+	}))
+	r.MethodNotAllowedHandler = response.Versioning(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response.Fail(w, r, http.StatusMethodNotAllowed, response.CodeMethodNotAllowed)
+	}))
+
+	return r
+}
+
+// registerAPI は api（/api/v1 または /api）の下に全APIを登録する
+func registerAPI(api *mux.Router, repo store.Store) {
+	// ========== 公開API（JWT認証不要） ==========
+	// アカウント作成/ログイン/メインデータ/ランク・ゲームモード一覧/ソロ・フレンドゲーム一覧/WebSocket（ブラックジャック）
+	// MEMO: メソッド制限が必要なら .Methods("POST") 等を付与
+	// アカウント作成は IP ごとにさらに絞る（使い捨てアカウントの量産対策）
+	api.Handle("/create_account",
+		middleware.RateLimit(middleware.LimitCreateAccount)(http.HandlerFunc(handlers.CreateAccountHandler)))
+	api.HandleFunc("/login", handlers.LoginHandler)
+	// アクセストークン再発行（リフレッシュトークンと交換）
+	api.HandleFunc("/token/refresh", handlers.RefreshTokenHandler).Methods("POST")
+	// 引き継ぎコードで新端末にログイン
+	api.HandleFunc("/account/transfer", handlers.RedeemTransferCodeHandler(repo)).Methods("POST")
+	// リカバリーコード / 運営発行コードでパスワード再設定
+	api.HandleFunc("/account/reset_password", handlers.ResetPasswordHandler(repo)).Methods("POST")
+	api.HandleFunc("/get_main_data", handlers.GetMainDataHandler)
+	api.HandleFunc("/rank_mode", handlers.RankModeHandler(repo))
+	api.HandleFunc("/game_mode", handlers.GameModeHandler(repo))
+	api.HandleFunc("/solo_games", handlers.SoloGameListHandler)
+	api.HandleFunc("/friend_games", handlers.FriendGameListHandler)
+	// 設定で選べるアイコン・カードの裏面・言語の一覧
+	api.HandleFunc("/profile/catalog", handlers.ProfileCatalogHandler).Methods("GET")
+
+	// ========== 認証必須API（JWTミドルウェアで保護） ==========
+	// ログアウト（この端末） / 全端末からログアウト
+	api.Handle("/logout",
+		middleware.JWTMiddleware(http.HandlerFunc(handlers.LogoutHandler))).Methods("POST")
+	api.Handle("/logout_all",
+		middleware.JWTMiddleware(http.HandlerFunc(handlers.LogoutAllHandler))).Methods("POST")
+	// ゲストアカウントの本登録 / 機種変更用の引き継ぎコード発行
+	api.Handle("/account/upgrade",
+		middleware.JWTMiddleware(handlers.UpgradeGuestHandler(repo))).Methods("POST")
+	api.Handle("/account/transfer_code",
+		middleware.JWTMiddleware(handlers.IssueTransferCodeHandler(repo))).Methods("POST")
+	// パスワード変更 / リカバリーコード発行
+	api.Handle("/account/password",
+		middleware.JWTMiddleware(handlers.ChangePasswordHandler(repo))).Methods("POST")
+	api.Handle("/account/recovery_codes",
+		middleware.JWTMiddleware(handlers.IssueRecoveryCodesHandler(repo))).Methods("POST")
+	// 依存注入（repo を引数で渡す）パターンのハンドラは http.Handler/Func を生成して渡す
+	// ルーム作成（ユーザーごとにさらに絞る）
+	api.Handle("/create_room",
+		middleware.RateLimit(middleware.LimitCreateRoom)(
+			middleware.JWTMiddleware(http.HandlerFunc(handlers.CreateRoomHandler(repo)))))
+	// ルーム参加
+	api.Handle("/join_room",
+		middleware.JWTMiddleware(http.HandlerFunc(handlers.JoinRoomHandler(repo))))
+	// ルームwebsocket
+	api.Handle("/ws/room/{room_code}",
+		middleware.JWTMiddleware(http.HandlerFunc(handlers.GameRoomWebSocketHandler(repo))))
+	// ルーム退出
+	api.Handle("/rooms/leave",
+		middleware.JWTMiddleware(http.HandlerFunc(handlers.LeaveRoomHandler(repo)))).Methods("POST", "OPTIONS")
+	api.Handle("/rooms/start",
+		middleware.JWTMiddleware(http.HandlerFunc(handlers.StartRoomHandler(repo)))).
+		Methods("POST", "OPTIONS")
+	// ルーム内プレイヤー取得（GET限定）
+	api.HandleFunc("/rooms/{room_id}/players", handlers.GetPlayersForGameHandler(repo)).Methods("GET")
+	// ブラックジャックゲーム
+	api.Handle(
+		"/ws/blackjackwebsocket/{room_code}",
+		middleware.JWTMiddleware(http.HandlerFunc(handlers.BlackjackWebSocketHandle(repo))),
+	)
+	// 設定更新（ハンドラ側でグローバル repo を使う設計）
+	api.Handle("/update_settings",
+		middleware.JWTMiddleware(http.HandlerFunc(handlers.UpdateUserSettingsHandler)))
+	// 名前の変更（一度変えたら一定期間は変えられない）
+	api.Handle("/profile/name",
+		middleware.JWTMiddleware(handlers.ChangeNameHandler(repo))).Methods("POST")
+	// ソロチップ更新（依存注入）
+	api.Handle("/updatesolotip",
+		middleware.JWTMiddleware(handlers.UpdateSoloTipHandler(repo)))
+
+	// 戦績（round_results の集計）
+	api.Handle("/stats",
+		middleware.JWTMiddleware(handlers.PlayerStatsHandler(repo))).Methods("GET")
+	// ゲーム履歴とラウンドの経過（リプレイ）
+	api.Handle("/history",
+		middleware.JWTMiddleware(handlers.HistoryHandler(repo))).Methods("GET")
+	api.Handle("/history/rounds/{round_id}",
+		middleware.JWTMiddleware(handlers.RoundReplayHandler(repo))).Methods("GET")
+
+	// 所持チップ取得（GET限定・依存注入）
+	api.Handle("/get_chip_data",
+		middleware.JWTMiddleware(handlers.GetChipDataHandler(repo))).Methods("GET")
+
+	// ========== 運営API（role=admin のトークンのみ。操作は admin_audit_log に記録） ==========
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.AdminMiddleware)
+	// ルーム一覧・詳細（卓の状態）・強制クローズ
+	admin.HandleFunc("/rooms", handlers.AdminListRoomsHandler(repo)).Methods("GET")
+	admin.HandleFunc("/rooms/{room_code}", handlers.AdminRoomDetailHandler(repo)).Methods("GET")
+	admin.HandleFunc("/rooms/{room_code}/close", handlers.AdminCloseRoomHandler(repo)).Methods("POST")
+	// チップの台帳・調整 / BAN
+	admin.HandleFunc("/rounds/{round_id}", handlers.AdminRoundReplayHandler(repo)).Methods("GET")
+	admin.HandleFunc("/users/{user_id}/chips", handlers.AdminChipLedgerHandler(repo)).Methods("GET")
+	admin.HandleFunc("/users/{user_id}/chips", handlers.AdminAdjustChipsHandler(repo)).Methods("POST")
+	admin.HandleFunc("/users/{user_id}/ban", handlers.AdminBanUserHandler(repo)).Methods("POST")
+	admin.HandleFunc("/users/{user_id}/unban", handlers.AdminUnbanUserHandler(repo)).Methods("POST")
+	// モード・ゲーム種別の公開切り替え
+	admin.HandleFunc("/modes", handlers.AdminListModesHandler(repo)).Methods("GET")
+	admin.HandleFunc("/modes/{id}", handlers.AdminSetModeHandler(repo)).Methods("POST")
+	admin.HandleFunc("/types/{id}", handlers.AdminSetGameTypeHandler(repo)).Methods("POST")
+	// メンテナンス（新しいルームの作成・参加を止める）/ お知らせ
+	admin.HandleFunc("/maintenance", handlers.AdminGetMaintenanceHandler(repo)).Methods("GET")
+	admin.HandleFunc("/maintenance", handlers.AdminSetMaintenanceHandler(repo)).Methods("POST")
+	admin.HandleFunc("/announcements", handlers.AdminListAnnouncementsHandler(repo)).Methods("GET")
+	admin.HandleFunc("/announcements", handlers.AdminCreateAnnouncementHandler(repo)).Methods("POST")
+	admin.HandleFunc("/announcements/{id}", handlers.AdminDeleteAnnouncementHandler(repo)).Methods("DELETE")
+	// 操作ログ
+	admin.HandleFunc("/audit", handlers.AdminAuditLogHandler(repo)).Methods("GET")
+
+}
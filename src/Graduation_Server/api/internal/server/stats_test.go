@@ -0,0 +1,191 @@
+package server_test
+
+import (
+	"api/internal/bot"
+	"api/internal/handlers"
+	"api/internal/models"
+	"api/internal/response"
+	"net/http"
+	"testing"
+)
+
+// ディーラーが送った結果で払い戻しが決まり、ソロの結果と合わせて戦績に集計される
+func TestRoundResultsAndStats(t *testing.T) {
+	srv, _ := startServer(t)
+	host := bot.New(srv.URL, "stats-host")
+	guest := bot.New(srv.URL, "stats-guest")
+	for _, b := range []*bot.Client{host, guest} {
+		if err := b.CreateAccount(""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// ---- ソロ（ブラックジャックで 50 → +75）----
+	if err := guest.Call("POST", "/updatesolotip", handlers.UpdateTipRequest{
+		NewChips: -10, Round: &handlers.SoloRoundResult{Bet: 50, Result: "win"},
+	}, nil); !isStatus(err, http.StatusBadRequest) {
+		t.Fatalf("solo win with a loss: err = %v, want 400", err)
+	}
+	// 勝ちでもベットを超えては増えない
+	if err := guest.Call("POST", "/updatesolotip", handlers.UpdateTipRequest{
+		NewChips: 1000000, Round: &handlers.SoloRoundResult{Bet: 1, Result: "win"},
+	}, nil); !isStatus(err, http.StatusBadRequest) {
+		t.Fatalf("solo win over the bet: err = %v, want 400", err)
+	}
+	if err := guest.Call("POST", "/updatesolotip", handlers.UpdateTipRequest{
+		NewChips: 75, Round: &handlers.SoloRoundResult{Bet: 50, Result: "win", Blackjack: true},
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// ---- 卓に着く（ディーラーは席1のホスト）----
+	code, err := host.CreateRoom(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := guest.JoinRoom(code); err != nil {
+		t.Fatal(err)
+	}
+	var roomConns []*bot.Conn
+	for _, b := range []*bot.Client{host, guest} {
+		c, err := b.DialRoom(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		roomConns = append(roomConns, c)
+	}
+	for _, c := range roomConns {
+		if err := c.Send(handlers.RoomCommand{Type: "ready", RoomCode: code, IsReady: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect(t, roomConns[0], "room_status", allReady)
+	gameID, err := host.StartRoom(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostTable, err := host.DialBlackjack(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hostTable.Close()
+	guestTable, err := guest.DialBlackjack(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer guestTable.Close()
+	expect[handlers.PlayerOrderMessage](t, guestTable, "player_order", nil)
+
+	tableError := func(conn *bot.Conn, want response.Code) {
+		t.Helper()
+		expect(t, conn, "table_error", func(e handlers.TableErrorResponse) bool { return e.Code == want && e.Message != "" })
+	}
+	bet := func(amount int) handlers.BJBetPlayerState {
+		t.Helper()
+		if err := guestTable.Send(handlers.BetCommand{Type: "bet_update", Bet: amount, Confirm: true}); err != nil {
+			t.Fatal(err)
+		}
+		s, err := bot.ExpectDecoded(hostTable, "bet_state", func(s handlers.BetStateBroadcast) bool { return s.AllConfirmed }, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range s.Players {
+			if p.UserID == guest.UserID {
+				return p
+			}
+		}
+		t.Fatalf("guest is not in bet_state: %+v", s)
+		return handlers.BJBetPlayerState{}
+	}
+	settled := func(round int) handlers.RoundSettledBroadcast {
+		t.Helper()
+		s, err := bot.ExpectDecoded(guestTable, "round_settled", func(s handlers.RoundSettledBroadcast) bool { return s.Round == round }, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.GameID != gameID || s.DealerID != host.UserID || s.RoomCode != code || len(s.Results) != 1 {
+			t.Fatalf("round_settled = %+v", s)
+		}
+		return s
+	}
+
+	// ---- 1ラウンド目：勝ち（100 → 200 戻る）----
+	before := bet(100)
+	win := handlers.RoundResultCommand{Type: "round_result", Results: []handlers.PlayerRoundResult{{UserID: guest.UserID, Result: "win"}}}
+	if err := guestTable.Send(win); err != nil {
+		t.Fatal(err)
+	}
+	tableError(guestTable, response.CodeNotDealer)
+	for _, bad := range []handlers.RoundResultCommand{
+		{Type: "round_result"},
+		{Type: "round_result", Results: []handlers.PlayerRoundResult{{UserID: guest.UserID, Result: "win", Bust: true}}},
+		{Type: "round_result", Results: []handlers.PlayerRoundResult{{UserID: host.UserID, Result: "win"}}},
+		{Type: "round_result", Results: []handlers.PlayerRoundResult{{UserID: guest.UserID, Result: "draw"}}},
+		{Type: "round_result", Results: []handlers.PlayerRoundResult{{UserID: guest.UserID, Result: "win"}}, DealerBlackjack: true},
+	} {
+		if err := hostTable.Send(bad); err != nil {
+			t.Fatal(err)
+		}
+		tableError(hostTable, response.CodeInvalidRoundResult)
+	}
+	if err := hostTable.Send(win); err != nil {
+		t.Fatal(err)
+	}
+	s := settled(1)
+	if r := s.Results[0]; r.Bet != 100 || r.Payout != 200 || r.Net != 100 || r.TotalChips != before.TotalChips+200 || s.DealerNet != -100 {
+		t.Fatalf("round 1 = %+v (dealer_net %d), chips before %d", r, s.DealerNet, before.TotalChips)
+	}
+	// 精算したらベットは次のラウンドのために空になる
+	if _, err := bot.ExpectDecoded(hostTable, "bet_state", func(s handlers.BetStateBroadcast) bool {
+		return !s.AllConfirmed
+	}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := hostTable.Send(win); err != nil {
+		t.Fatal(err)
+	}
+	tableError(hostTable, response.CodeNoBets)
+
+	// ---- 2ラウンド目：バーストで負け ----
+	bet(50)
+	if err := hostTable.Send(handlers.RoundResultCommand{Type: "round_result", Results: []handlers.PlayerRoundResult{
+		{UserID: guest.UserID, Result: "lose", Bust: true},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if s := settled(2); s.Results[0].Payout != 0 || s.Results[0].Net != -50 || s.DealerNet != 50 {
+		t.Fatalf("round 2 = %+v", s)
+	}
+
+	// ---- 戦績 ----
+	stats := func(c *bot.Client) handlers.PlayerStatsResponse {
+		t.Helper()
+		var resp handlers.PlayerStatsResponse
+		if err := c.Call("GET", "/stats", nil, &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	g := stats(guest)
+	want := handlers.PlayerStats{
+		Hands: 3, Wins: 2, Losses: 1, WinRate: 0.667, LossRate: 0.333,
+		Blackjacks: 1, Busts: 1, NetChips: 125, BiggestWin: 100, BiggestPot: 200, LongestWinStreak: 2,
+	}
+	if g.Lifetime != want {
+		t.Fatalf("guest lifetime = %+v, want %+v", g.Lifetime, want)
+	}
+	if len(g.Modes) != 2 || g.Modes[0].ModeID != models.ModeSolo || g.Modes[1].ModeID != models.ModeMulti ||
+		g.Modes[0].Mode == "" || g.Modes[0].Hands != 1 || g.Modes[1].Hands != 2 || g.Modes[1].NetChips != 50 {
+		t.Fatalf("guest modes = %+v", g.Modes)
+	}
+
+	h := stats(host)
+	want = handlers.PlayerStats{
+		Hands: 2, HandsAsDealer: 2, Wins: 1, Losses: 1, WinRate: 0.5, LossRate: 0.5,
+		NetChips: -50, BiggestWin: 50, LongestWinStreak: 1,
+	}
+	if h.Lifetime != want || len(h.Modes) != 1 || h.Modes[0].ModeID != models.ModeMulti {
+		t.Fatalf("host stats = %+v, want lifetime %+v", h, want)
+	}
+}
@@ -0,0 +1,103 @@
+package server_test
+
+import (
+	"api/internal/bot"
+	"api/internal/tracing"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanRecorder はエクスポートされたスパンを貯めておく
+type spanRecorder struct {
+	*tracetest.InMemoryExporter
+}
+
+// find は条件に合うスパンが終わって送られてくるまで待つ
+// （クライアントに届いた時点ではサーバー側のスパンがまだ閉じていないことがある）
+func (s spanRecorder) find(t *testing.T, name string, match func(tracetest.SpanStub) bool) tracetest.SpanStub {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		tracing.Flush(context.Background())
+		var names []string
+		for _, sp := range s.GetSpans() {
+			if sp.Name == name && (match == nil || match(sp)) {
+				return sp
+			}
+			names = append(names, sp.Name)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("span %q not exported; have %v", name, names)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTracingHTTPAndWebSocket(t *testing.T) {
+	rec := spanRecorder{tracetest.NewInMemoryExporter()}
+	shutdown, err := tracing.Init(tracing.Options{ServiceName: "e2e", Exporter: rec, SampleRatio: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = shutdown(context.Background()) })
+
+	srv, _ := startServer(t)
+
+	// 呼び出し元の traceparent を親にする
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	req, _ := http.NewRequest("POST", srv.URL+"/api/login", strings.NewReader(`{"user_name":"nobody","password":"x"}`))
+	req.Header.Set(tracing.TraceparentHeader, "00-"+traceID+"-"+parentID+"-01")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	login := rec.find(t, "HTTP POST /api/login", nil)
+	if login.SpanContext.TraceID().String() != traceID || login.Parent.SpanID().String() != parentID {
+		t.Errorf("login span trace=%s parent=%s", login.SpanContext.TraceID(), login.Parent.SpanID())
+	}
+	if login.SpanKind != trace.SpanKindServer {
+		t.Errorf("login span kind = %v", login.SpanKind)
+	}
+
+	// WS のメッセージは接続のスパンへリンクした別トレースになり、ブロードキャストはその子になる
+	b := bot.New(srv.URL, "trace-bot")
+	if err := b.CreateAccount(""); err != nil {
+		t.Fatal(err)
+	}
+	code, err := b.CreateRoom(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := b.DialRoom(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Send(map[string]any{"type": "ready", "is_ready": true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bot.ExpectDecoded(c, "room_status", allReady, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := rec.find(t, "WS room ready", nil)
+	if len(msg.Links) != 1 {
+		t.Fatalf("message span links = %v", msg.Links)
+	}
+	// 接続のスパンは切断まで終わらないので、リンク先は別トレースであることだけ確かめる
+	link := msg.Links[0].SpanContext
+	if msg.Parent.IsValid() || !link.IsValid() || link.TraceID() == msg.SpanContext.TraceID() {
+		t.Errorf("message span should start a new trace: parent=%s link=%v", msg.Parent.SpanID(), link)
+	}
+	rec.find(t, "broadcast room_status", func(sp tracetest.SpanStub) bool {
+		return sp.SpanContext.TraceID() == msg.SpanContext.TraceID() && sp.Parent.SpanID() == msg.SpanContext.SpanID()
+	})
+}
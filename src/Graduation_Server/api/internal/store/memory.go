@@ -0,0 +1,1262 @@
+package store
+
+import (
+	"api/internal/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory は DB を使わないメモリ上の実装（ハンドラのテスト用）。
+// 振る舞いは MySQL 実装（UNIQUE 制約・席順・見つからない場合の sql.ErrNoRows など）に合わせている。
+type Memory struct {
+	mu sync.Mutex
+
+	users     map[int64]*models.User
+	settings  map[int64]models.Setting
+	tips      map[int64]models.Tip
+	rooms     map[int64]*models.Room
+	roomUsers map[int64]*memRoomUser
+	gameTypes map[int]string
+	games     map[int64]memGame
+	players   map[int64][]models.PlayerGameInfo // roomID -> players
+
+	refreshTokens map[string]*models.RefreshToken // token_hash -> 行
+	revokedTokens map[string]time.Time            // jti -> expires_at
+	tokenVersions map[int64]int                   // userID -> token_version
+	transferCodes []*memCode
+	resetCodes    []*memCode
+
+	snapshots map[string]models.BJSnapshot // room_code -> 行
+
+	modes      []models.Mode
+	modeTypes  []models.ModeGameType
+	chipLedger []models.ChipLedgerEntry
+	audit      []models.AuditEntry
+
+	maintenance   models.Maintenance
+	announcements []models.Announcement
+
+	rounds    []models.RoundResult
+	roundLogs map[int64]*models.RoundLog
+
+	nextUserID     int64
+	nextRoomID     int64
+	nextRoomUserID int64
+	nextGameID     int64
+	nextRefreshID  int64
+	nextNoticeID   int64
+	nextRoundID    int64
+	nextRoundLogID int64
+}
+
+type memRoomUser struct {
+	id      int64
+	roomID  int64
+	userID  int64
+	isReady bool
+	seatNo  int // 0 は未割当（MySQL の NULL）
+}
+
+// transfer_codes / password_reset_codes の1行
+type memCode struct {
+	userID    int64
+	hash      string
+	kind      string    // password_reset_codes のみ（models.ResetCodeRecovery / models.ResetCodeAdmin）
+	expiresAt time.Time // ゼロなら無期限
+	used      bool
+}
+
+func (c *memCode) usable(now time.Time) bool {
+	return !c.used && (c.expiresAt.IsZero() || c.expiresAt.After(now))
+}
+
+type memGame struct {
+	modeID int
+	typeID int
+	roomID int64
+}
+
+// NewMemory は空のストアを作る。ゲーム種別は migrations の初期データと同じものを入れておく。
+func NewMemory() *Memory {
+	return &Memory{
+		users:     make(map[int64]*models.User),
+		settings:  make(map[int64]models.Setting),
+		tips:      make(map[int64]models.Tip),
+		rooms:     make(map[int64]*models.Room),
+		roomUsers: make(map[int64]*memRoomUser),
+		gameTypes: map[int]string{1: "ブラックジャック"},
+		games:     make(map[int64]memGame),
+		players:   make(map[int64][]models.PlayerGameInfo),
+
+		refreshTokens: make(map[string]*models.RefreshToken),
+		revokedTokens: make(map[string]time.Time),
+		tokenVersions: make(map[int64]int),
+
+		snapshots: make(map[string]models.BJSnapshot),
+		roundLogs: make(map[int64]*models.RoundLog),
+
+		modes: []models.Mode{
+			{ID: 1, Mode: "ソロ", CanPlay: true},
+			{ID: 2, Mode: "マルチ", CanPlay: true},
+			{ID: 3, Mode: "ランク", CanPlay: false},
+			{ID: 4, Mode: "フレンド", CanPlay: true},
+		},
+		modeTypes: []models.ModeGameType{
+			{ID: 1, ModeID: 1, GameTypeID: 1, Name: "ブラックジャック", CanPlay: true},
+			{ID: 2, ModeID: 2, GameTypeID: 1, Name: "ブラックジャック", CanPlay: true},
+			{ID: 3, ModeID: 4, GameTypeID: 1, Name: "ブラックジャック", CanPlay: true},
+		},
+	}
+}
+
+func (m *Memory) Users() UserRepository         { return memUsers{m} }
+func (m *Memory) Rooms() RoomRepository         { return memRooms{m} }
+func (m *Memory) RoomUsers() RoomUserRepository { return memRoomUsers{m} }
+func (m *Memory) Tips() TipRepository           { return memTips{m} }
+func (m *Memory) Games() GameRepository         { return memGames{m} }
+func (m *Memory) Settings() SettingRepository   { return memSettings{m} }
+func (m *Memory) Tokens() TokenRepository       { return memTokens{m} }
+func (m *Memory) Snapshots() SnapshotRepository { return memSnapshots{m} }
+func (m *Memory) Audit() AuditRepository        { return memAudit{m} }
+func (m *Memory) Notices() NoticeRepository     { return memNotices{m} }
+func (m *Memory) Rounds() RoundRepository       { return memRounds{m} }
+
+// WithContext はメモリ実装では何もしない
+func (m *Memory) WithContext(context.Context) Store { return m }
+
+// AddGameType はテスト用にゲーム種別を登録する
+func (m *Memory) AddGameType(id int, name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gameTypes[id] = name
+}
+
+// AddPlayer はテスト用に players の行を登録する
+func (m *Memory) AddPlayer(roomID int64, p models.PlayerGameInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.players[roomID] = append(m.players[roomID], p)
+}
+
+// GameCount は作成されたゲーム数（テストの確認用）
+func (m *Memory) GameCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.games)
+}
+
+// ---- 内部ヘルパー（m.mu を持った状態で呼ぶ）----
+
+func (m *Memory) findRoomUser(roomID, userID int64) *memRoomUser {
+	for _, ru := range m.roomUsers {
+		if ru.roomID == roomID && ru.userID == userID {
+			return ru
+		}
+	}
+	return nil
+}
+
+// 席順（未割当は後ろ、同じなら参加順）に並べた参加者
+func (m *Memory) sortedRoomUsers(roomID int64) []*memRoomUser {
+	var list []*memRoomUser
+	for _, ru := range m.roomUsers {
+		if ru.roomID == roomID {
+			list = append(list, ru)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if (a.seatNo == 0) != (b.seatNo == 0) {
+			return b.seatNo == 0
+		}
+		if a.seatNo != b.seatNo {
+			return a.seatNo < b.seatNo
+		}
+		return a.id < b.id
+	})
+	return list
+}
+
+func (m *Memory) seatTaken(roomID int64, seatNo int) (*memRoomUser, bool) {
+	for _, ru := range m.roomUsers {
+		if ru.roomID == roomID && ru.seatNo == seatNo {
+			return ru, true
+		}
+	}
+	return nil, false
+}
+
+func (m *Memory) addRoomUser(roomID, userID int64, seatNo int) error {
+	if m.findRoomUser(roomID, userID) != nil {
+		return fmt.Errorf("store: duplicate room user (room=%d, user=%d)", roomID, userID)
+	}
+	if _, taken := m.seatTaken(roomID, seatNo); taken {
+		return fmt.Errorf("store: duplicate seat (room=%d, seat=%d)", roomID, seatNo)
+	}
+	m.nextRoomUserID++
+	m.roomUsers[m.nextRoomUserID] = &memRoomUser{
+		id:     m.nextRoomUserID,
+		roomID: roomID,
+		userID: userID,
+		seatNo: seatNo,
+	}
+	return nil
+}
+
+// ---- users ----
+
+type memUsers struct{ m *Memory }
+
+func (r memUsers) Create(name, hashedPassword string, now time.Time) (int64, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Name == name {
+			return 0, fmt.Errorf("store: duplicate user name %q", name)
+		}
+	}
+	m.nextUserID++
+	m.users[m.nextUserID] = &models.User{ID: m.nextUserID, Name: name, PasswordHash: hashedPassword, CreatedAt: now, Role: models.RolePlayer}
+	return m.nextUserID, nil
+}
+
+func (r memUsers) GetByID(userID int64) (*models.User, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *u
+	return &copied, nil
+}
+
+func (r memUsers) NameExists(name string) (bool, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memUsers) Rename(userID int64, name string, now, changedBefore time.Time) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	if u.NameChanged.Valid && u.NameChanged.Time.After(changedBefore) {
+		return models.ErrNameChangeTooSoon
+	}
+	for _, other := range m.users {
+		if other.ID != userID && other.Name == name {
+			return models.ErrNameTaken
+		}
+	}
+	u.Name = name
+	u.NameChanged = sql.NullTime{Time: now, Valid: true}
+	return nil
+}
+
+func (r memUsers) Credentials(name string) (int64, string, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Name == name {
+			return u.ID, u.PasswordHash, nil
+		}
+	}
+	return 0, "", ErrNotFound
+}
+
+func (r memUsers) UpgradeGuest(userID int64, name, hashedPassword string) (bool, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok || u.PasswordHash != "" {
+		return false, nil
+	}
+	for _, other := range m.users {
+		if other.ID != userID && other.Name == name {
+			return false, models.ErrNameTaken
+		}
+	}
+	u.Name = name
+	u.PasswordHash = hashedPassword
+	return true, nil
+}
+
+func (r memUsers) SetPassword(userID int64, hashedPassword string) error {
+	return r.update(userID, func(u *models.User) { u.PasswordHash = hashedPassword })
+}
+
+func (r memUsers) SetRole(userID int64, role string) error {
+	return r.update(userID, func(u *models.User) { u.Role = role })
+}
+
+func (r memUsers) Ban(userID int64, reason string, now time.Time) error {
+	return r.update(userID, func(u *models.User) {
+		u.BannedAt = sql.NullTime{Time: now, Valid: true}
+		u.BanReason = reason
+	})
+}
+
+func (r memUsers) Unban(userID int64) error {
+	return r.update(userID, func(u *models.User) {
+		u.BannedAt = sql.NullTime{}
+		u.BanReason = ""
+	})
+}
+
+func (r memUsers) update(userID int64, f func(*models.User)) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	f(u)
+	return nil
+}
+
+// ---- rooms ----
+
+type memRooms struct{ m *Memory }
+
+func (r memRooms) CreateWithHost(roomCode string, gameTypeID, maxPlayers int, ownerID int64, now time.Time) (int64, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextRoomID++
+	id := m.nextRoomID
+	m.rooms[id] = &models.Room{
+		ID:         id,
+		RoomCode:   roomCode,
+		GameTypeID: gameTypeID,
+		Status:     "waiting",
+		MaxPlayers: maxPlayers,
+		CreatedAt:  now.Format(time.RFC3339),
+		OwnerID:    ownerID,
+	}
+	if err := m.addRoomUser(id, ownerID, models.HostSeatNo); err != nil {
+		delete(m.rooms, id)
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r memRooms) GetByCode(roomCode string) (*models.Room, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var found *models.Room
+	for _, room := range m.rooms {
+		if room.RoomCode == roomCode && (found == nil || room.ID < found.ID) {
+			found = room
+		}
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	copied := *found
+	return &copied, nil
+}
+
+func (r memRooms) UpdateStatus(roomID int64, status string) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if room, ok := m.rooms[roomID]; ok {
+		room.Status = status
+	}
+	return nil
+}
+
+func (r memRooms) CountByStatus() (map[string]int, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counts := make(map[string]int)
+	for _, room := range m.rooms {
+		counts[room.Status]++
+	}
+	return counts, nil
+}
+
+func (r memRooms) ListOpen() ([]models.OpenRoom, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []models.OpenRoom
+	for _, room := range m.rooms {
+		if room.Status == "closed" {
+			continue
+		}
+		list = append(list, models.OpenRoom{Room: *room, Players: len(m.sortedRoomUsers(room.ID))})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (r memRooms) Close(roomID int64) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, ru := range m.roomUsers {
+		if ru.roomID == roomID {
+			delete(m.roomUsers, id)
+		}
+	}
+	if room, ok := m.rooms[roomID]; ok {
+		room.Status = "closed"
+	}
+	return nil
+}
+
+// ---- room_users ----
+
+type memRoomUsers struct{ m *Memory }
+
+func (r memRoomUsers) Join(roomID, userID int64, maxPlayers int) (int, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for n := 1; n <= maxPlayers; n++ {
+		if _, taken := m.seatTaken(roomID, n); !taken {
+			if err := m.addRoomUser(roomID, userID, n); err != nil {
+				return 0, err
+			}
+			return n, nil
+		}
+	}
+	return 0, models.ErrNoFreeSeat
+}
+
+func (r memRoomUsers) Leave(room *models.Room, userID int64) (models.LeaveResult, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var result models.LeaveResult
+	ru := m.findRoomUser(room.ID, userID)
+	if ru == nil {
+		return result, models.ErrNotInRoom
+	}
+	delete(m.roomUsers, ru.id)
+
+	rest := m.sortedRoomUsers(room.ID)
+	stored := m.rooms[room.ID]
+	if len(rest) == 0 {
+		if stored != nil {
+			stored.Status = "closed"
+		}
+		result.RoomBecameZero = true
+	} else if userID == room.OwnerID {
+		if stored != nil {
+			stored.OwnerID = rest[0].userID
+		}
+		result.HostChanged = true
+		result.NewHostUserID = rest[0].userID
+	}
+	return result, nil
+}
+
+func (r memRoomUsers) IsMember(roomID, userID int64) (bool, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.findRoomUser(roomID, userID) != nil, nil
+}
+
+func (r memRoomUsers) Count(roomID int64) (int, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sortedRoomUsers(roomID)), nil
+}
+
+func (r memRoomUsers) CountReady(roomID int64) (int, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, ru := range m.sortedRoomUsers(roomID) {
+		if ru.isReady {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r memRoomUsers) List(roomID int64) ([]models.RoomUser, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	room, ok := m.rooms[roomID]
+	if !ok {
+		return nil, nil
+	}
+	var users []models.RoomUser
+	for _, ru := range m.sortedRoomUsers(roomID) {
+		u, ok := m.users[ru.userID]
+		if !ok {
+			continue // INNER JOIN users と同じく、存在しないユーザーは出さない
+		}
+		users = append(users, models.RoomUser{
+			ID:       ru.id,
+			UserID:   ru.userID,
+			RoomID:   roomID,
+			UserName: u.Name,
+			IsReady:  ru.isReady,
+			IsHost:   ru.userID == room.OwnerID,
+			SeatNo:   ru.seatNo,
+		})
+	}
+	return users, nil
+}
+
+func (r memRoomUsers) SetReady(roomID, userID int64, isReady bool) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ru := m.findRoomUser(roomID, userID); ru != nil {
+		ru.isReady = isReady
+	}
+	return nil
+}
+
+func (r memRoomUsers) SeatNo(roomID, userID int64) (int, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ru := m.findRoomUser(roomID, userID)
+	if ru == nil {
+		return 0, ErrNotFound
+	}
+	return ru.seatNo, nil
+}
+
+func (r memRoomUsers) MoveToSeat(roomID, userID int64, seatNo int) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if owner, taken := m.seatTaken(roomID, seatNo); taken {
+		if owner.userID == userID {
+			return nil
+		}
+		return models.ErrSeatTaken
+	}
+	ru := m.findRoomUser(roomID, userID)
+	if ru == nil {
+		return ErrNotFound
+	}
+	ru.seatNo = seatNo
+	return nil
+}
+
+func (r memRoomUsers) SwapSeats(roomID, userA, userB int64) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, b := m.findRoomUser(roomID, userA), m.findRoomUser(roomID, userB)
+	if a == nil || b == nil {
+		return ErrNotFound
+	}
+	a.seatNo, b.seatNo = b.seatNo, a.seatNo
+	return nil
+}
+
+// ---- tips ----
+
+type memTips struct{ m *Memory }
+
+func (r memTips) Create(userID int64, t models.Tip, now time.Time) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.tips[userID]; ok {
+		return fmt.Errorf("store: duplicate tips for user %d", userID)
+	}
+	m.tips[userID] = t
+	return nil
+}
+
+func (r memTips) Get(userID int64) (*models.Tip, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tips[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &t, nil
+}
+
+func (r memTips) AddSolo(userID int64, diff int) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t, ok := m.tips[userID]; ok {
+		t.SoloTipCount += diff
+		m.tips[userID] = t
+	}
+	return nil
+}
+
+func (r memTips) Adjust(e models.ChipLedgerEntry) (*models.ChipLedgerEntry, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tips[e.UserID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	balance := &t.SoloTipCount
+	switch e.Wallet {
+	case models.WalletSolo:
+	case models.WalletMulti:
+		balance = &t.MultiTipCount
+	default:
+		return nil, fmt.Errorf("unknown wallet: %s", e.Wallet)
+	}
+	if *balance+e.Delta < 0 {
+		return nil, models.ErrInsufficientChips
+	}
+	*balance += e.Delta
+	m.tips[e.UserID] = t
+
+	e.ID = int64(len(m.chipLedger) + 1)
+	e.BalanceAfter = *balance
+	m.chipLedger = append(m.chipLedger, e)
+	return &e, nil
+}
+
+func (r memTips) Ledger(userID int64, limit int) ([]models.ChipLedgerEntry, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []models.ChipLedgerEntry
+	for i := len(m.chipLedger) - 1; i >= 0 && len(list) < limit; i-- {
+		if m.chipLedger[i].UserID == userID {
+			list = append(list, m.chipLedger[i])
+		}
+	}
+	return list, nil
+}
+
+// ---- games ----
+
+type memGames struct{ m *Memory }
+
+func (r memGames) GameTypeName(gameTypeID int) (string, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name, ok := m.gameTypes[gameTypeID]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return name, nil
+}
+
+func (r memGames) Start(room *models.Room, modeID int) (int64, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextGameID++
+	m.games[m.nextGameID] = memGame{modeID: modeID, typeID: room.GameTypeID, roomID: room.ID}
+	if stored, ok := m.rooms[room.ID]; ok {
+		stored.Status = "playing"
+	}
+	return m.nextGameID, nil
+}
+
+func (r memGames) PlayersForGame(roomID int64) ([]models.PlayerGameInfo, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.PlayerGameInfo(nil), m.players[roomID]...), nil
+}
+
+func (r memGames) Modes() ([]models.Mode, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.Mode(nil), m.modes...), nil
+}
+
+func (r memGames) ModeGameTypes() ([]models.ModeGameType, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.ModeGameType(nil), m.modeTypes...), nil
+}
+
+func (r memGames) SetModePlayable(modeID int, canPlay bool) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.modes {
+		if m.modes[i].ID == modeID {
+			m.modes[i].CanPlay = canPlay
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r memGames) SetModeGameTypePlayable(typeID int, canPlay bool) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.modeTypes {
+		if m.modeTypes[i].ID == typeID {
+			m.modeTypes[i].CanPlay = canPlay
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// ---- settings ----
+
+type memSettings struct{ m *Memory }
+
+func (r memSettings) Create(userID int64, s models.Setting) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.settings[userID]; ok {
+		return fmt.Errorf("store: duplicate settings for user %d", userID)
+	}
+	m.settings[userID] = s
+	return nil
+}
+
+func (r memSettings) Get(userID int64) (*models.Setting, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.settings[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &s, nil
+}
+
+func (r memSettings) Save(userID int64, s models.Setting) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.settings[userID] = s
+	return nil
+}
+
+// ---- tokens ----
+
+type memTokens struct{ m *Memory }
+
+func (r memTokens) CreateRefresh(userID int64, tokenHash, familyID string, expiresAt time.Time) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.refreshTokens[tokenHash]; ok {
+		return fmt.Errorf("store: duplicate refresh token")
+	}
+	m.nextRefreshID++
+	m.refreshTokens[tokenHash] = &models.RefreshToken{ID: m.nextRefreshID, UserID: userID, FamilyID: familyID, ExpiresAt: expiresAt}
+	return nil
+}
+
+func (r memTokens) GetRefresh(tokenHash string) (*models.RefreshToken, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.refreshTokens[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *t
+	return &copied, nil
+}
+
+func (r memTokens) RotateRefresh(old *models.RefreshToken, newHash string, expiresAt time.Time) (bool, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var stored *models.RefreshToken
+	for _, t := range m.refreshTokens {
+		if t.ID == old.ID {
+			stored = t
+			break
+		}
+	}
+	if stored == nil || stored.RevokedAt.Valid {
+		return false, nil
+	}
+	if _, ok := m.refreshTokens[newHash]; ok {
+		return false, fmt.Errorf("store: duplicate refresh token")
+	}
+	now := time.Now()
+	stored.RevokedAt = sql.NullTime{Time: now, Valid: true}
+	m.nextRefreshID++
+	m.refreshTokens[newHash] = &models.RefreshToken{ID: m.nextRefreshID, UserID: old.UserID, FamilyID: old.FamilyID, ExpiresAt: expiresAt}
+	return true, nil
+}
+
+func (r memTokens) RevokeFamily(familyID string) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, t := range m.refreshTokens {
+		if t.FamilyID == familyID && !t.RevokedAt.Valid {
+			t.RevokedAt = sql.NullTime{Time: now, Valid: true}
+		}
+	}
+	return nil
+}
+
+func (r memTokens) RevokeAll(userID int64) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, t := range m.refreshTokens {
+		if t.UserID == userID && !t.RevokedAt.Valid {
+			t.RevokedAt = sql.NullTime{Time: now, Valid: true}
+		}
+	}
+	if _, ok := m.users[userID]; ok {
+		m.tokenVersions[userID]++
+	}
+	return nil
+}
+
+func (r memTokens) RevokeAccess(jti string, userID int64, expiresAt time.Time) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revokedTokens[jti] = expiresAt
+	return nil
+}
+
+func (r memTokens) State(userID int64, jti string) (int, bool, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[userID]; !ok {
+		return 0, false, ErrNotFound
+	}
+	_, revoked := m.revokedTokens[jti]
+	return m.tokenVersions[userID], revoked, nil
+}
+
+func (r memTokens) UserInfo(userID int64) (*models.TokenSubject, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &models.TokenSubject{Name: u.Name, Version: m.tokenVersions[userID], Role: u.Role, Banned: u.BannedAt.Valid}, nil
+}
+
+func (r memTokens) PurgeExpired() error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for hash, t := range m.refreshTokens {
+		if t.ExpiresAt.Before(now) {
+			delete(m.refreshTokens, hash)
+		}
+	}
+	for jti, exp := range m.revokedTokens {
+		if exp.Before(now) {
+			delete(m.revokedTokens, jti)
+		}
+	}
+	return nil
+}
+
+func (r memTokens) CreateTransferCode(userID int64, codeHash string, expiresAt time.Time) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.transferCodes {
+		if c.userID == userID {
+			c.used = true
+		}
+	}
+	m.transferCodes = append(m.transferCodes, &memCode{userID: userID, hash: codeHash, expiresAt: expiresAt})
+	return nil
+}
+
+func (r memTokens) RedeemTransferCode(codeHash string) (int64, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, c := range m.transferCodes {
+		if c.hash == codeHash && c.usable(now) {
+			c.used = true
+			return c.userID, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+func (r memTokens) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.resetCodes[:0]
+	for _, c := range m.resetCodes {
+		if c.userID != userID || c.kind != models.ResetCodeRecovery || c.used {
+			kept = append(kept, c)
+		}
+	}
+	m.resetCodes = kept
+	for _, h := range codeHashes {
+		m.resetCodes = append(m.resetCodes, &memCode{userID: userID, hash: h, kind: models.ResetCodeRecovery})
+	}
+	return nil
+}
+
+func (r memTokens) CreateAdminResetCode(userID int64, codeHash string, expiresAt time.Time) error {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.resetCodes = append(m.resetCodes, &memCode{userID: userID, hash: codeHash, kind: models.ResetCodeAdmin, expiresAt: expiresAt})
+	return nil
+}
+
+func (r memTokens) ResetPassword(userID int64, codeHash, hashedPassword string) (bool, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, c := range m.resetCodes {
+		if c.userID == userID && c.hash == codeHash && c.usable(now) {
+			u, ok := m.users[userID]
+			if !ok {
+				return false, nil
+			}
+			c.used = true
+			u.PasswordHash = hashedPassword
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+var _ Store = (*Memory)(nil)
+
+// ---- bj_room_snapshots ----
+
+type memSnapshots struct{ m *Memory }
+
+func (r memSnapshots) Save(s models.BJSnapshot) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if cur, ok := r.m.snapshots[s.RoomCode]; ok && cur.Seq >= s.Seq {
+		return nil
+	}
+	s.State = append([]byte(nil), s.State...)
+	r.m.snapshots[s.RoomCode] = s
+	return nil
+}
+
+func (r memSnapshots) Delete(roomCode string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	delete(r.m.snapshots, roomCode)
+	return nil
+}
+
+func (r memSnapshots) Get(roomCode string) (*models.BJSnapshot, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	s, ok := r.m.snapshots[roomCode]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	s.State = append([]byte(nil), s.State...)
+	return &s, nil
+}
+
+func (r memSnapshots) List() ([]models.BJSnapshot, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	list := make([]models.BJSnapshot, 0, len(r.m.snapshots))
+	for _, s := range r.m.snapshots {
+		s.State = append([]byte(nil), s.State...)
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].RoomCode < list[j].RoomCode })
+	return list, nil
+}
+
+// ---- admin_audit_log ----
+
+type memAudit struct{ m *Memory }
+
+func (r memAudit) Record(e models.AuditEntry) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	e.ID = int64(len(r.m.audit) + 1)
+	e.Detail = append([]byte(nil), e.Detail...)
+	r.m.audit = append(r.m.audit, e)
+	return nil
+}
+
+func (r memAudit) List(beforeID int64, limit int) ([]models.AuditEntry, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	var list []models.AuditEntry
+	for i := len(r.m.audit) - 1; i >= 0 && len(list) < limit; i-- {
+		if e := r.m.audit[i]; beforeID == 0 || e.ID < beforeID {
+			list = append(list, e)
+		}
+	}
+	return list, nil
+}
+
+// ---- service_status / announcements ----
+
+type memNotices struct{ m *Memory }
+
+func (r memNotices) Maintenance() (*models.Maintenance, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	m := r.m.maintenance
+	return &m, nil
+}
+
+func (r memNotices) SetMaintenance(m models.Maintenance) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.maintenance = m
+	return nil
+}
+
+func (r memNotices) CreateAnnouncement(a models.Announcement) (int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.nextNoticeID++
+	a.ID = r.m.nextNoticeID
+	r.m.announcements = append(r.m.announcements, a)
+	return a.ID, nil
+}
+
+func (r memNotices) ActiveAnnouncements(now time.Time) ([]models.Announcement, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	var list []models.Announcement
+	for _, a := range r.m.announcements {
+		if !a.StartsAt.After(now) && a.EndsAt.After(now) {
+			list = append(list, a)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if !list[i].StartsAt.Equal(list[j].StartsAt) {
+			return list[i].StartsAt.After(list[j].StartsAt)
+		}
+		return list[i].ID > list[j].ID
+	})
+	return list, nil
+}
+
+func (r memNotices) PendingAnnouncements(now time.Time) ([]models.Announcement, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	var list []models.Announcement
+	for _, a := range r.m.announcements {
+		if a.EndsAt.After(now) {
+			list = append(list, a)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if !list[i].StartsAt.Equal(list[j].StartsAt) {
+			return list[i].StartsAt.Before(list[j].StartsAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (r memNotices) DeleteAnnouncement(id int64) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for i, a := range r.m.announcements {
+		if a.ID == id {
+			r.m.announcements = append(r.m.announcements[:i], r.m.announcements[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// ---- round_results ----
+
+type memRounds struct{ m *Memory }
+
+func (r memRounds) Record(log models.RoundLog, results []models.RoundResult) (int64, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextRoundLogID++
+	log.ID = m.nextRoundLogID
+	log.Events = append([]models.RoundEvent(nil), log.Events...)
+	m.roundLogs[log.ID] = &log
+	for _, res := range results {
+		m.nextRoundID++
+		res.ID = m.nextRoundID
+		res.LogID = log.ID
+		m.rounds = append(m.rounds, res)
+	}
+	return log.ID, nil
+}
+
+func (r memRounds) Totals(userID int64) ([]models.RoundTotals, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byMode := make(map[int]*models.RoundTotals)
+	var list []*models.RoundTotals
+	for _, res := range m.rounds {
+		if res.UserID != userID {
+			continue
+		}
+		t, ok := byMode[res.ModeID]
+		if !ok {
+			// MAX(net) と同じく最初の行の値から始める
+			t = &models.RoundTotals{ModeID: res.ModeID, MaxNet: res.Net, MaxPayout: res.Payout}
+			byMode[res.ModeID] = t
+			list = append(list, t)
+		}
+		t.Hands++
+		if res.Role == models.RoundRoleDealer {
+			t.HandsAsDealer++
+		}
+		switch res.Result {
+		case models.RoundWin:
+			t.Wins++
+		case models.RoundLose:
+			t.Losses++
+		case models.RoundPush:
+			t.Pushes++
+		}
+		if res.Blackjack {
+			t.Blackjacks++
+		}
+		if res.Bust {
+			t.Busts++
+		}
+		t.Net += res.Net
+		t.MaxNet = max(t.MaxNet, res.Net)
+		t.MaxPayout = max(t.MaxPayout, res.Payout)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ModeID < list[j].ModeID })
+	out := make([]models.RoundTotals, len(list))
+	for i, t := range list {
+		out[i] = *t
+	}
+	return out, nil
+}
+
+func (r memRounds) Outcomes(userID int64) ([]models.RoundOutcome, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []models.RoundOutcome
+	for _, res := range m.rounds {
+		if res.UserID == userID {
+			list = append(list, models.RoundOutcome{ModeID: res.ModeID, Result: res.Result})
+		}
+	}
+	return list, nil
+}
+
+func (r memRounds) ListRecentGames(userID, beforeID int64, limit int) ([]models.RoundResult, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// ゲームごとの最後の結果の ID（ソロは1ラウンドを1ゲームとして -ID を鍵にする）
+	gameKey := func(res models.RoundResult) int64 {
+		if res.GameID != 0 {
+			return res.GameID
+		}
+		return -res.ID
+	}
+	last := make(map[int64]int64)
+	for _, res := range m.rounds {
+		if res.UserID == userID {
+			last[gameKey(res)] = res.ID
+		}
+	}
+	var keys []int64
+	for k, id := range last {
+		if beforeID == 0 || id < beforeID {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return last[keys[i]] > last[keys[j]] })
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	picked := make(map[int64]bool, len(keys))
+	for _, k := range keys {
+		picked[k] = true
+	}
+	var list []models.RoundResult
+	for _, res := range m.rounds {
+		if res.UserID == userID && picked[gameKey(res)] {
+			list = append(list, res)
+		}
+	}
+	return list, nil
+}
+
+func (r memRounds) ListByLog(logID int64) ([]models.RoundResult, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []models.RoundResult
+	for _, res := range m.rounds {
+		if res.LogID == logID {
+			list = append(list, res)
+		}
+	}
+	return list, nil
+}
+
+func (r memRounds) Log(logID int64) (*models.RoundLog, error) {
+	m := r.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, ok := m.roundLogs[logID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	cp := *l
+	cp.Events = append([]models.RoundEvent(nil), l.Events...)
+	return &cp, nil
+}
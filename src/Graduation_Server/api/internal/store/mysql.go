@@ -0,0 +1,372 @@
+package store
+
+import (
+	"api/internal/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// MySQL は models パッケージの関数で各リポジトリを実装する
+type MySQL struct {
+	raw *sql.DB
+	db  models.DB
+}
+
+func NewMySQL(db *sql.DB) *MySQL {
+	return &MySQL{raw: db, db: models.WithContext(db, context.Background())}
+}
+
+func (s *MySQL) WithContext(ctx context.Context) Store {
+	return &MySQL{raw: s.raw, db: models.WithContext(s.raw, ctx)}
+}
+
+func (s *MySQL) Users() UserRepository         { return mysqlUsers{s.db} }
+func (s *MySQL) Rooms() RoomRepository         { return mysqlRooms{s.db} }
+func (s *MySQL) RoomUsers() RoomUserRepository { return mysqlRoomUsers{s.db} }
+func (s *MySQL) Tips() TipRepository           { return mysqlTips{s.db} }
+func (s *MySQL) Games() GameRepository         { return mysqlGames{s.db} }
+func (s *MySQL) Settings() SettingRepository   { return mysqlSettings{s.db} }
+func (s *MySQL) Tokens() TokenRepository       { return mysqlTokens{s.db} }
+func (s *MySQL) Snapshots() SnapshotRepository { return mysqlSnapshots{s.db} }
+func (s *MySQL) Audit() AuditRepository        { return mysqlAudit{s.db} }
+func (s *MySQL) Notices() NoticeRepository     { return mysqlNotices{s.db} }
+func (s *MySQL) Rounds() RoundRepository       { return mysqlRounds{s.db} }
+
+// ---- users ----
+
+type mysqlUsers struct{ db models.DB }
+
+func (r mysqlUsers) Create(name, hashedPassword string, now time.Time) (int64, error) {
+	return models.CreateUser(r.db, name, hashedPassword, now)
+}
+
+func (r mysqlUsers) GetByID(userID int64) (*models.User, error) {
+	return models.GetUserByID(r.db, userID)
+}
+
+func (r mysqlUsers) NameExists(name string) (bool, error) {
+	return models.UserNameExists(r.db, name)
+}
+
+func (r mysqlUsers) Rename(userID int64, name string, now, changedBefore time.Time) error {
+	return models.RenameUser(r.db, userID, name, now, changedBefore)
+}
+
+func (r mysqlUsers) Credentials(name string) (int64, string, error) {
+	return models.GetUserCredentials(r.db, name)
+}
+
+func (r mysqlUsers) UpgradeGuest(userID int64, name, hashedPassword string) (bool, error) {
+	return models.UpgradeGuestAccount(r.db, userID, name, hashedPassword)
+}
+
+func (r mysqlUsers) SetPassword(userID int64, hashedPassword string) error {
+	return models.UpdatePassword(r.db, userID, hashedPassword)
+}
+
+func (r mysqlUsers) SetRole(userID int64, role string) error {
+	return models.SetUserRole(r.db, userID, role)
+}
+
+func (r mysqlUsers) Ban(userID int64, reason string, now time.Time) error {
+	return models.BanUser(r.db, userID, reason, now)
+}
+
+func (r mysqlUsers) Unban(userID int64) error {
+	return models.UnbanUser(r.db, userID)
+}
+
+// ---- rooms ----
+
+type mysqlRooms struct{ db models.DB }
+
+func (r mysqlRooms) CreateWithHost(roomCode string, gameTypeID, maxPlayers int, ownerID int64, now time.Time) (int64, error) {
+	return models.CreateRoomWithHost(r.db, roomCode, gameTypeID, maxPlayers, ownerID, now)
+}
+
+func (r mysqlRooms) GetByCode(roomCode string) (*models.Room, error) {
+	return models.GetRoomByCode(r.db, roomCode)
+}
+
+func (r mysqlRooms) UpdateStatus(roomID int64, status string) error {
+	return models.UpdateRoomStatus(r.db, roomID, status)
+}
+
+func (r mysqlRooms) CountByStatus() (map[string]int, error) {
+	return models.CountRoomsByStatus(r.db)
+}
+
+func (r mysqlRooms) ListOpen() ([]models.OpenRoom, error) {
+	return models.ListOpenRooms(r.db)
+}
+
+func (r mysqlRooms) Close(roomID int64) error {
+	return models.CloseRoom(r.db, roomID)
+}
+
+// ---- room_users ----
+
+type mysqlRoomUsers struct{ db models.DB }
+
+func (r mysqlRoomUsers) Join(roomID, userID int64, maxPlayers int) (int, error) {
+	return models.JoinRoom(r.db, roomID, userID, maxPlayers)
+}
+
+func (r mysqlRoomUsers) Leave(room *models.Room, userID int64) (models.LeaveResult, error) {
+	return models.LeaveRoom(r.db, room, userID)
+}
+
+func (r mysqlRoomUsers) IsMember(roomID, userID int64) (bool, error) {
+	return models.IsUserInRoom(r.db, roomID, userID)
+}
+
+func (r mysqlRoomUsers) Count(roomID int64) (int, error) {
+	return models.CountUsersInRoom(r.db, roomID)
+}
+
+func (r mysqlRoomUsers) CountReady(roomID int64) (int, error) {
+	return models.CountReadyUsers(r.db, roomID)
+}
+
+func (r mysqlRoomUsers) List(roomID int64) ([]models.RoomUser, error) {
+	return models.GetUsersInRoom(r.db, roomID)
+}
+
+func (r mysqlRoomUsers) SetReady(roomID, userID int64, isReady bool) error {
+	return models.UpdateUserReady(r.db, roomID, userID, isReady)
+}
+
+func (r mysqlRoomUsers) SeatNo(roomID, userID int64) (int, error) {
+	return models.GetSeatNo(r.db, roomID, userID)
+}
+
+func (r mysqlRoomUsers) MoveToSeat(roomID, userID int64, seatNo int) error {
+	return models.MoveUserToSeat(r.db, roomID, userID, seatNo)
+}
+
+func (r mysqlRoomUsers) SwapSeats(roomID, userA, userB int64) error {
+	return models.SwapSeats(r.db, roomID, userA, userB)
+}
+
+// ---- tips ----
+
+type mysqlTips struct{ db models.DB }
+
+func (r mysqlTips) Create(userID int64, t models.Tip, now time.Time) error {
+	return models.CreateTip(r.db, userID, t, now)
+}
+
+func (r mysqlTips) Get(userID int64) (*models.Tip, error) {
+	return models.GetTip(r.db, userID)
+}
+
+func (r mysqlTips) AddSolo(userID int64, diff int) error {
+	return models.AddSoloTip(r.db, userID, diff)
+}
+
+func (r mysqlTips) Adjust(e models.ChipLedgerEntry) (*models.ChipLedgerEntry, error) {
+	return models.AdjustChips(r.db, e)
+}
+
+func (r mysqlTips) Ledger(userID int64, limit int) ([]models.ChipLedgerEntry, error) {
+	return models.ListChipLedger(r.db, userID, limit)
+}
+
+// ---- games ----
+
+type mysqlGames struct{ db models.DB }
+
+func (r mysqlGames) GameTypeName(gameTypeID int) (string, error) {
+	return models.GetGameTypeName(r.db, gameTypeID)
+}
+
+func (r mysqlGames) Start(room *models.Room, modeID int) (int64, error) {
+	return models.StartGame(r.db, room, modeID)
+}
+
+func (r mysqlGames) PlayersForGame(roomID int64) ([]models.PlayerGameInfo, error) {
+	return models.GetPlayersForGame(r.db, roomID)
+}
+
+func (r mysqlGames) Modes() ([]models.Mode, error) {
+	return models.ListModes(r.db)
+}
+
+func (r mysqlGames) ModeGameTypes() ([]models.ModeGameType, error) {
+	return models.ListModeGameTypes(r.db)
+}
+
+func (r mysqlGames) SetModePlayable(modeID int, canPlay bool) error {
+	return models.SetModePlayable(r.db, modeID, canPlay)
+}
+
+func (r mysqlGames) SetModeGameTypePlayable(typeID int, canPlay bool) error {
+	return models.SetModeGameTypePlayable(r.db, typeID, canPlay)
+}
+
+// ---- settings ----
+
+type mysqlSettings struct{ db models.DB }
+
+func (r mysqlSettings) Create(userID int64, s models.Setting) error {
+	return models.CreateSetting(r.db, userID, s)
+}
+
+func (r mysqlSettings) Get(userID int64) (*models.Setting, error) {
+	return models.GetSetting(r.db, userID)
+}
+
+func (r mysqlSettings) Save(userID int64, s models.Setting) error {
+	return models.SaveSetting(r.db, userID, s)
+}
+
+// ---- tokens ----
+
+type mysqlTokens struct{ db models.DB }
+
+func (r mysqlTokens) CreateRefresh(userID int64, tokenHash, familyID string, expiresAt time.Time) error {
+	return models.CreateRefreshToken(r.db, userID, tokenHash, familyID, expiresAt)
+}
+
+func (r mysqlTokens) GetRefresh(tokenHash string) (*models.RefreshToken, error) {
+	return models.GetRefreshToken(r.db, tokenHash)
+}
+
+func (r mysqlTokens) RotateRefresh(old *models.RefreshToken, newHash string, expiresAt time.Time) (bool, error) {
+	return models.RotateRefreshToken(r.db, old, newHash, expiresAt)
+}
+
+func (r mysqlTokens) RevokeFamily(familyID string) error {
+	return models.RevokeRefreshTokenFamily(r.db, familyID)
+}
+
+func (r mysqlTokens) RevokeAll(userID int64) error {
+	return models.RevokeAllUserTokens(r.db, userID)
+}
+
+func (r mysqlTokens) RevokeAccess(jti string, userID int64, expiresAt time.Time) error {
+	return models.RevokeAccessToken(r.db, jti, userID, expiresAt)
+}
+
+func (r mysqlTokens) State(userID int64, jti string) (int, bool, error) {
+	return models.GetTokenState(r.db, userID, jti)
+}
+
+func (r mysqlTokens) UserInfo(userID int64) (*models.TokenSubject, error) {
+	return models.GetUserTokenInfo(r.db, userID)
+}
+
+func (r mysqlTokens) CreateTransferCode(userID int64, codeHash string, expiresAt time.Time) error {
+	return models.CreateTransferCode(r.db, userID, codeHash, expiresAt)
+}
+
+func (r mysqlTokens) RedeemTransferCode(codeHash string) (int64, error) {
+	return models.RedeemTransferCode(r.db, codeHash)
+}
+
+func (r mysqlTokens) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	return models.ReplaceRecoveryCodes(r.db, userID, codeHashes)
+}
+
+func (r mysqlTokens) CreateAdminResetCode(userID int64, codeHash string, expiresAt time.Time) error {
+	return models.CreateAdminResetCode(r.db, userID, codeHash, expiresAt)
+}
+
+func (r mysqlTokens) ResetPassword(userID int64, codeHash, hashedPassword string) (bool, error) {
+	return models.ResetPasswordWithCode(r.db, userID, codeHash, hashedPassword)
+}
+
+func (r mysqlTokens) PurgeExpired() error {
+	return models.PurgeExpiredTokens(r.db)
+}
+
+var _ Store = (*MySQL)(nil)
+
+// ---- bj_room_snapshots ----
+
+type mysqlSnapshots struct{ db models.DB }
+
+func (r mysqlSnapshots) Save(s models.BJSnapshot) error {
+	return models.SaveBJSnapshot(r.db, s)
+}
+
+func (r mysqlSnapshots) Delete(roomCode string) error {
+	return models.DeleteBJSnapshot(r.db, roomCode)
+}
+
+func (r mysqlSnapshots) Get(roomCode string) (*models.BJSnapshot, error) {
+	return models.GetBJSnapshot(r.db, roomCode)
+}
+
+func (r mysqlSnapshots) List() ([]models.BJSnapshot, error) {
+	return models.ListBJSnapshots(r.db)
+}
+
+// ---- admin_audit_log ----
+
+type mysqlAudit struct{ db models.DB }
+
+func (r mysqlAudit) Record(e models.AuditEntry) error {
+	return models.InsertAuditEntry(r.db, e)
+}
+
+func (r mysqlAudit) List(beforeID int64, limit int) ([]models.AuditEntry, error) {
+	return models.ListAuditEntries(r.db, beforeID, limit)
+}
+
+// ---- service_status / announcements ----
+
+type mysqlNotices struct{ db models.DB }
+
+func (r mysqlNotices) Maintenance() (*models.Maintenance, error) {
+	return models.GetMaintenance(r.db)
+}
+
+func (r mysqlNotices) SetMaintenance(m models.Maintenance) error {
+	return models.SetMaintenance(r.db, m)
+}
+
+func (r mysqlNotices) CreateAnnouncement(a models.Announcement) (int64, error) {
+	return models.CreateAnnouncement(r.db, a)
+}
+
+func (r mysqlNotices) ActiveAnnouncements(now time.Time) ([]models.Announcement, error) {
+	return models.ListActiveAnnouncements(r.db, now)
+}
+
+func (r mysqlNotices) PendingAnnouncements(now time.Time) ([]models.Announcement, error) {
+	return models.ListPendingAnnouncements(r.db, now)
+}
+
+func (r mysqlNotices) DeleteAnnouncement(id int64) error {
+	return models.DeleteAnnouncement(r.db, id)
+}
+
+// ---- round_results ----
+
+type mysqlRounds struct{ db models.DB }
+
+func (r mysqlRounds) Record(log models.RoundLog, results []models.RoundResult) (int64, error) {
+	return models.RecordRound(r.db, log, results)
+}
+
+func (r mysqlRounds) Totals(userID int64) ([]models.RoundTotals, error) {
+	return models.SumRoundResults(r.db, userID)
+}
+
+func (r mysqlRounds) Outcomes(userID int64) ([]models.RoundOutcome, error) {
+	return models.ListRoundOutcomes(r.db, userID)
+}
+
+func (r mysqlRounds) ListRecentGames(userID, beforeID int64, limit int) ([]models.RoundResult, error) {
+	return models.ListRecentGameResults(r.db, userID, beforeID, limit)
+}
+
+func (r mysqlRounds) ListByLog(logID int64) ([]models.RoundResult, error) {
+	return models.ListRoundResultsByLog(r.db, logID)
+}
+
+func (r mysqlRounds) Log(logID int64) (*models.RoundLog, error) {
+	return models.GetRoundLog(r.db, logID)
+}
@@ -0,0 +1,190 @@
+// Package store はハンドラから使う永続化の窓口（リポジトリ）をまとめる。
+//
+// 本番は MySQL（models パッケージの SQL をそのまま使う）、
+// テストは Memory（DB 無しで動くメモリ実装）を差し込む。
+package store
+
+import (
+	"api/internal/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ErrNotFound は対象の行が無い場合のエラー。
+// 既存の `err == sql.ErrNoRows` 判定がそのまま使えるよう同じ値にしている。
+var ErrNotFound = sql.ErrNoRows
+
+// Store は各リポジトリの入口
+type Store interface {
+	Users() UserRepository
+	Rooms() RoomRepository
+	RoomUsers() RoomUserRepository
+	Tips() TipRepository
+	Games() GameRepository
+	Settings() SettingRepository
+	Tokens() TokenRepository
+	Snapshots() SnapshotRepository
+	Audit() AuditRepository
+	Notices() NoticeRepository
+	Rounds() RoundRepository
+
+	// WithContext は ctx 付きで SQL を流す Store を返す（リクエストのキャンセル・トレースを DB まで通す）
+	WithContext(ctx context.Context) Store
+}
+
+// users
+type UserRepository interface {
+	Create(name, hashedPassword string, now time.Time) (int64, error)
+	GetByID(userID int64) (*models.User, error)
+	NameExists(name string) (bool, error)
+	// 名前を変えて変更日時を記録する
+	// （前回の変更が changedBefore より後なら models.ErrNameChangeTooSoon、使われている名前なら models.ErrNameTaken）
+	Rename(userID int64, name string, now, changedBefore time.Time) error
+	// ログイン照合用：ユーザーIDとパスワードハッシュ（ゲストは空文字）
+	Credentials(name string) (int64, string, error)
+	// ゲストにパスワードを設定して通常アカウントにする（既に設定済みなら false、使われている名前なら models.ErrNameTaken）
+	UpgradeGuest(userID int64, name, hashedPassword string) (bool, error)
+	SetPassword(userID int64, hashedPassword string) error
+	// 以下は運営用（ユーザーが居なければ ErrNotFound）
+	SetRole(userID int64, role string) error
+	Ban(userID int64, reason string, now time.Time) error
+	Unban(userID int64) error
+}
+
+// rooms
+type RoomRepository interface {
+	// ルームを作成し、作成者をホスト（席1・未準備）として登録する
+	CreateWithHost(roomCode string, gameTypeID, maxPlayers int, ownerID int64, now time.Time) (int64, error)
+	GetByCode(roomCode string) (*models.Room, error)
+	UpdateStatus(roomID int64, status string) error
+	// 状態（waiting / playing / closed）ごとのルーム数
+	CountByStatus() (map[string]int, error)
+	// 終わっていないルームと参加人数（運営用）
+	ListOpen() ([]models.OpenRoom, error)
+	// 参加者を全員外して closed にする（運営用）
+	Close(roomID int64) error
+}
+
+// room_users（参加・退出・Ready・席）
+type RoomUserRepository interface {
+	// 一番小さい空席に座らせて参加登録し、席番号を返す（満席なら models.ErrNoFreeSeat）
+	Join(roomID, userID int64, maxPlayers int) (int, error)
+	// 退出・ホスト交代・クローズ判定（未参加なら models.ErrNotInRoom）
+	Leave(room *models.Room, userID int64) (models.LeaveResult, error)
+	IsMember(roomID, userID int64) (bool, error)
+	Count(roomID int64) (int, error)
+	CountReady(roomID int64) (int, error)
+	// 席順に並べた参加者一覧
+	List(roomID int64) ([]models.RoomUser, error)
+	SetReady(roomID, userID int64, isReady bool) error
+	SeatNo(roomID, userID int64) (int, error)
+	// 空席へ移動（埋まっていれば models.ErrSeatTaken）
+	MoveToSeat(roomID, userID int64, seatNo int) error
+	SwapSeats(roomID, userA, userB int64) error
+}
+
+// tips
+type TipRepository interface {
+	Create(userID int64, t models.Tip, now time.Time) error
+	Get(userID int64) (*models.Tip, error)
+	AddSolo(userID int64, diff int) error
+	// 残高を増減して chip_ledger に記録する（足りなければ models.ErrInsufficientChips）
+	Adjust(e models.ChipLedgerEntry) (*models.ChipLedgerEntry, error)
+	// 台帳を新しい順に limit 件
+	Ledger(userID int64, limit int) ([]models.ChipLedgerEntry, error)
+}
+
+// games / game_types / players
+type GameRepository interface {
+	GameTypeName(gameTypeID int) (string, error)
+	// ゲームを作成してルームを playing にする
+	Start(room *models.Room, modeID int) (int64, error)
+	PlayersForGame(roomID int64) ([]models.PlayerGameInfo, error)
+	// modes / types の一覧と遊べるかどうかの切り替え（無ければ ErrNotFound）
+	Modes() ([]models.Mode, error)
+	ModeGameTypes() ([]models.ModeGameType, error)
+	SetModePlayable(modeID int, canPlay bool) error
+	SetModeGameTypePlayable(typeID int, canPlay bool) error
+}
+
+// settings
+type SettingRepository interface {
+	Create(userID int64, s models.Setting) error
+	Get(userID int64) (*models.Setting, error)
+	// 全項目を保存する（行が無ければ作る）。部分更新は Get した値に上書きして渡す
+	Save(userID int64, s models.Setting) error
+}
+
+// refresh_tokens / revoked_tokens / users.token_version
+type TokenRepository interface {
+	CreateRefresh(userID int64, tokenHash, familyID string, expiresAt time.Time) error
+	GetRefresh(tokenHash string) (*models.RefreshToken, error)
+	// 古いトークンを失効させて同じ family で新しいトークンを登録（同時実行は片方だけ true）
+	RotateRefresh(old *models.RefreshToken, newHash string, expiresAt time.Time) (bool, error)
+	RevokeFamily(familyID string) error
+	// 全リフレッシュトークンを失効させ、token_version を上げる
+	RevokeAll(userID int64) error
+	RevokeAccess(jti string, userID int64, expiresAt time.Time) error
+	// 現在の token_version と jti が失効済みかどうか（ユーザーが居なければ ErrNotFound）
+	State(userID int64, jti string) (version int, revoked bool, err error)
+	// 発行時に埋め込む名前・token_version・ロールと BAN 状態
+	UserInfo(userID int64) (*models.TokenSubject, error)
+	PurgeExpired() error
+
+	// transfer_codes（機種変更の引き継ぎコード。有効なのは最新の1つだけ）
+	CreateTransferCode(userID int64, codeHash string, expiresAt time.Time) error
+	// 使用済みにして userID を返す（無い・期限切れ・使用済みなら ErrNotFound）
+	RedeemTransferCode(codeHash string) (int64, error)
+	// password_reset_codes（リカバリーコードは作り直すと未使用の古いものを捨てる）
+	ReplaceRecoveryCodes(userID int64, codeHashes []string) error
+	CreateAdminResetCode(userID int64, codeHash string, expiresAt time.Time) error
+	// コードを消費してパスワードを更新する（無い・使用済み・期限切れなら false）
+	ResetPassword(userID int64, codeHash, hashedPassword string) (bool, error)
+}
+
+// bj_room_snapshots（ブラックジャック卓の状態。クラッシュ後の復元用）
+type SnapshotRepository interface {
+	// seq が保存済みのものより古ければ何もしない
+	Save(s models.BJSnapshot) error
+	Delete(roomCode string) error
+	// 無ければ ErrNotFound
+	Get(roomCode string) (*models.BJSnapshot, error)
+	List() ([]models.BJSnapshot, error)
+}
+
+// admin_audit_log（運営操作の記録）
+type AuditRepository interface {
+	Record(e models.AuditEntry) error
+	// 新しい順に limit 件（beforeID > 0 ならそれより古いもの）
+	List(beforeID int64, limit int) ([]models.AuditEntry, error)
+}
+
+// round_results / round_logs（ラウンドごとの結果と経過。戦績・履歴・リプレイ用）
+type RoundRepository interface {
+	// 1ラウンド分（経過と参加者全員の結果）をまとめて保存し、経過の ID を返す
+	Record(log models.RoundLog, results []models.RoundResult) (int64, error)
+	// ユーザーの結果のモードごとの集計（mode_id 順）
+	Totals(userID int64) ([]models.RoundTotals, error)
+	// ユーザーの勝敗だけを古い順にすべて（連勝数の集計用）
+	Outcomes(userID int64) ([]models.RoundOutcome, error)
+	// 最後に遊んだのが新しい順に limit ゲーム分の結果を古い順に（beforeID > 0 なら最後の結果の ID がそれより古いゲーム）
+	ListRecentGames(userID, beforeID int64, limit int) ([]models.RoundResult, error)
+	// 1ラウンドの参加者全員の結果
+	ListByLog(logID int64) ([]models.RoundResult, error)
+	// 経過（無ければ ErrNotFound）
+	Log(logID int64) (*models.RoundLog, error)
+}
+
+// service_status / announcements（メンテナンスとお知らせ）
+type NoticeRepository interface {
+	Maintenance() (*models.Maintenance, error)
+	SetMaintenance(m models.Maintenance) error
+	CreateAnnouncement(a models.Announcement) (int64, error)
+	// now の時点で表示期間中のもの（開始が新しい順）
+	ActiveAnnouncements(now time.Time) ([]models.Announcement, error)
+	// まだ終わっていないもの（開始前の予約も含む。開始が早い順）
+	PendingAnnouncements(now time.Time) ([]models.Announcement, error)
+	// 無ければ ErrNotFound
+	DeleteAnnouncement(id int64) error
+}
@@ -0,0 +1,28 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// W3C Trace Context: traceparent = "00-<trace-id>-<parent-id>-<flags>"
+const TraceparentHeader = "traceparent"
+
+// backplane のメッセージは HTTP ヘッダーを持たないので、traceparent を文字列1つで運ぶ
+var traceContext = propagation.TraceContext{}
+
+// Traceparent は ctx のスパンを traceparent 形式にする（スパンが無ければ ""）
+func Traceparent(ctx context.Context) string {
+	c := propagation.MapCarrier{}
+	traceContext.Inject(ctx, c)
+	return c.Get(TraceparentHeader)
+}
+
+// ContextWithTraceparent は traceparent 文字列を親にした ctx を返す（不正・空なら ctx のまま）
+func ContextWithTraceparent(ctx context.Context, tp string) context.Context {
+	if tp == "" {
+		return ctx
+	}
+	return traceContext.Extract(ctx, propagation.MapCarrier{TraceparentHeader: tp})
+}
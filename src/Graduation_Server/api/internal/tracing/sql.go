@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/XSAM/otelsql"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ---- database/sql の SQL スパン（otelsql）----
+//
+//	connector, _ := mysql.NewConnector(cfg)
+//	db := tracing.OpenDB(connector, semconv.DBSystemMySQL)
+//
+// ctx にスパンがある呼び出しだけ子スパンを作る（定期掃除などはトレースしない）。
+// トランザクション中の文は models.Tx が BeginTx の ctx で実行するので、同じリクエストの下に付く。
+// 文（db.statement）は残すが、引数の値は記録しない（パスワードのハッシュなどが入るため）。
+
+// OpenDB は connector の接続を SQL スパン付きにした *sql.DB を返す
+func OpenDB(c driver.Connector, system attribute.KeyValue) *sql.DB {
+	return otelsql.OpenDB(c,
+		otelsql.WithAttributes(system),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			// mysql は引数付きの文を ErrSkip で Prepare に回すので、それを失敗として残さない
+			DisableErrSkip:       true,
+			OmitConnPrepare:      true,
+			OmitConnResetSession: true,
+			OmitConnectorConnect: true,
+			OmitRows:             true,
+			SpanFilter: func(ctx context.Context, _ otelsql.Method, _ string, _ []driver.NamedValue) bool {
+				return trace.SpanContextFromContext(ctx).IsValid()
+			},
+		}),
+	)
+}
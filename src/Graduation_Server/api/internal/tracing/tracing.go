@@ -0,0 +1,85 @@
+// Package tracing は OpenTelemetry の分散トレースを設定する。
+//
+//   - Init で SDK の TracerProvider（サンプリング・バッチ送信・サービス名）をグローバルに入れる
+//   - スパンは Start（otel のグローバルな Tracer）で作る。HTTP は middleware.Tracing（otelmux）
+//   - W3C Trace Context（traceparent）での伝播（propagation.go）
+//   - database/sql の SQL スパン（sql.go。otelsql）
+//
+// エクスポーター（OTLP/HTTP・標準出力）は呼び出し側で作って Options に渡す。
+// Init を呼ばなければ（テストなど）Start は何も記録しない。
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// scope は otel の計装スコープ名（このサーバーが自分で作るスパン）
+const scope = "api"
+
+type Options struct {
+	ServiceName string
+	InstanceID  string
+	Exporter    sdktrace.SpanExporter
+	SampleRatio float64 // 新しいトレースを記録する割合（0〜1。親があれば親に従う）
+}
+
+var current atomic.Pointer[sdktrace.TracerProvider]
+
+// Init はトレースを有効にする。返した関数を終了時に呼ぶと、残りのスパンを送ってから止める。
+func Init(opts Options) (shutdown func(context.Context) error, err error) {
+	if opts.Exporter == nil {
+		return nil, errors.New("tracing: exporter is required")
+	}
+	if opts.SampleRatio < 0 || opts.SampleRatio > 1 {
+		return nil, errors.New("tracing: sample ratio must be between 0 and 1")
+	}
+	res, err := resource.New(context.Background(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			semconv.ServiceName(opts.ServiceName),
+			semconv.ServiceInstanceID(opts.InstanceID),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(opts.Exporter),
+		sdktrace.WithResource(res),
+		// trace ID で決めるので、同じトレースはどのインスタンスでも同じ判定になる
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	current.Store(tp)
+
+	return func(ctx context.Context) error {
+		if current.CompareAndSwap(tp, nil) {
+			otel.SetTracerProvider(noop.NewTracerProvider())
+		}
+		return tp.Shutdown(ctx)
+	}, nil
+}
+
+// Flush は積まれているスパンをすぐ送る（テスト・停止前用）
+func Flush(ctx context.Context) {
+	if tp := current.Load(); tp != nil {
+		_ = tp.ForceFlush(ctx)
+	}
+}
+
+// Start は ctx のスパンを親にして新しいスパンを始める。返した ctx を子の処理に渡すこと。
+// Tracer はその都度グローバルから引く（Init し直したときも新しい TracerProvider を使う）。
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(scope).Start(ctx, name, opts...)
+}
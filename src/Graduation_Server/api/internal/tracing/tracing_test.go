@@ -0,0 +1,202 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func useExporter(t *testing.T, ratio float64) *tracetest.InMemoryExporter {
+	t.Helper()
+	exp := tracetest.NewInMemoryExporter()
+	shutdown, err := Init(Options{ServiceName: "test", Exporter: exp, SampleRatio: ratio})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = shutdown(context.Background()) })
+	return exp
+}
+
+func exported(exp *tracetest.InMemoryExporter) tracetest.SpanStubs {
+	Flush(context.Background())
+	return exp.GetSpans()
+}
+
+func byName(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	var names []string
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+		names = append(names, s.Name)
+	}
+	t.Fatalf("span %q not exported; have %v", name, names)
+	return tracetest.SpanStub{}
+}
+
+func TestSamplingFollowsParent(t *testing.T) {
+	exp := useExporter(t, 0)
+
+	// 新しいトレースは記録しないが、記録する親から来たものは記録する
+	_, s := Start(context.Background(), "unsampled")
+	if s.IsRecording() || !s.SpanContext().IsValid() {
+		t.Errorf("root span: recording=%v", s.IsRecording())
+	}
+	s.End()
+	const tp = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	_, s = Start(ContextWithTraceparent(context.Background(), tp), "sampled by parent")
+	s.End()
+
+	spans := exported(exp)
+	got := byName(t, spans, "sampled by parent")
+	if got.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		got.Parent.SpanID().String() != "00f067aa0ba902b7" || len(spans) != 1 {
+		t.Errorf("spans = %+v", spans)
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	useExporter(t, 1)
+	if tp := Traceparent(context.Background()); tp != "" {
+		t.Errorf("traceparent without a span = %q", tp)
+	}
+
+	ctx, s := Start(context.Background(), "x")
+	defer s.End()
+	tp := Traceparent(ctx)
+	if !strings.HasPrefix(tp, "00-"+s.SpanContext().TraceID().String()+"-") || !strings.HasSuffix(tp, "-01") {
+		t.Fatalf("traceparent = %q", tp)
+	}
+	got := trace.SpanContextFromContext(ContextWithTraceparent(context.Background(), tp))
+	if got.TraceID() != s.SpanContext().TraceID() || got.SpanID() != s.SpanContext().SpanID() || !got.IsRemote() {
+		t.Errorf("round trip = %+v", got)
+	}
+
+	for _, bad := range []string{
+		"", "00-abc", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01",
+	} {
+		if sc := trace.SpanContextFromContext(ContextWithTraceparent(context.Background(), bad)); sc.IsValid() {
+			t.Errorf("accepted %q", bad)
+		}
+	}
+}
+
+// ---- SQL ----
+
+// fakeDriver は Prepare 経由だけで動く最小のドライバー（引数ありの文を ErrSkip で戻す mysql と同じ経路になる）
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{}, nil }
+
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) { return &fakeConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeConn struct{}
+
+func (*fakeConn) Prepare(q string) (driver.Stmt, error) {
+	if strings.Contains(q, "broken") {
+		return &fakeStmt{fail: true}, nil
+	}
+	return &fakeStmt{}, nil
+}
+func (*fakeConn) Close() error              { return nil }
+func (*fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeStmt struct{ fail bool }
+
+func (*fakeStmt) Close() error  { return nil }
+func (*fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	if s.fail {
+		return nil, errors.New("syntax error")
+	}
+	return driver.RowsAffected(1), nil
+}
+func (*fakeStmt) Query([]driver.Value) (driver.Rows, error) { return &fakeRows{}, nil }
+
+type fakeRows struct{}
+
+func (*fakeRows) Columns() []string         { return []string{"n"} }
+func (*fakeRows) Close() error              { return nil }
+func (*fakeRows) Next([]driver.Value) error { return io.EOF }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+func TestSQLSpans(t *testing.T) {
+	exp := useExporter(t, 1)
+	db := OpenDB(fakeConnector{}, semconv.DBSystemMySQL)
+	defer db.Close()
+
+	// スパンの無い ctx では記録しない
+	if _, err := db.Exec("DELETE FROM tokens WHERE expires_at < ?", 1); err != nil {
+		t.Fatal(err)
+	}
+	if spans := exported(exp); len(spans) != 0 {
+		t.Fatalf("spans without a parent: %v", spans)
+	}
+
+	const request = "HTTP POST /api/rooms/start"
+	ctx, root := Start(context.Background(), request)
+	rows, err := db.QueryContext(ctx, "SELECT id FROM rooms WHERE room_code = ?", "R1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	// トランザクション中の文は BeginTx と同じ ctx で実行する（models.Tx）
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO games (room_id) VALUES (?)", 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE broken"); err == nil {
+		t.Fatal("want error")
+	}
+	_ = tx.Rollback()
+	root.End()
+
+	spans := exported(exp)
+	var statements []tracetest.SpanStub
+	for _, s := range spans {
+		if s.Name == request {
+			continue
+		}
+		if s.Parent.SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("%s not under the request span", s.Name)
+		}
+		for _, a := range s.Attributes {
+			if a.Value.AsString() == "R1" {
+				t.Errorf("%s records argument values: %v", s.Name, a)
+			}
+			if a.Key == "db.statement" {
+				statements = append(statements, s)
+			}
+		}
+	}
+	if len(statements) != 3 {
+		t.Fatalf("statement spans = %d, want 3 (have %d spans)", len(statements), len(spans))
+	}
+	if failed := statements[2]; failed.Status.Code != codes.Error {
+		t.Errorf("failed statement %s status = %v", failed.Name, failed.Status)
+	}
+	byName(t, spans, "sql.conn.begin_tx")
+	byName(t, spans, "sql.tx.rollback")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"api/internal/config"
+	"api/internal/loadtest"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// runLoadtest は起動中のサーバーに bot の卓を大量に立てて負荷をかけ、結果を表示する。
+// サーバー側の設定・DB は使わない（接続先は -url で指定）。
+// -url を省略するとローカルの既定の待ち受け（config.Default の listen_addr のポート）に向ける。
+// 例: ./api loadtest -url http://10.0.0.5:9090 -tables 1000 -players 4 -duration 5m
+func runLoadtest(args []string) {
+	fs := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	opts := loadtest.Options{}
+	fs.StringVar(&opts.BaseURL, "url", defaultLoadtestURL(), "接続先サーバーのURL")
+	fs.IntVar(&opts.Tables, "tables", 100, "同時に立てる卓の数")
+	fs.IntVar(&opts.PlayersPerTable, "players", 4, "1卓の人数（ホスト込み）")
+	fs.DurationVar(&opts.Duration, "duration", time.Minute, "実行時間（立ち上げ込み）")
+	fs.DurationVar(&opts.RampUp, "ramp-up", 10*time.Second, "全卓の立ち上げにかける時間")
+	fs.DurationVar(&opts.BetInterval, "bet-interval", time.Second, "1人あたりのベット更新間隔")
+	fs.StringVar(&opts.NamePrefix, "prefix", fmt.Sprintf("lt%d", time.Now().Unix()), "bot のユーザー名の接頭辞")
+	progress := fs.Duration("progress", 5*time.Second, "途中経過の表示間隔（0 で表示しない）")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "使い方: api loadtest [flags]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// Ctrl+C で途中終了しても、それまでの結果は表示する
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	stats := loadtest.NewStats()
+	done := make(chan struct{})
+	if *progress > 0 {
+		go func() {
+			ticker := time.NewTicker(*progress)
+			defer ticker.Stop()
+			prev := stats.Snapshot()
+			for {
+				select {
+				case <-ticker.C:
+					cur := stats.Snapshot()
+					log.Println(cur.ProgressLine(prev))
+					prev = cur
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	log.Printf("loadtest: %s tables=%d players=%d duration=%s ramp-up=%s bet-interval=%s",
+		opts.BaseURL, opts.Tables, opts.PlayersPerTable, opts.Duration, opts.RampUp, opts.BetInterval)
+	err := loadtest.Run(ctx, opts, stats)
+	close(done)
+	if err != nil {
+		log.Fatalf("loadtest 失敗: %v", err)
+	}
+
+	report := stats.Snapshot()
+	report.Print(os.Stdout)
+	if report.TablesReady == 0 {
+		os.Exit(1)
+	}
+}
+
+// defaultLoadtestURL は既定設定の listen_addr と同じポートのローカルの URL
+func defaultLoadtestURL() string {
+	_, port, err := net.SplitHostPort(config.Default().Server.ListenAddr)
+	if err != nil {
+		port = "9090"
+	}
+	return "http://" + net.JoinHostPort("127.0.0.1", port)
+}
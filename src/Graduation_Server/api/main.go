@@ -1,123 +1,301 @@
 package main
 
 import (
+	"api/internal/auth"
+	"api/internal/backplane"
+	"api/internal/config"
 	"api/internal/handlers"
+	"api/internal/logging"
+	"api/internal/metrics"
 	"api/internal/middleware"
+	"api/internal/models"
+	"api/internal/server"
+	"api/internal/store"
+	"api/internal/tracing"
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/gorilla/mux"
+	"github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
-// getLocalIP はユーザーのホームディレクトリ直下の ip.txt を読み取り、
-// そこに書かれているIPアドレス文字列を返す。
-// 例: ip.txt の中身が "192.168.0.10" の場合、DB接続先に利用される。
-func getLocalIP() (string, error) {
-	homeDir := "/home/user1"
-	if homeDir == "" {
-		return "", fmt.Errorf("環境変数HOMEが設定されていません")
+// loadConfig は設定ファイル・環境変数・フラグから設定を読み込み、ログの出力形式を設定して
+// 秘密情報を伏せた一覧をログに出す。検証エラーなら起動しない。
+func loadConfig(name string, args []string) (*config.Config, []string) {
+	cfg, rest, warnings, err := config.Load(name, args)
+	if err != nil {
+		fatal("設定の読み込み失敗", "err", err)
+	}
+	if err := logging.Setup(os.Stderr, cfg.Log.Format, cfg.Log.Level); err != nil {
+		fatal("ログ設定の失敗", "err", err)
+	}
+	summary := cfg.Summary()
+	attrs := make([]any, 0, len(summary))
+	for _, line := range summary {
+		k, v, _ := strings.Cut(line, " = ")
+		attrs = append(attrs, slog.String(k, v))
+	}
+	slog.Info("設定", attrs...)
+	for _, w := range warnings {
+		slog.Warn("設定の注意: " + w)
 	}
+	return cfg, rest
+}
+
+// fatal はエラーをログに出して終了する（log.Fatal の slog 版）
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
 
-	path := filepath.Join(homeDir, "ip.txt")
-	data, err := os.ReadFile(path)
+// openDB は設定の接続先で DB を開き、疎通確認まで行う。
+func openDB(cfg *config.Config) *sql.DB {
+	// ---- DBオープンと疎通確認 ----
+	// クエリごとに SQL スパンを付けるため、ドライバのコネクタを otelsql で包む
+	dsn, err := mysql.ParseDSN(cfg.DB.DSN())
 	if err != nil {
-		return "", err
+		fatal("DBオープン失敗", "err", err)
 	}
-	return strings.TrimSpace(string(data)), nil
-}
+	connector, err := mysql.NewConnector(dsn)
+	if err != nil {
+		fatal("DBオープン失敗", "err", err)
+	}
+	db := tracing.OpenDB(connector, semconv.DBSystemMySQL)
 
-// ★ 固定したいDBのホストIP
-// const dbHost = "192.168.10.1" // ←ここをあなたの固定IPに
-//const dbHost = "192.168.56.102" // ←ここをあなたの固定IPに
+	if err := db.Ping(); err != nil {
+		fatal("DB接続失敗", "addr", fmt.Sprintf("%s:%d", cfg.DB.Host, cfg.DB.Port), "err", err)
+	}
+	slog.Info("DB接続成功", "addr", fmt.Sprintf("%s:%d", cfg.DB.Host, cfg.DB.Port), "db", cfg.DB.Name)
+	return db
+}
 
 func main() {
-	// ---- DB接続先のIPをファイルから取得 ----
-	ip, err := getLocalIP()
-	if err != nil {
-		log.Fatalf("IPファイル読み込み失敗: %v", err)
+	// ---- サブコマンド（運用作業用）----
+	//   reset-code [flags] <name> : パスワード再設定コードを発行して表示する
+	//   set-role [flags] <name> <role> : ロールを変更する（admin / player）
+	//   migrate [flags] <command> : スキーマのマイグレーション（migrate.go）
+	//   loadtest [flags]          : 起動中のサーバーへの負荷試験（loadtest.go）
+	//   openapi [flags]           : API の仕様書を書き出す（openapi.go）
+	// サブコマンド無し（またはフラグから始まる）場合はサーバーを起動する
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		switch os.Args[1] {
+		case "reset-code":
+			runResetCode(os.Args[2:])
+			return
+		case "set-role":
+			runSetRole(os.Args[2:])
+			return
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "loadtest":
+			runLoadtest(os.Args[2:])
+			return
+		case "openapi":
+			runOpenAPI(os.Args[2:])
+			return
+		default:
+			fatal("不明なサブコマンド", "command", os.Args[1])
+		}
 	}
 
-	// ---- DSNを生成（ユーザー名/パスワード/ホスト/DB名）----
-	// MEMO: 本番運用では環境変数やSecret管理を推奨（ハードコード回避）
-	dsn := fmt.Sprintf("db_user1:db_user1@tcp(%s:3306)/graduationdb", ip)
-	//dsn := fmt.Sprintf("db_user1:db_user1@tcp(%s:3306)/graduationdb", dbHost)
+	cfg, _ := loadConfig(os.Args[0], os.Args[1:])
 
-	log.Println("DSN:", dsn)
+	shutdownTracing := initTracing(cfg)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("トレースの送信を打ち切りました", "err", err)
+		}
+	}()
 
-	// ---- DBオープンと疎通確認 ----
-	db, err := sql.Open("mysql", dsn)
-	if err != nil {
-		log.Fatal(err)
-	}
+	db := openDB(cfg)
 	defer db.Close()
+	warnPendingMigrations(db)
 
-	if err := db.Ping(); err != nil {
-		log.Fatal(err)
+	// ---- トークンサービス（設定の jwt.keys / jwt.active_kid を使う）----
+	issuer, err := auth.NewIssuer(cfg.JWT.KeyBytes(), cfg.JWT.ActiveKID, cfg.JWT.AccessTokenTTL)
+	if err != nil {
+		fatal("JWT鍵の読み込み失敗", "err", err)
 	}
-	log.Println("DB接続成功")
+	auth.Init(issuer)
+
+	// ---- 設定の反映（タイマー・初期チップ・許可Origin・回数制限）----
+	handlers.InitConfig(cfg)
+	middleware.SetAllowedOrigins(cfg.Server.CORSOrigins)
+	middleware.InitRateLimits(cfg.RateLimit)
 
 	// ---- handlers パッケージでグローバルDBを使う場合の初期化 ----
 	// CreateAccountHandler 等がパッケージ内グローバル変数 db を参照する設計に対応
 	handlers.InitDB(db) // もしあれば
+	// DB接続プールの状態を /metrics に出す
+	metrics.RegisterDBStats(db, cfg.DB.Name)
+	// users / settings / tips などはリポジトリ経由（テストではメモリ実装に差し替える）
+	repo := store.NewMySQL(db)
+	handlers.InitStore(repo)
+	// インスタンス間の配送（ブロードキャスト・卓の担当決め）
+	bp := openBackplane(cfg)
+	defer bp.Close()
+	if err := handlers.InitBackplane(bp, cfg.Backplane.InstanceID, cfg.Backplane.OwnerTTL, repo); err != nil {
+		fatal("backplane の購読失敗", "err", err)
+	}
+	// 前回クラッシュしたときのブラックジャック卓の状態を読み戻す
+	if restored, discarded, err := handlers.RecoverBJRooms(repo); err != nil {
+		slog.Warn("卓の状態の復元に一部失敗", "err", err)
+	} else if restored+discarded > 0 {
+		slog.Info("卓の状態を復元", "restored", restored, "discarded", discarded)
+	}
+	// JWTミドルウェアのトークン失効チェック用
+	middleware.InitTokenStore(repo.Tokens())
+	// 期限切れトークンの定期掃除
+	handlers.StartTokenCleanup(repo.Tokens(), time.Hour)
 
-	// ---- ルーター設定（gorilla/mux）----
-	r := mux.NewRouter()
-
-	// ========== 公開API（JWT認証不要） ==========
-	// アカウント作成/ログイン/メインデータ/ランク・ゲームモード一覧/ソロ・フレンドゲーム一覧/WebSocket（ブラックジャック）
-	// MEMO: メソッド制限が必要なら .Methods("POST") 等を付与
-	r.HandleFunc("/api/create_account", handlers.CreateAccountHandler)
-	r.HandleFunc("/api/login", handlers.LoginHandler)
-	r.HandleFunc("/api/get_main_data", handlers.GetMainDataHandler)
-	r.HandleFunc("/api/rank_mode", handlers.RankModeHandler)
-	r.HandleFunc("/api/game_mode", handlers.GameModeHandler)
-	r.HandleFunc("/api/solo_games", handlers.SoloGameListHandler)
-	r.HandleFunc("/api/friend_games", handlers.FriendGameListHandler)
-
-	// ========== 認証必須API（JWTミドルウェアで保護） ==========
-	// 依存注入（dbを引数で渡す）パターンのハンドラは http.Handler/Func を生成して渡す
-	// ルーム作成
-	r.Handle("/api/create_room",
-		middleware.JWTMiddleware(http.HandlerFunc(handlers.CreateRoomHandler(db))))
-	// ルーム参加
-	r.Handle("/api/join_room",
-		middleware.JWTMiddleware(http.HandlerFunc(handlers.JoinRoomHandler(db))))
-	// ルームwebsocket
-	r.Handle("/api/ws/room/{room_code}",
-		middleware.JWTMiddleware(http.HandlerFunc(handlers.GameRoomWebSocketHandler(db))))
-	// ルーム退出
-	r.Handle("/api/rooms/leave",
-		middleware.JWTMiddleware(http.HandlerFunc(handlers.LeaveRoomHandler(db)))).Methods("POST", "OPTIONS")
-	r.Handle("/api/rooms/start",
-		middleware.JWTMiddleware(http.HandlerFunc(handlers.StartRoomHandler(db)))).
-		Methods("POST", "OPTIONS")
-	// ルーム内プレイヤー取得（GET限定）
-	r.HandleFunc("/api/rooms/{room_id}/players", handlers.GetPlayersForGameHandler(db)).Methods("GET")
-	// ブラックジャックゲーム
-	r.Handle(
-		"/api/ws/blackjackwebsocket/{room_code}",
-		middleware.JWTMiddleware(http.HandlerFunc(handlers.BlackjackWebSocketHandle(db))),
-	)
-	// 設定更新（ハンドラ側でグローバルdbを使う設計）
-	r.Handle("/api/update_settings",
-		middleware.JWTMiddleware(http.HandlerFunc(handlers.UpdateUserSettingsHandler)))
-	// ソロチップ更新（依存注入）
-	r.Handle("/api/updatesolotip",
-		middleware.JWTMiddleware(handlers.UpdateSoloTipHandler(db)))
-
-	// 所持チップ取得（GET限定・依存注入）
-	r.Handle("/api/get_chip_data",
-		middleware.JWTMiddleware(handlers.GetChipDataHandler(db))).Methods("GET")
+	// ---- ルーター設定（internal/server）----
+	r := server.NewRouter(repo)
 
 	// ---- サーバー起動 ----
-	//log.Println("サーバー起動: 0.0.0.0:8080")
-	//log.Fatal(http.ListenAndServe("0.0.0.0:8080", r))
-	log.Println("サーバー起動: 0.0.0.0:9090")
-	log.Fatal(http.ListenAndServe("0.0.0.0:9090", r))
+	srv := &http.Server{Addr: cfg.Server.ListenAddr, Handler: middleware.CORS(r)}
+	serveErr := make(chan error, 1)
+	go func() {
+		if cfg.Server.TLSEnabled() {
+			slog.Info("サーバー起動", "addr", cfg.Server.ListenAddr, "tls", true)
+			serveErr <- srv.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+			return
+		}
+		slog.Info("サーバー起動", "addr", cfg.Server.ListenAddr, "tls", false)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	// SIGINT / SIGTERM（デプロイ時）で停止処理に入る。停止処理中にもう一度送ると即終了。
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-serveErr:
+		fatal("サーバー停止", "err", err)
+	case <-ctx.Done():
+	}
+	stop()
+	gracefulShutdown(srv, repo, cfg.Server.ShutdownTimeout)
+}
+
+// initTracing は設定の exporter でトレースを始める。返した関数は終了時に呼ぶ（残りのスパンを送る）。
+func initTracing(cfg *config.Config) func(context.Context) error {
+	var exp sdktrace.SpanExporter
+	var err error
+	switch cfg.Tracing.Exporter {
+	case config.TracingStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case config.TracingOTLP:
+		// OTLP/HTTP（protobuf）。endpoint はパスまで指定する（http:// なら TLS なし）
+		exp, err = otlptracehttp.New(context.Background(),
+			otlptracehttp.WithEndpointURL(cfg.Tracing.OTLPEndpoint),
+			otlptracehttp.WithHeaders(cfg.Tracing.OTLPHeaderMap()))
+	default:
+		return func(context.Context) error { return nil }
+	}
+	if err != nil {
+		fatal("トレースの初期化失敗", "err", err)
+	}
+	shutdown, err := tracing.Init(tracing.Options{
+		ServiceName: cfg.Tracing.ServiceName,
+		InstanceID:  cfg.Backplane.InstanceID,
+		Exporter:    exp,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("トレースの初期化失敗", "err", err)
+	}
+	slog.Info("tracing", "exporter", cfg.Tracing.Exporter, "sample_ratio", cfg.Tracing.SampleRatio)
+	return shutdown
+}
+
+// openBackplane は設定の種類で配送の実装を作る。Redis は疎通確認まで行う。
+func openBackplane(cfg *config.Config) backplane.Backplane {
+	if cfg.Backplane.Driver != config.BackplaneRedis {
+		return backplane.NewLocal()
+	}
+	r := backplane.NewRedis(cfg.Backplane.RedisAddr, cfg.Backplane.RedisPassword)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Ping(ctx); err != nil {
+		fatal("Redis接続失敗", "addr", cfg.Backplane.RedisAddr, "err", err)
+	}
+	slog.Info("backplane", "driver", r.String(), "instance", cfg.Backplane.InstanceID)
+	return r
+}
+
+// runResetCode はサポート対応用に、指定ユーザーのパスワード再設定コードを発行して表示する。
+// 例: ./api reset-code taro
+func runResetCode(args []string) {
+	cfg, rest := loadConfig("reset-code", args)
+	if len(rest) != 1 || rest[0] == "" {
+		fmt.Fprintln(os.Stderr, "使い方: api reset-code [flags] <name>")
+		os.Exit(2)
+	}
+	name := rest[0]
+	db := openDB(cfg)
+	defer db.Close()
+
+	code, expiresAt, err := handlers.IssueAdminResetCode(store.NewMySQL(db), name)
+	if err == sql.ErrNoRows {
+		fatal("ユーザーが見つかりません", "name", name)
+	}
+	if err != nil {
+		fatal("再設定コードの発行失敗", "err", err)
+	}
+	fmt.Printf("reset code for %s: %s (expires %s)\n", name, code, expiresAt.Format(time.RFC3339))
+}
+
+// runSetRole は運営APIを使う人のロールを変更する（最初の admin はこれで作る）。
+// 発行済みトークンは失効させるので、新しいロールは次のログインから有効になる。
+// 例: ./api set-role taro admin
+func runSetRole(args []string) {
+	cfg, rest := loadConfig("set-role", args)
+	if len(rest) != 2 || (rest[1] != models.RoleAdmin && rest[1] != models.RolePlayer) {
+		fmt.Fprintln(os.Stderr, "使い方: api set-role [flags] <name> <admin|player>")
+		os.Exit(2)
+	}
+	name, role := rest[0], rest[1]
+	db := openDB(cfg)
+	defer db.Close()
+	repo := store.NewMySQL(db)
+
+	userID, _, err := repo.Users().Credentials(name)
+	if err == sql.ErrNoRows {
+		fatal("ユーザーが見つかりません", "name", name)
+	}
+	if err != nil {
+		fatal("ユーザーの取得失敗", "err", err)
+	}
+	if err := repo.Users().SetRole(userID, role); err != nil {
+		fatal("ロールの変更失敗", "err", err)
+	}
+	if err := repo.Tokens().RevokeAll(userID); err != nil {
+		fatal("トークンの失効失敗", "err", err)
+	}
+	// コマンドラインからの操作は admin_id = 0 で記録する
+	detail, _ := json.Marshal(map[string]string{"name": name, "role": role})
+	err = repo.Audit().Record(models.AuditEntry{
+		Action:    "user.role",
+		Target:    strconv.FormatInt(userID, 10),
+		Detail:    detail,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		slog.Warn("操作ログの記録失敗", "err", err)
+	}
+	fmt.Printf("role of %s (id=%d) is now %s\n", name, userID, role)
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"api/internal/migrations"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = `使い方: api migrate [flags] <command> [N]
+  up [N]        未適用のマイグレーションを流す（N 指定時はそのバージョンまで）
+  down [N]      適用済みを新しい順に N 個戻す（省略時は 1）
+  status        各バージョンの適用状況を表示
+  baseline N    既存DB向け：SQL を流さずに N までを適用済みとして記録`
+
+// runMigrate は埋め込みのマイグレーションを操作する。
+// 例: ./api migrate up / ./api migrate -db-host 10.0.0.5 status
+func runMigrate(args []string) {
+	cfg, rest := loadConfig("migrate", args)
+	if len(rest) == 0 || len(rest) > 2 {
+		migrateUsageExit()
+	}
+	n := 0
+	if len(rest) == 2 {
+		v, err := strconv.Atoi(rest[1])
+		if err != nil || v <= 0 {
+			migrateUsageExit()
+		}
+		n = v
+	}
+
+	db := openDB(cfg)
+	defer db.Close()
+	ctx := context.Background()
+
+	switch rest[0] {
+	case "up":
+		done, err := migrations.Up(ctx, db, n)
+		printMigrations("applied", done)
+		if err != nil {
+			fatal("migrate up 失敗", "err", err)
+		}
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		done, err := migrations.Down(ctx, db, n)
+		printMigrations("reverted", done)
+		if err != nil {
+			fatal("migrate down 失敗", "err", err)
+		}
+	case "status":
+		list, err := migrations.StatusList(ctx, db)
+		if err != nil {
+			fatal("migrate status 失敗", "err", err)
+		}
+		for _, s := range list {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-32s %s\n", s.Version, s.Name, state)
+		}
+	case "baseline":
+		if n == 0 {
+			migrateUsageExit()
+		}
+		if err := migrations.Baseline(ctx, db, n); err != nil {
+			fatal("migrate baseline 失敗", "err", err)
+		}
+		fmt.Printf("baseline: marked up to %04d as applied\n", n)
+	default:
+		migrateUsageExit()
+	}
+}
+
+func migrateUsageExit() {
+	fmt.Fprintln(os.Stderr, migrateUsage)
+	os.Exit(2)
+}
+
+func printMigrations(verb string, list []migrations.Migration) {
+	if len(list) == 0 {
+		fmt.Println("nothing to do")
+	}
+	for _, m := range list {
+		fmt.Printf("%s %04d_%s\n", verb, m.Version, m.Name)
+	}
+}
+
+// warnPendingMigrations はサーバー起動時にスキーマが古ければ警告を出す（起動は止めない）。
+func warnPendingMigrations(db *sql.DB) {
+	latest, err := migrations.Latest()
+	if err != nil {
+		slog.Warn("マイグレーションの読み込み失敗", "err", err)
+		return
+	}
+	current, err := migrations.CurrentVersion(context.Background(), db)
+	if err != nil {
+		slog.Warn("スキーマバージョンの確認失敗", "err", err)
+		return
+	}
+	if current < latest {
+		slog.Warn("スキーマが古い可能性があります。`api migrate up` を実行してください", "schema_version", current, "latest", latest)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"api/internal/openapi"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runOpenAPI は API の仕様書（/api/openapi.json と同じもの）を書き出す。
+// サーバーを起動せずにクライアントのコード生成などに使うとき用。設定・DB は使わない。
+// 例: ./api openapi -o openapi.json
+func runOpenAPI(args []string) {
+	fs := flag.NewFlagSet("openapi", flag.ExitOnError)
+	out := fs.String("o", "", "出力先のファイル（省略時は標準出力）")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "使い方: api openapi [flags]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	raw := append(append([]byte(nil), openapi.JSON()...), '\n')
+	if *out == "" {
+		_, _ = os.Stdout.Write(raw)
+		return
+	}
+	if err := os.WriteFile(*out, raw, 0o644); err != nil {
+		fatal("仕様書の書き出し失敗", "file", *out, "err", err)
+	}
+}
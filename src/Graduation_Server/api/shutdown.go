@@ -0,0 +1,49 @@
+package main
+
+import (
+	"api/internal/handlers"
+	"api/internal/store"
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// gracefulShutdown は進行中の卓を片付けてからサーバーを止める。
+//
+//  1. 新しいルームの作成・参加・開始を止め、接続中のクライアントに再起動を予告
+//  2. 進行中のラウンドが精算されるまで待つ（最大 timeout。新しいベットは受け付けない）
+//  3. 残ったベットは手持ちに戻して bet_state で知らせ、卓の状態を保存する
+//  4. WebSocket を 1012 (Service Restart) で閉じる
+//  5. 担当していた卓を手放す（複数台構成なら他のインスタンスがすぐ引き継ぐ）
+//  6. 処理中の HTTP リクエストを待って終了
+func gracefulShutdown(srv *http.Server, repo store.Store, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	slog.Info("停止処理を開始します", "deadline", deadline.Format(time.RFC3339))
+	handlers.BeginShutdown(deadline)
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	if err := handlers.WaitForTables(ctx); err != nil {
+		slog.Warn("期限までに終わらなかった卓があります")
+	}
+	rooms, chips, err := handlers.RefundOpenBets(repo)
+	if rooms > 0 {
+		slog.Info("未精算のベットを返却しました", "tables", rooms, "chips", chips)
+	}
+	if err != nil {
+		slog.Error("返却した卓の保存に失敗しました", "err", err)
+	}
+
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer closeCancel()
+	handlers.CloseAllConnections(closeCtx, "server restarting")
+	handlers.LeaveCluster()
+
+	httpCtx, httpCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer httpCancel()
+	if err := srv.Shutdown(httpCtx); err != nil {
+		slog.Warn("HTTPサーバーの停止でエラー", "err", err)
+	}
+	slog.Info("停止しました")
+}